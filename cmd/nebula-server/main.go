@@ -7,19 +7,30 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/victalejo/nebula/internal/api"
+	"github.com/victalejo/nebula/internal/builder/buildpacks"
+	dockerfilebuilder "github.com/victalejo/nebula/internal/builder/dockerfile"
+	"github.com/victalejo/nebula/internal/builder/nixpacks"
+	"github.com/victalejo/nebula/internal/builder/railpacks"
 	"github.com/victalejo/nebula/internal/config"
 	"github.com/victalejo/nebula/internal/container"
 	"github.com/victalejo/nebula/internal/container/docker"
+	"github.com/victalejo/nebula/internal/core/builder"
 	"github.com/victalejo/nebula/internal/core/deployer"
 	"github.com/victalejo/nebula/internal/core/events"
 	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/secrets"
 	gitdeployer "github.com/victalejo/nebula/internal/deployer/git"
 	imagedeployer "github.com/victalejo/nebula/internal/deployer/image"
 	"github.com/victalejo/nebula/internal/proxy/caddy"
+	"github.com/victalejo/nebula/internal/proxy/nginx"
+	"github.com/victalejo/nebula/internal/proxy/traefik"
 	"github.com/victalejo/nebula/internal/service"
 	"github.com/victalejo/nebula/internal/storage/sqlite"
 	"github.com/victalejo/nebula/internal/version"
@@ -51,6 +62,8 @@ func main() {
 	)
 
 	// Initialize storage
+	_, statErr := os.Stat(cfg.Database.Path)
+	existingInstall := statErr == nil
 	store, err := sqlite.NewStore(cfg.Database.Path)
 	if err != nil {
 		log.Error("failed to initialize storage", "error", err)
@@ -71,19 +84,48 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize Caddy proxy manager
-	proxyManager := caddy.NewManager(cfg.Caddy.AdminAPI, cfg.Caddy.Network, log)
+	// Initialize proxy manager
+	proxyManager, err := newProxyManager(cfg, dockerClient, log)
+	if err != nil {
+		log.Error("failed to initialize proxy", "error", err)
+		os.Exit(1)
+	}
+	// Without its container, the proxy can't reach apps on the Docker network.
+	// A proxy running on the host reaches them through published ports.
+	if cfg.Proxy.Container != "" && !cfg.Docker.PublishPorts {
+		if _, err := dockerClient.InspectContainer(context.Background(), cfg.Proxy.Container); err != nil {
+			log.Error("proxy container not found; for a proxy running on the host, set proxy.container to \"\" and docker.publish_ports to true",
+				"container", cfg.Proxy.Container,
+				"error", err,
+			)
+			os.Exit(1)
+		}
+	}
+
+	if attacher, ok := proxyManager.(proxy.NetworkAttacher); ok && !cfg.Docker.PublishPorts {
+		// Deployments are reached by container name on the Nebula network
+		for _, network := range []string{cfg.Proxy.Network, cfg.Docker.Network} {
+			if err := attacher.AttachNetwork(context.Background(), network); err != nil {
+				log.Warn("failed to attach proxy to network", "network", network, "error", err)
+			}
+		}
+	}
 
 	// Initialize deployer registry
 	registry := deployer.NewRegistry()
 
 	// Register image deployer
-	imgDeployer := imagedeployer.New(dockerClient, cfg.Docker.Network, log)
+	imgDeployer := imagedeployer.New(dockerClient, cfg.Docker.Network, cfg.Docker.PublishPorts, log)
 	registry.Register(imgDeployer)
 
-	// Register git deployer
+	// Register git deployer and the builders it delegates to
 	runtimeAdapter := container.NewRuntimeAdapter(dockerClient)
-	gitDep := gitdeployer.New(runtimeAdapter, log, "./data", store.Settings())
+	builders := builder.NewRegistry()
+	builders.Register(dockerfilebuilder.New(runtimeAdapter, log))
+	builders.Register(nixpacks.New(runtimeAdapter, log))
+	builders.Register(railpacks.New(runtimeAdapter, log))
+	builders.Register(buildpacks.New(runtimeAdapter, log))
+	gitDep := gitdeployer.New(runtimeAdapter, cfg.Docker.Network, cfg.Docker.PublishPorts, log, "./data", store.Settings(), builders)
 	registry.Register(gitDep)
 
 	// Initialize event bus for real-time status updates
@@ -92,12 +134,43 @@ func main() {
 	// Initialize notification service for WhatsApp alerts
 	notificationService := service.NewNotificationService(eventBus, log)
 
+	// Initialize cipher for secrets stored in the database. Without a
+	// configured key, one is kept next to the database; installs from before
+	// the key file encrypted with the JWT secret, which is pinned as the key
+	// so the JWT secret can be rotated on its own.
+	encryptionKey := cfg.Auth.EncryptionKey
+	if encryptionKey == "" {
+		keyPath := filepath.Join(filepath.Dir(cfg.Database.Path), "encryption.key")
+		seed := ""
+		if existingInstall {
+			seed = cfg.Auth.JWTSecret
+		}
+		key, created, err := secrets.LoadOrCreateKey(keyPath, seed)
+		if err != nil {
+			log.Error("failed to load encryption key", "error", err)
+			os.Exit(1)
+		}
+		if created && seed != "" {
+			log.Warn("auth.encryption_key is not set; the jwt secret is now kept as the encryption key for stored secrets", "path", keyPath)
+		} else if created {
+			log.Info("generated encryption key for stored secrets", "path", keyPath)
+		}
+		encryptionKey = key
+	}
+	secretCipher, err := secrets.NewCipher(encryptionKey)
+	if err != nil {
+		log.Error("failed to initialize secret cipher", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize services
 	appService := service.NewAppService(store, log)
-	serviceService := service.NewServiceService(store, log)
-	domainService := service.NewDomainService(store, log)
-	deployService := service.NewDeployService(store, registry, proxyManager, dockerClient, eventBus, log)
+	serviceService := service.NewServiceService(store, registry, secretCipher, log)
+	gitCredentialService := service.NewGitCredentialService(store, secretCipher, log)
+	deployService := service.NewDeployService(store, registry, proxyManager, dockerClient, cfg.Server.Container, gitCredentialService, secretCipher, eventBus, log)
+	domainService := service.NewDomainService(store, deployService, secretCipher, eventBus, log)
 	updateService := service.NewUpdateService(cfg.Update, store, log)
+	previewService := service.NewPreviewService(store, appService, deployService, secretCipher, log)
 
 	// Initialize API server
 	server := api.NewServer(api.ServerConfig{
@@ -107,11 +180,31 @@ func main() {
 		TokenDuration: time.Duration(cfg.Auth.TokenDuration) * time.Hour,
 		AdminUsername: cfg.Auth.AdminUsername,
 		AdminPassword: cfg.Auth.AdminPassword,
-	}, appService, serviceService, domainService, deployService, updateService, store.Settings(), dockerClient, store.Containers(), store.Deployments(), eventBus, log)
+	}, appService, serviceService, domainService, deployService, updateService, gitCredentialService, previewService, store.Settings(), dockerClient, store.Containers(), store.Deployments(), eventBus, log)
 
 	// Start background update checker
 	go updateService.StartBackgroundChecker(context.Background())
 
+	// Stop previous slots once their drain and keep-warm periods are over
+	go deployService.StartSlotReaper(context.Background())
+
+	// Converge the proxy's routes with the domains in the database
+	go func() {
+		if err := deployService.AdoptLegacyRoutes(context.Background()); err != nil {
+			log.Warn("failed to adopt legacy proxy routes", "error", err)
+			return
+		}
+		if _, err := deployService.SyncRoutes(context.Background()); err != nil {
+			log.Warn("failed to sync proxy routes", "error", err)
+		}
+	}()
+
+	// Report certificate expiry and reinstall uploaded certificates
+	go domainService.StartCertificateMonitor(context.Background())
+
+	// Tear down pull request previews whose TTL ran out
+	go previewService.StartPreviewReaper(context.Background())
+
 	// Start notification service for WhatsApp deployment alerts
 	notificationService.Start(context.Background())
 
@@ -142,3 +235,82 @@ func main() {
 
 	log.Info("server stopped")
 }
+
+// newProxyManager creates the proxy manager selected by the proxy section.
+// Traefik and nginx are configured through files rendered from a route table
+// kept next to the database.
+func newProxyManager(cfg *config.Config, runtime *docker.Client, log logger.Logger) (proxy.ProxyManager, error) {
+	stateFile := filepath.Join(filepath.Dir(cfg.Database.Path), "proxy-routes.json")
+
+	switch cfg.Proxy.Type {
+	case "caddy", "":
+		acme, err := caddyACME(cfg.Proxy.Caddy.ACME)
+		if err != nil {
+			return nil, err
+		}
+		return caddy.NewManager(cfg.Proxy.Caddy.AdminAPI, cfg.Proxy.Network, cfg.Proxy.Container, acme, runtime, log), nil
+	case "traefik":
+		return traefik.NewManager(traefik.Options{
+			ConfigFile:      cfg.Proxy.Traefik.ConfigFile,
+			StateFile:       stateFile,
+			APIURL:          cfg.Proxy.Traefik.APIURL,
+			HTTPEntrypoint:  cfg.Proxy.Traefik.HTTPEntrypoint,
+			HTTPSEntrypoint: cfg.Proxy.Traefik.HTTPSEntrypoint,
+			CertResolver:    cfg.Proxy.Traefik.CertResolver,
+			Container:       cfg.Proxy.Container,
+		}, runtime, log), nil
+	case "nginx":
+		return nginx.NewManager(nginx.Options{
+			ConfigFile:    cfg.Proxy.Nginx.ConfigFile,
+			StateFile:     stateFile,
+			CertDir:       cfg.Proxy.Nginx.CertDir,
+			TestCommand:   strings.Fields(cfg.Proxy.Nginx.TestCommand),
+			ReloadCommand: strings.Fields(cfg.Proxy.Nginx.ReloadCommand),
+			Container:     cfg.Proxy.Container,
+		}, runtime, log), nil
+	default:
+		return nil, fmt.Errorf("unknown proxy type %q: use caddy, traefik or nginx", cfg.Proxy.Type)
+	}
+}
+
+// caddyACME turns the acme section into Caddy's ACME options, with the DNS
+// provider in the form of its Caddy module
+func caddyACME(cfg config.ACMEConfig) (caddy.ACMEOptions, error) {
+	opts := caddy.ACMEOptions{CA: cfg.CA, Email: cfg.Email, OnDemandAsk: cfg.OnDemandAsk}
+	if cfg.CARoot != "" {
+		opts.TrustedRoots = []string{cfg.CARoot}
+	}
+
+	var provider map[string]string
+	switch cfg.DNS.Provider {
+	case "":
+		return opts, nil
+	case "cloudflare":
+		provider = map[string]string{"api_token": cfg.DNS.Cloudflare.APIToken}
+	case "route53":
+		provider = map[string]string{
+			"access_key_id":     cfg.DNS.Route53.AccessKeyID,
+			"secret_access_key": cfg.DNS.Route53.SecretAccessKey,
+			"region":            cfg.DNS.Route53.Region,
+			"hosted_zone_id":    cfg.DNS.Route53.HostedZoneID,
+		}
+	case "rfc2136":
+		provider = map[string]string{
+			"server":   cfg.DNS.RFC2136.Server,
+			"key_name": cfg.DNS.RFC2136.KeyName,
+			"key_alg":  cfg.DNS.RFC2136.KeyAlg,
+			"key":      cfg.DNS.RFC2136.Key,
+		}
+	default:
+		return opts, fmt.Errorf("unknown DNS provider %q: use cloudflare, route53 or rfc2136", cfg.DNS.Provider)
+	}
+
+	for field, value := range provider {
+		if value == "" {
+			delete(provider, field)
+		}
+	}
+	provider["name"] = cfg.DNS.Provider
+	opts.DNS = &caddy.DNSOptions{Provider: provider, Resolvers: cfg.DNS.Resolvers}
+	return opts, nil
+}
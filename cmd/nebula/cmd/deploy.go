@@ -8,9 +8,28 @@ import (
 )
 
 var deployCmd = &cobra.Command{
-	Use:   "deploy",
+	Use:   "deploy [<project> <service>]",
 	Short: "Deploy an application",
-	Long:  `Deploy an application using Git, Docker Image, or Docker Compose.`,
+	Long: `Deploy an application using Git, Docker Image, or Docker Compose.
+
+Given a project and a service, deploy that service; this is the same as
+'nebula deploy service', including its --canary rollout.
+
+Examples:
+  nebula deploy myproject web
+  nebula deploy myproject web --canary 5,25,100 --step-duration 5m`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return nil
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return cmd.Help()
+		}
+		return runDeployService(cmd, args)
+	},
 }
 
 var deployImageCmd = &cobra.Command{
@@ -25,9 +44,132 @@ Examples:
 	RunE: runDeployImage,
 }
 
+var deployServiceCmd = &cobra.Command{
+	Use:   "service <project> <service>",
+	Short: "Deploy a project service",
+	Long: `Deploy a service of a project.
+
+With --canary the new deployment receives the given percentages of traffic,
+one step at a time, and is rolled back automatically when it stops running or
+fails too many health probes or requests.
+
+Examples:
+  nebula deploy service myproject web
+  nebula deploy service myproject web --canary 5,25,100 --step-duration 5m
+  nebula deploy service myproject web --canary 10,50 --max-error-rate 5 --max-failed-requests 20`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDeployService,
+}
+
+var deployProjectCmd = &cobra.Command{
+	Use:   "project <project>",
+	Short: "Deploy all services of a project in dependency order",
+	Long: `Deploy every service of a project as one release. Databases are deployed
+first, then the services that depend on them, layer by layer; each layer
+starts once the previous one is running and healthy. A service that requires
+approval blocks the release until its deployment is approved with
+'nebula deploy approve', and fails it when rejected.
+
+Examples:
+  nebula deploy project myproject`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDeployProject,
+}
+
+var deployPromoteCmd = &cobra.Command{
+	Use:   "promote <project>",
+	Short: "Promote the images of one environment to another",
+	Long: `Deploy the images running in one environment of a project to the services
+of the same name in another environment, without rebuilding them.
+
+Examples:
+  nebula deploy promote myproject --from staging --to production
+  nebula deploy promote myproject --from staging --to production --services web,worker`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDeployPromote,
+}
+
+var deployDiffCmd = &cobra.Command{
+	Use:   "diff <deployment-a> <deployment-b>",
+	Short: "Show what changed between two deployments",
+	Long: `Compare two deployments: source, commit range, image digest, builder and
+runtime settings, and environment variables. Values of secret-looking
+variables are masked.
+
+Examples:
+  nebula deploy diff 3f2a1c 9b8e7d`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDeployDiff,
+}
+
+var deployApproveCmd = &cobra.Command{
+	Use:   "approve <deployment-id>",
+	Short: "Approve a deployment awaiting approval",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReviewDeployment(cmd, args[0], "approve")
+	},
+}
+
+var deployRejectCmd = &cobra.Command{
+	Use:   "reject <deployment-id>",
+	Short: "Reject a deployment awaiting approval",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReviewDeployment(cmd, args[0], "reject")
+	},
+}
+
+var deployCanaryCmd = &cobra.Command{
+	Use:   "canary",
+	Short: "Control a canary rollout in progress",
+}
+
+var deployCanaryPromoteCmd = &cobra.Command{
+	Use:   "promote <project> <service>",
+	Short: "Send all traffic to the canary deployment",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCanaryAction(args[0], args[1], "promote")
+	},
+}
+
+var deployCanaryAbortCmd = &cobra.Command{
+	Use:   "abort <project> <service>",
+	Short: "Roll the canary deployment back",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCanaryAction(args[0], args[1], "abort")
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(deployCmd)
 	deployCmd.AddCommand(deployImageCmd)
+	deployCmd.AddCommand(deployServiceCmd)
+	deployCmd.AddCommand(deployProjectCmd)
+	deployCmd.AddCommand(deployPromoteCmd)
+	deployCmd.AddCommand(deployDiffCmd)
+	deployCmd.AddCommand(deployApproveCmd)
+	deployCmd.AddCommand(deployRejectCmd)
+	deployCmd.AddCommand(deployCanaryCmd)
+	deployCanaryCmd.AddCommand(deployCanaryPromoteCmd)
+	deployCanaryCmd.AddCommand(deployCanaryAbortCmd)
+
+	for _, cmd := range []*cobra.Command{deployCmd, deployServiceCmd} {
+		cmd.Flags().StringSliceP("env", "e", []string{}, "Environment variables (KEY=VALUE)")
+		cmd.Flags().IntSlice("canary", nil, "Canary traffic steps in percent (e.g. 5,25,100)")
+		cmd.Flags().String("step-duration", "", "Observation time per canary step (default 2m)")
+		cmd.Flags().Float64("max-error-rate", -1, "Percentage of failed health probes tolerated per step (default 10)")
+		cmd.Flags().Int("max-failed-requests", 0, "Failed requests tolerated per step (0 disables the check)")
+		cmd.Flags().String("freeze-override", "", "Reason for deploying during a freeze window (audited)")
+	}
+	deployProjectCmd.Flags().String("freeze-override", "", "Reason for deploying during a freeze window (audited)")
+	deployRejectCmd.Flags().String("reason", "", "Why the deployment is rejected")
+	deployPromoteCmd.Flags().String("from", "staging", "Environment whose images are promoted")
+	deployPromoteCmd.Flags().String("to", "production", "Environment the images are deployed to")
+	deployPromoteCmd.Flags().StringSlice("services", nil, "Services to promote (default all)")
+	deployPromoteCmd.Flags().String("freeze-override", "", "Reason for promoting during a freeze window (audited)")
 
 	deployImageCmd.Flags().StringP("image", "i", "", "Docker image to deploy (required)")
 	deployImageCmd.Flags().IntP("port", "p", 0, "Container port to expose (required)")
@@ -42,12 +184,13 @@ func init() {
 
 // Deployment represents a deployment response
 type Deployment struct {
-	ID        string `json:"id"`
-	AppID     string `json:"app_id"`
-	Version   string `json:"version"`
-	Slot      string `json:"slot"`
-	Status    string `json:"status"`
-	CreatedAt string `json:"created_at"`
+	ID            string `json:"id"`
+	AppID         string `json:"app_id"`
+	Version       string `json:"version"`
+	Slot          string `json:"slot"`
+	Status        string `json:"status"`
+	TrafficWeight int    `json:"traffic_weight"`
+	CreatedAt     string `json:"created_at"`
 }
 
 func runDeployImage(cmd *cobra.Command, args []string) error {
@@ -115,3 +258,294 @@ func runDeployImage(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runDeployService(cmd *cobra.Command, args []string) error {
+	projectName, serviceName := args[0], args[1]
+
+	envVars, _ := cmd.Flags().GetStringSlice("env")
+	steps, _ := cmd.Flags().GetIntSlice("canary")
+	stepDuration, _ := cmd.Flags().GetString("step-duration")
+	maxErrorRate, _ := cmd.Flags().GetFloat64("max-error-rate")
+	maxFailedRequests, _ := cmd.Flags().GetInt("max-failed-requests")
+	freezeOverride, _ := cmd.Flags().GetString("freeze-override")
+
+	body := map[string]interface{}{}
+	if freezeOverride != "" {
+		body["freeze_override"] = freezeOverride
+	}
+
+	env := make(map[string]string)
+	for _, e := range envVars {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	if len(env) > 0 {
+		body["environment"] = env
+	}
+
+	if cmd.Flags().Changed("canary") {
+		canary := map[string]interface{}{
+			"steps": steps,
+		}
+		if stepDuration != "" {
+			canary["step_duration"] = stepDuration
+		}
+		if maxErrorRate >= 0 {
+			canary["max_error_rate"] = maxErrorRate
+		}
+		if maxFailedRequests > 0 {
+			canary["max_failed_requests"] = maxFailedRequests
+		}
+		body["canary"] = canary
+		fmt.Printf("Deploying %s/%s as a canary (steps %v)...\n", projectName, serviceName, steps)
+	} else {
+		fmt.Printf("Deploying %s/%s...\n", projectName, serviceName)
+	}
+
+	client := NewClient()
+	resp, err := client.Post(fmt.Sprintf("/api/v1/projects/%s/services/%s/deploy", projectName, serviceName), body)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data    Deployment `json:"data"`
+		Message string     `json:"message"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	if result.Data.Status == "awaiting_approval" {
+		fmt.Printf("✓ Deployment awaiting approval\n")
+		fmt.Printf("  ID: %s\n", result.Data.ID)
+		fmt.Printf("  Approve with: nebula deploy approve %s\n", result.Data.ID)
+		return nil
+	}
+
+	fmt.Printf("✓ Deployment started\n")
+	fmt.Printf("  ID: %s\n", result.Data.ID)
+	fmt.Printf("  Version: %s\n", result.Data.Version)
+	fmt.Printf("  Slot: %s\n", result.Data.Slot)
+	fmt.Printf("  Status: %s\n", result.Data.Status)
+
+	return nil
+}
+
+func runCanaryAction(projectName, serviceName, action string) error {
+	client := NewClient()
+	resp, err := client.Post(fmt.Sprintf("/api/v1/projects/%s/services/%s/canary/%s", projectName, serviceName, action), nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data    Deployment `json:"data"`
+		Message string     `json:"message"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ %s\n", result.Message)
+	fmt.Printf("  Deployment: %s (%s, %d%% of traffic)\n", result.Data.ID, result.Data.Status, result.Data.TrafficWeight)
+
+	return nil
+}
+
+func runDeployProject(cmd *cobra.Command, args []string) error {
+	projectName := args[0]
+
+	fmt.Printf("Deploying project %s...\n", projectName)
+
+	body := map[string]interface{}{}
+	if freezeOverride, _ := cmd.Flags().GetString("freeze-override"); freezeOverride != "" {
+		body["freeze_override"] = freezeOverride
+	}
+
+	client := NewClient()
+	resp, err := client.Post(fmt.Sprintf("/api/v1/projects/%s/deploy", projectName), body)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data struct {
+			ID     string     `json:"id"`
+			Status string     `json:"status"`
+			Layers [][]string `json:"layers"`
+		} `json:"data"`
+		Message string `json:"message"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Release started\n")
+	fmt.Printf("  ID: %s\n", result.Data.ID)
+	fmt.Printf("  Status: %s\n", result.Data.Status)
+	for i, layer := range result.Data.Layers {
+		fmt.Printf("  Layer %d: %s\n", i+1, strings.Join(layer, ", "))
+	}
+
+	return nil
+}
+
+func runDeployPromote(cmd *cobra.Command, args []string) error {
+	from, _ := cmd.Flags().GetString("from")
+	to, _ := cmd.Flags().GetString("to")
+
+	body := map[string]interface{}{
+		"from": from,
+		"to":   to,
+	}
+	if services, _ := cmd.Flags().GetStringSlice("services"); len(services) > 0 {
+		body["services"] = services
+	}
+	if freezeOverride, _ := cmd.Flags().GetString("freeze-override"); freezeOverride != "" {
+		body["freeze_override"] = freezeOverride
+	}
+
+	fmt.Printf("Promoting %s from %s to %s...\n", args[0], from, to)
+
+	client := NewClient()
+	resp, err := client.Post(fmt.Sprintf("/api/v1/projects/%s/promote", args[0]), body)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data []struct {
+			ID          string `json:"id"`
+			ServiceName string `json:"service_name"`
+			Version     string `json:"version"`
+			Status      string `json:"status"`
+		} `json:"data"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Promotion started\n")
+	for _, d := range result.Data {
+		fmt.Printf("  %s: %s (%s)\n", d.ServiceName, d.ID, d.Status)
+	}
+
+	return nil
+}
+
+func runDeployDiff(cmd *cobra.Command, args []string) error {
+	client := NewClient()
+	resp, err := client.Get(fmt.Sprintf("/api/v1/deployments/%s/diff/%s", args[0], args[1]))
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	type fieldChange struct {
+		Field string `json:"field"`
+		From  string `json:"from"`
+		To    string `json:"to"`
+	}
+	var result struct {
+		Data struct {
+			From    Deployment    `json:"from"`
+			To      Deployment    `json:"to"`
+			Source  []fieldChange `json:"source"`
+			Commits *struct {
+				Repository string `json:"repository"`
+				From       string `json:"from"`
+				To         string `json:"to"`
+				CompareURL string `json:"compare_url"`
+			} `json:"commits"`
+			Builder     []fieldChange `json:"builder"`
+			Runtime     []fieldChange `json:"runtime"`
+			Environment []struct {
+				Key    string `json:"key"`
+				Change string `json:"change"`
+				From   string `json:"from"`
+				To     string `json:"to"`
+			} `json:"environment"`
+		} `json:"data"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	diff := result.Data
+	fmt.Printf("Deployment %s (%s) -> %s (%s)\n", diff.From.ID, diff.From.Version, diff.To.ID, diff.To.Version)
+
+	printChanges := func(title string, changes []fieldChange) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Printf("\n%s:\n", title)
+		for _, c := range changes {
+			fmt.Printf("  %s: %s -> %s\n", c.Field, orNone(c.From), orNone(c.To))
+		}
+	}
+	printChanges("Source", diff.Source)
+	if diff.Commits != nil {
+		fmt.Printf("\nCommits:\n  %s..%s\n", diff.Commits.From, diff.Commits.To)
+		if diff.Commits.CompareURL != "" {
+			fmt.Printf("  %s\n", diff.Commits.CompareURL)
+		}
+	}
+	printChanges("Builder", diff.Builder)
+	printChanges("Runtime", diff.Runtime)
+
+	if len(diff.Environment) > 0 {
+		fmt.Printf("\nEnvironment:\n")
+		for _, e := range diff.Environment {
+			switch e.Change {
+			case "added":
+				fmt.Printf("  + %s=%s\n", e.Key, e.To)
+			case "removed":
+				fmt.Printf("  - %s=%s\n", e.Key, e.From)
+			default:
+				fmt.Printf("  ~ %s: %s -> %s\n", e.Key, e.From, e.To)
+			}
+		}
+	}
+
+	if len(diff.Source)+len(diff.Builder)+len(diff.Runtime)+len(diff.Environment) == 0 {
+		fmt.Println("\nNo differences")
+	}
+
+	return nil
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+func runReviewDeployment(cmd *cobra.Command, deploymentID, action string) error {
+	body := map[string]interface{}{}
+	if reason, _ := cmd.Flags().GetString("reason"); reason != "" {
+		body["reason"] = reason
+	}
+
+	client := NewClient()
+	resp, err := client.Post(fmt.Sprintf("/api/v1/deployments/%s/%s", deploymentID, action), body)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data    Deployment `json:"data"`
+		Message string     `json:"message"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ %s\n", result.Message)
+	fmt.Printf("  ID: %s\n", result.Data.ID)
+	fmt.Printf("  Status: %s\n", result.Data.Status)
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage project environments",
+	Long: `Manage the environments of a project, such as staging and production.
+Each environment has its own services, domains, environment variables and
+databases, on a network of its own.`,
+}
+
+var envListCmd = &cobra.Command{
+	Use:   "list <project>",
+	Short: "List the environments of a project",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runEnvList,
+}
+
+var envCreateCmd = &cobra.Command{
+	Use:   "create <project> <name>",
+	Short: "Add an environment to a project",
+	Long: `Add an environment to a project. The services of an existing environment
+(production by default) are copied without their domains or deployments.
+
+Examples:
+  nebula env create myproject staging --branch develop
+  nebula env create myproject qa --clone-from staging -e LOG_LEVEL=debug`,
+	Args: cobra.ExactArgs(2),
+	RunE: runEnvCreate,
+}
+
+var envDeleteCmd = &cobra.Command{
+	Use:   "delete <project> <name>",
+	Short: "Remove an environment and its services",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runEnvDelete,
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.AddCommand(envListCmd)
+	envCmd.AddCommand(envCreateCmd)
+	envCmd.AddCommand(envDeleteCmd)
+
+	envCreateCmd.Flags().String("branch", "", "Git branch deployed to the environment (default the project's)")
+	envCreateCmd.Flags().String("clone-from", "", "Environment whose services are copied (default production)")
+	envCreateCmd.Flags().StringSliceP("env", "e", []string{}, "Environment variables (KEY=VALUE)")
+}
+
+// Environment represents a project environment response
+type Environment struct {
+	Name        string   `json:"name"`
+	ProjectID   string   `json:"project_id"`
+	ProjectName string   `json:"project_name"`
+	GitBranch   string   `json:"git_branch"`
+	Network     string   `json:"network"`
+	Services    []string `json:"services"`
+}
+
+func runEnvList(cmd *cobra.Command, args []string) error {
+	client := NewClient()
+	resp, err := client.Get(fmt.Sprintf("/api/v1/projects/%s/environments", args[0]))
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data []Environment `json:"data"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPROJECT\tBRANCH\tSERVICES")
+	for _, env := range result.Data {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", env.Name, env.ProjectName, env.GitBranch, strings.Join(env.Services, ","))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runEnvCreate(cmd *cobra.Command, args []string) error {
+	branch, _ := cmd.Flags().GetString("branch")
+	cloneFrom, _ := cmd.Flags().GetString("clone-from")
+	envVars, _ := cmd.Flags().GetStringSlice("env")
+
+	env := make(map[string]string)
+	for _, e := range envVars {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+
+	body := map[string]interface{}{
+		"name":        args[1],
+		"git_branch":  branch,
+		"clone_from":  cloneFrom,
+		"environment": env,
+	}
+
+	client := NewClient()
+	resp, err := client.Post(fmt.Sprintf("/api/v1/projects/%s/environments", args[0]), body)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data Environment `json:"data"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Environment %s created\n", result.Data.Name)
+	fmt.Printf("  Project: %s\n", result.Data.ProjectName)
+	fmt.Printf("  Network: %s\n", result.Data.Network)
+	if len(result.Data.Services) > 0 {
+		fmt.Printf("  Services: %s\n", strings.Join(result.Data.Services, ", "))
+	}
+
+	return nil
+}
+
+func runEnvDelete(cmd *cobra.Command, args []string) error {
+	client := NewClient()
+	resp, err := client.Delete(fmt.Sprintf("/api/v1/projects/%s/environments/%s", args[0], args[1]))
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Message string `json:"message"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Environment %s deleted\n", args[1])
+	return nil
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var freezeCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "Manage deploy freeze windows",
+	Long: `Manage the freeze windows of a project. While a window is in effect,
+deployments are refused unless they give an override reason, which is
+recorded in the project's audit log.`,
+}
+
+var freezeListCmd = &cobra.Command{
+	Use:   "list <project>",
+	Short: "List the freeze windows of a project",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFreezeList,
+}
+
+var freezeAddCmd = &cobra.Command{
+	Use:   "add <project> <name>",
+	Short: "Add a freeze window",
+	Long: `Add a one-off freeze window with --from and --until, or a recurring one
+with --weekdays and/or --start and --end.
+
+Examples:
+  nebula freeze add myproject launch --from 2026-11-27T00:00:00Z --until 2026-11-30T23:59:00Z
+  nebula freeze add myproject weekend --weekdays fri,sat,sun --start 18:00 --end 08:00 --timezone Europe/Madrid`,
+	Args: cobra.ExactArgs(2),
+	RunE: runFreezeAdd,
+}
+
+var freezeRemoveCmd = &cobra.Command{
+	Use:   "remove <project> <window-id>",
+	Short: "Remove a freeze window",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runFreezeRemove,
+}
+
+func init() {
+	rootCmd.AddCommand(freezeCmd)
+	freezeCmd.AddCommand(freezeListCmd)
+	freezeCmd.AddCommand(freezeAddCmd)
+	freezeCmd.AddCommand(freezeRemoveCmd)
+
+	freezeAddCmd.Flags().String("from", "", "Start of a one-off window (RFC 3339)")
+	freezeAddCmd.Flags().String("until", "", "End of a one-off window (RFC 3339)")
+	freezeAddCmd.Flags().StringSlice("weekdays", nil, "Days a recurring window starts on (mon,tue,...)")
+	freezeAddCmd.Flags().String("start", "", "Daily start time of a recurring window (HH:MM)")
+	freezeAddCmd.Flags().String("end", "", "Daily end time of a recurring window (HH:MM)")
+	freezeAddCmd.Flags().String("timezone", "", "Timezone of a recurring window (default UTC)")
+}
+
+// FreezeWindow represents a freeze window response
+type FreezeWindow struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	StartsAt  string   `json:"starts_at"`
+	EndsAt    string   `json:"ends_at"`
+	Weekdays  []string `json:"weekdays"`
+	StartTime string   `json:"start_time"`
+	EndTime   string   `json:"end_time"`
+	Timezone  string   `json:"timezone"`
+	Active    bool     `json:"active"`
+}
+
+func runFreezeList(cmd *cobra.Command, args []string) error {
+	client := NewClient()
+	resp, err := client.Get(fmt.Sprintf("/api/v1/projects/%s/freeze-windows", args[0]))
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data []FreezeWindow `json:"data"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	if len(result.Data) == 0 {
+		fmt.Println("No freeze windows")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tWHEN\tACTIVE")
+	for _, fw := range result.Data {
+		when := fmt.Sprintf("%s - %s", fw.StartsAt, fw.EndsAt)
+		if fw.StartsAt == "" {
+			days := "every day"
+			if len(fw.Weekdays) > 0 {
+				days = strings.Join(fw.Weekdays, ",")
+			}
+			when = days
+			if fw.StartTime != "" {
+				when += fmt.Sprintf(" %s-%s", fw.StartTime, fw.EndTime)
+			}
+			if fw.Timezone != "" {
+				when += " " + fw.Timezone
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\n", fw.ID, fw.Name, when, fw.Active)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runFreezeAdd(cmd *cobra.Command, args []string) error {
+	body := map[string]interface{}{
+		"name": args[1],
+	}
+	for flag, field := range map[string]string{
+		"from":     "starts_at",
+		"until":    "ends_at",
+		"start":    "start_time",
+		"end":      "end_time",
+		"timezone": "timezone",
+	} {
+		if value, _ := cmd.Flags().GetString(flag); value != "" {
+			body[field] = value
+		}
+	}
+	if weekdays, _ := cmd.Flags().GetStringSlice("weekdays"); len(weekdays) > 0 {
+		body["weekdays"] = weekdays
+	}
+
+	client := NewClient()
+	resp, err := client.Post(fmt.Sprintf("/api/v1/projects/%s/freeze-windows", args[0]), body)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data FreezeWindow `json:"data"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Freeze window added\n")
+	fmt.Printf("  ID: %s\n", result.Data.ID)
+	fmt.Printf("  Active: %v\n", result.Data.Active)
+
+	return nil
+}
+
+func runFreezeRemove(cmd *cobra.Command, args []string) error {
+	client := NewClient()
+	resp, err := client.Delete(fmt.Sprintf("/api/v1/projects/%s/freeze-windows/%s", args[0], args[1]))
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Message string `json:"message"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Println("✓ Freeze window removed")
+	return nil
+}
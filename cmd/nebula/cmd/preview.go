@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Manage pull request previews",
+	Long: `Manage the preview environments Nebula deploys for pull requests. Point a
+GitHub or GitLab webhook for pull/merge request events at the project's
+webhook path, signed with the project's webhook secret.`,
+}
+
+var previewListCmd = &cobra.Command{
+	Use:   "list <project>",
+	Short: "List the previews of a project",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPreviewList,
+}
+
+var previewDeleteCmd = &cobra.Command{
+	Use:   "delete <project> <pr-number>",
+	Short: "Tear down the preview of a pull request",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runPreviewDelete,
+}
+
+var previewCleanupCmd = &cobra.Command{
+	Use:   "cleanup <project>",
+	Short: "Tear down expired previews",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPreviewCleanup,
+}
+
+var previewConfigCmd = &cobra.Command{
+	Use:   "config <project>",
+	Short: "Show or change the preview settings of a project",
+	Long: `Show the preview settings of a project, or change them with flags.
+
+Examples:
+  nebula preview config myproject
+  nebula preview config myproject --enable --domain preview.example.com --ttl 72 --max 5 --fork-databases`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPreviewConfig,
+}
+
+func init() {
+	rootCmd.AddCommand(previewCmd)
+	previewCmd.AddCommand(previewListCmd)
+	previewCmd.AddCommand(previewDeleteCmd)
+	previewCmd.AddCommand(previewCleanupCmd)
+	previewCmd.AddCommand(previewConfigCmd)
+
+	previewCleanupCmd.Flags().Bool("all", false, "Tear down every preview, expired or not")
+
+	previewConfigCmd.Flags().Bool("enable", false, "Deploy previews for pull requests")
+	previewConfigCmd.Flags().Bool("disable", false, "Stop deploying previews")
+	previewConfigCmd.Flags().String("domain", "", "Base domain of preview subdomains")
+	previewConfigCmd.Flags().Bool("fork-databases", false, "Copy the project's databases into previews")
+	previewConfigCmd.Flags().Int("ttl", 0, "Hours after the last push before a preview is torn down (0 = on close only)")
+	previewConfigCmd.Flags().Int("max", 0, "Maximum concurrent previews (0 = unlimited)")
+	previewConfigCmd.Flags().String("secret", "", "Webhook secret (generated when previews are enabled without one)")
+	previewConfigCmd.Flags().Bool("regenerate-secret", false, "Generate a new webhook secret")
+}
+
+// Preview represents a pull request preview response
+type Preview struct {
+	PRNumber  int      `json:"pr_number"`
+	Title     string   `json:"title"`
+	Branch    string   `json:"branch"`
+	URLs      []string `json:"urls"`
+	Status    string   `json:"status"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+// PreviewConfig represents the preview settings response
+type PreviewConfig struct {
+	Enabled       bool   `json:"enabled"`
+	BaseDomain    string `json:"base_domain"`
+	ForkDatabases bool   `json:"fork_databases"`
+	TTLHours      int    `json:"ttl_hours"`
+	MaxPreviews   int    `json:"max_previews"`
+	WebhookPath   string `json:"webhook_path"`
+	WebhookSecret string `json:"webhook_secret"`
+	HasSecret     bool   `json:"has_secret"`
+}
+
+func runPreviewList(cmd *cobra.Command, args []string) error {
+	client := NewClient()
+	resp, err := client.Get(fmt.Sprintf("/api/v1/projects/%s/previews", args[0]))
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data []Preview `json:"data"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	if len(result.Data) == 0 {
+		fmt.Println("No previews")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PR\tBRANCH\tSTATUS\tURL\tEXPIRES")
+	for _, p := range result.Data {
+		fmt.Fprintf(w, "#%d\t%s\t%s\t%s\t%s\n", p.PRNumber, p.Branch, p.Status, strings.Join(p.URLs, ","), p.ExpiresAt)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runPreviewDelete(cmd *cobra.Command, args []string) error {
+	client := NewClient()
+	resp, err := client.Delete(fmt.Sprintf("/api/v1/projects/%s/previews/%s", args[0], strings.TrimPrefix(args[1], "#")))
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Message string `json:"message"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Println("✓ Preview deleted")
+	return nil
+}
+
+func runPreviewCleanup(cmd *cobra.Command, args []string) error {
+	path := fmt.Sprintf("/api/v1/projects/%s/previews/cleanup", args[0])
+	if all, _ := cmd.Flags().GetBool("all"); all {
+		path += "?all=true"
+	}
+
+	client := NewClient()
+	resp, err := client.Post(path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data []Preview `json:"data"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ %d previews removed\n", len(result.Data))
+	for _, p := range result.Data {
+		fmt.Printf("  #%d %s\n", p.PRNumber, p.Branch)
+	}
+	return nil
+}
+
+func runPreviewConfig(cmd *cobra.Command, args []string) error {
+	body := map[string]interface{}{}
+	flags := cmd.Flags()
+	if enable, _ := flags.GetBool("enable"); enable {
+		body["enabled"] = true
+	}
+	if disable, _ := flags.GetBool("disable"); disable {
+		body["enabled"] = false
+	}
+	if flags.Changed("domain") {
+		body["base_domain"], _ = flags.GetString("domain")
+	}
+	if flags.Changed("fork-databases") {
+		body["fork_databases"], _ = flags.GetBool("fork-databases")
+	}
+	if flags.Changed("ttl") {
+		body["ttl_hours"], _ = flags.GetInt("ttl")
+	}
+	if flags.Changed("max") {
+		body["max_previews"], _ = flags.GetInt("max")
+	}
+	if flags.Changed("secret") {
+		body["webhook_secret"], _ = flags.GetString("secret")
+	}
+	if regenerate, _ := flags.GetBool("regenerate-secret"); regenerate {
+		body["regenerate_secret"] = true
+	}
+
+	client := NewClient()
+	path := fmt.Sprintf("/api/v1/projects/%s/preview-config", args[0])
+	var resp *http.Response
+	var err error
+	if len(body) == 0 {
+		resp, err = client.Get(path)
+	} else {
+		resp, err = client.Put(path, body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	var result struct {
+		Data PreviewConfig `json:"data"`
+	}
+	if err := ParseResponse(resp, &result); err != nil {
+		return err
+	}
+
+	cfg := result.Data
+	fmt.Printf("Enabled: %v\n", cfg.Enabled)
+	fmt.Printf("Domain: %s\n", orNone(cfg.BaseDomain))
+	fmt.Printf("Fork databases: %v\n", cfg.ForkDatabases)
+	fmt.Printf("TTL: %d hours\n", cfg.TTLHours)
+	fmt.Printf("Max previews: %d\n", cfg.MaxPreviews)
+	fmt.Printf("Webhook: %s\n", cfg.WebhookPath)
+	if cfg.WebhookSecret != "" {
+		fmt.Printf("Webhook secret: %s (shown once)\n", cfg.WebhookSecret)
+	} else {
+		fmt.Printf("Webhook secret set: %v\n", cfg.HasSecret)
+	}
+	return nil
+}
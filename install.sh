@@ -78,6 +78,11 @@ install_caddy() {
             chmod +x /usr/local/bin/caddy
             ;;
     esac
+
+    # Per-domain rate limits use the caddy-ratelimit plugin
+    caddy add-package github.com/mholt/caddy-ratelimit || warn "Could not add the Caddy rate limit plugin; domain rate limits will not load"
+    # Wildcard domains solve DNS-01 challenges through these DNS providers
+    caddy add-package github.com/caddy-dns/cloudflare github.com/caddy-dns/route53 github.com/caddy-dns/rfc2136 || warn "Could not add the Caddy DNS provider plugins; wildcard domains will not get certificates"
     log "Caddy installed successfully"
 }
 
@@ -143,9 +148,14 @@ database:
 
 docker:
   host: unix:///var/run/docker.sock
-
-caddy:
-  admin_url: http://localhost:2019
+  # Caddy runs on the host, so it reaches apps through loopback ports
+  publish_ports: true
+
+proxy:
+  type: caddy
+  container: ""
+  caddy:
+    admin_api: http://localhost:2019
 
 data:
   dir: $NEBULA_HOME/data
@@ -110,6 +110,58 @@ func (h *AppHandler) Delete(c *gin.Context) {
 	})
 }
 
+// ListEnvironments returns the environments of a project
+func (h *AppHandler) ListEnvironments(c *gin.Context) {
+	id := c.Param("id")
+
+	envs, err := h.appService.ListEnvironments(c.Request.Context(), id)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": envs,
+	})
+}
+
+// CreateEnvironment adds an environment to a project
+func (h *AppHandler) CreateEnvironment(c *gin.Context) {
+	id := c.Param("id")
+
+	var req service.CreateEnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	env, err := h.appService.CreateEnvironment(c.Request.Context(), id, req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": env,
+	})
+}
+
+// DeleteEnvironment removes an environment from a project
+func (h *AppHandler) DeleteEnvironment(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.appService.DeleteEnvironment(c.Request.Context(), id, c.Param("env")); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "environment deleted",
+	})
+}
+
 // handleError handles application errors
 func handleError(c *gin.Context, err error) {
 	if appErr, ok := err.(*apperrors.AppError); ok {
@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 
+	"github.com/victalejo/nebula/internal/api/middleware"
 	"github.com/victalejo/nebula/internal/core/logger"
 )
 
@@ -30,6 +31,11 @@ func NewAuthHandler(jwtSecret string, tokenDuration time.Duration, adminUsername
 	}
 }
 
+// adminPermissions are granted to the configured admin user
+var adminPermissions = []string{
+	middleware.PermissionApproveDeployments,
+}
+
 // LoginRequest represents a login request
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
@@ -64,10 +70,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	expiresAt := time.Now().Add(h.tokenDuration)
 
 	claims := jwt.MapClaims{
-		"sub":      "user-1",
-		"username": req.Username,
-		"exp":      expiresAt.Unix(),
-		"iat":      time.Now().Unix(),
+		"sub":         "user-1",
+		"username":    req.Username,
+		"permissions": adminPermissions,
+		"exp":         expiresAt.Unix(),
+		"iat":         time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -118,10 +125,11 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 	expiresAt := time.Now().Add(h.tokenDuration)
 
 	newClaims := jwt.MapClaims{
-		"sub":      claims["sub"],
-		"username": claims["username"],
-		"exp":      expiresAt.Unix(),
-		"iat":      time.Now().Unix(),
+		"sub":         claims["sub"],
+		"username":    claims["username"],
+		"permissions": claims["permissions"],
+		"exp":         expiresAt.Unix(),
+		"iat":         time.Now().Unix(),
 	}
 
 	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims)
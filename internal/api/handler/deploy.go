@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/victalejo/nebula/internal/core/deployer"
 	"github.com/victalejo/nebula/internal/core/logger"
 	"github.com/victalejo/nebula/internal/service"
 )
@@ -111,6 +112,7 @@ func (h *DeployHandler) DeployService(c *gin.Context) {
 		// Allow empty body
 		req = service.DeployServiceRequest{}
 	}
+	req.RequestedBy = c.GetString("username")
 
 	deployment, err := h.deployService.DeployServiceByName(c.Request.Context(), projectID, serviceName, req)
 	if err != nil {
@@ -118,9 +120,14 @@ func (h *DeployHandler) DeployService(c *gin.Context) {
 		return
 	}
 
+	message := "deployment started"
+	if deployment.Status == string(deployer.StatusAwaitingApproval) {
+		message = "deployment awaiting approval"
+	}
+
 	c.JSON(http.StatusAccepted, gin.H{
 		"data":    deployment,
-		"message": "deployment started",
+		"message": message,
 	})
 }
 
@@ -139,3 +146,241 @@ func (h *DeployHandler) ListServiceDeployments(c *gin.Context) {
 		"data": deployments,
 	})
 }
+
+// RollbackService switches a service back to its warm standby deployment
+func (h *DeployHandler) RollbackService(c *gin.Context) {
+	projectID := c.Param("id")
+	serviceName := c.Param("serviceName")
+
+	deployment, err := h.deployService.RollbackService(c.Request.Context(), projectID, serviceName)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    deployment,
+		"message": "rolled back",
+	})
+}
+
+// PromoteCanary sends all traffic to a service's canary deployment
+func (h *DeployHandler) PromoteCanary(c *gin.Context) {
+	projectID := c.Param("id")
+	serviceName := c.Param("serviceName")
+
+	deployment, err := h.deployService.PromoteCanary(c.Request.Context(), projectID, serviceName)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    deployment,
+		"message": "canary promotion requested",
+	})
+}
+
+// AbortCanary rolls a service's canary deployment back
+func (h *DeployHandler) AbortCanary(c *gin.Context) {
+	projectID := c.Param("id")
+	serviceName := c.Param("serviceName")
+
+	deployment, err := h.deployService.AbortCanary(c.Request.Context(), projectID, serviceName)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    deployment,
+		"message": "canary rollback requested",
+	})
+}
+
+// DeployProject deploys all services of a project in dependency order
+func (h *DeployHandler) DeployProject(c *gin.Context) {
+	projectID := c.Param("id")
+
+	var req service.DeployProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		// Allow empty body
+		req = service.DeployProjectRequest{}
+	}
+	req.RequestedBy = c.GetString("username")
+
+	release, err := h.deployService.DeployProject(c.Request.Context(), projectID, req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data":    release,
+		"message": "release started",
+	})
+}
+
+// PromoteEnvironment deploys the images of one environment to another
+func (h *DeployHandler) PromoteEnvironment(c *gin.Context) {
+	projectID := c.Param("id")
+
+	var req service.PromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+	req.RequestedBy = c.GetString("username")
+
+	deployments, err := h.deployService.PromoteEnvironment(c.Request.Context(), projectID, req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data":    deployments,
+		"message": "promotion started",
+	})
+}
+
+// ListReleases returns the releases of a project
+func (h *DeployHandler) ListReleases(c *gin.Context) {
+	projectID := c.Param("id")
+
+	releases, err := h.deployService.ListReleases(c.Request.Context(), projectID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": releases,
+	})
+}
+
+// GetRelease returns a project release with its service deployments
+func (h *DeployHandler) GetRelease(c *gin.Context) {
+	projectID := c.Param("id")
+	releaseID := c.Param("rid")
+
+	release, err := h.deployService.GetRelease(c.Request.Context(), projectID, releaseID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": release,
+	})
+}
+
+// DiffDeployments compares two deployments
+func (h *DeployHandler) DiffDeployments(c *gin.Context) {
+	diff, err := h.deployService.DiffDeployments(c.Request.Context(), c.Param("did"), c.Param("other"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": diff,
+	})
+}
+
+// ReviewDeploymentRequest represents the body of an approval or rejection
+type ReviewDeploymentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ApproveDeployment starts a deployment that is awaiting approval
+func (h *DeployHandler) ApproveDeployment(c *gin.Context) {
+	deployment, err := h.deployService.ApproveDeployment(c.Request.Context(), c.Param("did"), c.GetString("username"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data":    deployment,
+		"message": "deployment approved",
+	})
+}
+
+// RejectDeployment declines a deployment that is awaiting approval
+func (h *DeployHandler) RejectDeployment(c *gin.Context) {
+	var req ReviewDeploymentRequest
+	_ = c.ShouldBindJSON(&req)
+
+	deployment, err := h.deployService.RejectDeployment(c.Request.Context(), c.Param("did"), c.GetString("username"), req.Reason)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    deployment,
+		"message": "deployment rejected",
+	})
+}
+
+// ListFreezeWindows returns the freeze windows of a project
+func (h *DeployHandler) ListFreezeWindows(c *gin.Context) {
+	windows, err := h.deployService.ListFreezeWindows(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": windows,
+	})
+}
+
+// CreateFreezeWindow adds a freeze window to a project
+func (h *DeployHandler) CreateFreezeWindow(c *gin.Context) {
+	var req service.FreezeWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	window, err := h.deployService.CreateFreezeWindow(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": window,
+	})
+}
+
+// DeleteFreezeWindow removes a freeze window from a project
+func (h *DeployHandler) DeleteFreezeWindow(c *gin.Context) {
+	if err := h.deployService.DeleteFreezeWindow(c.Request.Context(), c.Param("id"), c.Param("wid")); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "freeze window deleted",
+	})
+}
+
+// ListAuditLog returns the audit log of a project
+func (h *DeployHandler) ListAuditLog(c *gin.Context) {
+	entries, err := h.deployService.ListAuditLog(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": entries,
+	})
+}
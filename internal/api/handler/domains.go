@@ -78,11 +78,11 @@ func (h *DomainHandler) Create(c *gin.Context) {
 	})
 }
 
-// Get retrieves a domain by domain name
+// Get retrieves a domain by domain name, and by path prefix with ?path=/api
 func (h *DomainHandler) Get(c *gin.Context) {
 	domainName := c.Param("domain")
 
-	domain, err := h.domainService.Get(c.Request.Context(), domainName)
+	domain, err := h.domainService.Get(c.Request.Context(), domainName, c.Query("path"))
 	if err != nil {
 		handleError(c, err)
 		return
@@ -105,7 +105,7 @@ func (h *DomainHandler) Update(c *gin.Context) {
 		return
 	}
 
-	domain, err := h.domainService.Update(c.Request.Context(), domainName, req)
+	domain, err := h.domainService.Update(c.Request.Context(), domainName, c.Query("path"), req)
 	if err != nil {
 		handleError(c, err)
 		return
@@ -120,7 +120,7 @@ func (h *DomainHandler) Update(c *gin.Context) {
 func (h *DomainHandler) Delete(c *gin.Context) {
 	domainName := c.Param("domain")
 
-	if err := h.domainService.Delete(c.Request.Context(), domainName); err != nil {
+	if err := h.domainService.Delete(c.Request.Context(), domainName, c.Query("path")); err != nil {
 		handleError(c, err)
 		return
 	}
@@ -129,3 +129,257 @@ func (h *DomainHandler) Delete(c *gin.Context) {
 		"message": "domain deleted",
 	})
 }
+
+// GetRules returns a domain's redirect and rewrite rules
+func (h *DomainHandler) GetRules(c *gin.Context) {
+	domainName := c.Param("domain")
+
+	rules, err := h.domainService.GetRules(c.Request.Context(), domainName, c.Query("path"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": rules,
+	})
+}
+
+// UpdateRules replaces a domain's redirect and rewrite rules
+func (h *DomainHandler) UpdateRules(c *gin.Context) {
+	domainName := c.Param("domain")
+
+	var req service.DomainRules
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	rules, err := h.domainService.UpdateRules(c.Request.Context(), domainName, c.Query("path"), req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": rules,
+	})
+}
+
+// GetAccess returns a domain's basic auth users, IP lists and rate limit
+func (h *DomainHandler) GetAccess(c *gin.Context) {
+	domainName := c.Param("domain")
+
+	access, err := h.domainService.GetAccess(c.Request.Context(), domainName, c.Query("path"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": access,
+	})
+}
+
+// UpdateAccess replaces a domain's access control
+func (h *DomainHandler) UpdateAccess(c *gin.Context) {
+	domainName := c.Param("domain")
+
+	var req service.DomainAccess
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	access, err := h.domainService.UpdateAccess(c.Request.Context(), domainName, c.Query("path"), req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": access,
+	})
+}
+
+// GetHeaders returns a domain's response header policy
+func (h *DomainHandler) GetHeaders(c *gin.Context) {
+	domainName := c.Param("domain")
+
+	headers, err := h.domainService.GetHeaders(c.Request.Context(), domainName, c.Query("path"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": headers,
+	})
+}
+
+// UpdateHeaders replaces a domain's response headers, HSTS and CORS policy
+func (h *DomainHandler) UpdateHeaders(c *gin.Context) {
+	domainName := c.Param("domain")
+
+	var req service.DomainHeaders
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	headers, err := h.domainService.UpdateHeaders(c.Request.Context(), domainName, c.Query("path"), req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": headers,
+	})
+}
+
+// GetCertificate returns the certificate status of a domain's host
+func (h *DomainHandler) GetCertificate(c *gin.Context) {
+	domainName := c.Param("domain")
+
+	cert, err := h.domainService.GetCertificate(c.Request.Context(), domainName)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": cert,
+	})
+}
+
+// UploadCertificate installs a custom certificate and key for a domain's host
+func (h *DomainHandler) UploadCertificate(c *gin.Context) {
+	domainName := c.Param("domain")
+
+	var req service.UploadCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	cert, err := h.domainService.UploadCertificate(c.Request.Context(), domainName, req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": cert,
+	})
+}
+
+// DeleteCertificate removes a domain's custom certificate
+func (h *DomainHandler) DeleteCertificate(c *gin.Context) {
+	domainName := c.Param("domain")
+
+	if err := h.domainService.DeleteCertificate(c.Request.Context(), domainName); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "certificate deleted",
+	})
+}
+
+// GetTLSAllowlist returns the on-demand TLS allowlist of a project
+func (h *DomainHandler) GetTLSAllowlist(c *gin.Context) {
+	allowlist, err := h.domainService.GetTLSAllowlist(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": allowlist,
+	})
+}
+
+// UpdateTLSAllowlist replaces the on-demand TLS allowlist of a project
+func (h *DomainHandler) UpdateTLSAllowlist(c *gin.Context) {
+	var req service.UpdateTLSAllowlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	allowlist, err := h.domainService.UpdateTLSAllowlist(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": allowlist,
+	})
+}
+
+// AskCertificate answers Caddy's on-demand TLS "ask" request: 200 approves
+// a certificate for ?domain=, anything else denies it
+func (h *DomainHandler) AskCertificate(c *gin.Context) {
+	host := c.Query("domain")
+	if host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "domain is required",
+		})
+		return
+	}
+
+	allowed, err := h.domainService.AllowCertificate(c.Request.Context(), host)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "no certificate allowed for " + host,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{"domain": host, "allowed": true},
+	})
+}
+
+// DiffRoutes shows the proxy route changes a sync would make (dry run)
+func (h *DomainHandler) DiffRoutes(c *gin.Context) {
+	changes, err := h.domainService.DiffRoutes(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": changes,
+	})
+}
+
+// SyncRoutes rewrites the proxy's routes from the database
+func (h *DomainHandler) SyncRoutes(c *gin.Context) {
+	changes, err := h.domainService.SyncRoutes(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": changes,
+	})
+}
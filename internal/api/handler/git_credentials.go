@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/service"
+)
+
+// GitCredentialHandler handles git credential endpoints
+type GitCredentialHandler struct {
+	gitCredentialService *service.GitCredentialService
+	log                  logger.Logger
+}
+
+// NewGitCredentialHandler creates a new git credential handler
+func NewGitCredentialHandler(gitCredentialService *service.GitCredentialService, log logger.Logger) *GitCredentialHandler {
+	return &GitCredentialHandler{
+		gitCredentialService: gitCredentialService,
+		log:                  log,
+	}
+}
+
+// List returns all git credentials for a project
+func (h *GitCredentialHandler) List(c *gin.Context) {
+	projectID := c.Param("id")
+
+	credentials, err := h.gitCredentialService.List(c.Request.Context(), projectID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": credentials,
+	})
+}
+
+// Create creates a new git credential for a project
+func (h *GitCredentialHandler) Create(c *gin.Context) {
+	projectID := c.Param("id")
+
+	var req service.CreateGitCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	credential, err := h.gitCredentialService.Create(c.Request.Context(), projectID, req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": credential,
+	})
+}
+
+// Get retrieves a git credential by ID
+func (h *GitCredentialHandler) Get(c *gin.Context) {
+	credentialID := c.Param("credentialId")
+
+	credential, err := h.gitCredentialService.Get(c.Request.Context(), credentialID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": credential,
+	})
+}
+
+// Update updates a git credential
+func (h *GitCredentialHandler) Update(c *gin.Context) {
+	credentialID := c.Param("credentialId")
+
+	var req service.UpdateGitCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	credential, err := h.gitCredentialService.Update(c.Request.Context(), credentialID, req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": credential,
+	})
+}
+
+// ScanKnownHosts fetches and pins the host keys of a credential's git host
+func (h *GitCredentialHandler) ScanKnownHosts(c *gin.Context) {
+	credentialID := c.Param("credentialId")
+
+	credential, err := h.gitCredentialService.ScanKnownHosts(c.Request.Context(), credentialID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": credential,
+	})
+}
+
+// Delete deletes a git credential
+func (h *GitCredentialHandler) Delete(c *gin.Context) {
+	credentialID := c.Param("credentialId")
+
+	if err := h.gitCredentialService.Delete(c.Request.Context(), credentialID); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "git credential deleted",
+	})
+}
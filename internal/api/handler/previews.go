@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/service"
+)
+
+// PreviewHandler handles pull request preview endpoints
+type PreviewHandler struct {
+	previewService *service.PreviewService
+	log            logger.Logger
+}
+
+// NewPreviewHandler creates a new preview handler
+func NewPreviewHandler(previewService *service.PreviewService, log logger.Logger) *PreviewHandler {
+	return &PreviewHandler{
+		previewService: previewService,
+		log:            log,
+	}
+}
+
+// GetConfig returns the preview settings of a project
+func (h *PreviewHandler) GetConfig(c *gin.Context) {
+	config, err := h.previewService.GetConfig(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": config,
+	})
+}
+
+// UpdateConfig changes the preview settings of a project
+func (h *PreviewHandler) UpdateConfig(c *gin.Context) {
+	var req service.UpdatePreviewConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	config, err := h.previewService.UpdateConfig(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": config,
+	})
+}
+
+// List returns the previews of a project
+func (h *PreviewHandler) List(c *gin.Context) {
+	previews, err := h.previewService.List(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": previews,
+	})
+}
+
+// Delete tears down the preview of a pull request
+func (h *PreviewHandler) Delete(c *gin.Context) {
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid pull request number",
+		})
+		return
+	}
+
+	if err := h.previewService.Delete(c.Request.Context(), c.Param("id"), number); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "preview deleted",
+	})
+}
+
+// Cleanup tears down expired previews, or all previews with ?all=true
+func (h *PreviewHandler) Cleanup(c *gin.Context) {
+	all := c.Query("all") == "true"
+
+	removed, err := h.previewService.Cleanup(c.Request.Context(), c.Param("id"), all)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    removed,
+		"message": strconv.Itoa(len(removed)) + " previews removed",
+	})
+}
+
+// Webhook receives pull request events from GitHub and GitLab
+func (h *PreviewHandler) Webhook(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	hook := service.GitWebhook{
+		Event:     c.GetHeader("X-GitHub-Event"),
+		Signature: c.GetHeader("X-Hub-Signature-256"),
+		Token:     c.GetHeader("X-Gitlab-Token"),
+		Body:      body,
+	}
+	if hook.Event == "" {
+		hook.Event = c.GetHeader("X-Gitlab-Event")
+	}
+
+	preview, err := h.previewService.HandleWebhook(c.Request.Context(), c.Param("project"), hook)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	if preview == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "no preview changes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data":    preview,
+		"message": "preview deploying",
+	})
+}
@@ -130,3 +130,18 @@ func (h *ServiceHandler) Delete(c *gin.Context) {
 		"message": "service deleted",
 	})
 }
+
+// ClearBuildCache discards the build caches of a service
+func (h *ServiceHandler) ClearBuildCache(c *gin.Context) {
+	projectID := c.Param("id")
+	serviceName := c.Param("serviceName")
+
+	if err := h.serviceService.ClearBuildCache(c.Request.Context(), projectID, serviceName); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "build cache cleared",
+	})
+}
@@ -51,6 +51,31 @@ func CORS() gin.HandlerFunc {
 	}
 }
 
+// Permissions granted through the "permissions" token claim
+const (
+	PermissionApproveDeployments = "deployments:approve"
+)
+
+// RequirePermission returns a middleware that rejects requests whose token
+// lacks the given permission. It must run after Auth.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, _ := c.Get("permissions")
+		if list, ok := granted.([]interface{}); ok {
+			for _, p := range list {
+				if p == permission {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "missing permission: " + permission,
+		})
+	}
+}
+
 // Auth returns an authentication middleware
 func Auth(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -99,6 +124,7 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 		if claims, ok := token.Claims.(jwt.MapClaims); ok {
 			c.Set("user_id", claims["sub"])
 			c.Set("username", claims["username"])
+			c.Set("permissions", claims["permissions"])
 		}
 
 		c.Next()
@@ -39,6 +39,8 @@ type Server struct {
 	domainService    *service.DomainService
 	deployService    *service.DeployService
 	updateService    *service.UpdateService
+	gitCredService   *service.GitCredentialService
+	previewService   *service.PreviewService
 	settingsStore    storage.SettingsRepository
 	containerRuntime nebulacontainer.ContainerRuntime
 	containerStore   storage.ContainerRepository
@@ -55,6 +57,8 @@ func NewServer(
 	domainService *service.DomainService,
 	deployService *service.DeployService,
 	updateService *service.UpdateService,
+	gitCredService *service.GitCredentialService,
+	previewService *service.PreviewService,
 	settingsStore storage.SettingsRepository,
 	containerRuntime nebulacontainer.ContainerRuntime,
 	containerStore storage.ContainerRepository,
@@ -75,6 +79,8 @@ func NewServer(
 		domainService:    domainService,
 		deployService:    deployService,
 		updateService:    updateService,
+		gitCredService:   gitCredService,
+		previewService:   previewService,
 		settingsStore:    settingsStore,
 		containerRuntime: containerRuntime,
 		containerStore:   containerStore,
@@ -140,6 +146,14 @@ func (s *Server) setupRoutes() {
 	v1.POST("/auth/login", authHandler.Login)
 	v1.POST("/auth/refresh", authHandler.Refresh)
 
+	// Git webhooks (verified with the project's webhook secret)
+	previewHandler := handler.NewPreviewHandler(s.previewService, s.log)
+	v1.POST("/webhooks/git/:project", previewHandler.Webhook)
+
+	// On-demand TLS approval, asked by Caddy before obtaining a certificate
+	domainHandler := handler.NewDomainHandler(s.domainService, s.log)
+	v1.GET("/tls/ask", domainHandler.AskCertificate)
+
 	// Protected routes
 	protected := v1.Group("")
 	protected.Use(middleware.Auth(s.config.JWTSecret))
@@ -161,6 +175,9 @@ func (s *Server) setupRoutes() {
 	protected.GET("/projects/:id", appHandler.Get)
 	protected.PUT("/projects/:id", appHandler.Update)
 	protected.DELETE("/projects/:id", appHandler.Delete)
+	protected.GET("/projects/:id/environments", appHandler.ListEnvironments)
+	protected.POST("/projects/:id/environments", appHandler.CreateEnvironment)
+	protected.DELETE("/projects/:id/environments/:env", appHandler.DeleteEnvironment)
 
 	// Service routes
 	serviceHandler := handler.NewServiceHandler(s.serviceService, s.log)
@@ -169,16 +186,38 @@ func (s *Server) setupRoutes() {
 	protected.GET("/projects/:id/services/:serviceName", serviceHandler.Get)
 	protected.PUT("/projects/:id/services/:serviceName", serviceHandler.Update)
 	protected.DELETE("/projects/:id/services/:serviceName", serviceHandler.Delete)
+	protected.DELETE("/projects/:id/services/:serviceName/build-cache", serviceHandler.ClearBuildCache)
 	protected.GET("/services/:serviceId", serviceHandler.GetByID)
 
+	// Git credential routes
+	gitCredentialHandler := handler.NewGitCredentialHandler(s.gitCredService, s.log)
+	protected.GET("/projects/:id/git-credentials", gitCredentialHandler.List)
+	protected.POST("/projects/:id/git-credentials", gitCredentialHandler.Create)
+	protected.GET("/git-credentials/:credentialId", gitCredentialHandler.Get)
+	protected.PUT("/git-credentials/:credentialId", gitCredentialHandler.Update)
+	protected.DELETE("/git-credentials/:credentialId", gitCredentialHandler.Delete)
+	protected.POST("/git-credentials/:credentialId/known-hosts/scan", gitCredentialHandler.ScanKnownHosts)
+
 	// Domain routes
-	domainHandler := handler.NewDomainHandler(s.domainService, s.log)
 	protected.GET("/projects/:id/domains", domainHandler.ListByProject)
 	protected.GET("/projects/:id/services/:serviceName/domains", domainHandler.ListByService)
 	protected.POST("/projects/:id/services/:serviceName/domains", domainHandler.Create)
 	protected.GET("/domains/:domain", domainHandler.Get)
 	protected.PUT("/domains/:domain", domainHandler.Update)
 	protected.DELETE("/domains/:domain", domainHandler.Delete)
+	protected.GET("/domains/:domain/rules", domainHandler.GetRules)
+	protected.PUT("/domains/:domain/rules", domainHandler.UpdateRules)
+	protected.GET("/domains/:domain/access", domainHandler.GetAccess)
+	protected.PUT("/domains/:domain/access", domainHandler.UpdateAccess)
+	protected.GET("/domains/:domain/headers", domainHandler.GetHeaders)
+	protected.PUT("/domains/:domain/headers", domainHandler.UpdateHeaders)
+	protected.GET("/domains/:domain/certificate", domainHandler.GetCertificate)
+	protected.PUT("/domains/:domain/certificate", domainHandler.UploadCertificate)
+	protected.DELETE("/domains/:domain/certificate", domainHandler.DeleteCertificate)
+	protected.GET("/projects/:id/tls-allowlist", domainHandler.GetTLSAllowlist)
+	protected.PUT("/projects/:id/tls-allowlist", domainHandler.UpdateTLSAllowlist)
+	protected.GET("/proxy/routes/diff", domainHandler.DiffRoutes)
+	protected.POST("/proxy/routes/sync", domainHandler.SyncRoutes)
 
 	// Deployment routes
 	deployHandler := handler.NewDeployHandler(s.deployService, s.log)
@@ -190,6 +229,30 @@ func (s *Server) setupRoutes() {
 	// Service deployment routes
 	protected.POST("/projects/:id/services/:serviceName/deploy", deployHandler.DeployService)
 	protected.GET("/projects/:id/services/:serviceName/deployments", deployHandler.ListServiceDeployments)
+	protected.POST("/projects/:id/services/:serviceName/rollback", deployHandler.RollbackService)
+	protected.POST("/projects/:id/services/:serviceName/canary/promote", deployHandler.PromoteCanary)
+	protected.POST("/projects/:id/services/:serviceName/canary/abort", deployHandler.AbortCanary)
+	protected.POST("/projects/:id/deploy", deployHandler.DeployProject)
+	protected.POST("/projects/:id/promote", deployHandler.PromoteEnvironment)
+	protected.GET("/projects/:id/releases", deployHandler.ListReleases)
+	protected.GET("/projects/:id/releases/:rid", deployHandler.GetRelease)
+	protected.GET("/deployments/:did/diff/:other", deployHandler.DiffDeployments)
+
+	// Deploy guardrails: approvals, freeze windows and their audit log
+	approve := middleware.RequirePermission(middleware.PermissionApproveDeployments)
+	protected.POST("/deployments/:did/approve", approve, deployHandler.ApproveDeployment)
+	protected.POST("/deployments/:did/reject", approve, deployHandler.RejectDeployment)
+	protected.GET("/projects/:id/freeze-windows", deployHandler.ListFreezeWindows)
+	protected.POST("/projects/:id/freeze-windows", deployHandler.CreateFreezeWindow)
+	protected.DELETE("/projects/:id/freeze-windows/:wid", deployHandler.DeleteFreezeWindow)
+	protected.GET("/projects/:id/audit", deployHandler.ListAuditLog)
+
+	// Pull request previews
+	protected.GET("/projects/:id/preview-config", previewHandler.GetConfig)
+	protected.PUT("/projects/:id/preview-config", previewHandler.UpdateConfig)
+	protected.GET("/projects/:id/previews", previewHandler.List)
+	protected.DELETE("/projects/:id/previews/:number", previewHandler.Delete)
+	protected.POST("/projects/:id/previews/cleanup", previewHandler.Cleanup)
 
 	// Log routes
 	logHandler := handler.NewLogHandler(s.containerRuntime, s.containerStore, s.deploymentStore, s.log)
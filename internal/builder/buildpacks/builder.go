@@ -89,6 +89,26 @@ func (b *Builder) Build(ctx context.Context, buildCtx *builder.BuildContext) (*b
 		args = append(args, "--env", fmt.Sprintf("PORT=%d", buildCtx.Port))
 	}
 
+	// Keep the build cache in a volume owned by the service
+	if buildCtx.CacheKey != "" {
+		args = append(args, "--cache", fmt.Sprintf("type=build;format=volume;name=%s", CacheVolume(buildCtx.CacheKey)))
+	}
+	if buildCtx.NoCache {
+		args = append(args, "--clear-cache")
+	}
+
+	// Secrets go through a private env file rather than the command line,
+	// where other users could read them; build-time env is not exported to
+	// the image
+	if len(buildCtx.Secrets) > 0 {
+		envFile, cleanup, err := writeEnvFile(buildCtx.Secrets)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		args = append(args, "--env-file", envFile)
+	}
+
 	b.log.Debug("running pack build", "args", args)
 
 	cmd := exec.CommandContext(ctx, "pack", args...)
@@ -192,3 +212,33 @@ func (b *Builder) detectPort(sourceDir string) int {
 
 	return 8080 // Default
 }
+
+// writeEnvFile writes variables to a KEY=VALUE file readable only by the
+// current user, removed by cleanup
+func writeEnvFile(vars map[string]string) (string, func(), error) {
+	var b strings.Builder
+	for key, val := range vars {
+		if strings.ContainsAny(val, "\r\n") {
+			return "", nil, fmt.Errorf("build secret %s spans several lines, which pack env files cannot hold", key)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, val)
+	}
+
+	dir, err := os.MkdirTemp("", "nebula-pack-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create env file directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	path := filepath.Join(dir, "secrets.env")
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write env file: %w", err)
+	}
+	return path, cleanup, nil
+}
+
+// CacheVolume returns the volume holding the build cache of a cache key
+func CacheVolume(cacheKey string) string {
+	return "nebula-cache-" + cacheKey
+}
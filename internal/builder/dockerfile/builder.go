@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/victalejo/nebula/internal/container"
 	"github.com/victalejo/nebula/internal/core/builder"
@@ -52,7 +54,15 @@ func (b *Builder) Build(ctx context.Context, buildCtx *builder.BuildContext) (*b
 		imageName = fmt.Sprintf("%s:%s", buildCtx.ImageName, buildCtx.ImageTag)
 	}
 
-	imageID, buildOutput, err := b.runtime.BuildImage(ctx, sourceDir, imageName)
+	imageID, buildOutput, err := b.runtime.BuildImage(ctx, &container.BuildRequest{
+		ContextDir: sourceDir,
+		Dockerfile: dockerfilePath,
+		ImageName:  imageName,
+		BuildArgs:  buildCtx.BuildArgs,
+		Secrets:    buildCtx.Secrets,
+		CacheFrom:  buildCtx.CacheFrom,
+		NoCache:    buildCtx.NoCache,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("docker build failed: %w\n%s", err, buildOutput)
 	}
@@ -81,37 +91,41 @@ func (b *Builder) Detect(ctx context.Context, sourceDir string) (bool, int) {
 	return false, 0
 }
 
-// detectPortFromDockerfile tries to detect exposed port from Dockerfile
+// detectPortFromDockerfile returns the first port exposed by the final build
+// stage of the Dockerfile, falling back to 8080
 func detectPortFromDockerfile(dockerfilePath string) int {
 	content, err := os.ReadFile(dockerfilePath)
 	if err != nil {
 		return 8080
 	}
 
-	// Simple EXPOSE detection
-	// In a real implementation, we'd parse the Dockerfile properly
-	contentStr := string(content)
-	ports := []int{8080, 3000, 5000, 80, 443}
-	for _, port := range ports {
-		if contains(contentStr, fmt.Sprintf("EXPOSE %d", port)) {
-			return port
+	port := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
 		}
-	}
-
-	return 8080
-}
-
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsHelper(s, substr))
-}
 
-func containsHelper(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
+		switch strings.ToUpper(fields[0]) {
+		case "FROM":
+			// A new stage starts; only the final stage's EXPOSE matters
+			port = 0
+		case "EXPOSE":
+			if port != 0 || len(fields) < 2 {
+				continue
+			}
+			// EXPOSE 8080/tcp, EXPOSE 3000 3001
+			spec := strings.SplitN(fields[1], "/", 2)
+			if p, err := strconv.Atoi(spec[0]); err == nil && p > 0 && p < 65536 {
+				port = p
+			}
 		}
 	}
-	return false
+
+	if port == 0 {
+		return 8080
+	}
+	return port
 }
 
 func init() {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/victalejo/nebula/internal/container"
@@ -54,7 +55,7 @@ func (b *Builder) Build(ctx context.Context, buildCtx *builder.BuildContext) (*b
 	}
 
 	// Build arguments
-	args := []string{"build", sourceDir, "--name", imageName}
+	args := []string{"build", sourceDir}
 
 	// Add start command if specified
 	if buildCtx.Command != "" {
@@ -66,14 +67,37 @@ func (b *Builder) Build(ctx context.Context, buildCtx *builder.BuildContext) (*b
 		args = append(args, "--env", fmt.Sprintf("%s=%s", key, val))
 	}
 
-	b.log.Debug("running nixpacks", "args", args)
+	// Cache mounts are keyed per service
+	if buildCtx.CacheKey != "" {
+		args = append(args, "--cache-key", buildCtx.CacheKey)
+	}
 
-	cmd := exec.CommandContext(ctx, "nixpacks", args...)
-	cmd.Dir = sourceDir
+	var output string
+	if len(buildCtx.Secrets) > 0 {
+		var err error
+		if output, err = b.buildWithSecrets(ctx, buildCtx, sourceDir, imageName, args); err != nil {
+			return nil, err
+		}
+	} else {
+		// The previous image seeds the layer cache
+		args = append(args, "--name", imageName)
+		if buildCtx.NoCache {
+			args = append(args, "--no-cache")
+		} else if len(buildCtx.CacheFrom) > 0 {
+			args = append(args, "--cache-from", buildCtx.CacheFrom[0])
+		}
+		args = append(args, "--inline-cache")
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("nixpacks build failed: %w\n%s", err, string(output))
+		b.log.Debug("running nixpacks", "args", args)
+
+		cmd := exec.CommandContext(ctx, "nixpacks", args...)
+		cmd.Dir = sourceDir
+
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("nixpacks build failed: %w\n%s", err, string(out))
+		}
+		output = string(out)
 	}
 
 	// Get image ID
@@ -93,11 +117,82 @@ func (b *Builder) Build(ctx context.Context, buildCtx *builder.BuildContext) (*b
 		ImageID:   imageID,
 		ImageName: buildCtx.ImageName,
 		ImageTag:  buildCtx.ImageTag,
-		BuildLogs: fmt.Sprintf("Nixpacks build output:\n%s", string(output)),
+		BuildLogs: fmt.Sprintf("Nixpacks build output:\n%s", output),
 		Port:      port,
 	}, nil
 }
 
+// buildWithSecrets has nixpacks write its Dockerfile instead of building it,
+// since values passed with --env become image environment. The build secrets
+// are mounted into each RUN step and the image is built through the runtime,
+// which hands them to BuildKit as private files.
+func (b *Builder) buildWithSecrets(ctx context.Context, buildCtx *builder.BuildContext, sourceDir, imageName string, args []string) (string, error) {
+	args = append(args, "--out", sourceDir)
+
+	b.log.Debug("running nixpacks", "args", args)
+
+	cmd := exec.CommandContext(ctx, "nixpacks", args...)
+	cmd.Dir = sourceDir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("nixpacks plan failed: %w\n%s", err, string(output))
+	}
+
+	dockerfilePath := filepath.Join(sourceDir, ".nixpacks", "Dockerfile")
+	dockerfile, err := os.ReadFile(dockerfilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read nixpacks Dockerfile: %w", err)
+	}
+	names := make([]string, 0, len(buildCtx.Secrets))
+	for name := range buildCtx.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if err := os.WriteFile(dockerfilePath, []byte(mountSecrets(string(dockerfile), names)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write nixpacks Dockerfile: %w", err)
+	}
+
+	_, logs, err := b.runtime.BuildImage(ctx, &container.BuildRequest{
+		ContextDir: sourceDir,
+		Dockerfile: dockerfilePath,
+		ImageName:  imageName,
+		Secrets:    buildCtx.Secrets,
+		CacheFrom:  buildCtx.CacheFrom,
+		NoCache:    buildCtx.NoCache,
+	})
+	if err != nil {
+		return "", fmt.Errorf("nixpacks build failed: %w\n%s", err, logs)
+	}
+	return string(output) + logs, nil
+}
+
+// mountSecrets mounts build secrets under /run/secrets in every RUN step of
+// a Dockerfile and exports them for the duration of the step only, so they
+// never land in an image layer
+func mountSecrets(dockerfile string, names []string) string {
+	lines := strings.Split(dockerfile, "\n")
+	for i, line := range lines {
+		rest, ok := strings.CutPrefix(line, "RUN ")
+		if !ok {
+			continue
+		}
+		parts := []string{"RUN"}
+		rest = strings.TrimLeft(rest, " ")
+		for strings.HasPrefix(rest, "--") {
+			flag, remainder, _ := strings.Cut(rest, " ")
+			parts = append(parts, flag)
+			rest = strings.TrimLeft(remainder, " ")
+		}
+		for _, name := range names {
+			parts = append(parts, fmt.Sprintf("--mount=type=secret,id=%s", name))
+		}
+		parts = append(parts, `for s in /run/secrets/*; do export "${s##*/}=$(cat "$s")"; done; `+rest)
+		lines[i] = strings.Join(parts, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Detect checks if nixpacks can build this source
 func (b *Builder) Detect(ctx context.Context, sourceDir string) (bool, int) {
 	// Nixpacks can build most things, give it medium priority
@@ -2,10 +2,12 @@ package railpacks
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/victalejo/nebula/internal/container"
@@ -14,6 +16,9 @@ import (
 	"github.com/victalejo/nebula/internal/core/storage"
 )
 
+// frontendImage is the BuildKit frontend that builds railpacks plans
+const frontendImage = "ghcr.io/railwayapp/railpack-frontend"
+
 // Builder builds images using railpacks (Railway's builder)
 type Builder struct {
 	runtime container.Runtime
@@ -54,7 +59,7 @@ func (b *Builder) Build(ctx context.Context, buildCtx *builder.BuildContext) (*b
 	}
 
 	// Build arguments
-	args := []string{"build", "--name", imageName, sourceDir}
+	args := []string{sourceDir}
 
 	// Add start command if specified
 	if buildCtx.Command != "" {
@@ -66,14 +71,25 @@ func (b *Builder) Build(ctx context.Context, buildCtx *builder.BuildContext) (*b
 		args = append(args, "--env", fmt.Sprintf("%s=%s", key, val))
 	}
 
-	b.log.Debug("running railpacks", "args", args)
+	var output string
+	if len(buildCtx.Secrets) > 0 {
+		var err error
+		if output, err = b.buildWithSecrets(ctx, buildCtx, sourceDir, imageName, args); err != nil {
+			return nil, err
+		}
+	} else {
+		args = append([]string{"build", "--name", imageName}, args...)
 
-	cmd := exec.CommandContext(ctx, "railpacks", args...)
-	cmd.Dir = sourceDir
+		b.log.Debug("running railpacks", "args", args)
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("railpacks build failed: %w\n%s", err, string(output))
+		cmd := exec.CommandContext(ctx, "railpacks", args...)
+		cmd.Dir = sourceDir
+
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("railpacks build failed: %w\n%s", err, string(out))
+		}
+		output = string(out)
 	}
 
 	// Get image ID
@@ -93,11 +109,67 @@ func (b *Builder) Build(ctx context.Context, buildCtx *builder.BuildContext) (*b
 		ImageID:   imageID,
 		ImageName: buildCtx.ImageName,
 		ImageTag:  buildCtx.ImageTag,
-		BuildLogs: fmt.Sprintf("Railpacks build output:\n%s", string(output)),
+		BuildLogs: fmt.Sprintf("Railpacks build output:\n%s", output),
 		Port:      port,
 	}, nil
 }
 
+// buildWithSecrets has railpacks write its build plan instead of building
+// it, so secret values stay off the command line. The plan lists the secret
+// names and is built through the runtime with the railpack frontend, which
+// receives the values from BuildKit as private files.
+func (b *Builder) buildWithSecrets(ctx context.Context, buildCtx *builder.BuildContext, sourceDir, imageName string, args []string) (string, error) {
+	planPath := filepath.Join(sourceDir, "railpack-plan.json")
+	args = append(append([]string{"prepare"}, args...), "--plan-out", planPath)
+
+	b.log.Debug("running railpacks", "args", args)
+
+	cmd := exec.CommandContext(ctx, "railpacks", args...)
+	cmd.Dir = sourceDir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("railpacks prepare failed: %w\n%s", err, string(output))
+	}
+
+	data, err := os.ReadFile(planPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read railpacks plan: %w", err)
+	}
+	plan := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return "", fmt.Errorf("failed to decode railpacks plan: %w", err)
+	}
+	names := make([]string, 0, len(buildCtx.Secrets))
+	for name := range buildCtx.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if plan["secrets"], err = json.Marshal(names); err != nil {
+		return "", err
+	}
+	if data, err = json.Marshal(plan); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(planPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write railpacks plan: %w", err)
+	}
+
+	_, logs, err := b.runtime.BuildImage(ctx, &container.BuildRequest{
+		ContextDir: sourceDir,
+		Dockerfile: planPath,
+		ImageName:  imageName,
+		BuildArgs:  map[string]string{"BUILDKIT_SYNTAX": frontendImage},
+		Secrets:    buildCtx.Secrets,
+		CacheFrom:  buildCtx.CacheFrom,
+		NoCache:    buildCtx.NoCache,
+	})
+	if err != nil {
+		return "", fmt.Errorf("railpacks build failed: %w\n%s", err, logs)
+	}
+	return string(output) + logs, nil
+}
+
 // Detect checks if railpacks can build this source
 func (b *Builder) Detect(ctx context.Context, sourceDir string) (bool, int) {
 	// Railpacks is similar to nixpacks, give it slightly lower priority
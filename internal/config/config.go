@@ -11,7 +11,7 @@ type Config struct {
 	Server   ServerConfig   `mapstructure:"server"`
 	Database DatabaseConfig `mapstructure:"database"`
 	Docker   DockerConfig   `mapstructure:"docker"`
-	Caddy    CaddyConfig    `mapstructure:"caddy"`
+	Proxy    ProxyConfig    `mapstructure:"proxy"`
 	Log      LogConfig      `mapstructure:"log"`
 	Auth     AuthConfig     `mapstructure:"auth"`
 	Update   UpdateConfig   `mapstructure:"update"`
@@ -21,6 +21,10 @@ type Config struct {
 type ServerConfig struct {
 	Host string `mapstructure:"host"`
 	Port int    `mapstructure:"port"`
+
+	// Container is Nebula's own container, empty when it runs on the host.
+	// It joins the networks of environments to health check their apps.
+	Container string `mapstructure:"container"`
 }
 
 // DatabaseConfig holds database configuration
@@ -33,12 +37,89 @@ type DockerConfig struct {
 	Host       string `mapstructure:"host"`
 	APIVersion string `mapstructure:"api_version"`
 	Network    string `mapstructure:"network"`
+
+	// PublishPorts publishes app ports on 127.0.0.1 and routes to them, for
+	// a proxy running on the host instead of in a container
+	PublishPorts bool `mapstructure:"publish_ports"`
+}
+
+// ProxyConfig selects and configures the reverse proxy
+type ProxyConfig struct {
+	Type      string `mapstructure:"type"`      // "caddy", "traefik" or "nginx"
+	Network   string `mapstructure:"network"`   // network the proxy shares with the apps
+	Container string `mapstructure:"container"` // proxy's container, empty when it runs on the host
+
+	Caddy   CaddyConfig   `mapstructure:"caddy"`
+	Traefik TraefikConfig `mapstructure:"traefik"`
+	Nginx   NginxConfig   `mapstructure:"nginx"`
 }
 
 // CaddyConfig holds Caddy proxy configuration
 type CaddyConfig struct {
-	AdminAPI string `mapstructure:"admin_api"`
-	Network  string `mapstructure:"network"`
+	AdminAPI string     `mapstructure:"admin_api"`
+	ACME     ACMEConfig `mapstructure:"acme"`
+}
+
+// ACMEConfig configures how Caddy obtains certificates
+type ACMEConfig struct {
+	CA     string    `mapstructure:"ca"`      // ACME directory URL, empty for Caddy's defaults
+	CARoot string    `mapstructure:"ca_root"` // PEM root of a private CA such as pebble, as Caddy sees it
+	Email  string    `mapstructure:"email"`
+	DNS    DNSConfig `mapstructure:"dns"` // required by wildcard domains
+
+	// OnDemandAsk is Nebula's /api/v1/tls/ask URL as Caddy reaches it, such
+	// as http://nebula:8080/api/v1/tls/ask. Setting it obtains certificates
+	// at the first TLS handshake for a host, once Nebula approves the host.
+	OnDemandAsk string `mapstructure:"on_demand_ask"`
+}
+
+// DNSConfig selects the DNS provider solving DNS-01 challenges. Caddy must
+// be built with the provider's module.
+type DNSConfig struct {
+	Provider   string              `mapstructure:"provider"`  // "cloudflare", "route53" or "rfc2136"
+	Resolvers  []string            `mapstructure:"resolvers"` // DNS servers checked for propagation
+	Cloudflare CloudflareDNSConfig `mapstructure:"cloudflare"`
+	Route53    Route53DNSConfig    `mapstructure:"route53"`
+	RFC2136    RFC2136DNSConfig    `mapstructure:"rfc2136"`
+}
+
+// CloudflareDNSConfig holds Cloudflare credentials
+type CloudflareDNSConfig struct {
+	APIToken string `mapstructure:"api_token"` // Zone.DNS edit permission
+}
+
+// Route53DNSConfig holds Route53 credentials; without keys the AWS default
+// credential chain is used
+type Route53DNSConfig struct {
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	Region          string `mapstructure:"region"`
+	HostedZoneID    string `mapstructure:"hosted_zone_id"`
+}
+
+// RFC2136DNSConfig holds the dynamic DNS update server and its TSIG key
+type RFC2136DNSConfig struct {
+	Server  string `mapstructure:"server"`   // host:port
+	KeyName string `mapstructure:"key_name"` // TSIG key name
+	KeyAlg  string `mapstructure:"key_alg"`  // e.g. hmac-sha256
+	Key     string `mapstructure:"key"`      // base64 TSIG secret
+}
+
+// TraefikConfig holds Traefik proxy configuration
+type TraefikConfig struct {
+	ConfigFile      string `mapstructure:"config_file"`      // dynamic configuration watched by the file provider
+	APIURL          string `mapstructure:"api_url"`          // optional, for health checks
+	HTTPEntrypoint  string `mapstructure:"http_entrypoint"`  // entrypoint serving plain HTTP
+	HTTPSEntrypoint string `mapstructure:"https_entrypoint"` // entrypoint serving TLS
+	CertResolver    string `mapstructure:"cert_resolver"`    // ACME resolver for TLS routes
+}
+
+// NginxConfig holds nginx proxy configuration
+type NginxConfig struct {
+	ConfigFile    string `mapstructure:"config_file"`    // server configuration included by nginx.conf
+	CertDir       string `mapstructure:"cert_dir"`       // <cert_dir>/<domain>/fullchain.pem and privkey.pem
+	TestCommand   string `mapstructure:"test_command"`   // validates the configuration
+	ReloadCommand string `mapstructure:"reload_command"` // applies the configuration
 }
 
 // LogConfig holds logging configuration
@@ -53,6 +134,7 @@ type AuthConfig struct {
 	TokenDuration int    `mapstructure:"token_duration"` // in hours
 	AdminUsername string `mapstructure:"admin_username"`
 	AdminPassword string `mapstructure:"admin_password"`
+	EncryptionKey string `mapstructure:"encryption_key"` // encrypts stored secrets, defaults to a key kept next to the database
 }
 
 // UpdateConfig holds auto-update configuration
@@ -71,8 +153,14 @@ func Load(configPath string) (*Config, error) {
 	v.SetDefault("database.path", "./data/nebula.db")
 	v.SetDefault("docker.host", "unix:///var/run/docker.sock")
 	v.SetDefault("docker.network", "nebula-network")
-	v.SetDefault("caddy.admin_api", "http://localhost:2019")
-	v.SetDefault("caddy.network", "web")
+	v.SetDefault("proxy.type", "caddy")
+	v.SetDefault("proxy.traefik.config_file", "/etc/traefik/dynamic/nebula.yml")
+	v.SetDefault("proxy.traefik.http_entrypoint", "web")
+	v.SetDefault("proxy.traefik.https_entrypoint", "websecure")
+	v.SetDefault("proxy.nginx.config_file", "/etc/nginx/conf.d/nebula.conf")
+	v.SetDefault("proxy.nginx.cert_dir", "/etc/letsencrypt/live")
+	v.SetDefault("proxy.nginx.test_command", "nginx -t")
+	v.SetDefault("proxy.nginx.reload_command", "nginx -s reload")
 	v.SetDefault("log.level", "info")
 	v.SetDefault("log.format", "json")
 	v.SetDefault("auth.token_duration", 24)
@@ -104,6 +192,49 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
+	// The proxy section replaced the caddy section; configs and environments
+	// written for it keep working
+	legacyCaddy := v.IsSet("caddy")
+	for key, legacy := range map[string]string{
+		"proxy.caddy.admin_api": "caddy.admin_api",
+		"proxy.network":         "caddy.network",
+		"proxy.container":       "caddy.container",
+	} {
+		_ = v.BindEnv(key)
+		_ = v.BindEnv(legacy)
+		if v.IsSet(legacy) {
+			legacyCaddy = true
+			if !v.IsSet(key) {
+				v.Set(key, v.Get(legacy))
+			}
+		}
+	}
+	v.SetDefault("proxy.caddy.admin_api", "http://localhost:2019")
+
+	// DNS provider credentials and the on-demand ask URL can come from the
+	// environment alone
+	for _, key := range []string{
+		"proxy.caddy.acme.on_demand_ask",
+		"proxy.caddy.acme.dns.cloudflare.api_token",
+		"proxy.caddy.acme.dns.route53.access_key_id",
+		"proxy.caddy.acme.dns.route53.secret_access_key",
+		"proxy.caddy.acme.dns.rfc2136.key",
+	} {
+		_ = v.BindEnv(key)
+	}
+	_ = v.BindEnv("server.container")
+	_ = v.BindEnv("docker.publish_ports")
+	v.SetDefault("proxy.network", "nebula-network")
+	if v.GetString("proxy.type") == "caddy" {
+		if legacyCaddy && !v.IsSet("proxy.container") {
+			// Configs written for the caddy section run Caddy on the host,
+			// which reaches apps through their published ports
+			v.SetDefault("docker.publish_ports", true)
+		} else {
+			v.SetDefault("proxy.container", "nebula-caddy")
+		}
+	}
+
 	var config Config
 	if err := v.Unmarshal(&config); err != nil {
 		return nil, err
@@ -126,9 +257,24 @@ func LoadDefault() *Config {
 			Host:    "unix:///var/run/docker.sock",
 			Network: "nebula-network",
 		},
-		Caddy: CaddyConfig{
-			AdminAPI: "http://localhost:2019",
-			Network:  "web",
+		Proxy: ProxyConfig{
+			Type:      "caddy",
+			Network:   "nebula-network",
+			Container: "nebula-caddy",
+			Caddy: CaddyConfig{
+				AdminAPI: "http://localhost:2019",
+			},
+			Traefik: TraefikConfig{
+				ConfigFile:      "/etc/traefik/dynamic/nebula.yml",
+				HTTPEntrypoint:  "web",
+				HTTPSEntrypoint: "websecure",
+			},
+			Nginx: NginxConfig{
+				ConfigFile:    "/etc/nginx/conf.d/nebula.conf",
+				CertDir:       "/etc/letsencrypt/live",
+				TestCommand:   "nginx -t",
+				ReloadCommand: "nginx -s reload",
+			},
 		},
 		Log: LogConfig{
 			Level:  "info",
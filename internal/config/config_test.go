@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// load reads a config file with the given contents
+func load(t *testing.T, contents string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return cfg
+}
+
+func TestLoadKeepsLegacyCaddyInstallsOnPublishedPorts(t *testing.T) {
+	cfg := load(t, `
+caddy:
+  admin_api: http://localhost:2019
+`)
+	if cfg.Proxy.Container != "" {
+		t.Errorf("proxy container = %q, want none for a host Caddy", cfg.Proxy.Container)
+	}
+	if !cfg.Docker.PublishPorts {
+		t.Error("ports are not published for a host Caddy")
+	}
+	if cfg.Proxy.Caddy.AdminAPI != "http://localhost:2019" {
+		t.Errorf("admin API = %q, want the legacy setting", cfg.Proxy.Caddy.AdminAPI)
+	}
+
+	// As written by older install scripts
+	cfg = load(t, `
+caddy:
+  admin_url: http://localhost:2019
+`)
+	if cfg.Proxy.Container != "" || !cfg.Docker.PublishPorts {
+		t.Errorf("proxy container = %q, publish ports = %v; want a host Caddy", cfg.Proxy.Container, cfg.Docker.PublishPorts)
+	}
+
+	// Settings of their own still win
+	cfg = load(t, `
+docker:
+  publish_ports: false
+caddy:
+  admin_api: http://localhost:2019
+`)
+	if cfg.Docker.PublishPorts {
+		t.Error("publish_ports: false was overridden")
+	}
+}
+
+func TestLoadDefaultsToTheCaddyContainer(t *testing.T) {
+	cfg := load(t, `
+proxy:
+  caddy:
+    admin_api: http://caddy:2019
+`)
+	if cfg.Proxy.Container != "nebula-caddy" {
+		t.Errorf("proxy container = %q, want nebula-caddy", cfg.Proxy.Container)
+	}
+	if cfg.Docker.PublishPorts {
+		t.Error("ports are published for a containerized Caddy")
+	}
+}
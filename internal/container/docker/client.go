@@ -6,6 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
@@ -16,6 +21,7 @@ import (
 	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 
 	nebulacontainer "github.com/victalejo/nebula/internal/core/container"
@@ -23,7 +29,8 @@ import (
 
 // Client wraps the Docker SDK client
 type Client struct {
-	cli *client.Client
+	cli  *client.Client
+	host string
 }
 
 // NewClient creates a new Docker client
@@ -42,7 +49,7 @@ func NewClient(host string) (*Client, error) {
 		return nil, fmt.Errorf("failed to create docker client: %w", err)
 	}
 
-	return &Client{cli: cli}, nil
+	return &Client{cli: cli, host: host}, nil
 }
 
 // Ping checks Docker daemon connectivity
@@ -78,10 +85,82 @@ func (c *Client) PullImage(ctx context.Context, ref string, auth *nebulacontaine
 	return err
 }
 
-// BuildImage builds an image from a Dockerfile
+// BuildImage builds an image from a Dockerfile.
+// Builds run through the docker CLI with BuildKit enabled, since cache mounts,
+// secrets and cache imports need a BuildKit session that the SDK does not provide.
 func (c *Client) BuildImage(ctx context.Context, opts nebulacontainer.BuildOptions) (string, error) {
-	// TODO: Implement build functionality
-	return "", fmt.Errorf("build not implemented yet")
+	// Private (0700) directory for the image ID file and build secrets
+	tmpDir, err := os.MkdirTemp("", "nebula-build-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	iidFile := filepath.Join(tmpDir, "iid")
+
+	args := []string{"build", "--progress=plain", "--iidfile", iidFile}
+	if opts.DockerfilePath != "" {
+		args = append(args, "--file", opts.DockerfilePath)
+	}
+	for _, tag := range opts.Tags {
+		args = append(args, "--tag", tag)
+	}
+	for _, key := range sortedKeys(opts.BuildArgs) {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, opts.BuildArgs[key]))
+	}
+	for _, key := range sortedKeys(opts.Secrets) {
+		// Secrets are handed to BuildKit through private files, never as build args
+		secretFile := filepath.Join(tmpDir, "secret-"+key)
+		if err := os.WriteFile(secretFile, []byte(opts.Secrets[key]), 0600); err != nil {
+			return "", fmt.Errorf("failed to write build secret %s: %w", key, err)
+		}
+		args = append(args, "--secret", fmt.Sprintf("id=%s,src=%s", key, secretFile))
+	}
+	for _, key := range sortedKeys(opts.Labels) {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", key, opts.Labels[key]))
+	}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	} else {
+		for _, ref := range opts.CacheFrom {
+			args = append(args, "--cache-from", ref)
+		}
+	}
+	if opts.InlineCache {
+		args = append(args, "--build-arg", "BUILDKIT_INLINE_CACHE=1")
+	}
+	args = append(args, opts.ContextPath)
+
+	output := opts.Output
+	if output == nil {
+		output = io.Discard
+	}
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+	if c.host != "" {
+		cmd.Env = append(cmd.Env, "DOCKER_HOST="+c.host)
+	}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker build failed: %w", err)
+	}
+
+	imageID, err := os.ReadFile(iidFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image ID: %w", err)
+	}
+	return strings.TrimSpace(string(imageID)), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // ListImages lists all images
@@ -103,12 +182,34 @@ func (c *Client) ListImages(ctx context.Context) ([]nebulacontainer.Image, error
 	return result, nil
 }
 
+// InspectImage returns details of a local image
+func (c *Client) InspectImage(ctx context.Context, ref string) (*nebulacontainer.Image, error) {
+	info, err := c.cli.ImageInspect(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	created, _ := time.Parse(time.RFC3339Nano, info.Created)
+	return &nebulacontainer.Image{
+		ID:          info.ID,
+		Tags:        info.RepoTags,
+		RepoDigests: info.RepoDigests,
+		Size:        info.Size,
+		Created:     created,
+	}, nil
+}
+
 // RemoveImage removes an image
 func (c *Client) RemoveImage(ctx context.Context, id string) error {
 	_, err := c.cli.ImageRemove(ctx, id, image.RemoveOptions{})
 	return err
 }
 
+// TagImage adds the target reference to a local image
+func (c *Client) TagImage(ctx context.Context, source, target string) error {
+	return c.cli.ImageTag(ctx, source, target)
+}
+
 // CreateContainer creates a new container
 func (c *Client) CreateContainer(ctx context.Context, config nebulacontainer.ContainerConfig) (string, error) {
 	// Prepare environment variables
@@ -123,9 +224,13 @@ func (c *Client) CreateContainer(ctx context.Context, config nebulacontainer.Con
 	for _, p := range config.Ports {
 		port := nat.Port(fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol))
 		exposedPorts[port] = struct{}{}
+		hostIP := p.HostIP
+		if hostIP == "" {
+			hostIP = "0.0.0.0"
+		}
 		portBindings[port] = []nat.PortBinding{
 			{
-				HostIP:   "0.0.0.0",
+				HostIP:   hostIP,
 				HostPort: fmt.Sprintf("%d", p.HostPort),
 			},
 		}
@@ -262,6 +367,15 @@ func (c *Client) InspectContainer(ctx context.Context, id string) (*nebulacontai
 
 	createdTime, _ := time.Parse(time.RFC3339Nano, info.Created)
 
+	networks := make(map[string]string)
+	if info.NetworkSettings != nil {
+		for name, endpoint := range info.NetworkSettings.Networks {
+			if endpoint != nil {
+				networks[name] = endpoint.IPAddress
+			}
+		}
+	}
+
 	return &nebulacontainer.ContainerInfo{
 		ID:      info.ID,
 		Name:    info.Name,
@@ -272,6 +386,9 @@ func (c *Client) InspectContainer(ctx context.Context, id string) (*nebulacontai
 		Ports:   ports,
 		Labels:  info.Config.Labels,
 		Health:  health,
+
+		RestartCount: info.RestartCount,
+		Networks:     networks,
 	}, nil
 }
 
@@ -368,6 +485,50 @@ func (c *Client) WaitContainer(ctx context.Context, id string) (<-chan nebulacon
 	return resultCh, errCh
 }
 
+// ExecContainer runs a command inside a running container and waits for it to exit
+func (c *Client) ExecContainer(ctx context.Context, id string, cmd []string) (*nebulacontainer.ExecResult, error) {
+	created, err := c.cli.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	attach, err := c.cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	defer attach.Close()
+
+	var output strings.Builder
+	done := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&output, &output, attach.Reader)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exec output: %w", err)
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	inspect, err := c.cli.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+
+	return &nebulacontainer.ExecResult{
+		ExitCode: inspect.ExitCode,
+		Output:   output.String(),
+	}, nil
+}
+
 // CreateNetwork creates a Docker network
 func (c *Client) CreateNetwork(ctx context.Context, name string, opts nebulacontainer.NetworkOptions) (string, error) {
 	// Check if network already exists
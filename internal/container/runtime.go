@@ -2,22 +2,39 @@ package container
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
+
 	core "github.com/victalejo/nebula/internal/core/container"
 )
 
 // Runtime is a simplified interface for services that need container operations
 type Runtime interface {
 	PullImage(ctx context.Context, ref string, auth *core.RegistryAuth) error
-	BuildImage(ctx context.Context, contextDir string, imageName string) (imageID string, buildLogs string, err error)
+	BuildImage(ctx context.Context, req *BuildRequest) (imageID string, buildLogs string, err error)
 	CreateContainer(ctx context.Context, config *ContainerConfig) (string, error)
 	StartContainer(ctx context.Context, id string) error
 	StopContainer(ctx context.Context, id string, timeout time.Duration) error
 	RestartContainer(ctx context.Context, id string, timeout time.Duration) error
 	RemoveContainer(ctx context.Context, id string) error
 	InspectContainer(ctx context.Context, id string) (*ContainerInfo, error)
+	ExecContainer(ctx context.Context, id string, cmd []string) (*core.ExecResult, error)
 	CreateNetwork(ctx context.Context, name string) error
+	RemoveVolume(ctx context.Context, name string) error
+}
+
+// BuildRequest describes an image build
+type BuildRequest struct {
+	ContextDir string
+	Dockerfile string // path to the Dockerfile, defaults to ContextDir/Dockerfile
+	ImageName  string
+	BuildArgs  map[string]string
+	Secrets    map[string]string // mounted with BuildKit secrets, never stored in layers
+	Labels     map[string]string
+	CacheFrom  []string // previous images to reuse layers from
+	NoCache    bool
 }
 
 // ContainerConfig simplified config for services
@@ -37,6 +54,7 @@ type ContainerConfig struct {
 
 type PortMapping struct {
 	HostPort      int
+	HostIP        string // defaults to 0.0.0.0
 	ContainerPort int
 }
 
@@ -54,13 +72,14 @@ type HealthCheck struct {
 }
 
 type ContainerInfo struct {
-	ID      string
-	Name    string
-	Image   string
-	State   string
-	Health  string
-	Ports   []PortMapping
-	Created time.Time
+	ID       string
+	Name     string
+	Image    string
+	State    string
+	Health   string
+	Ports    []PortMapping
+	Networks map[string]string // network name -> container address
+	Created  time.Time
 }
 
 // RuntimeAdapter adapts core.ContainerRuntime to the simplified Runtime interface
@@ -76,12 +95,21 @@ func (a *RuntimeAdapter) PullImage(ctx context.Context, ref string, auth *core.R
 	return a.runtime.PullImage(ctx, ref, auth)
 }
 
-func (a *RuntimeAdapter) BuildImage(ctx context.Context, contextDir string, imageName string) (string, string, error) {
+func (a *RuntimeAdapter) BuildImage(ctx context.Context, req *BuildRequest) (string, string, error) {
+	var logs strings.Builder
 	imageID, err := a.runtime.BuildImage(ctx, core.BuildOptions{
-		ContextPath: contextDir,
-		Tags:        []string{imageName},
+		ContextPath:    req.ContextDir,
+		DockerfilePath: req.Dockerfile,
+		Tags:           []string{req.ImageName},
+		BuildArgs:      req.BuildArgs,
+		Secrets:        req.Secrets,
+		Labels:         req.Labels,
+		NoCache:        req.NoCache,
+		CacheFrom:      req.CacheFrom,
+		InlineCache:    true, // every image can seed the cache of the next build
+		Output:         &logs,
 	})
-	return imageID, "", err
+	return imageID, logs.String(), err
 }
 
 func (a *RuntimeAdapter) CreateContainer(ctx context.Context, config *ContainerConfig) (string, error) {
@@ -101,6 +129,7 @@ func (a *RuntimeAdapter) CreateContainer(ctx context.Context, config *ContainerC
 	for i, p := range config.Ports {
 		ports[i] = core.PortBinding{
 			HostPort:      p.HostPort,
+			HostIP:        p.HostIP,
 			ContainerPort: p.ContainerPort,
 			Protocol:      "tcp",
 		}
@@ -184,17 +213,30 @@ func (a *RuntimeAdapter) InspectContainer(ctx context.Context, id string) (*Cont
 	}
 
 	return &ContainerInfo{
-		ID:      info.ID,
-		Name:    info.Name,
-		Image:   info.Image,
-		State:   info.State,
-		Health:  info.Health,
-		Ports:   ports,
-		Created: info.Created,
+		ID:       info.ID,
+		Name:     info.Name,
+		Image:    info.Image,
+		State:    info.State,
+		Health:   info.Health,
+		Ports:    ports,
+		Networks: info.Networks,
+		Created:  info.Created,
 	}, nil
 }
 
+func (a *RuntimeAdapter) ExecContainer(ctx context.Context, id string, cmd []string) (*core.ExecResult, error) {
+	return a.runtime.ExecContainer(ctx, id, cmd)
+}
+
 func (a *RuntimeAdapter) CreateNetwork(ctx context.Context, name string) error {
 	_, err := a.runtime.CreateNetwork(ctx, name, core.NetworkOptions{})
 	return err
 }
+
+// RemoveVolume removes a volume; a volume that does not exist is not an error
+func (a *RuntimeAdapter) RemoveVolume(ctx context.Context, name string) error {
+	if err := a.runtime.RemoveVolume(ctx, name); err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -28,6 +28,12 @@ type BuildContext struct {
 	Port      int
 	Command   string
 	BuildArgs map[string]string
+	Secrets   map[string]string // build-time secrets, never written to image layers
+
+	// Cache configuration
+	CacheKey  string   // identifies the service's persistent caches (cache mounts, volumes)
+	CacheFrom []string // previous images whose layers may be reused
+	NoCache   bool     // ignore all caches for this build
 
 	// Output
 	ImageName string
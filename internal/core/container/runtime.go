@@ -12,7 +12,9 @@ type ContainerRuntime interface {
 	PullImage(ctx context.Context, ref string, auth *RegistryAuth) error
 	BuildImage(ctx context.Context, opts BuildOptions) (string, error)
 	ListImages(ctx context.Context) ([]Image, error)
+	InspectImage(ctx context.Context, ref string) (*Image, error)
 	RemoveImage(ctx context.Context, id string) error
+	TagImage(ctx context.Context, source, target string) error
 
 	// Container operations
 	CreateContainer(ctx context.Context, config ContainerConfig) (string, error)
@@ -24,6 +26,7 @@ type ContainerRuntime interface {
 	ListContainers(ctx context.Context, filter ContainerFilter) ([]ContainerInfo, error)
 	ContainerLogs(ctx context.Context, id string, opts LogOptions) (io.ReadCloser, error)
 	WaitContainer(ctx context.Context, id string) (<-chan WaitResult, <-chan error)
+	ExecContainer(ctx context.Context, id string, cmd []string) (*ExecResult, error)
 
 	// Network operations
 	CreateNetwork(ctx context.Context, name string, opts NetworkOptions) (string, error)
@@ -53,15 +56,27 @@ type BuildOptions struct {
 	DockerfilePath string
 	Tags           []string
 	BuildArgs      map[string]string
+	Secrets        map[string]string // exposed to RUN --mount=type=secret,id=<name>
+	Labels         map[string]string
 	NoCache        bool
+	CacheFrom      []string  // images whose layers may be reused
+	InlineCache    bool      // embed cache metadata so the image can serve as CacheFrom
+	Output         io.Writer // receives build progress output (optional)
+}
+
+// ExecResult is the outcome of a command run inside a container
+type ExecResult struct {
+	ExitCode int
+	Output   string // combined stdout and stderr
 }
 
 // Image represents a Docker image
 type Image struct {
-	ID      string
-	Tags    []string
-	Size    int64
-	Created time.Time
+	ID          string
+	Tags        []string
+	RepoDigests []string // e.g. nginx@sha256:..., set for pulled images
+	Size        int64
+	Created     time.Time
 }
 
 // ContainerConfig for creating containers
@@ -83,6 +98,7 @@ type ContainerConfig struct {
 type PortBinding struct {
 	ContainerPort int
 	HostPort      int
+	HostIP        string // interface the host port is bound on, defaults to 0.0.0.0
 	Protocol      string // tcp, udp
 }
 
@@ -119,6 +135,12 @@ type ContainerInfo struct {
 	Ports   []PortBinding
 	Labels  map[string]string
 	Health  string
+
+	// RestartCount is the number of times the runtime restarted the container
+	RestartCount int
+
+	// Networks maps the networks the container joined to its address on each
+	Networks map[string]string
 }
 
 // ContainerFilter for listing containers
@@ -2,8 +2,12 @@ package deployer
 
 import (
 	"context"
+	"time"
 )
 
+// DefaultStopGracePeriod is how long stopped containers get to exit after SIGTERM
+const DefaultStopGracePeriod = 30 * time.Second
+
 // Deployer is the core interface all deployment strategies must implement
 type Deployer interface {
 	// Mode returns the deployment mode this deployer handles
@@ -21,13 +25,21 @@ type Deployer interface {
 	// HealthCheck verifies the deployment is healthy before switching
 	HealthCheck(ctx context.Context, result *DeploymentResult) (*HealthCheckResult, error)
 
-	// Stop stops containers for a deployment
-	Stop(ctx context.Context, containerIDs []string) error
+	// Stop stops containers for a deployment, sending SIGTERM and killing
+	// them after the grace period (DefaultStopGracePeriod when zero)
+	Stop(ctx context.Context, containerIDs []string, gracePeriod time.Duration) error
 
 	// Destroy completely removes containers and cleanup
 	Destroy(ctx context.Context, containerIDs []string) error
 }
 
+// BuildCacheCleaner is implemented by deployers that keep persistent build
+// caches outside of BuildKit's own garbage collection
+type BuildCacheCleaner interface {
+	// ClearBuildCache removes the caches kept for a cache key
+	ClearBuildCache(ctx context.Context, cacheKey string) error
+}
+
 // Registry manages available deployers
 type Registry interface {
 	Register(deployer Deployer)
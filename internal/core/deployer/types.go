@@ -33,14 +33,22 @@ func (s Slot) Opposite() Slot {
 type DeploymentStatus string
 
 const (
-	StatusPending   DeploymentStatus = "pending"
-	StatusPreparing DeploymentStatus = "preparing"
-	StatusDeploying DeploymentStatus = "deploying"
-	StatusRunning   DeploymentStatus = "running"
-	StatusFailed    DeploymentStatus = "failed"
-	StatusStopped   DeploymentStatus = "stopped"
+	StatusPending          DeploymentStatus = "pending"
+	StatusPreparing        DeploymentStatus = "preparing"
+	StatusDeploying        DeploymentStatus = "deploying"
+	StatusRunning          DeploymentStatus = "running"
+	StatusFailed           DeploymentStatus = "failed"
+	StatusStopped          DeploymentStatus = "stopped"
+	StatusStandby          DeploymentStatus = "standby"           // previous slot, kept running for rollback
+	StatusCanary           DeploymentStatus = "canary"            // receiving a share of traffic during a progressive rollout
+	StatusRolledBack       DeploymentStatus = "rolled_back"       // became unhealthy after going live; traffic returned to the previous slot
+	StatusAwaitingApproval DeploymentStatus = "awaiting_approval" // service requires approval before the deployment starts
+	StatusRejected         DeploymentStatus = "rejected"          // approval was declined
 )
 
+// PullNever is the pull policy of images that are only available locally
+const PullNever = "never"
+
 // Application represents an application in Nebula
 type Application struct {
 	ID             string            `json:"id"`
@@ -73,16 +81,24 @@ type SourceConfig struct {
 	// Git mode
 	GitURL         string            `json:"git_url,omitempty"`
 	GitBranch      string            `json:"git_branch,omitempty"`
-	GitCommit      string            `json:"git_commit,omitempty"`
+	GitCommit      string            `json:"git_commit,omitempty"` // commit that was built, recorded after cloning
+	Builder        string            `json:"builder,omitempty"`
+	GitSubmodules  bool              `json:"git_submodules,omitempty"`
+	GitLFS         bool              `json:"git_lfs,omitempty"`
+	GitDepth       int               `json:"git_depth,omitempty"` // 0 = full history
 	DockerfilePath string            `json:"dockerfile_path,omitempty"`
 	BuildArgs      map[string]string `json:"build_args,omitempty"`
 
 	// Docker Image mode
 	Image        string        `json:"image,omitempty"`
 	RegistryAuth *RegistryAuth `json:"registry_auth,omitempty"`
-	PullPolicy   string        `json:"pull_policy,omitempty"`
+	PullPolicy   string        `json:"pull_policy,omitempty"` // PullNever deploys a local image without pulling
 	Port         int           `json:"port,omitempty"`
 
+	// ImageDigest identifies the exact image that was deployed, recorded
+	// after pulling or building
+	ImageDigest string `json:"image_digest,omitempty"`
+
 	// Docker Compose mode
 	ComposeContent string   `json:"compose_content,omitempty"`
 	Services       []string `json:"services,omitempty"`
@@ -96,6 +112,24 @@ type RegistryAuth struct {
 	Token    string `json:"token,omitempty"`
 }
 
+// GitAuthType represents how the git deployer authenticates when cloning
+type GitAuthType string
+
+const (
+	GitAuthSSHKey GitAuthType = "ssh_key"
+	GitAuthHTTPS  GitAuthType = "https_token"
+)
+
+// GitAuth holds decrypted credentials for cloning a private repository.
+// It is never persisted with the deployment record.
+type GitAuth struct {
+	Type       GitAuthType
+	Username   string
+	Token      string
+	PrivateKey string
+	KnownHosts string
+}
+
 // Route represents a routing configuration
 type Route struct {
 	ID         string `json:"id"`
@@ -107,6 +141,16 @@ type Route struct {
 	GreenPort  int    `json:"green_port,omitempty"`
 }
 
+// HealthCheckType selects how Nebula probes a deployment
+type HealthCheckType string
+
+const (
+	HealthCheckHTTP HealthCheckType = "http" // request a path on the application port
+	HealthCheckTCP  HealthCheckType = "tcp"  // connect to the application port
+	HealthCheckExec HealthCheckType = "exec" // run a command inside the container
+	HealthCheckNone HealthCheckType = "none" // only require the container to be running
+)
+
 // HealthCheckConfig holds custom health check configuration
 type HealthCheckConfig struct {
 	// Test command for Docker health check (e.g., ["CMD", "mysqladmin", "ping"])
@@ -117,6 +161,18 @@ type HealthCheckConfig struct {
 	// Extended timeout for services that take longer to start (e.g., databases)
 	MaxAttempts int
 	Interval    time.Duration
+
+	// Probe run by Nebula from the host (defaults to TCP when Type is empty)
+	Type      HealthCheckType
+	Path      string            // HTTP path, defaults to "/"
+	StatusMin int               // lowest accepted HTTP status, defaults to 200
+	StatusMax int               // highest accepted HTTP status, defaults to 399
+	Headers   map[string]string // extra HTTP request headers
+	Command   []string          // exec command, succeeds on exit code 0
+
+	Timeout     time.Duration // per-probe timeout
+	Retries     int           // failed probes tolerated after the start period
+	StartPeriod time.Duration // failures during this period are not counted
 }
 
 // DeploymentSpec contains all information needed for deployment
@@ -124,6 +180,7 @@ type DeploymentSpec struct {
 	AppID       string
 	AppName     string
 	ServiceID   string
+	ServiceName string
 	App         *Application
 	Source      SourceConfig
 	Environment map[string]string
@@ -134,6 +191,18 @@ type DeploymentSpec struct {
 	// HealthCheck configuration (optional, uses defaults if nil)
 	HealthCheck *HealthCheckConfig
 
+	// GitAuth holds credentials for private repositories (optional)
+	GitAuth *GitAuth
+
+	// BuildCacheKey scopes the persistent build caches of the service
+	BuildCacheKey string
+
+	// BuildSecrets are decrypted build-time secrets (never persisted)
+	BuildSecrets map[string]string
+
+	// Network is the Docker network the containers join (empty = the deployer's default)
+	Network string
+
 	// Convenience accessors (populated from Source)
 	GitRepo     string
 	GitBranch   string
@@ -146,9 +215,30 @@ type DeploymentSpec struct {
 type DeploymentResult struct {
 	DeploymentID string
 	ContainerIDs []string
-	Ports        map[string]int // container name -> exposed port
+	Ports        map[string]int // container name -> published host port (0 = not published)
 	Port         int            // Primary port for single container deployments
+	AppPort      int            // Port the application listens on inside the container
 	Version      string
+
+	// Host is the container name the proxy reaches AppPort at on Network.
+	// It is empty when the port is published on the host instead.
+	Host    string
+	Network string // Docker network the containers joined
+	IP      string // container address on Network, for probes run by Nebula
+
+	// HealthCheck is the health check configuration of this deployment.
+	// Deployers read it in HealthCheck instead of keeping per-deployment
+	// state, so they can run deployments concurrently.
+	HealthCheck *HealthCheckConfig
+}
+
+// Upstream returns the address the proxy routes to: the container on its
+// network, or the port published on the host
+func (r *DeploymentResult) Upstream() (string, int) {
+	if r.Host != "" {
+		return r.Host, r.AppPort
+	}
+	return "localhost", r.Ports["main"]
 }
 
 // PrepareResult contains the result of preparation phase
@@ -156,6 +246,7 @@ type PrepareResult struct {
 	ImageID   string
 	ImageTag  string
 	BuildLogs string
+	Port      int // Port the built application listens on
 }
 
 // HealthCheckResult contains health check results
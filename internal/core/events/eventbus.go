@@ -9,15 +9,19 @@ import (
 type EventType string
 
 const (
-	EventDeploymentStatus EventType = "deployment_status"
-	EventServiceStatus    EventType = "service_status"
+	EventDeploymentStatus  EventType = "deployment_status"
+	EventServiceStatus     EventType = "service_status"
+	EventReleaseStatus     EventType = "release_status"
+	EventCertificateStatus EventType = "certificate_status"
 )
 
 // StatusEvent represents a status change event
 type StatusEvent struct {
 	Type         EventType `json:"type"`
 	DeploymentID string    `json:"deployment_id,omitempty"`
+	ReleaseID    string    `json:"release_id,omitempty"`
 	ServiceID    string    `json:"service_id,omitempty"`
+	Domain       string    `json:"domain,omitempty"`
 	ProjectID    string    `json:"project_id"`
 	Status       string    `json:"status"`
 	ErrorMessage string    `json:"error_message,omitempty"`
@@ -112,3 +116,26 @@ func (eb *EventBus) PublishServiceStatus(projectID, serviceID, status string) {
 		Status:    status,
 	})
 }
+
+// PublishReleaseStatus is a convenience method for publishing project release status changes
+func (eb *EventBus) PublishReleaseStatus(projectID, releaseID, status, errorMessage string) {
+	eb.Publish(StatusEvent{
+		Type:         EventReleaseStatus,
+		ProjectID:    projectID,
+		ReleaseID:    releaseID,
+		Status:       status,
+		ErrorMessage: errorMessage,
+	})
+}
+
+// PublishCertificateStatus is a convenience method for publishing domain
+// certificate status changes, such as an approaching expiry
+func (eb *EventBus) PublishCertificateStatus(projectID, domain, status, message string) {
+	eb.Publish(StatusEvent{
+		Type:         EventCertificateStatus,
+		ProjectID:    projectID,
+		Domain:       domain,
+		Status:       status,
+		ErrorMessage: message,
+	})
+}
@@ -2,6 +2,10 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
 )
 
 // Slot represents blue or green deployment slot
@@ -17,8 +21,8 @@ type ProxyManager interface {
 	// Route management
 	AddRoute(ctx context.Context, route Route) error
 	UpdateRoute(ctx context.Context, route Route) error
-	RemoveRoute(ctx context.Context, domain string) error
-	GetRoute(ctx context.Context, domain string) (*Route, error)
+	RemoveRoute(ctx context.Context, domain, pathPrefix string) error
+	GetRoute(ctx context.Context, domain, pathPrefix string) (*Route, error)
 	ListRoutes(ctx context.Context) ([]Route, error)
 
 	// Traffic switching for blue-green deployments
@@ -40,6 +44,200 @@ type Route struct {
 	GreenTarget *Upstream
 	ActiveSlot  Slot
 	SSLEnabled  bool
+
+	// PathPrefix limits the route to requests under a path, e.g. "/api".
+	// Empty or "/" matches the whole host. StripPrefix removes the prefix
+	// before the request is proxied.
+	PathPrefix  string
+	StripPrefix bool
+
+	// Weighted routing for canary releases. When both weights are set, traffic
+	// is split between BlueTarget and GreenTarget instead of following ActiveSlot.
+	BlueWeight  int
+	GreenWeight int
+
+	// FailureWindow enables counting 5xx responses per upstream over this window
+	FailureWindow time.Duration
+
+	// Aliases are more hosts served by the route
+	Aliases []string
+	// HostRedirects send every request for other hosts to Domain, keeping
+	// the request URI
+	HostRedirects []HostRedirect
+	// ForceHTTPS redirects plain HTTP requests to HTTPS
+	ForceHTTPS bool
+	// Redirects answer requests under a path with a redirect; Rewrites change
+	// the path before the request is proxied. Both apply in order.
+	Redirects []Redirect
+	Rewrites  []Rewrite
+
+	// BasicAuth requires one of the users' credentials. DenyCIDRs reject
+	// clients; a non-empty AllowCIDRs rejects every other client. RateLimit
+	// limits requests per client IP.
+	BasicAuth  []BasicAuthUser
+	AllowCIDRs []string
+	DenyCIDRs  []string
+	RateLimit  *RateLimit
+
+	// SetHeaders are set on responses, replacing the upstream's values;
+	// RemoveHeaders are removed from them. CORS answers preflight requests
+	// and adds CORS headers for allowed origins.
+	SetHeaders    map[string]string
+	RemoveHeaders []string
+	CORS          *CORS
+}
+
+// Weighted reports whether traffic is split between both slots
+func (r Route) Weighted() bool {
+	return r.BlueWeight > 0 && r.GreenWeight > 0 && r.BlueTarget != nil && r.GreenTarget != nil
+}
+
+// PathMatched reports whether the route is limited to a path prefix
+func (r Route) PathMatched() bool {
+	return r.PathPrefix != "" && r.PathPrefix != "/"
+}
+
+// Wildcard reports whether the route's domain is a wildcard such as
+// *.app.example.com, which matches any single label in place of the *
+func (r Route) Wildcard() bool {
+	return strings.HasPrefix(r.Domain, "*.")
+}
+
+// Hosts returns the domain and its aliases
+func (r Route) Hosts() []string {
+	return append([]string{r.Domain}, r.Aliases...)
+}
+
+// HostRedirect redirects a host to the route's domain
+type HostRedirect struct {
+	Host   string
+	Status int // 301, 302, 307 or 308
+}
+
+// Redirect redirects requests for a path, and the paths below it, to
+// another path or URL; the rest of the path is appended to To
+type Redirect struct {
+	From   string
+	To     string
+	Status int // 301, 302, 307 or 308
+}
+
+// Rewrite replaces a path prefix before the request is proxied
+type Rewrite struct {
+	From string
+	To   string
+}
+
+// BasicAuthUser is a user allowed through HTTP basic auth
+type BasicAuthUser struct {
+	Username     string
+	PasswordHash string // bcrypt
+}
+
+// RateLimit allows each client IP a number of requests per window
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// CORS is a route's cross-origin resource sharing policy
+type CORS struct {
+	AllowOrigins     []string // "*" allows any origin
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int // seconds browsers may cache a preflight response
+}
+
+// AnyOrigin reports whether every origin is allowed
+func (c *CORS) AnyOrigin() bool {
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// RouteValidator is implemented by proxies that cannot render every route,
+// so unsupported settings are rejected before they are stored
+type RouteValidator interface {
+	ValidateRoute(route Route) error
+}
+
+// PathPatterns returns regular expressions, without anchors, matching a path
+// exactly and the paths below it. The second captures the rest of the path.
+func PathPatterns(path string) (exact, below string) {
+	if path == "/" {
+		return "/", "(/[^?]+)"
+	}
+	quoted := regexp.QuoteMeta(path)
+	return quoted, quoted + "(/[^?]*)"
+}
+
+// JoinPath appends the rest of a path matched by PathPatterns to a target
+func JoinPath(target, rest string) string {
+	return strings.TrimSuffix(target, "/") + rest
+}
+
+// UpstreamStats reports recent failures of an upstream
+type UpstreamStats struct {
+	Address  string // host:port
+	Requests int    // requests in flight
+	Fails    int    // failed responses within the route's failure window
+}
+
+// StatsReporter is implemented by proxies that can report per-upstream failures
+type StatsReporter interface {
+	UpstreamStats(ctx context.Context) ([]UpstreamStats, error)
+}
+
+// NetworkAttacher is implemented by proxies running in a container, which
+// must join the Docker networks of the containers they route to
+type NetworkAttacher interface {
+	AttachNetwork(ctx context.Context, network string) error
+}
+
+// CertificateInstaller is implemented by proxies that can serve an uploaded
+// certificate for a domain instead of obtaining one
+type CertificateInstaller interface {
+	InstallCertificate(ctx context.Context, domain string, certPEM, keyPEM []byte) error
+	RemoveCertificate(ctx context.Context, domain string) error
+}
+
+// LegacyRouteAdopter is implemented by proxies holding routes Nebula added
+// before it could tell its routes from others. The routes serving hosts
+// Nebula routes are taken over; the rest are left alone.
+type LegacyRouteAdopter interface {
+	AdoptLegacyRoutes(ctx context.Context, hosts []string) (int, error)
+}
+
+// RouteSyncer is implemented by proxies that can replace their whole route
+// set at once, so routes rendered from the database converge in one step
+type RouteSyncer interface {
+	// SyncRoutes makes routes the proxy's complete set of Nebula routes
+	SyncRoutes(ctx context.Context, routes []Route) error
+	// DiffRoutes reports what SyncRoutes would change, without applying it
+	DiffRoutes(ctx context.Context, routes []Route) ([]RouteChange, error)
+}
+
+// RouteChangeAction is the kind of a pending route change
+type RouteChangeAction string
+
+const (
+	RouteAdded   RouteChangeAction = "add"
+	RouteUpdated RouteChangeAction = "update"
+	RouteRemoved RouteChangeAction = "remove"
+)
+
+// RouteChange is a difference between the proxy's routes and the desired ones
+type RouteChange struct {
+	Action     RouteChangeAction
+	Domain     string
+	PathPrefix string
+	Current    json.RawMessage // proxy configuration in place, nil when added
+	Desired    json.RawMessage // proxy configuration to apply, nil when removed
 }
 
 // Upstream represents a backend target
@@ -0,0 +1,51 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadOrCreateKey returns the encryption key kept in the file at path,
+// creating the file on first start. The new key is seed when one is given,
+// so values encrypted before the key was kept stay readable, and a random
+// key otherwise. It reports whether the file was created.
+func LoadOrCreateKey(path, seed string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return "", false, fmt.Errorf("encryption key file %s is empty", path)
+		}
+		return key, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", false, fmt.Errorf("failed to read encryption key: %w", err)
+	}
+
+	key := seed
+	if key == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return "", false, fmt.Errorf("failed to generate encryption key: %w", err)
+		}
+		key = base64.StdEncoding.EncodeToString(raw)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", false, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create encryption key file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(key + "\n"); err != nil {
+		return "", false, fmt.Errorf("failed to write encryption key: %w", err)
+	}
+	return key, true, nil
+}
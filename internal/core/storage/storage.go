@@ -37,6 +37,13 @@ type Project struct {
 	Environment string // JSON encoded shared env vars
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+
+	// Environments of a project are stored as projects of their own, with
+	// their own services, domains and databases. ParentID links an
+	// environment to its project; the project itself is its first
+	// environment (production by default).
+	ParentID        string
+	EnvironmentName string
 }
 
 // Service represents a service within a project
@@ -53,6 +60,37 @@ type Service struct {
 	Subdirectory string // for monorepos: "apps/api"
 	DockerImage  string // if builder=docker_image
 
+	// GitCredentialID selects the credential used to clone GitRepo
+	// (empty = match a project credential by host)
+	GitCredentialID string
+
+	// Clone options for git builds
+	GitSubmodules bool // clone submodules recursively
+	GitLFS        bool // fetch Git LFS objects
+	GitDepth      int  // fetch depth (default 1, 0 = full history)
+
+	// BuildCacheKey scopes persistent build caches; rotated to clear them
+	BuildCacheKey string
+
+	// Build-time configuration
+	BuildArgs    string // JSON encoded, values may reference environment variables
+	BuildSecrets string // encrypted JSON encoded, mounted as BuildKit secrets
+
+	// HealthCheck is the JSON encoded health check probed before traffic is switched
+	HealthCheck string
+
+	// Retirement of the previous slot after traffic is switched
+	DrainSeconds     int // wait before stopping, so in-flight requests can finish
+	StopGraceSeconds int // time between SIGTERM and SIGKILL
+	KeepWarmMinutes  int // keep the old slot running for rollback (0 = stop after draining)
+	WatchSeconds     int // watch the new slot for crashes and roll back within this window (0 = off)
+
+	// DependsOn is the JSON encoded list of services, by name, deployed before this one
+	DependsOn string
+
+	// RequiresApproval holds deployments in awaiting_approval until approved
+	RequiresApproval bool
+
 	// Database configuration (only for type=database)
 	DatabaseType     string // postgres, mysql, redis, mongodb
 	DatabaseVersion  string
@@ -78,14 +116,18 @@ type Service struct {
 
 // Domain represents a domain routing configuration
 type Domain struct {
-	ID         string
-	ProjectID  string
-	ServiceID  string
-	Domain     string // e.g., "api.example.com"
-	PathPrefix string // e.g., "/api" (empty = root)
-	ActiveSlot string // blue/green
-	SSLEnabled bool
-	CreatedAt  time.Time
+	ID          string
+	ProjectID   string
+	ServiceID   string
+	Domain      string // e.g., "api.example.com"
+	PathPrefix  string // e.g., "/api" (empty = root)
+	StripPrefix bool   // remove PathPrefix before proxying
+	ActiveSlot  string // blue/green
+	SSLEnabled  bool
+	Rules       string // JSON encoded redirects, rewrites and aliases
+	Access      string // JSON encoded basic auth, IP lists and rate limit
+	Headers     string // JSON encoded response headers, HSTS and CORS
+	CreatedAt   time.Time
 }
 
 // Deployment represents a deployment entity
@@ -103,6 +145,138 @@ type Deployment struct {
 	CreatedAt    time.Time
 	StartedAt    *time.Time
 	FinishedAt   *time.Time
+	RetireAt     *time.Time // standby deployments are stopped after this time
+
+	// TrafficWeight is the share of traffic (0-100) a canary deployment receives
+	TrafficWeight int
+
+	// ReleaseID links the deployment to the project release it is part of
+	ReleaseID string
+
+	// Approval of deployments of services that require it
+	Request     string // JSON encoded deploy request, replayed once approved
+	RequestedBy string
+	ApprovedBy  string // user who approved or rejected the deployment
+
+	// FreezeOverride is the reason given to deploy during a freeze window
+	FreezeOverride string
+}
+
+// Release groups the service deployments of a project deployed together in
+// dependency order
+type Release struct {
+	ID           string
+	ProjectID    string
+	Status       string // deploying, blocked, running, failed
+	Plan         string // JSON encoded layers of service names, in deploy order
+	ErrorMessage string
+	CreatedAt    time.Time
+	FinishedAt   *time.Time
+}
+
+// FreezeWindow is a period during which deployments of a project are refused
+// unless overridden. One-off windows set StartsAt and EndsAt; recurring
+// windows set Weekdays, StartTime and EndTime, evaluated in Timezone.
+type FreezeWindow struct {
+	ID        string
+	ProjectID string
+	Name      string
+	StartsAt  *time.Time
+	EndsAt    *time.Time
+	Weekdays  string // comma separated, e.g. "fri,sat,sun" (empty = every day)
+	StartTime string // HH:MM
+	EndTime   string // HH:MM, before StartTime for windows spanning midnight
+	Timezone  string // IANA name (default UTC)
+	CreatedAt time.Time
+}
+
+// AuditEntry records a user action that bypasses or grants deployment guardrails
+type AuditEntry struct {
+	ID        string
+	ProjectID string
+	Actor     string
+	Action    string // e.g. deployment.approved, freeze.override
+	TargetID  string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// PreviewConfig controls the pull request preview environments of a project
+type PreviewConfig struct {
+	ProjectID     string
+	Enabled       bool
+	WebhookSecret string // encrypted, verifies git webhook deliveries
+	BaseDomain    string // previews are served at pr-<number>-<project>.<BaseDomain>
+	ForkDatabases bool   // copy the data of the project's databases into each preview
+	TTLHours      int    // tear previews down this long after their last push (0 = only when closed)
+	MaxPreviews   int    // concurrent previews per project (0 = unlimited)
+	UpdatedAt     time.Time
+}
+
+// Preview is an environment deployed from the branch of a pull request
+type Preview struct {
+	ID            string
+	ProjectID     string // the project the pull request targets
+	EnvironmentID string // the environment project holding the preview's services
+	PRNumber      int
+	Title         string
+	Branch        string
+	CommitSHA     string
+	ReleaseID     string // latest release of the preview
+	ExpiresAt     *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Certificate is the TLS certificate state of a domain host, shared by
+// every path prefix routed on it
+type Certificate struct {
+	Domain       string
+	Source       string // "custom" for uploaded certificates, "managed" for ACME
+	CertPEM      string // uploaded certificate chain
+	KeyPEM       string // uploaded private key, encrypted
+	Issuer       string
+	Names        string // comma separated names the certificate covers
+	NotBefore    *time.Time
+	NotAfter     *time.Time
+	CheckedAt    *time.Time
+	LastError    string // last renewal or check error
+	ExpiryNotice int    // days-before-expiry threshold last notified (0 = expired, -1 = none)
+	UpdatedAt    time.Time
+}
+
+// TLSAllowlist lists the hosts of a project approved for on-demand
+// certificates without a domain of their own. With a ServiceID, the hosts
+// are also routed to that service under PathPrefix.
+type TLSAllowlist struct {
+	ProjectID  string
+	Patterns   string // comma separated hosts, such as shop.example.com or *.customers.example.com
+	ServiceID  string
+	PathPrefix string
+	UpdatedAt  time.Time
+}
+
+// GitCredentialType represents the kind of git credential
+type GitCredentialType string
+
+const (
+	GitCredentialSSHKey     GitCredentialType = "ssh_key"
+	GitCredentialHTTPSToken GitCredentialType = "https_token"
+)
+
+// GitCredential holds credentials used to clone private repositories
+type GitCredential struct {
+	ID         string
+	ProjectID  string
+	Name       string
+	Type       GitCredentialType
+	Host       string // e.g., "github.com", "gitlab.example.com"
+	Username   string // HTTPS username (e.g., "oauth2", "x-token-auth")
+	Secret     string // encrypted: HTTPS token or SSH private key
+	PublicKey  string // SSH public key in authorized_keys format
+	KnownHosts string // known_hosts entries used to verify SSH hosts
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 // Route represents a route entity (legacy, replaced by Domain)
@@ -124,7 +298,9 @@ type Container struct {
 	ContainerID  string
 	Name         string
 	Status       string
-	Port         int
+	Port         int    // published host port, 0 when not published
+	Host         string // name the proxy reaches the container at on its network
+	AppPort      int    // port the application listens on inside the container
 	CreatedAt    time.Time
 }
 
@@ -172,6 +348,7 @@ type ProjectRepository interface {
 	Update(ctx context.Context, project *Project) error
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context) ([]*Project, error)
+	ListByParentID(ctx context.Context, parentID string) ([]*Project, error)
 }
 
 // ServiceRepository handles service persistence
@@ -190,13 +367,24 @@ type DomainRepository interface {
 	Create(ctx context.Context, domain *Domain) error
 	GetByID(ctx context.Context, id string) (*Domain, error)
 	GetByDomain(ctx context.Context, domain string) (*Domain, error)
+	GetByDomainAndPath(ctx context.Context, domain, pathPrefix string) (*Domain, error)
 	Update(ctx context.Context, domain *Domain) error
 	Delete(ctx context.Context, id string) error
 	ListByProjectID(ctx context.Context, projectID string) ([]*Domain, error)
 	ListByServiceID(ctx context.Context, serviceID string) ([]*Domain, error)
+	ListByDomain(ctx context.Context, domain string) ([]*Domain, error)
 	List(ctx context.Context) ([]*Domain, error)
 }
 
+// GitCredentialRepository handles git credential persistence
+type GitCredentialRepository interface {
+	Create(ctx context.Context, credential *GitCredential) error
+	GetByID(ctx context.Context, id string) (*GitCredential, error)
+	Update(ctx context.Context, credential *GitCredential) error
+	Delete(ctx context.Context, id string) error
+	ListByProjectID(ctx context.Context, projectID string) ([]*GitCredential, error)
+}
+
 // AppRepository handles application persistence (legacy, use ProjectRepository)
 type AppRepository interface {
 	Create(ctx context.Context, app *Project) error
@@ -219,6 +407,61 @@ type DeploymentRepository interface {
 	GetLatestByServiceID(ctx context.Context, serviceID string) (*Deployment, error)
 	GetByAppIDAndSlot(ctx context.Context, appID string, slot string) (*Deployment, error)
 	GetByServiceIDAndSlot(ctx context.Context, serviceID string, slot string) (*Deployment, error)
+	ListByStatus(ctx context.Context, status string) ([]*Deployment, error)
+	ListByReleaseID(ctx context.Context, releaseID string) ([]*Deployment, error)
+}
+
+// ReleaseRepository handles project release persistence
+type ReleaseRepository interface {
+	Create(ctx context.Context, release *Release) error
+	GetByID(ctx context.Context, id string) (*Release, error)
+	Update(ctx context.Context, release *Release) error
+	ListByProjectID(ctx context.Context, projectID string) ([]*Release, error)
+}
+
+// FreezeWindowRepository handles freeze window persistence
+type FreezeWindowRepository interface {
+	Create(ctx context.Context, window *FreezeWindow) error
+	GetByID(ctx context.Context, id string) (*FreezeWindow, error)
+	Delete(ctx context.Context, id string) error
+	ListByProjectID(ctx context.Context, projectID string) ([]*FreezeWindow, error)
+}
+
+// AuditRepository handles audit log persistence
+type AuditRepository interface {
+	Create(ctx context.Context, entry *AuditEntry) error
+	ListByProjectID(ctx context.Context, projectID string) ([]*AuditEntry, error)
+}
+
+// PreviewConfigRepository handles preview settings persistence
+type PreviewConfigRepository interface {
+	Get(ctx context.Context, projectID string) (*PreviewConfig, error)
+	Save(ctx context.Context, config *PreviewConfig) error
+}
+
+// PreviewRepository handles pull request preview persistence
+type PreviewRepository interface {
+	Create(ctx context.Context, preview *Preview) error
+	GetByPullRequest(ctx context.Context, projectID string, number int) (*Preview, error)
+	Update(ctx context.Context, preview *Preview) error
+	Delete(ctx context.Context, id string) error
+	ListByProjectID(ctx context.Context, projectID string) ([]*Preview, error)
+	ListExpired(ctx context.Context, before time.Time) ([]*Preview, error)
+}
+
+// CertificateRepository handles certificate persistence
+type CertificateRepository interface {
+	Get(ctx context.Context, domain string) (*Certificate, error)
+	Save(ctx context.Context, cert *Certificate) error
+	Delete(ctx context.Context, domain string) error
+	List(ctx context.Context) ([]*Certificate, error)
+}
+
+// TLSAllowlistRepository handles on-demand TLS allowlist persistence
+type TLSAllowlistRepository interface {
+	Get(ctx context.Context, projectID string) (*TLSAllowlist, error)
+	Save(ctx context.Context, allowlist *TLSAllowlist) error
+	List(ctx context.Context) ([]*TLSAllowlist, error)
 }
 
 // RouteRepository handles route persistence (legacy, use DomainRepository)
@@ -282,6 +525,14 @@ type Store interface {
 	Projects() ProjectRepository
 	Services() ServiceRepository
 	Domains() DomainRepository
+	GitCredentials() GitCredentialRepository
+	Releases() ReleaseRepository
+	FreezeWindows() FreezeWindowRepository
+	Audit() AuditRepository
+	PreviewConfigs() PreviewConfigRepository
+	Previews() PreviewRepository
+	Certificates() CertificateRepository
+	TLSAllowlists() TLSAllowlistRepository
 
 	// Legacy repositories (for backwards compatibility during migration)
 	Apps() AppRepository
@@ -290,6 +290,7 @@ func (d *Deployer) Deploy(ctx context.Context, spec *deployer.DeploymentSpec) (*
 		ContainerIDs: containerIDs,
 		Port:         primaryPort,
 		Version:      uuid.New().String()[:8],
+		HealthCheck:  spec.HealthCheck,
 	}, nil
 }
 
@@ -330,10 +331,13 @@ func (d *Deployer) HealthCheck(ctx context.Context, result *deployer.DeploymentR
 	}, nil
 }
 
-func (d *Deployer) Stop(ctx context.Context, containerIDs []string) error {
+func (d *Deployer) Stop(ctx context.Context, containerIDs []string, gracePeriod time.Duration) error {
+	if gracePeriod <= 0 {
+		gracePeriod = deployer.DefaultStopGracePeriod
+	}
 	var errs []error
 	for _, id := range containerIDs {
-		if err := d.runtime.StopContainer(ctx, id, 30*time.Second); err != nil {
+		if err := d.runtime.StopContainer(ctx, id, gracePeriod); err != nil {
 			errs = append(errs, fmt.Errorf("failed to stop %s: %w", id[:12], err))
 		}
 	}
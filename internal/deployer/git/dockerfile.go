@@ -0,0 +1,61 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dockerfileOptions holds the per-service settings applied to generated Dockerfiles
+type dockerfileOptions struct {
+	cacheKey  string   // scopes BuildKit cache mount ids to the service
+	buildArgs []string // build argument names declared with ARG
+	secrets   []string // build secret names mounted into RUN steps
+}
+
+func newDockerfileOptions(cacheKey string, buildArgs, secrets map[string]string) dockerfileOptions {
+	return dockerfileOptions{
+		cacheKey:  cacheKey,
+		buildArgs: sortedKeys(buildArgs),
+		secrets:   sortedKeys(secrets),
+	}
+}
+
+// args declares the build arguments so RUN steps see them as environment variables
+func (o dockerfileOptions) args() string {
+	var b strings.Builder
+	for _, name := range o.buildArgs {
+		fmt.Fprintf(&b, "ARG %s\n", name)
+	}
+	return b.String()
+}
+
+// cacheMount returns a BuildKit cache mount that persists target across builds
+func (o dockerfileOptions) cacheMount(name, target string) string {
+	return fmt.Sprintf("--mount=type=cache,id=nebula-%s-%s,target=%s", o.cacheKey, name, target)
+}
+
+// run returns a RUN instruction with the given mounts. Build secrets are mounted
+// under /run/secrets and exported only for the duration of the command, so they
+// never land in an image layer.
+func (o dockerfileOptions) run(command string, mounts ...string) string {
+	parts := []string{"RUN"}
+	parts = append(parts, mounts...)
+	for _, name := range o.secrets {
+		parts = append(parts, fmt.Sprintf("--mount=type=secret,id=%s", name))
+	}
+	if len(o.secrets) > 0 {
+		command = `for s in /run/secrets/*; do export "${s##*/}=$(cat "$s")"; done; ` + command
+	}
+	parts = append(parts, command)
+	return strings.Join(parts, " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,229 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	core "github.com/victalejo/nebula/internal/core/container"
+	"github.com/victalejo/nebula/internal/core/deployer"
+)
+
+// Default probe settings, used when the service does not configure them
+const (
+	DefaultInterval  = 2 * time.Second
+	DefaultTimeout   = 5 * time.Second
+	DefaultRetries   = 30
+	DefaultStatusMin = 200
+	DefaultStatusMax = 399
+)
+
+// Execer runs commands inside containers for exec probes
+type Execer interface {
+	ExecContainer(ctx context.Context, id string, cmd []string) (*core.ExecResult, error)
+}
+
+// Target identifies what a probe checks
+type Target struct {
+	ContainerID string
+	Host        string // 127.0.0.1 for published ports, else the container address
+	Port        int    // published host port, or AppPort on the container network
+	AppPort     int    // port inside the container, used in messages
+}
+
+// ResultTarget returns where a deployment is probed: its published port on
+// the host, or its port on the container network
+func ResultTarget(result *deployer.DeploymentResult) Target {
+	target := Target{
+		Host:    "127.0.0.1",
+		Port:    result.Ports["main"],
+		AppPort: result.AppPort,
+	}
+	if target.Port == 0 && result.IP != "" {
+		target.Host = result.IP
+		target.Port = result.AppPort
+	}
+	if len(result.ContainerIDs) > 0 {
+		target.ContainerID = result.ContainerIDs[0]
+	}
+	return target
+}
+
+func (t Target) address() string {
+	return net.JoinHostPort(t.Host, fmt.Sprintf("%d", t.Port))
+}
+
+// Prober probes deployments from Nebula, so images need no tools of their own
+type Prober struct {
+	exec   Execer
+	client *http.Client
+}
+
+// NewProber creates a new prober
+func NewProber(exec Execer) *Prober {
+	return &Prober{
+		exec: exec,
+		client: &http.Client{
+			// Report redirects as-is so status ranges can match 3xx
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Wait probes the target until it passes or the configured retries are used up.
+// running is called before each probe and fails the check immediately when the
+// container is no longer running.
+func (p *Prober) Wait(ctx context.Context, cfg *deployer.HealthCheckConfig, target Target, running func(ctx context.Context) error) error {
+	cfg = withDefaults(cfg)
+	deadline := time.Now().Add(cfg.StartPeriod)
+
+	failures := 0
+	for {
+		if err := running(ctx); err != nil {
+			return err
+		}
+
+		err := p.Probe(ctx, cfg, target)
+		if err == nil {
+			return nil
+		}
+
+		// Failures during the start period do not count against the retries
+		if time.Now().After(deadline) {
+			failures++
+			if failures > cfg.Retries {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("health check timeout: %w", err)
+		case <-time.After(cfg.Interval):
+		}
+	}
+}
+
+// Probe runs a single check against the target
+func (p *Prober) Probe(ctx context.Context, cfg *deployer.HealthCheckConfig, target Target) error {
+	cfg = withDefaults(cfg)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	switch cfg.Type {
+	case deployer.HealthCheckNone:
+		return nil
+	case deployer.HealthCheckHTTP:
+		return p.probeHTTP(ctx, cfg, target)
+	case deployer.HealthCheckExec:
+		return p.probeExec(ctx, cfg, target)
+	default:
+		return probeTCP(ctx, target)
+	}
+}
+
+func (p *Prober) probeHTTP(ctx context.Context, cfg *deployer.HealthCheckConfig, target Target) error {
+	url := fmt.Sprintf("http://%s%s", target.address(), cfg.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid health check request: %w", err)
+	}
+	for k, v := range cfg.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET %s on port %d failed: %w", cfg.Path, target.AppPort, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < cfg.StatusMin || resp.StatusCode > cfg.StatusMax {
+		return fmt.Errorf("GET %s returned status %d, expected %d-%d", cfg.Path, resp.StatusCode, cfg.StatusMin, cfg.StatusMax)
+	}
+	return nil
+}
+
+func (p *Prober) probeExec(ctx context.Context, cfg *deployer.HealthCheckConfig, target Target) error {
+	if p.exec == nil {
+		return errors.New("exec health checks are not supported by this runtime")
+	}
+
+	result, err := p.exec.ExecContainer(ctx, target.ContainerID, cfg.Command)
+	if err != nil {
+		return fmt.Errorf("health check command failed: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("health check command exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Output))
+	}
+	return nil
+}
+
+// probeTCP checks that a connection to the target reaches the application.
+// docker-proxy accepts and immediately closes connections when a published
+// port has no listener, so a connection that survives a short read is
+// considered served.
+func probeTCP(ctx context.Context, target Target) error {
+	notListening := fmt.Errorf("application is not listening on port %d: make sure it binds to 0.0.0.0:$PORT (PORT=%d) or set the service port", target.AppPort, target.AppPort)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", target.address())
+	if err != nil {
+		return notListening
+	}
+	defer conn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 1))
+	if err == nil {
+		return nil
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	return notListening
+}
+
+// withDefaults returns a copy of cfg with unset fields filled in
+func withDefaults(cfg *deployer.HealthCheckConfig) *deployer.HealthCheckConfig {
+	c := deployer.HealthCheckConfig{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Type == "" {
+		c.Type = deployer.HealthCheckTCP
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if c.StatusMin == 0 {
+		c.StatusMin = DefaultStatusMin
+	}
+	if c.StatusMax == 0 {
+		c.StatusMax = DefaultStatusMax
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.Retries == 0 {
+		c.Retries = DefaultRetries
+	}
+	return &c
+}
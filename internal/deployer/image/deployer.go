@@ -3,7 +3,6 @@ package image
 import (
 	"context"
 	"fmt"
-	"net"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,23 +10,27 @@ import (
 	"github.com/victalejo/nebula/internal/core/container"
 	"github.com/victalejo/nebula/internal/core/deployer"
 	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/deployer/health"
 )
 
 // Deployer implements the Deployer interface for Docker images
 type Deployer struct {
-	runtime container.ContainerRuntime
-	network string
-	log     logger.Logger
-	// lastHealthCheckConfig stores the health check config from the last deployment
-	lastHealthCheckConfig *deployer.HealthCheckConfig
+	runtime      container.ContainerRuntime
+	network      string
+	publishPorts bool // publish app ports on 127.0.0.1 for a proxy running on the host
+	log          logger.Logger
+	prober       *health.Prober
 }
 
-// New creates a new image deployer
-func New(runtime container.ContainerRuntime, network string, log logger.Logger) *Deployer {
+// New creates a new image deployer. Containers are reached over network
+// unless publishPorts is set.
+func New(runtime container.ContainerRuntime, network string, publishPorts bool, log logger.Logger) *Deployer {
 	return &Deployer{
-		runtime: runtime,
-		network: network,
-		log:     log,
+		runtime:      runtime,
+		network:      network,
+		publishPorts: publishPorts,
+		log:          log,
+		prober:       health.NewProber(runtime),
 	}
 }
 
@@ -49,20 +52,35 @@ func (d *Deployer) Validate(ctx context.Context, spec *deployer.DeploymentSpec)
 
 // Prepare pulls the Docker image
 func (d *Deployer) Prepare(ctx context.Context, spec *deployer.DeploymentSpec) (*deployer.PrepareResult, error) {
-	d.log.Info("pulling image",
-		"image", spec.Source.Image,
-	)
+	if spec.Source.PullPolicy == deployer.PullNever {
+		// Local images, e.g. promoted from another environment, are never pulled
+		if _, err := d.runtime.InspectImage(ctx, spec.Source.Image); err != nil {
+			return nil, fmt.Errorf("image %s is not available locally: %w", spec.Source.Image, err)
+		}
+	} else {
+		d.log.Info("pulling image",
+			"image", spec.Source.Image,
+		)
+
+		var auth *container.RegistryAuth
+		if spec.Source.RegistryAuth != nil {
+			auth = &container.RegistryAuth{
+				Username: spec.Source.RegistryAuth.Username,
+				Password: spec.Source.RegistryAuth.Password,
+			}
+		}
 
-	var auth *container.RegistryAuth
-	if spec.Source.RegistryAuth != nil {
-		auth = &container.RegistryAuth{
-			Username: spec.Source.RegistryAuth.Username,
-			Password: spec.Source.RegistryAuth.Password,
+		if err := d.runtime.PullImage(ctx, spec.Source.Image, auth); err != nil {
+			return nil, fmt.Errorf("failed to pull image: %w", err)
 		}
 	}
 
-	if err := d.runtime.PullImage(ctx, spec.Source.Image, auth); err != nil {
-		return nil, fmt.Errorf("failed to pull image: %w", err)
+	// Record what the tag resolved to, so deployments can be compared later
+	if img, err := d.runtime.InspectImage(ctx, spec.Source.Image); err == nil {
+		spec.Source.ImageDigest = img.ID
+		if len(img.RepoDigests) > 0 {
+			spec.Source.ImageDigest = img.RepoDigests[0]
+		}
 	}
 
 	return &deployer.PrepareResult{
@@ -77,21 +95,17 @@ func (d *Deployer) Deploy(ctx context.Context, spec *deployer.DeploymentSpec) (*
 		"slot", spec.TargetSlot,
 	)
 
-	// Store health check config for later use
-	d.lastHealthCheckConfig = spec.HealthCheck
-
-	// Find available port
-	hostPort, err := findAvailablePort()
-	if err != nil {
-		return nil, fmt.Errorf("failed to find available port: %w", err)
-	}
-
 	// Prepare environment variables
 	env := make(map[string]string)
 	for k, v := range spec.Environment {
 		env[k] = v
 	}
 
+	network := d.network
+	if spec.Network != "" {
+		network = spec.Network
+	}
+
 	// Generate container name
 	containerName := fmt.Sprintf("nebula-%s-%s-%s", spec.AppID[:8], spec.TargetSlot, uuid.New().String()[:8])
 
@@ -108,44 +122,36 @@ func (d *Deployer) Deploy(ctx context.Context, spec *deployer.DeploymentSpec) (*
 		Image: spec.Source.Image,
 		Env:   env,
 		Labels: labels,
-		Ports: []container.PortBinding{
+		Networks: []string{network},
+		RestartPolicy: "unless-stopped",
+	}
+
+	// Only a proxy outside Docker needs the port on the host; Docker picks a
+	// free one
+	if d.publishPorts {
+		config.Ports = []container.PortBinding{
 			{
 				ContainerPort: spec.Source.Port,
-				HostPort:      hostPort,
+				HostIP:        "127.0.0.1",
 				Protocol:      "tcp",
 			},
-		},
-		Networks: []string{d.network},
-		RestartPolicy: "unless-stopped",
+		}
 	}
 
-	// Configure health check based on spec
-	if spec.HealthCheck != nil && spec.HealthCheck.SkipHTTPCheck {
-		// For databases and services that don't have HTTP endpoints
-		if len(spec.HealthCheck.Test) > 0 {
-			// Use custom health check command
-			config.HealthCheck = &container.HealthCheckConfig{
-				Test:        spec.HealthCheck.Test,
-				Interval:    30 * time.Second,
-				Timeout:     10 * time.Second,
-				Retries:     10,
-				StartPeriod: 60 * time.Second, // Give databases more time to initialize
-			}
-		}
-		// If Test is empty, no Docker health check (we'll just check running state)
-	} else {
-		// Default HTTP health check for web services
+	// Databases may use a Docker health check command; web services are
+	// probed by Nebula from the host so images need no curl or similar tools
+	if spec.HealthCheck != nil && spec.HealthCheck.SkipHTTPCheck && len(spec.HealthCheck.Test) > 0 {
 		config.HealthCheck = &container.HealthCheckConfig{
-			Test:        []string{"CMD-SHELL", fmt.Sprintf("curl -f http://localhost:%d/ || exit 1", spec.Source.Port)},
-			Interval:    10 * time.Second,
-			Timeout:     5 * time.Second,
-			Retries:     3,
-			StartPeriod: 30 * time.Second,
+			Test:        spec.HealthCheck.Test,
+			Interval:    30 * time.Second,
+			Timeout:     10 * time.Second,
+			Retries:     10,
+			StartPeriod: 60 * time.Second, // Give databases more time to initialize
 		}
 	}
 
 	// Ensure network exists
-	_, err = d.runtime.CreateNetwork(ctx, d.network, container.NetworkOptions{})
+	_, err := d.runtime.CreateNetwork(ctx, network, container.NetworkOptions{})
 	if err != nil {
 		d.log.Warn("failed to create network", "error", err)
 	}
@@ -163,17 +169,43 @@ func (d *Deployer) Deploy(ctx context.Context, spec *deployer.DeploymentSpec) (*
 		return nil, fmt.Errorf("failed to start container: %w", err)
 	}
 
+	info, err := d.runtime.InspectContainer(ctx, containerID)
+	if err != nil {
+		_ = d.runtime.RemoveContainer(ctx, containerID, true)
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	result := &deployer.DeploymentResult{
+		ContainerIDs: []string{containerID},
+		Ports:        map[string]int{"main": 0},
+		AppPort:      spec.Source.Port,
+		Network:      network,
+		IP:           info.Networks[network],
+		HealthCheck:  spec.HealthCheck,
+	}
+	if d.publishPorts {
+		for _, p := range info.Ports {
+			if p.ContainerPort == spec.Source.Port && p.HostPort > 0 {
+				result.Ports["main"] = p.HostPort
+				result.Port = p.HostPort
+				break
+			}
+		}
+		if result.Port == 0 {
+			_ = d.runtime.RemoveContainer(ctx, containerID, true)
+			return nil, fmt.Errorf("container port %d was not published", spec.Source.Port)
+		}
+	} else {
+		result.Host = containerName
+	}
+
 	d.log.Info("container started",
 		"container_id", containerID[:12],
-		"host_port", hostPort,
+		"host", result.Host,
+		"host_port", result.Port,
 	)
 
-	return &deployer.DeploymentResult{
-		ContainerIDs: []string{containerID},
-		Ports: map[string]int{
-			"main": hostPort,
-		},
-	}, nil
+	return result, nil
 }
 
 // HealthCheck performs health checks on the deployment
@@ -185,22 +217,28 @@ func (d *Deployer) HealthCheck(ctx context.Context, result *deployer.DeploymentR
 		}, nil
 	}
 
+	// The deployment carries its own health check, so concurrent deployments
+	// never see each other's settings
+	cfg := result.HealthCheck
+
+	// Web services are probed from the host with the service's health check
+	if cfg == nil || !cfg.SkipHTTPCheck {
+		return d.probe(ctx, result, cfg)
+	}
+
 	// Determine health check parameters
 	maxAttempts := 30
 	interval := 2 * time.Second
-	skipDockerHealthCheck := false
 
-	if d.lastHealthCheckConfig != nil {
-		if d.lastHealthCheckConfig.MaxAttempts > 0 {
-			maxAttempts = d.lastHealthCheckConfig.MaxAttempts
-		}
-		if d.lastHealthCheckConfig.Interval > 0 {
-			interval = d.lastHealthCheckConfig.Interval
-		}
-		// For databases without HTTP, we skip Docker health check waiting
-		// and just verify the container is running
-		skipDockerHealthCheck = d.lastHealthCheckConfig.SkipHTTPCheck && len(d.lastHealthCheckConfig.Test) == 0
+	if cfg.MaxAttempts > 0 {
+		maxAttempts = cfg.MaxAttempts
 	}
+	if cfg.Interval > 0 {
+		interval = cfg.Interval
+	}
+	// For databases without HTTP, we skip Docker health check waiting
+	// and just verify the container is running
+	skipDockerHealthCheck := len(cfg.Test) == 0
 
 	for attempt := 0; attempt < maxAttempts; attempt++ {
 		allHealthy := true
@@ -253,10 +291,42 @@ func (d *Deployer) HealthCheck(ctx context.Context, result *deployer.DeploymentR
 	}, nil
 }
 
+// probe waits for the service's health check to pass
+func (d *Deployer) probe(ctx context.Context, result *deployer.DeploymentResult, cfg *deployer.HealthCheckConfig) (*deployer.HealthCheckResult, error) {
+	containerID := result.ContainerIDs[0]
+	target := health.ResultTarget(result)
+
+	running := func(ctx context.Context) error {
+		info, err := d.runtime.InspectContainer(ctx, containerID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container: %w", err)
+		}
+		if info.State != "running" {
+			return fmt.Errorf("container not running: %s", info.State)
+		}
+		return nil
+	}
+
+	if err := d.prober.Wait(ctx, cfg, target, running); err != nil {
+		return &deployer.HealthCheckResult{
+			Healthy: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &deployer.HealthCheckResult{
+		Healthy: true,
+		Message: "health check passed",
+	}, nil
+}
+
 // Stop stops the containers
-func (d *Deployer) Stop(ctx context.Context, containerIDs []string) error {
+func (d *Deployer) Stop(ctx context.Context, containerIDs []string, gracePeriod time.Duration) error {
+	if gracePeriod <= 0 {
+		gracePeriod = deployer.DefaultStopGracePeriod
+	}
 	for _, id := range containerIDs {
-		if err := d.runtime.StopContainer(ctx, id, 30*time.Second); err != nil {
+		if err := d.runtime.StopContainer(ctx, id, gracePeriod); err != nil {
 			d.log.Warn("failed to stop container", "container_id", id, "error", err)
 		}
 	}
@@ -276,14 +346,3 @@ func (d *Deployer) Destroy(ctx context.Context, containerIDs []string) error {
 	return nil
 }
 
-// findAvailablePort finds an available port on the host
-func findAvailablePort() (int, error) {
-	listener, err := net.Listen("tcp", ":0")
-	if err != nil {
-		return 0, err
-	}
-	defer listener.Close()
-
-	addr := listener.Addr().(*net.TCPAddr)
-	return addr.Port, nil
-}
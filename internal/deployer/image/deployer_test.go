@@ -0,0 +1,173 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/container"
+	"github.com/victalejo/nebula/internal/core/deployer"
+	"github.com/victalejo/nebula/internal/core/logger"
+)
+
+// probeRecorder is an application answering health checks, failing the first
+// one so every deployment probes more than once
+type probeRecorder struct {
+	mu       sync.Mutex
+	requests []string // "<host> <path>" of every probe
+}
+
+func (p *probeRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.requests = append(p.requests, r.Host+" "+r.URL.Path)
+	first := len(p.requests) == 1
+	p.mu.Unlock()
+
+	if first {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *probeRecorder) seen() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string{}, p.requests...)
+}
+
+// TestConcurrentDeploymentsProbeTheirOwnHealthCheck deploys several services
+// at once and checks that each deployment is probed with its own health
+// check, against its own container. Run with -race.
+func TestConcurrentDeploymentsProbeTheirOwnHealthCheck(t *testing.T) {
+	const services = 8
+
+	runtime := newFakeRuntime("127.0.0.1")
+	var execMu sync.Mutex
+	execCalls := make(map[string]int)
+	runtime.execResult = func(id string, cmd []string) *container.ExecResult {
+		execMu.Lock()
+		defer execMu.Unlock()
+		execCalls[id]++
+		if execCalls[id] == 1 {
+			return &container.ExecResult{ExitCode: 1, Output: "starting"}
+		}
+		return &container.ExecResult{}
+	}
+	d := New(runtime, "nebula-apps", false, logger.New("error"))
+
+	// Even services are probed over HTTP, odd ones with a command
+	apps := make([]*probeRecorder, services)
+	specs := make([]*deployer.DeploymentSpec, services)
+	for i := range specs {
+		spec := &deployer.DeploymentSpec{
+			AppID:       fmt.Sprintf("project-%02d-0000", i),
+			ServiceName: fmt.Sprintf("svc-%d", i),
+			Source:      deployer.SourceConfig{Image: fmt.Sprintf("svc-%d:latest", i)},
+			TargetSlot:  deployer.SlotBlue,
+			HealthCheck: &deployer.HealthCheckConfig{
+				Interval: 5 * time.Millisecond,
+				Timeout:  time.Second,
+				Retries:  20,
+			},
+		}
+		if i%2 == 0 {
+			apps[i] = &probeRecorder{}
+			server := httptest.NewServer(apps[i])
+			t.Cleanup(server.Close)
+			_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+			spec.Source.Port, _ = strconv.Atoi(port)
+			spec.HealthCheck.Type = deployer.HealthCheckHTTP
+			spec.HealthCheck.Path = fmt.Sprintf("/healthz-%d", i)
+			spec.HealthCheck.Headers = map[string]string{"Host": fmt.Sprintf("svc-%d.internal", i)}
+		} else {
+			spec.Source.Port = 8000 + i
+			spec.HealthCheck.Type = deployer.HealthCheckExec
+			spec.HealthCheck.Command = []string{"check", fmt.Sprintf("svc-%d", i)}
+		}
+		specs[i] = spec
+	}
+
+	results := make([]*deployer.DeploymentResult, services)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		go func(i int, spec *deployer.DeploymentSpec) {
+			defer wg.Done()
+			<-start
+
+			ctx := context.Background()
+			if _, err := d.Prepare(ctx, spec); err != nil {
+				t.Errorf("%s: prepare: %v", spec.ServiceName, err)
+				return
+			}
+			result, err := d.Deploy(ctx, spec)
+			if err != nil {
+				t.Errorf("%s: deploy: %v", spec.ServiceName, err)
+				return
+			}
+			results[i] = result
+
+			health, err := d.HealthCheck(ctx, result)
+			if err != nil {
+				t.Errorf("%s: health check: %v", spec.ServiceName, err)
+				return
+			}
+			if !health.Healthy {
+				t.Errorf("%s: unhealthy: %s", spec.ServiceName, health.Message)
+			}
+		}(i, spec)
+	}
+	close(start)
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	for i, result := range results {
+		name := specs[i].ServiceName
+		if result.HealthCheck != specs[i].HealthCheck {
+			t.Errorf("%s: result carries another deployment's health check", name)
+		}
+
+		if i%2 == 0 {
+			requests := apps[i].seen()
+			if len(requests) < 2 {
+				t.Errorf("%s: probed %d times, want a failed probe and a passing one", name, len(requests))
+			}
+			want := fmt.Sprintf("svc-%d.internal /healthz-%d", i, i)
+			for _, got := range requests {
+				if got != want {
+					t.Errorf("%s: probed with %q, want %q", name, got, want)
+				}
+			}
+			continue
+		}
+
+		execs := runtime.execsOf(result.ContainerIDs[0])
+		if len(execs) < 2 {
+			t.Errorf("%s: exec probed %d times, want a failed probe and a passing one", name, len(execs))
+		}
+		for _, cmd := range execs {
+			if fmt.Sprint(cmd) != fmt.Sprint(specs[i].HealthCheck.Command) {
+				t.Errorf("%s: ran %v in its container, want %v", name, cmd, specs[i].HealthCheck.Command)
+			}
+		}
+	}
+
+	// Exec probes only ran in the containers of exec-probed services
+	for i, result := range results {
+		if i%2 == 0 {
+			if execs := runtime.execsOf(result.ContainerIDs[0]); len(execs) > 0 {
+				t.Errorf("%s: HTTP-probed container ran %v", specs[i].ServiceName, execs)
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/container"
+)
+
+// fakeRuntime is an in-memory container.ContainerRuntime. Every container
+// runs as soon as it starts and is reachable at the runtime's address on
+// each network it joins.
+type fakeRuntime struct {
+	addr string // address containers get on their networks
+
+	mu         sync.Mutex
+	containers map[string]*container.ContainerInfo
+	configs    map[string]container.ContainerConfig
+	execs      map[string][][]string // commands run in each container
+	execResult func(id string, cmd []string) *container.ExecResult
+	nextID     int
+}
+
+func newFakeRuntime(addr string) *fakeRuntime {
+	return &fakeRuntime{
+		addr:       addr,
+		containers: make(map[string]*container.ContainerInfo),
+		configs:    make(map[string]container.ContainerConfig),
+		execs:      make(map[string][][]string),
+	}
+}
+
+// execsOf returns the commands run in a container
+func (r *fakeRuntime) execsOf(id string) [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]string{}, r.execs[id]...)
+}
+
+func (r *fakeRuntime) PullImage(ctx context.Context, ref string, auth *container.RegistryAuth) error {
+	return nil
+}
+
+func (r *fakeRuntime) BuildImage(ctx context.Context, opts container.BuildOptions) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (r *fakeRuntime) ListImages(ctx context.Context) ([]container.Image, error) {
+	return nil, nil
+}
+
+func (r *fakeRuntime) InspectImage(ctx context.Context, ref string) (*container.Image, error) {
+	return &container.Image{ID: "sha256:" + ref}, nil
+}
+
+func (r *fakeRuntime) RemoveImage(ctx context.Context, id string) error {
+	return nil
+}
+
+func (r *fakeRuntime) TagImage(ctx context.Context, source, target string) error {
+	return nil
+}
+
+func (r *fakeRuntime) CreateContainer(ctx context.Context, config container.ContainerConfig) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	id := fmt.Sprintf("%064x", r.nextID)
+	networks := make(map[string]string, len(config.Networks))
+	for _, network := range config.Networks {
+		networks[network] = r.addr
+	}
+	r.containers[id] = &container.ContainerInfo{
+		ID:       id,
+		Name:     config.Name,
+		Image:    config.Image,
+		State:    "created",
+		Labels:   config.Labels,
+		Networks: networks,
+	}
+	r.configs[id] = config
+	return id, nil
+}
+
+func (r *fakeRuntime) setState(id, state string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.containers[id]
+	if !ok {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	info.State = state
+	return nil
+}
+
+func (r *fakeRuntime) StartContainer(ctx context.Context, id string) error {
+	return r.setState(id, "running")
+}
+
+func (r *fakeRuntime) StopContainer(ctx context.Context, id string, timeout time.Duration) error {
+	return r.setState(id, "exited")
+}
+
+func (r *fakeRuntime) RestartContainer(ctx context.Context, id string, timeout time.Duration) error {
+	return r.setState(id, "running")
+}
+
+func (r *fakeRuntime) RemoveContainer(ctx context.Context, id string, force bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.containers, id)
+	return nil
+}
+
+func (r *fakeRuntime) InspectContainer(ctx context.Context, id string) (*container.ContainerInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.containers[id]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", id)
+	}
+	copied := *info
+	return &copied, nil
+}
+
+func (r *fakeRuntime) ListContainers(ctx context.Context, filter container.ContainerFilter) ([]container.ContainerInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var infos []container.ContainerInfo
+	for _, info := range r.containers {
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+func (r *fakeRuntime) ContainerLogs(ctx context.Context, id string, opts container.LogOptions) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (r *fakeRuntime) WaitContainer(ctx context.Context, id string) (<-chan container.WaitResult, <-chan error) {
+	return make(chan container.WaitResult), make(chan error)
+}
+
+func (r *fakeRuntime) ExecContainer(ctx context.Context, id string, cmd []string) (*container.ExecResult, error) {
+	r.mu.Lock()
+	r.execs[id] = append(r.execs[id], cmd)
+	execResult := r.execResult
+	r.mu.Unlock()
+
+	if execResult != nil {
+		return execResult(id, cmd), nil
+	}
+	return &container.ExecResult{}, nil
+}
+
+func (r *fakeRuntime) CreateNetwork(ctx context.Context, name string, opts container.NetworkOptions) (string, error) {
+	return name, nil
+}
+
+func (r *fakeRuntime) RemoveNetwork(ctx context.Context, id string) error {
+	return nil
+}
+
+func (r *fakeRuntime) ConnectToNetwork(ctx context.Context, containerID, networkID string) error {
+	return nil
+}
+
+func (r *fakeRuntime) DisconnectFromNetwork(ctx context.Context, containerID, networkID string) error {
+	return nil
+}
+
+func (r *fakeRuntime) CreateVolume(ctx context.Context, name string, opts container.VolumeOptions) error {
+	return nil
+}
+
+func (r *fakeRuntime) RemoveVolume(ctx context.Context, name string) error {
+	return nil
+}
+
+func (r *fakeRuntime) ListVolumes(ctx context.Context) ([]container.Volume, error) {
+	return nil, nil
+}
+
+func (r *fakeRuntime) Ping(ctx context.Context) error {
+	return nil
+}
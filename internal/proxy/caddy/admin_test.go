@@ -0,0 +1,85 @@
+package caddy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+const routesPath = "/config/apps/http/servers/srv0/routes"
+
+// fakeAdmin is an in-memory Caddy admin API serving the srv0 route list
+type fakeAdmin struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	routes []json.RawMessage
+	writes int // route list writes
+	reject int // status route list writes fail with, 0 to accept them
+}
+
+// newFakeAdmin starts a fake admin API holding routes, in order
+func newFakeAdmin(t *testing.T, routes ...string) *fakeAdmin {
+	t.Helper()
+
+	a := &fakeAdmin{}
+	for _, route := range routes {
+		a.routes = append(a.routes, json.RawMessage(route))
+	}
+	a.Server = httptest.NewServer(http.HandlerFunc(a.serve))
+	t.Cleanup(a.Close)
+	return a
+}
+
+func (a *fakeAdmin) serve(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/config/apps/http/servers/srv0" && r.Method == http.MethodGet:
+		_, _ = io.WriteString(w, `{"listen":[":80",":443"]}`)
+	case r.URL.Path == routesPath && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(a.routes)
+	case r.URL.Path == routesPath && r.Method == http.MethodPatch:
+		if a.reject != 0 {
+			http.Error(w, "rejected", a.reject)
+			return
+		}
+		var routes []json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&routes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.routes = routes
+		a.writes++
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// snapshot returns the route list and the number of writes so far
+func (a *fakeAdmin) snapshot() ([]json.RawMessage, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]json.RawMessage(nil), a.routes...), a.writes
+}
+
+// setRoutes replaces the route list, as changes made behind Nebula's back do
+func (a *fakeAdmin) setRoutes(routes ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.routes = nil
+	for _, route := range routes {
+		a.routes = append(a.routes, json.RawMessage(route))
+	}
+}
+
+// rejectWrites makes route list writes fail with status
+func (a *fakeAdmin) rejectWrites(status int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.reject = status
+}
@@ -3,29 +3,52 @@ package caddy
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/victalejo/nebula/internal/core/container"
 	"github.com/victalejo/nebula/internal/core/logger"
 	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/proxy/dockernet"
 )
 
 // Manager implements the ProxyManager interface for Caddy
 type Manager struct {
-	adminAPI string
-	network  string
-	client   *http.Client
-	log      logger.Logger
+	adminAPI  string
+	network   string
+	container string // Caddy's container, empty when Caddy runs on the host
+	acme      ACMEOptions
+	runtime   container.ContainerRuntime
+	client    *http.Client
+	log       logger.Logger
+
+	mu sync.Mutex // serializes route changes, which rewrite the route list
+
+	// routes are Nebula's routes as last synced, keyed by @id, so they can
+	// be rendered again; nil until the first sync
+	routes map[string]proxy.Route
 }
 
-// NewManager creates a new Caddy manager
-func NewManager(adminAPI string, network string, log logger.Logger) *Manager {
+// NewManager creates a new Caddy manager. When Caddy runs in a container,
+// it is attached to the networks of the containers it routes to. The ACME
+// options shape Caddy's TLS automation.
+func NewManager(adminAPI string, network string, caddyContainer string, acme ACMEOptions, runtime container.ContainerRuntime, log logger.Logger) *Manager {
 	return &Manager{
-		adminAPI: adminAPI,
-		network:  network,
+		adminAPI:  adminAPI,
+		network:   network,
+		container: caddyContainer,
+		acme:      acme,
+		runtime:   runtime,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -33,6 +56,19 @@ func NewManager(adminAPI string, network string, log logger.Logger) *Manager {
 	}
 }
 
+// AttachNetwork connects Caddy's container to a Docker network, so it can
+// reach the containers on it by name
+func (m *Manager) AttachNetwork(ctx context.Context, network string) error {
+	attached, err := dockernet.Attach(ctx, m.runtime, m.container, network)
+	if err != nil {
+		return err
+	}
+	if attached {
+		m.log.Info("caddy attached to network", "network", network)
+	}
+	return nil
+}
+
 // CaddyConfig represents the Caddy JSON configuration
 type CaddyConfig struct {
 	Apps CaddyApps `json:"apps"`
@@ -48,72 +84,558 @@ type CaddyHTTP struct {
 
 type CaddyServer struct {
 	Listen []string      `json:"listen"`
-	Routes []CaddyRoute  `json:"routes"`
+	Routes []CaddyRoute `json:"routes"`
 }
 
 type CaddyRoute struct {
-	Match   []CaddyMatch   `json:"match,omitempty"`
-	Handle  []CaddyHandler `json:"handle"`
-	Terminal bool          `json:"terminal,omitempty"`
+	ID       string         `json:"@id,omitempty"`
+	Match    []CaddyMatch   `json:"match,omitempty"`
+	Handle   []CaddyHandler `json:"handle"`
+	Terminal bool           `json:"terminal,omitempty"`
+
+	// raw is the route as read from Caddy, written back unchanged so fields
+	// Nebula does not model survive
+	raw json.RawMessage
 }
 
 type CaddyMatch struct {
-	Host []string `json:"host,omitempty"`
+	Host       []string               `json:"host,omitempty"`
+	Path       []string               `json:"path,omitempty"`
+	Protocol   string                 `json:"protocol,omitempty"`
+	VarsRegexp map[string]CaddyRegexp `json:"vars_regexp,omitempty"`
+	RemoteIP   *CaddyRemoteIP         `json:"remote_ip,omitempty"`
+	Not        []CaddyMatch           `json:"not,omitempty"`
+	Method     []string               `json:"method,omitempty"`
+	Header     map[string][]string    `json:"header,omitempty"`
+}
+
+type CaddyRemoteIP struct {
+	Ranges []string `json:"ranges"`
+}
+
+// CaddyRegexp is a named regular expression; its groups are available as
+// {http.regexp.<name>.<n>} placeholders
+type CaddyRegexp struct {
+	Name    string `json:"name,omitempty"`
+	Pattern string `json:"pattern"`
 }
 
 type CaddyHandler struct {
-	Handler   string           `json:"handler"`
-	Upstreams []CaddyUpstream  `json:"upstreams,omitempty"`
-	Routes    []CaddyRoute     `json:"routes,omitempty"`
+	Handler         string                    `json:"handler"`
+	Upstreams       []CaddyUpstream           `json:"upstreams,omitempty"`
+	LoadBalancing   *CaddyLoadBalancing       `json:"load_balancing,omitempty"`
+	HealthChecks    *CaddyHealthChecks        `json:"health_checks,omitempty"`
+	Routes          []CaddyRoute              `json:"routes,omitempty"`
+	StripPathPrefix string                    `json:"strip_path_prefix,omitempty"`
+	PathRegexp      []CaddyPathRegexp         `json:"path_regexp,omitempty"`
+	StatusCode      int                       `json:"status_code,omitempty"`
+	Headers         map[string][]string       `json:"headers,omitempty"`
+	Providers       *CaddyAuthProviders       `json:"providers,omitempty"`
+	RateLimits      map[string]CaddyRateLimit `json:"rate_limits,omitempty"`
+	Response        *CaddyHeaderOps           `json:"response,omitempty"`
+}
+
+// CaddyHeaderOps are the response header operations of the headers
+// handler. Deferred operations apply once the response is written, so they
+// override the upstream's headers.
+type CaddyHeaderOps struct {
+	Set      map[string][]string `json:"set,omitempty"`
+	Add      map[string][]string `json:"add,omitempty"`
+	Delete   []string            `json:"delete,omitempty"`
+	Deferred bool                `json:"deferred,omitempty"`
+}
+
+type CaddyAuthProviders struct {
+	HTTPBasic *CaddyHTTPBasic `json:"http_basic,omitempty"`
+}
+
+type CaddyHTTPBasic struct {
+	Accounts []CaddyAccount `json:"accounts"`
+	Hash     CaddyHash      `json:"hash"`
+	Realm    string         `json:"realm,omitempty"`
+}
+
+// CaddyAccount is a basic auth account; Caddy expects the password hash
+// base64 encoded
+type CaddyAccount struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type CaddyHash struct {
+	Algorithm string `json:"algorithm"`
+}
+
+// CaddyRateLimit is a zone of the rate_limit handler, which is provided by
+// the github.com/mholt/caddy-ratelimit plugin
+type CaddyRateLimit struct {
+	Key       string `json:"key"`
+	Window    string `json:"window"`
+	MaxEvents int    `json:"max_events"`
+}
+
+// CaddyPathRegexp replaces the matches of a regular expression in the path
+type CaddyPathRegexp struct {
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
 }
 
 type CaddyUpstream struct {
 	Dial string `json:"dial"`
 }
 
-// AddRoute adds a new route to Caddy
+type CaddyLoadBalancing struct {
+	SelectionPolicy *CaddySelectionPolicy `json:"selection_policy,omitempty"`
+}
+
+type CaddySelectionPolicy struct {
+	Policy  string `json:"policy"`
+	Weights []int  `json:"weights,omitempty"`
+}
+
+type CaddyHealthChecks struct {
+	Passive *CaddyPassiveHealthCheck `json:"passive,omitempty"`
+}
+
+// CaddyPassiveHealthCheck counts failed responses per upstream
+type CaddyPassiveHealthCheck struct {
+	FailDuration    string `json:"fail_duration,omitempty"`
+	MaxFails        int    `json:"max_fails,omitempty"`
+	UnhealthyStatus []int  `json:"unhealthy_status,omitempty"`
+}
+
+// caddyUpstreamStatus is returned by the admin API's /reverse_proxy/upstreams
+type caddyUpstreamStatus struct {
+	Address     string `json:"address"`
+	NumRequests int    `json:"num_requests"`
+	Fails       int    `json:"fails"`
+}
+
+// AddRoute adds a route to Caddy, replacing any route already serving the
+// same domain and path prefix
 func (m *Manager) AddRoute(ctx context.Context, route proxy.Route) error {
-	m.log.Info("adding route to caddy",
-		"domain", route.Domain,
-		"app_id", route.AppID,
-	)
+	return m.UpdateRoute(ctx, route)
+}
 
-	// Get current active upstream
-	var upstream *proxy.Upstream
-	if route.ActiveSlot == proxy.SlotBlue {
-		upstream = route.BlueTarget
-	} else {
-		upstream = route.GreenTarget
+// routeIDPrefix marks the routes Nebula manages in Caddy's config
+const routeIDPrefix = "nebula:"
+
+// routeID returns the @id of the route serving a domain and path prefix
+func routeID(domain, pathPrefix string) string {
+	if pathPrefix == "" {
+		pathPrefix = "/"
 	}
+	return routeIDPrefix + domain + pathPrefix
+}
 
-	if upstream == nil {
-		return fmt.Errorf("no active upstream configured")
+// ownedIDPrefix prefixes the @id of routes rendered for a proxy route besides
+// its own, such as host redirects, so they are replaced along with it
+func ownedIDPrefix(domain, pathPrefix string) string {
+	return routeID(domain, pathPrefix) + "#"
+}
+
+// renderRoute builds the Caddy routes of a proxy route: the route itself and
+// one route per host redirected to it
+func renderRoute(route proxy.Route) ([]CaddyRoute, error) {
+	handler, err := reverseProxyHandler(route)
+	if err != nil {
+		return nil, fmt.Errorf("route %s%s: %w", route.Domain, route.PathPrefix, err)
 	}
 
-	// Create reverse proxy route
 	caddyRoute := CaddyRoute{
-		Match: []CaddyMatch{
-			{Host: []string{route.Domain}},
+		ID:       routeID(route.Domain, route.PathPrefix),
+		Match:    []CaddyMatch{routeMatch(route)},
+		Terminal: true,
+	}
+	if redirects := redirectRoutes(route); len(redirects) > 0 {
+		caddyRoute.Handle = append(caddyRoute.Handle, CaddyHandler{Handler: "subroute", Routes: redirects})
+	}
+	if len(route.SetHeaders) > 0 || len(route.RemoveHeaders) > 0 {
+		caddyRoute.Handle = append(caddyRoute.Handle, headersHandler(route))
+	}
+	if route.CORS != nil {
+		caddyRoute.Handle = append(caddyRoute.Handle, corsHandler(route.CORS))
+	}
+	caddyRoute.Handle = append(caddyRoute.Handle, accessHandlers(route)...)
+	if len(route.Rewrites) > 0 {
+		caddyRoute.Handle = append(caddyRoute.Handle, rewriteHandler(route.Rewrites))
+	}
+	if route.PathMatched() && route.StripPrefix {
+		caddyRoute.Handle = append(caddyRoute.Handle, CaddyHandler{Handler: "rewrite", StripPathPrefix: route.PathPrefix})
+	}
+	caddyRoute.Handle = append(caddyRoute.Handle, handler)
+
+	routes := []CaddyRoute{caddyRoute}
+	scheme := "{http.request.scheme}"
+	if route.SSLEnabled || route.ForceHTTPS {
+		scheme = "https"
+	}
+	for _, redirect := range route.HostRedirects {
+		routes = append(routes, CaddyRoute{
+			ID:       ownedIDPrefix(route.Domain, route.PathPrefix) + "redirect:" + redirect.Host,
+			Match:    []CaddyMatch{{Host: []string{redirect.Host}}},
+			Handle:   []CaddyHandler{redirectHandler(redirect.Status, scheme+"://"+route.Domain+"{http.request.uri}")},
+			Terminal: true,
+		})
+	}
+	return routes, nil
+}
+
+// redirectRoutes answers plain HTTP requests and requests for redirected
+// paths before they are proxied. Paths are matched with the query string, so
+// redirects keep it.
+func redirectRoutes(route proxy.Route) []CaddyRoute {
+	var routes []CaddyRoute
+	if route.ForceHTTPS {
+		routes = append(routes, CaddyRoute{
+			Match:  []CaddyMatch{{Protocol: "http"}},
+			Handle: []CaddyHandler{redirectHandler(308, "https://{http.request.host}{http.request.uri}")},
+		})
+	}
+
+	for i, redirect := range route.Redirects {
+		exact, below := proxy.PathPatterns(redirect.From)
+		name := fmt.Sprintf("redirect%d", i)
+		group := func(n int) string { return fmt.Sprintf("{http.regexp.%s.%d}", name, n) }
+
+		routes = append(routes,
+			CaddyRoute{
+				Match:  []CaddyMatch{uriRegexp(name, "^"+exact+`(\?.*)?$`)},
+				Handle: []CaddyHandler{redirectHandler(redirect.Status, redirect.To+group(1))},
+			},
+			CaddyRoute{
+				Match:  []CaddyMatch{uriRegexp(name, "^"+below+`(\?.*)?$`)},
+				Handle: []CaddyHandler{redirectHandler(redirect.Status, proxy.JoinPath(redirect.To, group(1))+group(2))},
+			},
+		)
+	}
+	return routes
+}
+
+// headersHandler sets and removes the route's response headers
+func headersHandler(route proxy.Route) CaddyHandler {
+	ops := &CaddyHeaderOps{Delete: route.RemoveHeaders, Deferred: true}
+	for name, value := range route.SetHeaders {
+		if ops.Set == nil {
+			ops.Set = make(map[string][]string)
+		}
+		ops.Set[name] = []string{value}
+	}
+	return CaddyHandler{Handler: "headers", Response: ops}
+}
+
+// corsHandler answers preflight requests from allowed origins before access
+// control, since browsers send them without credentials, and adds CORS
+// headers to the responses of other requests from them
+func corsHandler(cors *proxy.CORS) CaddyHandler {
+	origins := cors.AllowOrigins
+	allowOrigin := "{http.request.header.Origin}"
+	if cors.AnyOrigin() {
+		origins = []string{"*"}
+		if !cors.AllowCredentials {
+			allowOrigin = "*"
+		}
+	}
+
+	preflight := map[string][]string{
+		"Access-Control-Allow-Origin":  {allowOrigin},
+		"Access-Control-Allow-Methods": {strings.Join(cors.AllowMethods, ", ")},
+		"Vary":                         {"Origin"},
+	}
+	response := &CaddyHeaderOps{
+		Set:      map[string][]string{"Access-Control-Allow-Origin": {allowOrigin}},
+		Add:      map[string][]string{"Vary": {"Origin"}},
+		Deferred: true,
+	}
+	if len(cors.AllowHeaders) > 0 {
+		preflight["Access-Control-Allow-Headers"] = []string{strings.Join(cors.AllowHeaders, ", ")}
+	}
+	if cors.MaxAge > 0 {
+		preflight["Access-Control-Max-Age"] = []string{fmt.Sprint(cors.MaxAge)}
+	}
+	if cors.AllowCredentials {
+		preflight["Access-Control-Allow-Credentials"] = []string{"true"}
+		response.Set["Access-Control-Allow-Credentials"] = []string{"true"}
+	}
+	if len(cors.ExposeHeaders) > 0 {
+		response.Set["Access-Control-Expose-Headers"] = []string{strings.Join(cors.ExposeHeaders, ", ")}
+	}
+
+	return CaddyHandler{Handler: "subroute", Routes: []CaddyRoute{
+		{
+			Match: []CaddyMatch{{
+				Method: []string{"OPTIONS"},
+				Header: map[string][]string{"Origin": origins, "Access-Control-Request-Method": {"*"}},
+			}},
+			Handle: []CaddyHandler{{Handler: "static_response", StatusCode: 204, Headers: preflight}},
+		},
+		{
+			Match:  []CaddyMatch{{Header: map[string][]string{"Origin": origins}}},
+			Handle: []CaddyHandler{{Handler: "headers", Response: response}},
 		},
-		Handle: []CaddyHandler{
-			{
-				Handler: "reverse_proxy",
-				Upstreams: []CaddyUpstream{
-					{Dial: fmt.Sprintf("%s:%d", upstream.Host, upstream.Port)},
+	}}
+}
+
+// accessHandlers reject clients by IP, limit their request rate and require
+// basic auth, in that order
+func accessHandlers(route proxy.Route) []CaddyHandler {
+	var handlers []CaddyHandler
+
+	var ipRoutes []CaddyRoute
+	if len(route.DenyCIDRs) > 0 {
+		ipRoutes = append(ipRoutes, CaddyRoute{
+			Match:  []CaddyMatch{{RemoteIP: &CaddyRemoteIP{Ranges: route.DenyCIDRs}}},
+			Handle: []CaddyHandler{{Handler: "static_response", StatusCode: 403}},
+		})
+	}
+	if len(route.AllowCIDRs) > 0 {
+		ipRoutes = append(ipRoutes, CaddyRoute{
+			Match:  []CaddyMatch{{Not: []CaddyMatch{{RemoteIP: &CaddyRemoteIP{Ranges: route.AllowCIDRs}}}}},
+			Handle: []CaddyHandler{{Handler: "static_response", StatusCode: 403}},
+		})
+	}
+	if len(ipRoutes) > 0 {
+		handlers = append(handlers, CaddyHandler{Handler: "subroute", Routes: ipRoutes})
+	}
+
+	if route.RateLimit != nil {
+		handlers = append(handlers, CaddyHandler{
+			Handler: "rate_limit",
+			RateLimits: map[string]CaddyRateLimit{
+				routeID(route.Domain, route.PathPrefix): {
+					Key:       "{http.request.remote.host}",
+					Window:    route.RateLimit.Window.String(),
+					MaxEvents: route.RateLimit.Requests,
 				},
 			},
-		},
-		Terminal: true,
+		})
+	}
+
+	if len(route.BasicAuth) > 0 {
+		basic := &CaddyHTTPBasic{Hash: CaddyHash{Algorithm: "bcrypt"}, Realm: "restricted"}
+		for _, user := range route.BasicAuth {
+			basic.Accounts = append(basic.Accounts, CaddyAccount{
+				Username: user.Username,
+				Password: base64.StdEncoding.EncodeToString([]byte(user.PasswordHash)),
+			})
+		}
+		handlers = append(handlers, CaddyHandler{
+			Handler:   "authentication",
+			Providers: &CaddyAuthProviders{HTTPBasic: basic},
+		})
+	}
+	return handlers
+}
+
+// uriRegexp matches the request URI, path and query, against a pattern
+func uriRegexp(name, pattern string) CaddyMatch {
+	return CaddyMatch{VarsRegexp: map[string]CaddyRegexp{
+		"{http.request.uri}": {Name: name, Pattern: pattern},
+	}}
+}
+
+func redirectHandler(status int, location string) CaddyHandler {
+	return CaddyHandler{
+		Handler:    "static_response",
+		StatusCode: status,
+		Headers:    map[string][]string{"Location": {location}},
+	}
+}
+
+// rewriteHandler replaces path prefixes in order. The paths below a prefix
+// are rewritten before the prefix itself, so a rewrite into a path below its
+// own prefix applies once.
+func rewriteHandler(rewrites []proxy.Rewrite) CaddyHandler {
+	handler := CaddyHandler{Handler: "rewrite"}
+	for _, rewrite := range rewrites {
+		exact, below := proxy.PathPatterns(rewrite.From)
+		handler.PathRegexp = append(handler.PathRegexp,
+			CaddyPathRegexp{Find: "^" + below + "$", Replace: proxy.JoinPath(rewrite.To, "$1")},
+			CaddyPathRegexp{Find: "^" + exact + "$", Replace: rewrite.To},
+		)
+	}
+	return handler
+}
+
+// managed reports whether Nebula owns a route: its @id carries Nebula's
+// prefix. Routes from the Caddyfile or added by hand are left alone; routes
+// Nebula added before routes carried an @id are tagged by AdoptLegacyRoutes.
+func managed(route CaddyRoute) bool {
+	return strings.HasPrefix(route.ID, routeIDPrefix)
+}
+
+// AdoptLegacyRoutes tags the routes Nebula added before routes carried an
+// @id, so later changes replace them instead of adding duplicates. Only
+// untagged routes serving one of hosts are adopted; of several serving the
+// same host and path prefix, the first is kept. It returns how many routes
+// were adopted.
+func (m *Manager) AdoptLegacyRoutes(ctx context.Context, hosts []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, err := m.getRoutes(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	known := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		known[host] = true
+	}
+	ids := make(map[string]bool)
+	for _, route := range current {
+		if managed(route) {
+			ids[route.ID] = true
+		}
+	}
+
+	adopted := 0
+	routes := make([]CaddyRoute, 0, len(current))
+	for _, route := range current {
+		if route.ID == "" && known[routeHost(route)] {
+			id := routeID(routeHost(route), routePathPrefix(route))
+			if ids[id] {
+				continue // duplicate left behind by append-only updates
+			}
+			route.ID, route.raw = id, nil
+			ids[id] = true
+			adopted++
+		}
+		routes = append(routes, route)
+	}
+	if len(routes) == len(current) && adopted == 0 {
+		return 0, nil
+	}
+
+	m.log.Info("adopting legacy caddy routes", "routes", adopted, "dropped", len(current)-len(routes))
+	return adopted, m.putRoutes(ctx, routes)
+}
+
+// routeHost returns the first host a Caddy route matches
+func routeHost(route CaddyRoute) string {
+	for _, match := range route.Match {
+		if len(match.Host) > 0 {
+			return match.Host[0]
+		}
 	}
+	return ""
+}
+
+// mergeRoutes combines Nebula's routes with the routes Nebula does not manage.
+// Caddy runs the first matching route, so Nebula's routes come first with
+// longer path prefixes before shorter ones and exact hosts before wildcards,
+// and the others keep their order behind them.
+func mergeRoutes(nebula []CaddyRoute, current []CaddyRoute) []CaddyRoute {
+	sorted := append([]CaddyRoute(nil), nebula...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		pi, pj := routePathPrefix(sorted[i]), routePathPrefix(sorted[j])
+		if len(pi) != len(pj) {
+			return len(pi) > len(pj)
+		}
+		wi, wj := strings.HasPrefix(routeHost(sorted[i]), "*."), strings.HasPrefix(routeHost(sorted[j]), "*.")
+		if wi != wj {
+			return wj
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
 
-	// Add route via Caddy Admin API
-	routeJSON, err := json.Marshal(caddyRoute)
+	merged := make([]CaddyRoute, 0, len(current)+len(sorted))
+	merged = append(merged, sorted...)
+	for _, route := range current {
+		if !managed(route) {
+			merged = append(merged, route)
+		}
+	}
+	return merged
+}
+
+// writeRoutes applies a change to Nebula's routes in Caddy with m.mu held.
+// Changes are written with a single request, so Caddy never sees a partial
+// route set.
+func (m *Manager) writeRoutes(ctx context.Context, change func(map[string]CaddyRoute)) error {
+	current, err := m.getRoutes(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal route: %w", err)
+		return err
+	}
+
+	nebula := make(map[string]CaddyRoute)
+	for _, route := range current {
+		if !managed(route) {
+			continue
+		}
+		if _, ok := nebula[route.ID]; ok {
+			continue // duplicate left behind by append-only updates
+		}
+		nebula[route.ID] = route
+	}
+	change(nebula)
+
+	routes := make([]CaddyRoute, 0, len(nebula))
+	for _, route := range nebula {
+		routes = append(routes, route)
+	}
+	// Policies for new wildcard hosts go in before their routes, so Caddy
+	// never tries to obtain a wildcard certificate without DNS-01
+	if err := m.syncAutomation(ctx, routes); err != nil {
+		return err
+	}
+
+	merged := mergeRoutes(routes, current)
+	if routesEqual(merged, current) {
+		return nil
+	}
+	return m.putRoutes(ctx, merged)
+}
+
+// encodeRoutes renders a route list, keeping routes read from Caddy as they were
+func encodeRoutes(routes []CaddyRoute) ([]byte, error) {
+	encoded := make([]json.RawMessage, len(routes))
+	for i, route := range routes {
+		if route.raw != nil {
+			encoded[i] = route.raw
+			continue
+		}
+		data, err := json.Marshal(route)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = data
+	}
+	return json.Marshal(encoded)
+}
+
+// routesEqual reports whether two route lists render to the same config,
+// regardless of how Caddy orders the keys it returns
+func routesEqual(a, b []CaddyRoute) bool {
+	aConfig, errA := routesConfig(a)
+	bConfig, errB := routesConfig(b)
+	return errA == nil && errB == nil && reflect.DeepEqual(aConfig, bConfig)
+}
+
+// routesConfig decodes a route list into generic JSON values
+func routesConfig(routes []CaddyRoute) (interface{}, error) {
+	data, err := encodeRoutes(routes)
+	if err != nil {
+		return nil, err
+	}
+	var config interface{}
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+// putRoutes replaces the server's route list
+func (m *Manager) putRoutes(ctx context.Context, routes []CaddyRoute) error {
+	if err := m.InitializeServer(ctx); err != nil {
+		return err
+	}
+
+	routesJSON, err := encodeRoutes(routes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal routes: %w", err)
 	}
 
 	url := fmt.Sprintf("%s/config/apps/http/servers/srv0/routes", m.adminAPI)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(routeJSON))
+	req, err := http.NewRequestWithContext(ctx, "PATCH", url, bytes.NewReader(routesJSON))
 	if err != nil {
 		return err
 	}
@@ -121,7 +643,7 @@ func (m *Manager) AddRoute(ctx context.Context, route proxy.Route) error {
 
 	resp, err := m.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to add route: %w", err)
+		return fmt.Errorf("failed to update routes: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -129,96 +651,366 @@ func (m *Manager) AddRoute(ctx context.Context, route proxy.Route) error {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("caddy returned error %d: %s", resp.StatusCode, string(body))
 	}
+	return nil
+}
 
-	m.log.Info("route added successfully",
-		"domain", route.Domain,
-	)
+// SyncRoutes makes routes the complete set of Nebula routes in Caddy. Stale
+// and duplicate routes are dropped; routes Nebula does not manage are kept.
+func (m *Manager) SyncRoutes(ctx context.Context, routes []proxy.Route) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.syncRoutes(ctx, routes)
+}
 
+// syncRoutes is SyncRoutes with m.mu held. The routes are kept to be
+// rendered again.
+func (m *Manager) syncRoutes(ctx context.Context, routes []proxy.Route) error {
+	desired, err := renderRoutes(routes)
+	if err != nil {
+		return err
+	}
+
+	m.log.Info("syncing caddy routes", "routes", len(desired))
+	err = m.writeRoutes(ctx, func(nebula map[string]CaddyRoute) {
+		for id := range nebula {
+			delete(nebula, id)
+		}
+		for id, route := range desired {
+			nebula[id] = route
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	m.routes = make(map[string]proxy.Route, len(routes))
+	for _, route := range routes {
+		m.routes[routeID(route.Domain, route.PathPrefix)] = route
+	}
 	return nil
 }
 
-// UpdateRoute updates an existing route
-func (m *Manager) UpdateRoute(ctx context.Context, route proxy.Route) error {
-	// For simplicity, remove and re-add the route
-	if err := m.RemoveRoute(ctx, route.Domain); err != nil {
-		m.log.Warn("failed to remove old route", "error", err)
+// syncedRoutes returns the routes as last synced, with m.mu held
+func (m *Manager) syncedRoutes() ([]proxy.Route, error) {
+	if m.routes == nil {
+		return nil, fmt.Errorf("caddy routes have not been synced")
 	}
-	return m.AddRoute(ctx, route)
+	routes := make([]proxy.Route, 0, len(m.routes))
+	for _, route := range m.routes {
+		routes = append(routes, route)
+	}
+	return routes, nil
 }
 
-// RemoveRoute removes a route from Caddy
-func (m *Manager) RemoveRoute(ctx context.Context, domain string) error {
-	m.log.Info("removing route from caddy", "domain", domain)
-
-	// Get all routes and find the one to remove
-	routes, err := m.getRoutes(ctx)
+// DiffRoutes reports the changes SyncRoutes would make to Caddy's routes
+func (m *Manager) DiffRoutes(ctx context.Context, routes []proxy.Route) ([]proxy.RouteChange, error) {
+	desired, err := renderRoutes(routes)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	current, err := m.getRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []proxy.RouteChange
+	seen := make(map[string]bool)
+	for _, route := range current {
+		if !managed(route) {
+			continue
+		}
+		id := route.ID
+		change := proxy.RouteChange{
+			Domain:     routeHost(route),
+			PathPrefix: routePathPrefix(route),
+			Current:    marshalRoute(route),
+		}
+		want, ok := desired[id]
+		switch {
+		case !ok || seen[id]:
+			change.Action = proxy.RouteRemoved
+		case !routesEqual([]CaddyRoute{route}, []CaddyRoute{want}):
+			change.Action = proxy.RouteUpdated
+			change.Desired = marshalRoute(want)
+		}
+		seen[id] = true
+		if change.Action != "" {
+			changes = append(changes, change)
+		}
+	}
+
+	ids := make([]string, 0, len(desired))
+	for id := range desired {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		route := desired[id]
+		changes = append(changes, proxy.RouteChange{
+			Action:     proxy.RouteAdded,
+			Domain:     routeHost(route),
+			PathPrefix: routePathPrefix(route),
+			Desired:    marshalRoute(route),
+		})
+	}
+	return changes, nil
+}
+
+// renderRoutes renders proxy routes keyed by their @id
+func renderRoutes(routes []proxy.Route) (map[string]CaddyRoute, error) {
+	rendered := make(map[string]CaddyRoute, len(routes))
+	for _, route := range routes {
+		caddyRoutes, err := renderRoute(route)
+		if err != nil {
+			return nil, err
+		}
+		for _, caddyRoute := range caddyRoutes {
+			rendered[caddyRoute.ID] = caddyRoute
+		}
 	}
+	return rendered, nil
+}
+
+func marshalRoute(route CaddyRoute) json.RawMessage {
+	if route.raw != nil {
+		return route.raw
+	}
+	data, _ := json.Marshal(route)
+	return data
+}
+
+// routeMatch matches the route's host and, for path routes, the prefix itself
+// and everything below it
+func routeMatch(route proxy.Route) CaddyMatch {
+	match := CaddyMatch{Host: route.Hosts()}
+	if route.PathMatched() {
+		match.Path = []string{route.PathPrefix, route.PathPrefix + "/*"}
+	}
+	return match
+}
 
+// routePathPrefix returns the path prefix a Caddy route matches, "/" for
+// whole hosts
+func routePathPrefix(route CaddyRoute) string {
+	for _, match := range route.Match {
+		if len(match.Path) > 0 {
+			return match.Path[0]
+		}
+	}
+	return "/"
+}
+
+// findRoute returns the index of the route serving a domain and path prefix,
+// or -1
+func findRoute(routes []CaddyRoute, domain, pathPrefix string) int {
+	if pathPrefix == "" {
+		pathPrefix = "/"
+	}
 	for i, route := range routes {
+		if routePathPrefix(route) != pathPrefix {
+			continue
+		}
 		for _, match := range route.Match {
 			for _, host := range match.Host {
 				if host == domain {
-					// Found the route, delete it
-					url := fmt.Sprintf("%s/config/apps/http/servers/srv0/routes/%d", m.adminAPI, i)
-					req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
-					if err != nil {
-						return err
-					}
-
-					resp, err := m.client.Do(req)
-					if err != nil {
-						return fmt.Errorf("failed to remove route: %w", err)
-					}
-					resp.Body.Close()
-
-					if resp.StatusCode >= 400 {
-						return fmt.Errorf("caddy returned error %d", resp.StatusCode)
-					}
-
-					m.log.Info("route removed successfully", "domain", domain)
-					return nil
+					return i
 				}
 			}
 		}
 	}
+	return -1
+}
 
-	return nil // Route not found, consider it removed
+// toProxyRoute converts a Caddy route back into a proxy route for one host
+func toProxyRoute(route CaddyRoute, host string) proxy.Route {
+	result := proxy.Route{
+		Domain:     host,
+		PathPrefix: routePathPrefix(route),
+		ActiveSlot: proxy.SlotBlue,
+	}
+	for _, handler := range route.Handle {
+		switch handler.Handler {
+		case "rewrite":
+			result.StripPrefix = handler.StripPathPrefix != ""
+		case "reverse_proxy":
+			if len(handler.Upstreams) > 0 {
+				result.BlueTarget = dialUpstream(handler.Upstreams[0].Dial)
+			}
+		}
+	}
+	return result
 }
 
-// GetRoute retrieves a route by domain
-func (m *Manager) GetRoute(ctx context.Context, domain string) (*proxy.Route, error) {
-	routes, err := m.getRoutes(ctx)
+// dialUpstream parses an upstream's host:port dial address, or returns nil
+// when it has none
+func dialUpstream(dial string) *proxy.Upstream {
+	host, portStr, err := net.SplitHostPort(dial)
+	if err != nil {
+		return nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil
+	}
+	return &proxy.Upstream{Host: host, Port: port}
+}
+
+// reverseProxyHandler builds the reverse_proxy handler for a route, splitting
+// traffic between both slots with weighted round robin for canary releases
+func reverseProxyHandler(route proxy.Route) (CaddyHandler, error) {
+	handler := CaddyHandler{Handler: "reverse_proxy"}
+
+	if route.Weighted() {
+		handler.Upstreams = []CaddyUpstream{
+			{Dial: fmt.Sprintf("%s:%d", route.BlueTarget.Host, route.BlueTarget.Port)},
+			{Dial: fmt.Sprintf("%s:%d", route.GreenTarget.Host, route.GreenTarget.Port)},
+		}
+		handler.LoadBalancing = &CaddyLoadBalancing{
+			SelectionPolicy: &CaddySelectionPolicy{
+				Policy:  "weighted_round_robin",
+				Weights: []int{route.BlueWeight, route.GreenWeight},
+			},
+		}
+	} else {
+		// Get current active upstream
+		var upstream *proxy.Upstream
+		if route.ActiveSlot == proxy.SlotBlue {
+			upstream = route.BlueTarget
+		} else {
+			upstream = route.GreenTarget
+		}
+
+		if upstream == nil {
+			return handler, fmt.Errorf("no active upstream configured")
+		}
+		handler.Upstreams = []CaddyUpstream{
+			{Dial: fmt.Sprintf("%s:%d", upstream.Host, upstream.Port)},
+		}
+	}
+
+	if route.FailureWindow > 0 {
+		// Count 5xx responses without ever taking the upstream out of rotation
+		handler.HealthChecks = &CaddyHealthChecks{
+			Passive: &CaddyPassiveHealthCheck{
+				FailDuration:    route.FailureWindow.String(),
+				MaxFails:        1 << 30,
+				UnhealthyStatus: []int{5},
+			},
+		}
+	}
+
+	return handler, nil
+}
+
+// UpstreamStats returns the failure counts Caddy keeps per upstream
+func (m *Manager) UpstreamStats(ctx context.Context) ([]proxy.UpstreamStats, error) {
+	url := fmt.Sprintf("%s/reverse_proxy/upstreams", m.adminAPI)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, route := range routes {
-		for _, match := range route.Match {
-			for _, host := range match.Host {
-				if host == domain {
-					// Found the route
-					var upstream *proxy.Upstream
-					if len(route.Handle) > 0 && len(route.Handle[0].Upstreams) > 0 {
-						dial := route.Handle[0].Upstreams[0].Dial
-						var host string
-						var port int
-						_, _ = fmt.Sscanf(dial, "%s:%d", &host, &port)
-						upstream = &proxy.Upstream{Host: host, Port: port}
-					}
-
-					return &proxy.Route{
-						Domain:     domain,
-						BlueTarget: upstream, // Simplified
-						ActiveSlot: proxy.SlotBlue,
-					}, nil
-				}
-			}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upstream stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("caddy returned error %d", resp.StatusCode)
+	}
+
+	var statuses []caddyUpstreamStatus
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		return nil, err
+	}
+
+	stats := make([]proxy.UpstreamStats, len(statuses))
+	for i, st := range statuses {
+		stats[i] = proxy.UpstreamStats{
+			Address:  st.Address,
+			Requests: st.NumRequests,
+			Fails:    st.Fails,
+		}
+	}
+	return stats, nil
+}
+
+// UpdateRoute creates or replaces the route of a domain and path prefix
+func (m *Manager) UpdateRoute(ctx context.Context, route proxy.Route) error {
+	m.log.Info("updating caddy route",
+		"domain", route.Domain,
+		"path_prefix", route.PathPrefix,
+		"app_id", route.AppID,
+	)
+
+	caddyRoutes, err := renderRoute(route)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err = m.writeRoutes(ctx, func(nebula map[string]CaddyRoute) {
+		removeRoute(nebula, route.Domain, route.PathPrefix)
+		for _, caddyRoute := range caddyRoutes {
+			nebula[caddyRoute.ID] = caddyRoute
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if m.routes != nil {
+		m.routes[routeID(route.Domain, route.PathPrefix)] = route
+	}
+	return nil
+}
+
+// RemoveRoute removes the route of a domain and path prefix from Caddy
+func (m *Manager) RemoveRoute(ctx context.Context, domain, pathPrefix string) error {
+	m.log.Info("removing route from caddy", "domain", domain, "path_prefix", pathPrefix)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.writeRoutes(ctx, func(nebula map[string]CaddyRoute) {
+		removeRoute(nebula, domain, pathPrefix)
+	})
+	if err != nil {
+		return err
+	}
+	if m.routes != nil {
+		delete(m.routes, routeID(domain, pathPrefix))
+	}
+	return nil
+}
+
+// removeRoute deletes a route and the routes rendered along with it
+func removeRoute(nebula map[string]CaddyRoute, domain, pathPrefix string) {
+	delete(nebula, routeID(domain, pathPrefix))
+	owned := ownedIDPrefix(domain, pathPrefix)
+	for id := range nebula {
+		if strings.HasPrefix(id, owned) {
+			delete(nebula, id)
 		}
 	}
+}
+
+// GetRoute retrieves the route of a domain and path prefix
+func (m *Manager) GetRoute(ctx context.Context, domain, pathPrefix string) (*proxy.Route, error) {
+	routes, err := m.getRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	return nil, nil
+	i := findRoute(routes, domain, pathPrefix)
+	if i < 0 {
+		return nil, nil
+	}
+	route := toProxyRoute(routes[i], domain)
+	return &route, nil
 }
 
 // ListRoutes returns all routes
@@ -232,20 +1024,7 @@ func (m *Manager) ListRoutes(ctx context.Context) ([]proxy.Route, error) {
 	for _, route := range routes {
 		for _, match := range route.Match {
 			for _, host := range match.Host {
-				var upstream *proxy.Upstream
-				if len(route.Handle) > 0 && len(route.Handle[0].Upstreams) > 0 {
-					dial := route.Handle[0].Upstreams[0].Dial
-					var h string
-					var p int
-					_, _ = fmt.Sscanf(dial, "%s:%d", &h, &p)
-					upstream = &proxy.Upstream{Host: h, Port: p}
-				}
-
-				result = append(result, proxy.Route{
-					Domain:     host,
-					BlueTarget: upstream,
-					ActiveSlot: proxy.SlotBlue,
-				})
+				result = append(result, toProxyRoute(route, host))
 			}
 		}
 	}
@@ -253,16 +1032,33 @@ func (m *Manager) ListRoutes(ctx context.Context) ([]proxy.Route, error) {
 	return result, nil
 }
 
-// SwitchTraffic switches traffic from one slot to another
+// SwitchTraffic points every route of a domain at another slot and renders
+// the synced routes again
 func (m *Manager) SwitchTraffic(ctx context.Context, domain string, targetSlot proxy.Slot) error {
 	m.log.Info("switching traffic",
 		"domain", domain,
 		"target_slot", targetSlot,
 	)
 
-	// This would update the upstream to point to the target slot's containers
-	// For now, this is handled by UpdateRoute with the new upstream
-	return nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	routes, err := m.syncedRoutes()
+	if err != nil {
+		return err
+	}
+	found := false
+	for i, route := range routes {
+		if route.Domain == domain {
+			routes[i].ActiveSlot = targetSlot
+			routes[i].BlueWeight, routes[i].GreenWeight = 0, 0
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no route for domain %s", domain)
+	}
+	return m.syncRoutes(ctx, routes)
 }
 
 // ProvisionSSL provisions SSL for a domain
@@ -294,21 +1090,17 @@ func (m *Manager) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
-// ReloadConfig reloads the Caddy configuration
+// ReloadConfig renders the synced routes into Caddy again, restoring routes
+// changed or lost since, such as by a Caddy restart
 func (m *Manager) ReloadConfig(ctx context.Context) error {
-	url := fmt.Sprintf("%s/load", m.adminAPI)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
-	if err != nil {
-		return err
-	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	resp, err := m.client.Do(req)
+	routes, err := m.syncedRoutes()
 	if err != nil {
-		return fmt.Errorf("failed to reload caddy config: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	return nil
+	return m.syncRoutes(ctx, routes)
 }
 
 // getRoutes retrieves all routes from Caddy
@@ -333,11 +1125,18 @@ func (m *Manager) getRoutes(ctx context.Context) ([]CaddyRoute, error) {
 		return nil, fmt.Errorf("caddy returned error %d", resp.StatusCode)
 	}
 
-	var routes []CaddyRoute
-	if err := json.NewDecoder(resp.Body).Decode(&routes); err != nil {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
 		return nil, err
 	}
 
+	routes := make([]CaddyRoute, len(raw))
+	for i, data := range raw {
+		if err := json.Unmarshal(data, &routes[i]); err != nil {
+			return nil, err
+		}
+		routes[i].raw = data
+	}
 	return routes, nil
 }
 
@@ -0,0 +1,357 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/core/proxy"
+)
+
+// Routes from the Caddyfile: a hand-written host route and the catch-all.
+// Neither carries an @id.
+const (
+	caddyfileHostRoute = `{"match":[{"host":["admin.example.com"]}],"handle":[{"handler":"reverse_proxy","upstreams":[{"dial":"localhost:9000"}]}],"terminal":true}`
+	caddyfileCatchAll  = `{"handle":[{"handler":"static_response","body":"Nebula"}]}`
+)
+
+func newTestManager(admin *fakeAdmin) *Manager {
+	return NewManager(admin.URL, "", "", ACMEOptions{}, nil, logger.New("error"))
+}
+
+// appRoute routes a domain to an upstream on the blue slot
+func appRoute(domain string, port int) proxy.Route {
+	return proxy.Route{
+		Domain:     domain,
+		ActiveSlot: proxy.SlotBlue,
+		BlueTarget: &proxy.Upstream{Host: "app", Port: port},
+	}
+}
+
+// routeIDs returns the @id of each route, "" for routes without one
+func routeIDs(t *testing.T, routes []json.RawMessage) []string {
+	t.Helper()
+	ids := make([]string, len(routes))
+	for i, raw := range routes {
+		var route struct {
+			ID string `json:"@id"`
+		}
+		if err := json.Unmarshal(raw, &route); err != nil {
+			t.Fatalf("decode route %s: %v", raw, err)
+		}
+		ids[i] = route.ID
+	}
+	return ids
+}
+
+// assertJSONEqual fails unless both documents decode to the same value
+func assertJSONEqual(t *testing.T, got, want json.RawMessage) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("decode %s: %v", got, err)
+	}
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatalf("decode %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("route = %s, want %s", got, want)
+	}
+}
+
+func TestSyncRoutesIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	admin := newFakeAdmin(t, caddyfileCatchAll)
+	m := newTestManager(admin)
+
+	routes := []proxy.Route{appRoute("app.example.com", 3000), appRoute("api.example.com", 4000)}
+	for i := 0; i < 3; i++ {
+		if err := m.SyncRoutes(ctx, routes); err != nil {
+			t.Fatalf("sync %d: %v", i+1, err)
+		}
+	}
+
+	current, writes := admin.snapshot()
+	if writes != 1 {
+		t.Errorf("route list written %d times, want once", writes)
+	}
+	want := []string{"nebula:api.example.com/", "nebula:app.example.com/", ""}
+	if got := routeIDs(t, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("route ids = %q, want %q", got, want)
+	}
+
+	changes, err := m.DiffRoutes(ctx, routes)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("diff after sync = %+v, want no changes", changes)
+	}
+
+	// Single route updates replace the synced route instead of adding one
+	if err := m.UpdateRoute(ctx, appRoute("app.example.com", 3001)); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	current, _ = admin.snapshot()
+	if got := routeIDs(t, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("route ids after update = %q, want %q", got, want)
+	}
+}
+
+func TestDiffRoutes(t *testing.T) {
+	ctx := context.Background()
+	admin := newFakeAdmin(t, caddyfileHostRoute, caddyfileCatchAll)
+	m := newTestManager(admin)
+
+	if err := m.SyncRoutes(ctx, []proxy.Route{
+		appRoute("app.example.com", 3000),
+		appRoute("old.example.com", 5000),
+		appRoute("same.example.com", 6000),
+	}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	before, writes := admin.snapshot()
+
+	changes, err := m.DiffRoutes(ctx, []proxy.Route{
+		appRoute("app.example.com", 3001),
+		appRoute("new.example.com", 7000),
+		appRoute("same.example.com", 6000),
+	})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+
+	got := make(map[string]proxy.RouteChangeAction)
+	for _, change := range changes {
+		if change.PathPrefix != "/" {
+			t.Errorf("%s: path prefix = %q, want /", change.Domain, change.PathPrefix)
+		}
+		got[change.Domain] = change.Action
+	}
+	want := map[string]proxy.RouteChangeAction{
+		"app.example.com": proxy.RouteUpdated,
+		"old.example.com": proxy.RouteRemoved,
+		"new.example.com": proxy.RouteAdded,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+
+	for _, change := range changes {
+		switch change.Action {
+		case proxy.RouteUpdated:
+			if change.Current == nil || change.Desired == nil {
+				t.Errorf("%s: update without current and desired route", change.Domain)
+			}
+		case proxy.RouteRemoved:
+			if change.Current == nil || change.Desired != nil {
+				t.Errorf("%s: removal should carry only the current route", change.Domain)
+			}
+		case proxy.RouteAdded:
+			if change.Current != nil || change.Desired == nil {
+				t.Errorf("%s: addition should carry only the desired route", change.Domain)
+			}
+		}
+	}
+
+	after, afterWrites := admin.snapshot()
+	if afterWrites != writes || !reflect.DeepEqual(after, before) {
+		t.Error("diff changed Caddy's routes")
+	}
+}
+
+func TestSyncRoutesLeavesCaddyfileRoutesAlone(t *testing.T) {
+	ctx := context.Background()
+	admin := newFakeAdmin(t, caddyfileHostRoute, caddyfileCatchAll)
+	m := newTestManager(admin)
+
+	if err := m.SyncRoutes(ctx, []proxy.Route{appRoute("app.example.com", 3000)}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	current, _ := admin.snapshot()
+	if got, want := routeIDs(t, current), []string{"nebula:app.example.com/", "", ""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("route ids = %q, want %q", got, want)
+	}
+	assertJSONEqual(t, current[1], json.RawMessage(caddyfileHostRoute))
+	assertJSONEqual(t, current[2], json.RawMessage(caddyfileCatchAll))
+
+	// Syncing no routes removes Nebula's only
+	if err := m.SyncRoutes(ctx, nil); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	current, _ = admin.snapshot()
+	if len(current) != 2 {
+		t.Fatalf("%d routes left, want the 2 Caddyfile routes", len(current))
+	}
+	assertJSONEqual(t, current[0], json.RawMessage(caddyfileHostRoute))
+	assertJSONEqual(t, current[1], json.RawMessage(caddyfileCatchAll))
+
+	// Nor does removing a route for the same host touch them
+	if err := m.RemoveRoute(ctx, "admin.example.com", "/"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	after, _ := admin.snapshot()
+	if !reflect.DeepEqual(after, current) {
+		t.Error("removing a Nebula route changed the Caddyfile routes")
+	}
+}
+
+func TestAdoptLegacyRoutes(t *testing.T) {
+	ctx := context.Background()
+	legacy := `{"match":[{"host":["app.example.com"]}],"handle":[{"handler":"reverse_proxy","upstreams":[{"dial":"app:3000"}]}],"terminal":true}`
+	admin := newFakeAdmin(t, legacy, legacy, caddyfileHostRoute, caddyfileCatchAll)
+	m := newTestManager(admin)
+
+	adopted, err := m.AdoptLegacyRoutes(ctx, []string{"app.example.com"})
+	if err != nil {
+		t.Fatalf("adopt: %v", err)
+	}
+	if adopted != 1 {
+		t.Errorf("adopted %d routes, want 1", adopted)
+	}
+	current, _ := admin.snapshot()
+	if got, want := routeIDs(t, current), []string{"nebula:app.example.com/", "", ""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("route ids = %q, want %q", got, want)
+	}
+	assertJSONEqual(t, current[1], json.RawMessage(caddyfileHostRoute))
+
+	// Adopting again changes nothing
+	_, writes := admin.snapshot()
+	if adopted, err := m.AdoptLegacyRoutes(ctx, []string{"app.example.com"}); err != nil || adopted != 0 {
+		t.Errorf("second adoption = %d, %v; want 0, nil", adopted, err)
+	}
+	if _, after := admin.snapshot(); after != writes {
+		t.Error("second adoption wrote the route list")
+	}
+
+	// The adopted route is replaced by a sync rather than duplicated
+	if err := m.SyncRoutes(ctx, []proxy.Route{appRoute("app.example.com", 3001)}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	current, _ = admin.snapshot()
+	if got, want := routeIDs(t, current), []string{"nebula:app.example.com/", "", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("route ids after sync = %q, want %q", got, want)
+	}
+}
+
+func TestGetRouteReadsContainerUpstreams(t *testing.T) {
+	ctx := context.Background()
+	admin := newFakeAdmin(t, caddyfileCatchAll)
+	m := newTestManager(admin)
+
+	route := proxy.Route{
+		Domain:     "app.example.com",
+		ActiveSlot: proxy.SlotBlue,
+		BlueTarget: &proxy.Upstream{Host: "myapp-web-blue", Port: 3000},
+	}
+	if err := m.SyncRoutes(ctx, []proxy.Route{route}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	got, err := m.GetRoute(ctx, "app.example.com", "/")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got == nil || !reflect.DeepEqual(got.BlueTarget, route.BlueTarget) {
+		t.Fatalf("route = %+v, want upstream %+v", got, route.BlueTarget)
+	}
+
+	routes, err := m.ListRoutes(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(routes) != 1 || !reflect.DeepEqual(routes[0].BlueTarget, route.BlueTarget) {
+		t.Errorf("routes = %+v, want one with upstream %+v", routes, route.BlueTarget)
+	}
+}
+
+// routeDials returns the upstream dial addresses of the route with an @id
+func routeDials(t *testing.T, routes []json.RawMessage, id string) []string {
+	t.Helper()
+	for _, raw := range routes {
+		var route CaddyRoute
+		if err := json.Unmarshal(raw, &route); err != nil {
+			t.Fatalf("decode route %s: %v", raw, err)
+		}
+		if route.ID != id {
+			continue
+		}
+		var dials []string
+		for _, handler := range route.Handle {
+			for _, upstream := range handler.Upstreams {
+				dials = append(dials, upstream.Dial)
+			}
+		}
+		return dials
+	}
+	t.Fatalf("no route %s", id)
+	return nil
+}
+
+func TestSwitchTraffic(t *testing.T) {
+	ctx := context.Background()
+	admin := newFakeAdmin(t, caddyfileCatchAll)
+	m := newTestManager(admin)
+
+	if err := m.SwitchTraffic(ctx, "app.example.com", proxy.SlotGreen); err == nil {
+		t.Error("switched traffic before routes were synced")
+	}
+
+	route := appRoute("app.example.com", 3000)
+	route.GreenTarget = &proxy.Upstream{Host: "app-green", Port: 3000}
+	if err := m.SyncRoutes(ctx, []proxy.Route{route, appRoute("api.example.com", 4000)}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	if err := m.SwitchTraffic(ctx, "app.example.com", proxy.SlotGreen); err != nil {
+		t.Fatalf("switch: %v", err)
+	}
+	current, _ := admin.snapshot()
+	if got, want := routeDials(t, current, "nebula:app.example.com/"), []string{"app-green:3000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dials after switch = %q, want %q", got, want)
+	}
+	if got, want := routeDials(t, current, "nebula:api.example.com/"), []string{"app:4000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("other domain dials = %q, want %q", got, want)
+	}
+
+	if err := m.SwitchTraffic(ctx, "missing.example.com", proxy.SlotGreen); err == nil {
+		t.Error("switched traffic of a domain without routes")
+	}
+}
+
+func TestReloadConfigRestoresSyncedRoutes(t *testing.T) {
+	ctx := context.Background()
+	admin := newFakeAdmin(t, caddyfileCatchAll)
+	m := newTestManager(admin)
+
+	if err := m.ReloadConfig(ctx); err == nil {
+		t.Error("reloaded before routes were synced")
+	}
+
+	if err := m.SyncRoutes(ctx, []proxy.Route{appRoute("app.example.com", 3000)}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if err := m.UpdateRoute(ctx, appRoute("api.example.com", 4000)); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// Caddy lost its routes, such as by restarting
+	admin.setRoutes(caddyfileCatchAll)
+	if err := m.ReloadConfig(ctx); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	current, _ := admin.snapshot()
+	if got, want := routeIDs(t, current), []string{"nebula:api.example.com/", "nebula:app.example.com/", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("route ids after reload = %q, want %q", got, want)
+	}
+
+	// Errors from Caddy are surfaced
+	admin.setRoutes(caddyfileCatchAll)
+	admin.rejectWrites(http.StatusBadRequest)
+	if err := m.ReloadConfig(ctx); err == nil {
+		t.Error("reload succeeded though Caddy rejected the routes")
+	}
+}
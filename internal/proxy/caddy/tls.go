@@ -0,0 +1,374 @@
+package caddy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/victalejo/nebula/internal/core/proxy"
+)
+
+// ACMEOptions configure how Caddy obtains certificates. Without a CA, Caddy
+// uses its default issuers; wildcard hosts need a DNS provider.
+type ACMEOptions struct {
+	CA           string   // ACME directory URL, e.g. a local pebble
+	TrustedRoots []string // PEM files of the CA's roots, for private CAs
+	Email        string
+	DNS          *DNSOptions
+
+	// OnDemandAsk is the URL Caddy asks before obtaining a certificate at
+	// the first handshake for a host; empty obtains them up front
+	OnDemandAsk string
+}
+
+// DNSOptions select the DNS provider solving DNS-01 challenges
+type DNSOptions struct {
+	// Provider is the provider module's config, such as
+	// {"name": "cloudflare", "api_token": "..."}; the module must be built
+	// into Caddy
+	Provider  map[string]string
+	Resolvers []string // DNS servers checked for propagation
+}
+
+// CaddyAutomationPolicy tells Caddy how to obtain certificates for subjects,
+// or for every other name when it has none
+type CaddyAutomationPolicy struct {
+	Subjects []string      `json:"subjects,omitempty"`
+	Issuers  []CaddyIssuer `json:"issuers,omitempty"`
+	OnDemand bool          `json:"on_demand,omitempty"`
+}
+
+// CaddyOnDemand configures on-demand TLS; Caddy only obtains a certificate
+// when the permission module approves the host
+type CaddyOnDemand struct {
+	Permission CaddyOnDemandPermission `json:"permission"`
+}
+
+// CaddyOnDemandPermission asks an HTTP endpoint with ?domain=<host>; any
+// status but 200 denies the certificate
+type CaddyOnDemandPermission struct {
+	Module   string `json:"module"`
+	Endpoint string `json:"endpoint"`
+}
+
+// CaddyIssuer is an ACME issuer
+type CaddyIssuer struct {
+	Module               string           `json:"module"`
+	CA                   string           `json:"ca,omitempty"`
+	Email                string           `json:"email,omitempty"`
+	TrustedRootsPEMFiles []string         `json:"trusted_roots_pem_files,omitempty"`
+	Challenges           *CaddyChallenges `json:"challenges,omitempty"`
+}
+
+type CaddyChallenges struct {
+	DNS *CaddyDNSChallenge `json:"dns,omitempty"`
+}
+
+type CaddyDNSChallenge struct {
+	Provider  map[string]string `json:"provider"`
+	Resolvers []string          `json:"resolvers,omitempty"`
+}
+
+// CaddyLoadPEM is a certificate loaded into Caddy's TLS app from PEM. Caddy
+// does not obtain certificates for names a loaded certificate covers.
+type CaddyLoadPEM struct {
+	Certificate string   `json:"certificate"`
+	Key         string   `json:"key"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// certificateTag marks the loaded certificate Nebula installed for a domain
+func certificateTag(domain string) string {
+	return routeIDPrefix + domain
+}
+
+// InstallCertificate loads an uploaded certificate for a domain, replacing
+// the one installed before
+func (m *Manager) InstallCertificate(ctx context.Context, domain string, certPEM, keyPEM []byte) error {
+	m.log.Info("installing caddy certificate", "domain", domain)
+
+	return m.updateLoadedCertificates(ctx, func(certs []CaddyLoadPEM) []CaddyLoadPEM {
+		certs = withoutCertificate(certs, domain)
+		return append(certs, CaddyLoadPEM{
+			Certificate: string(certPEM),
+			Key:         string(keyPEM),
+			Tags:        []string{certificateTag(domain)},
+		})
+	})
+}
+
+// RemoveCertificate unloads a domain's uploaded certificate, so Caddy manages
+// the domain's certificate again
+func (m *Manager) RemoveCertificate(ctx context.Context, domain string) error {
+	m.log.Info("removing caddy certificate", "domain", domain)
+
+	return m.updateLoadedCertificates(ctx, func(certs []CaddyLoadPEM) []CaddyLoadPEM {
+		return withoutCertificate(certs, domain)
+	})
+}
+
+func withoutCertificate(certs []CaddyLoadPEM, domain string) []CaddyLoadPEM {
+	kept := certs[:0]
+	for _, cert := range certs {
+		tagged := false
+		for _, tag := range cert.Tags {
+			tagged = tagged || tag == certificateTag(domain)
+		}
+		if !tagged {
+			kept = append(kept, cert)
+		}
+	}
+	return kept
+}
+
+// updateLoadedCertificates changes the certificates loaded from PEM. The rest
+// of the TLS app, such as automation policies, is written back unchanged.
+func (m *Manager) updateLoadedCertificates(ctx context.Context, change func([]CaddyLoadPEM) []CaddyLoadPEM) error {
+	return m.updateTLSApp(ctx, func(tls map[string]json.RawMessage) error {
+		certificates := make(map[string]json.RawMessage)
+		if raw, ok := tls["certificates"]; ok {
+			if err := json.Unmarshal(raw, &certificates); err != nil {
+				return fmt.Errorf("failed to decode caddy certificates: %w", err)
+			}
+		}
+		var loaded []CaddyLoadPEM
+		if raw, ok := certificates["load_pem"]; ok {
+			if err := json.Unmarshal(raw, &loaded); err != nil {
+				return fmt.Errorf("failed to decode caddy certificates: %w", err)
+			}
+		}
+
+		loaded = change(loaded)
+		if len(loaded) == 0 {
+			delete(certificates, "load_pem")
+		} else {
+			data, err := json.Marshal(loaded)
+			if err != nil {
+				return err
+			}
+			certificates["load_pem"] = data
+		}
+
+		if len(certificates) == 0 {
+			delete(tls, "certificates")
+			return nil
+		}
+		data, err := json.Marshal(certificates)
+		if err != nil {
+			return err
+		}
+		tls["certificates"] = data
+		return nil
+	})
+}
+
+// ValidateRoute rejects wildcard hosts served over TLS when no DNS provider
+// can solve the DNS-01 challenge their certificates need, unless on-demand
+// TLS obtains a certificate per host instead
+func (m *Manager) ValidateRoute(route proxy.Route) error {
+	if route.Wildcard() && route.SSLEnabled && m.acme.DNS == nil && m.acme.OnDemandAsk == "" {
+		return errors.New("wildcard domains need a DNS provider for their certificates, or on-demand TLS; configure proxy.caddy.acme.dns or proxy.caddy.acme.on_demand_ask")
+	}
+	return nil
+}
+
+// syncAutomation points Caddy's certificate automation at the configured CA,
+// solves DNS-01 challenges for the wildcard hosts of routes and enables
+// on-demand TLS. Nebula owns the automation policies and the on-demand
+// settings once ACME options are set. Called with m.mu held.
+func (m *Manager) syncAutomation(ctx context.Context, routes []CaddyRoute) error {
+	if m.acme.CA == "" && m.acme.Email == "" && m.acme.DNS == nil && m.acme.OnDemandAsk == "" {
+		return nil
+	}
+
+	return m.changeTLSApp(ctx, func(tls map[string]json.RawMessage) error {
+		automation := make(map[string]json.RawMessage)
+		if raw, ok := tls["automation"]; ok {
+			if err := json.Unmarshal(raw, &automation); err != nil {
+				return fmt.Errorf("failed to decode caddy tls automation: %w", err)
+			}
+		}
+
+		policies := m.automationPolicies(wildcardHosts(routes))
+		if len(policies) == 0 {
+			delete(automation, "policies")
+		} else {
+			data, err := json.Marshal(policies)
+			if err != nil {
+				return err
+			}
+			automation["policies"] = data
+		}
+
+		if m.acme.OnDemandAsk == "" {
+			delete(automation, "on_demand")
+		} else {
+			data, err := json.Marshal(CaddyOnDemand{Permission: CaddyOnDemandPermission{
+				Module:   "http",
+				Endpoint: m.acme.OnDemandAsk,
+			}})
+			if err != nil {
+				return err
+			}
+			automation["on_demand"] = data
+		}
+
+		if len(automation) == 0 {
+			delete(tls, "automation")
+			return nil
+		}
+		data, err := json.Marshal(automation)
+		if err != nil {
+			return err
+		}
+		tls["automation"] = data
+		return nil
+	})
+}
+
+// automationPolicies returns a DNS-01 policy for the wildcard hosts, then a
+// catch-all policy using the configured CA, on demand when an ask URL is set
+func (m *Manager) automationPolicies(wildcards []string) []CaddyAutomationPolicy {
+	var policies []CaddyAutomationPolicy
+	if len(wildcards) > 0 && m.acme.DNS != nil {
+		issuer := m.issuer()
+		issuer.Challenges = &CaddyChallenges{DNS: &CaddyDNSChallenge{
+			Provider:  m.acme.DNS.Provider,
+			Resolvers: m.acme.DNS.Resolvers,
+		}}
+		policies = append(policies, CaddyAutomationPolicy{Subjects: wildcards, Issuers: []CaddyIssuer{issuer}})
+	}
+	if m.acme.CA != "" || m.acme.Email != "" || m.acme.OnDemandAsk != "" {
+		policy := CaddyAutomationPolicy{OnDemand: m.acme.OnDemandAsk != ""}
+		if m.acme.CA != "" || m.acme.Email != "" {
+			policy.Issuers = []CaddyIssuer{m.issuer()}
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+func (m *Manager) issuer() CaddyIssuer {
+	return CaddyIssuer{
+		Module:               "acme",
+		CA:                   m.acme.CA,
+		Email:                m.acme.Email,
+		TrustedRootsPEMFiles: m.acme.TrustedRoots,
+	}
+}
+
+// wildcardHosts returns the wildcard hosts Nebula's routes match, sorted
+func wildcardHosts(routes []CaddyRoute) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, route := range routes {
+		for _, match := range route.Match {
+			for _, host := range match.Host {
+				if strings.HasPrefix(host, "*.") && !seen[host] {
+					seen[host] = true
+					hosts = append(hosts, host)
+				}
+			}
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// updateTLSApp reads Caddy's TLS app, changes it and writes it back
+func (m *Manager) updateTLSApp(ctx context.Context, change func(tls map[string]json.RawMessage) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.changeTLSApp(ctx, change)
+}
+
+// changeTLSApp is updateTLSApp with m.mu held. An unchanged app is not
+// written back.
+func (m *Manager) changeTLSApp(ctx context.Context, change func(tls map[string]json.RawMessage) error) error {
+	if err := m.InitializeServer(ctx); err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/config/apps/tls", m.adminAPI)
+	tls := make(map[string]json.RawMessage)
+	if err := m.adminRequest(ctx, "GET", url, nil, &tls); err != nil {
+		return err
+	}
+	if tls == nil {
+		tls = make(map[string]json.RawMessage)
+	}
+	before, err := json.Marshal(tls)
+	if err != nil {
+		return err
+	}
+	if err := change(tls); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(tls)
+	if err != nil {
+		return err
+	}
+	if jsonEqual(before, body) {
+		return nil
+	}
+	if err := m.adminRequest(ctx, "POST", url, body, nil); err != nil {
+		return fmt.Errorf("failed to update caddy tls app: %w", err)
+	}
+	return nil
+}
+
+// adminRequest sends a request to Caddy's admin API and decodes the response
+// into out. A missing config path decodes as nothing.
+func (m *Manager) adminRequest(ctx context.Context, method, url string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && method == "GET" {
+		return nil
+	}
+	if resp.StatusCode >= 400 {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("caddy returned error %d: %s", resp.StatusCode, string(data))
+	}
+	if out == nil {
+		return nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// jsonEqual reports whether two JSON documents hold the same values,
+// regardless of key order
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,31 @@
+// Package dockernet connects a containerized proxy to the Docker networks of
+// the containers it routes to.
+package dockernet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/victalejo/nebula/internal/core/container"
+)
+
+// Attach connects the proxy's container to a network, creating the network
+// when needed. It reports whether the container was newly attached, and is a
+// no-op when the proxy runs on the host (proxyContainer is empty).
+func Attach(ctx context.Context, runtime container.ContainerRuntime, proxyContainer, network string) (bool, error) {
+	if proxyContainer == "" || network == "" {
+		return false, nil
+	}
+
+	if _, err := runtime.CreateNetwork(ctx, network, container.NetworkOptions{}); err != nil {
+		return false, fmt.Errorf("failed to create network %s: %w", network, err)
+	}
+	if err := runtime.ConnectToNetwork(ctx, proxyContainer, network); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to attach %s to network %s: %w", proxyContainer, network, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,618 @@
+// Package nginx implements the ProxyManager interface for nginx, by rendering
+// a server configuration file and reloading nginx.
+package nginx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/container"
+	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/proxy/dockernet"
+	"github.com/victalejo/nebula/internal/proxy/routefile"
+)
+
+// commandTimeout bounds nginx -t and reloads
+const commandTimeout = 30 * time.Second
+
+// Options configures the nginx manager
+type Options struct {
+	ConfigFile    string   // server configuration included by nginx.conf, e.g. conf.d/nebula.conf
+	StateFile     string   // where Nebula keeps the routes it rendered
+	CertDir       string   // certificates are read from <cert_dir>/<domain>/fullchain.pem and privkey.pem
+	TestCommand   []string // validates the configuration, e.g. nginx -t
+	ReloadCommand []string // applies the configuration, e.g. nginx -s reload
+	Container     string   // nginx's container, where commands run; empty when nginx runs on the host
+}
+
+// Manager implements the ProxyManager interface for nginx
+type Manager struct {
+	opts    Options
+	table   *routefile.Table
+	runtime container.ContainerRuntime
+	log     logger.Logger
+}
+
+// NewManager creates a new nginx manager
+func NewManager(opts Options, runtime container.ContainerRuntime, log logger.Logger) *Manager {
+	return &Manager{
+		opts:    opts,
+		table:   routefile.NewTable(opts.StateFile),
+		runtime: runtime,
+		log:     log,
+	}
+}
+
+// upstreamName returns the name of a route's upstream block. The hash keeps
+// names unique when domains and prefixes sanitize alike.
+func upstreamName(route proxy.Route) string {
+	key := routefile.Key(route.Domain, route.PathPrefix)
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	sanitized := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(key))
+	return fmt.Sprintf("nebula_%s_%08x", strings.Trim(sanitized, "_"), h.Sum32())
+}
+
+// writeUpstream renders a route's upstream block. Canary releases split
+// traffic by weight between both slots; their servers are never marked down
+// so the split holds.
+func writeUpstream(b *strings.Builder, route proxy.Route) error {
+	fmt.Fprintf(b, "upstream %s {\n", upstreamName(route))
+	if route.Weighted() {
+		fmt.Fprintf(b, "    server %s:%d weight=%d max_fails=0;\n", route.BlueTarget.Host, route.BlueTarget.Port, route.BlueWeight)
+		fmt.Fprintf(b, "    server %s:%d weight=%d max_fails=0;\n", route.GreenTarget.Host, route.GreenTarget.Port, route.GreenWeight)
+	} else {
+		upstream := route.BlueTarget
+		if route.ActiveSlot == proxy.SlotGreen {
+			upstream = route.GreenTarget
+		}
+		if upstream == nil {
+			return fmt.Errorf("route %s%s: no active upstream configured", route.Domain, route.PathPrefix)
+		}
+		fmt.Fprintf(b, "    server %s:%d;\n", upstream.Host, upstream.Port)
+	}
+	b.WriteString("}\n")
+	return nil
+}
+
+// writeLocations renders the locations of a route. Path routes match the
+// prefix itself and everything below it.
+func (m *Manager) writeLocations(b *strings.Builder, route proxy.Route) {
+	if !route.PathMatched() {
+		m.writeLocation(b, "/", route)
+		return
+	}
+	m.writeLocation(b, "= "+route.PathPrefix, route)
+	m.writeLocation(b, route.PathPrefix+"/", route)
+}
+
+// writeLocation renders a location proxying to the route's upstream. Requests
+// are redirected first, then rewritten, then the prefix is stripped. Without
+// rewrites a trailing slash on proxy_pass replaces the prefix, which strips
+// it; with rewrites nginx passes the rewritten URI as is, so the prefix is
+// stripped by rewriting too.
+func (m *Manager) writeLocation(b *strings.Builder, match string, route proxy.Route) {
+	fmt.Fprintf(b, "    location %s {\n", match)
+
+	if route.ForceHTTPS {
+		b.WriteString("        if ($scheme = http) {\n            return 308 https://$host$request_uri;\n        }\n")
+	}
+	for _, redirect := range route.Redirects {
+		exact, below := proxy.PathPatterns(redirect.From)
+		fmt.Fprintf(b, "        if ($uri ~ \"^%s$\") {\n            return %d %s$is_args$args;\n        }\n", exact, redirect.Status, redirect.To)
+		fmt.Fprintf(b, "        if ($uri ~ \"^%s$\") {\n            return %d %s$is_args$args;\n        }\n", below, redirect.Status, proxy.JoinPath(redirect.To, "$1"))
+	}
+	writeHeaders(b, route)
+	m.writeAccess(b, route)
+
+	target := "http://" + upstreamName(route)
+	strip := route.PathMatched() && route.StripPrefix
+	if len(route.Rewrites) > 0 {
+		for _, rewrite := range route.Rewrites {
+			exact, below := proxy.PathPatterns(rewrite.From)
+			fmt.Fprintf(b, "        rewrite \"^%s$\" \"%s\";\n", below, proxy.JoinPath(rewrite.To, "$1"))
+			fmt.Fprintf(b, "        rewrite \"^%s$\" \"%s\";\n", exact, rewrite.To)
+		}
+		if strip {
+			exact, below := proxy.PathPatterns(route.PathPrefix)
+			fmt.Fprintf(b, "        rewrite \"^%s$\" \"$1\" break;\n", below)
+			fmt.Fprintf(b, "        rewrite \"^%s$\" / break;\n", exact)
+		}
+		// Keep the rewritten request in this location
+		b.WriteString("        break;\n")
+	} else if strip {
+		target += "/"
+	}
+
+	fmt.Fprintf(b, "        proxy_pass %s;\n", target)
+	b.WriteString("        proxy_http_version 1.1;\n")
+	b.WriteString("        proxy_set_header Host $host;\n")
+	b.WriteString("        proxy_set_header X-Real-IP $remote_addr;\n")
+	b.WriteString("        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
+	b.WriteString("        proxy_set_header X-Forwarded-Proto $scheme;\n")
+	b.WriteString("        proxy_set_header Upgrade $http_upgrade;\n")
+	b.WriteString("        proxy_set_header Connection $nebula_connection_upgrade;\n")
+	b.WriteString("    }\n")
+}
+
+// writeHeaders renders a location's response headers and CORS policy. Headers
+// set here replace the upstream's, so it hides them first.
+func writeHeaders(b *strings.Builder, route proxy.Route) {
+	for _, name := range route.RemoveHeaders {
+		fmt.Fprintf(b, "        proxy_hide_header %s;\n", name)
+	}
+
+	set := make(map[string]string, len(route.SetHeaders))
+	for name, value := range route.SetHeaders {
+		set[name] = quote(value)
+	}
+	if cors := route.CORS; cors != nil {
+		set["Access-Control-Allow-Origin"] = corsVariable(route, "origin")
+		if cors.AnyOrigin() && !cors.AllowCredentials {
+			set["Access-Control-Allow-Origin"] = quote("*")
+		}
+		set["Access-Control-Allow-Methods"] = quote(strings.Join(cors.AllowMethods, ", "))
+		if len(cors.AllowHeaders) > 0 {
+			set["Access-Control-Allow-Headers"] = quote(strings.Join(cors.AllowHeaders, ", "))
+		}
+		if len(cors.ExposeHeaders) > 0 {
+			set["Access-Control-Expose-Headers"] = quote(strings.Join(cors.ExposeHeaders, ", "))
+		}
+		if cors.AllowCredentials {
+			set["Access-Control-Allow-Credentials"] = quote("true")
+		}
+		if cors.MaxAge > 0 {
+			set["Access-Control-Max-Age"] = quote(fmt.Sprint(cors.MaxAge))
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "        proxy_hide_header %s;\n", name)
+		fmt.Fprintf(b, "        add_header %s %s always;\n", name, set[name])
+	}
+	if route.CORS != nil {
+		b.WriteString("        add_header Vary Origin always;\n")
+		// Preflight requests carry no credentials, so they are answered
+		// before access control, with the headers above
+		fmt.Fprintf(b, "        if (%s) {\n            return 204;\n        }\n", corsVariable(route, "preflight"))
+	}
+}
+
+// writeCORSMaps renders the variables a route's CORS policy needs: the
+// request's origin when it is allowed, and whether the request is a
+// preflight request from an allowed origin
+func writeCORSMaps(b *strings.Builder, route proxy.Route) {
+	cors := route.CORS
+	if cors == nil {
+		return
+	}
+
+	pattern := ".+"
+	if !cors.AnyOrigin() {
+		quoted := make([]string, len(cors.AllowOrigins))
+		for i, origin := range cors.AllowOrigins {
+			quoted[i] = regexp.QuoteMeta(origin)
+		}
+		pattern = "(?:" + strings.Join(quoted, "|") + ")"
+	}
+
+	fmt.Fprintf(b, "map $http_origin %s {\n    default \"\";\n    \"~^%s$\" $http_origin;\n}\n", corsVariable(route, "origin"), pattern)
+	fmt.Fprintf(b, "map \"$request_method:$http_origin\" %s {\n    default 0;\n    \"~^OPTIONS:%s$\" 1;\n}\n", corsVariable(route, "preflight"), pattern)
+}
+
+func corsVariable(route proxy.Route, name string) string {
+	return fmt.Sprintf("$%s_cors_%s", upstreamName(route), name)
+}
+
+// quote quotes a header value for nginx
+func quote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// writeAccess renders a location's access control. nginx checks it after the
+// redirects above, which run in its rewrite phase.
+func (m *Manager) writeAccess(b *strings.Builder, route proxy.Route) {
+	for _, cidr := range route.DenyCIDRs {
+		fmt.Fprintf(b, "        deny %s;\n", cidr)
+	}
+	for _, cidr := range route.AllowCIDRs {
+		fmt.Fprintf(b, "        allow %s;\n", cidr)
+	}
+	if len(route.AllowCIDRs) > 0 {
+		b.WriteString("        deny all;\n")
+	}
+	if route.RateLimit != nil {
+		fmt.Fprintf(b, "        limit_req zone=%s burst=%d nodelay;\n", upstreamName(route), route.RateLimit.Requests)
+		b.WriteString("        limit_req_status 429;\n")
+	}
+	if len(route.BasicAuth) > 0 {
+		b.WriteString("        auth_basic \"restricted\";\n")
+		fmt.Fprintf(b, "        auth_basic_user_file %s;\n", m.htpasswdFile(route))
+	}
+}
+
+// writeRateLimitZone renders the shared memory zone counting a route's
+// requests per client IP. nginx takes rates per second or minute, so the
+// rate is rounded up to requests per minute.
+func writeRateLimitZone(b *strings.Builder, route proxy.Route) {
+	if route.RateLimit == nil {
+		return
+	}
+	window := route.RateLimit.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	perMinute := int(math.Ceil(float64(route.RateLimit.Requests) * float64(time.Minute) / float64(window)))
+	fmt.Fprintf(b, "limit_req_zone $binary_remote_addr zone=%s:10m rate=%dr/m;\n", upstreamName(route), max(perMinute, 1))
+}
+
+// htpasswdDir holds the basic auth users of each route, next to the
+// configuration file. nginx checks bcrypt hashes with the system's crypt(3),
+// which musl based images such as nginx:alpine do not support.
+func (m *Manager) htpasswdDir() string {
+	return filepath.Join(filepath.Dir(m.opts.ConfigFile), "nebula-htpasswd")
+}
+
+func (m *Manager) htpasswdFile(route proxy.Route) string {
+	return filepath.Join(m.htpasswdDir(), upstreamName(route))
+}
+
+// writeHtpasswdFiles writes the basic auth users of the routes and removes
+// the files of routes without them
+func (m *Manager) writeHtpasswdFiles(routes []proxy.Route) error {
+	wanted := make(map[string]bool)
+	for _, route := range routes {
+		if len(route.BasicAuth) == 0 {
+			continue
+		}
+		var b strings.Builder
+		for _, user := range route.BasicAuth {
+			fmt.Fprintf(&b, "%s:%s\n", user.Username, user.PasswordHash)
+		}
+		path := m.htpasswdFile(route)
+		if err := routefile.WriteFile(path, []byte(b.String())); err != nil {
+			return err
+		}
+		wanted[filepath.Base(path)] = true
+	}
+
+	entries, err := os.ReadDir(m.htpasswdDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to list htpasswd files: %w", err)
+	}
+	for _, entry := range entries {
+		if !wanted[entry.Name()] {
+			_ = os.Remove(filepath.Join(m.htpasswdDir(), entry.Name()))
+		}
+	}
+	return nil
+}
+
+// certificate returns the certificate files of a domain, or empty strings
+// when they are not in place. Uploaded certificates take precedence over
+// the ones in cert_dir, where certbot names a wildcard certificate after its
+// base domain.
+func (m *Manager) certificate(domain string) (string, string) {
+	if cert, key := routefile.CertificateFiles(routefile.CertificateDir(m.opts.ConfigFile), domain); cert != "" {
+		return cert, key
+	}
+	return routefile.CertificateFiles(m.opts.CertDir, strings.TrimPrefix(domain, "*."))
+}
+
+// render renders the server configuration: an upstream per route, a server
+// block per host and one per host redirected to a route's domain
+func (m *Manager) render(routes []proxy.Route) (string, error) {
+	var b strings.Builder
+	b.WriteString("# Managed by Nebula - manual changes will be overwritten\n\n")
+	b.WriteString("map $http_upgrade $nebula_connection_upgrade {\n    default upgrade;\n    '' close;\n}\n")
+
+	byHost := make(map[string][]proxy.Route)
+	for _, route := range routes {
+		b.WriteString("\n")
+		writeRateLimitZone(&b, route)
+		writeCORSMaps(&b, route)
+		if err := writeUpstream(&b, route); err != nil {
+			return "", err
+		}
+		for _, host := range route.Hosts() {
+			byHost[host] = append(byHost[host], route)
+		}
+	}
+
+	hosts := make([]string, 0, len(byHost))
+	for host := range byHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		hostRoutes := byHost[host]
+
+		b.WriteString("\nserver {\n")
+		m.writeListen(&b, host, sslEnabled(hostRoutes))
+		fmt.Fprintf(&b, "    server_name %s;\n", host)
+
+		hasRoot := false
+		for _, route := range hostRoutes {
+			b.WriteString("\n")
+			m.writeLocations(&b, route)
+			hasRoot = hasRoot || !route.PathMatched()
+		}
+		if !hasRoot {
+			// Paths outside the routed prefixes must not fall through to
+			// nginx's default document root
+			b.WriteString("\n    location / {\n        return 404;\n    }\n")
+		}
+		b.WriteString("}\n")
+	}
+
+	for _, route := range routes {
+		m.writeHostRedirects(&b, route)
+	}
+	return b.String(), nil
+}
+
+// writeHostRedirects renders a server block per host redirected to the
+// route's domain
+func (m *Manager) writeHostRedirects(b *strings.Builder, route proxy.Route) {
+	scheme := "$scheme"
+	if route.SSLEnabled || route.ForceHTTPS {
+		scheme = "https"
+	}
+	for _, redirect := range route.HostRedirects {
+		b.WriteString("\nserver {\n")
+		m.writeListen(b, redirect.Host, route.SSLEnabled)
+		fmt.Fprintf(b, "    server_name %s;\n", redirect.Host)
+		fmt.Fprintf(b, "    return %d %s://%s$request_uri;\n", redirect.Status, scheme, route.Domain)
+		b.WriteString("}\n")
+	}
+}
+
+// writeListen renders the listen directives of a host's server block,
+// serving TLS when the host has a certificate
+func (m *Manager) writeListen(b *strings.Builder, host string, ssl bool) {
+	b.WriteString("    listen 80;\n")
+	if !ssl {
+		return
+	}
+	cert, key := m.certificate(host)
+	if cert == "" {
+		m.log.Warn("no certificate for domain, serving it over HTTP only", "domain", host, "cert_dir", m.opts.CertDir)
+		return
+	}
+	b.WriteString("    listen 443 ssl;\n")
+	fmt.Fprintf(b, "    ssl_certificate %s;\n", cert)
+	fmt.Fprintf(b, "    ssl_certificate_key %s;\n", key)
+}
+
+func sslEnabled(routes []proxy.Route) bool {
+	for _, route := range routes {
+		if route.SSLEnabled || route.ForceHTTPS {
+			return true
+		}
+	}
+	return false
+}
+
+// renderRoute returns the configuration of a single route, for diffs
+func (m *Manager) renderRoute(route proxy.Route) (json.RawMessage, error) {
+	var b strings.Builder
+	writeRateLimitZone(&b, route)
+	writeCORSMaps(&b, route)
+	if err := writeUpstream(&b, route); err != nil {
+		return nil, err
+	}
+	m.writeLocations(&b, route)
+	m.writeHostRedirects(&b, route)
+	return json.Marshal(b.String())
+}
+
+// apply writes the configuration and reloads nginx. A configuration nginx
+// rejects is rolled back, so a bad route never takes the other sites down.
+func (m *Manager) apply(routes []proxy.Route) error {
+	config, err := m.render(routes)
+	if err != nil {
+		return err
+	}
+
+	previous, err := os.ReadFile(m.opts.ConfigFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read nginx config: %w", err)
+	}
+	if err := m.writeHtpasswdFiles(routes); err != nil {
+		return err
+	}
+	if err := routefile.WriteFile(m.opts.ConfigFile, []byte(config)); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	if err := m.run(ctx, m.opts.TestCommand); err != nil {
+		if previous != nil {
+			_ = routefile.WriteFile(m.opts.ConfigFile, previous)
+		} else {
+			_ = os.Remove(m.opts.ConfigFile)
+		}
+		return fmt.Errorf("nginx rejected the configuration: %w", err)
+	}
+	if err := m.run(ctx, m.opts.ReloadCommand); err != nil {
+		return fmt.Errorf("failed to reload nginx: %w", err)
+	}
+	return nil
+}
+
+// run runs an nginx command in nginx's container, or on the host
+func (m *Manager) run(ctx context.Context, cmd []string) error {
+	if len(cmd) == 0 {
+		return nil
+	}
+
+	if m.opts.Container != "" {
+		result, err := m.runtime.ExecContainer(ctx, m.opts.Container, cmd)
+		if err != nil {
+			return err
+		}
+		if result.ExitCode != 0 {
+			return fmt.Errorf("%s exited with code %d: %s", strings.Join(cmd, " "), result.ExitCode, strings.TrimSpace(result.Output))
+		}
+		return nil
+	}
+
+	output, err := exec.CommandContext(ctx, cmd[0], cmd[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", strings.Join(cmd, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// AttachNetwork connects nginx's container to a Docker network, so it can
+// reach the containers on it by name
+func (m *Manager) AttachNetwork(ctx context.Context, network string) error {
+	attached, err := dockernet.Attach(ctx, m.runtime, m.opts.Container, network)
+	if err != nil {
+		return err
+	}
+	if attached {
+		m.log.Info("nginx attached to network", "network", network)
+	}
+	return nil
+}
+
+// AddRoute adds a route, replacing any route serving the same domain and path prefix
+func (m *Manager) AddRoute(ctx context.Context, route proxy.Route) error {
+	return m.UpdateRoute(ctx, route)
+}
+
+// UpdateRoute creates or replaces the route of a domain and path prefix
+func (m *Manager) UpdateRoute(ctx context.Context, route proxy.Route) error {
+	m.log.Info("updating nginx route",
+		"domain", route.Domain,
+		"path_prefix", route.PathPrefix,
+		"app_id", route.AppID,
+	)
+
+	return m.table.Update(func(routes map[string]proxy.Route) {
+		routes[routefile.Key(route.Domain, route.PathPrefix)] = route
+	}, m.apply)
+}
+
+// RemoveRoute removes the route of a domain and path prefix
+func (m *Manager) RemoveRoute(ctx context.Context, domain, pathPrefix string) error {
+	m.log.Info("removing nginx route", "domain", domain, "path_prefix", pathPrefix)
+
+	return m.table.Update(func(routes map[string]proxy.Route) {
+		delete(routes, routefile.Key(domain, pathPrefix))
+	}, m.apply)
+}
+
+// GetRoute retrieves the route of a domain and path prefix
+func (m *Manager) GetRoute(ctx context.Context, domain, pathPrefix string) (*proxy.Route, error) {
+	return m.table.Get(domain, pathPrefix)
+}
+
+// ListRoutes returns all routes
+func (m *Manager) ListRoutes(ctx context.Context) ([]proxy.Route, error) {
+	return m.table.Routes()
+}
+
+// SyncRoutes makes routes the complete set of routes in the configuration
+func (m *Manager) SyncRoutes(ctx context.Context, routes []proxy.Route) error {
+	m.log.Info("syncing nginx routes", "routes", len(routes))
+	return m.table.Replace(routes, m.apply)
+}
+
+// DiffRoutes reports the changes SyncRoutes would make
+func (m *Manager) DiffRoutes(ctx context.Context, routes []proxy.Route) ([]proxy.RouteChange, error) {
+	return m.table.Diff(routes, m.renderRoute)
+}
+
+// SwitchTraffic points every route of a domain at another slot
+func (m *Manager) SwitchTraffic(ctx context.Context, domain string, targetSlot proxy.Slot) error {
+	m.log.Info("switching traffic", "domain", domain, "target_slot", targetSlot)
+
+	return m.table.Update(func(routes map[string]proxy.Route) {
+		for key, route := range routes {
+			if route.Domain == domain {
+				route.ActiveSlot = targetSlot
+				route.BlueWeight, route.GreenWeight = 0, 0
+				routes[key] = route
+			}
+		}
+	}, m.apply)
+}
+
+// InstallCertificate serves an uploaded certificate for a domain
+func (m *Manager) InstallCertificate(ctx context.Context, domain string, certPEM, keyPEM []byte) error {
+	m.log.Info("installing nginx certificate", "domain", domain)
+
+	if err := routefile.WriteCertificate(routefile.CertificateDir(m.opts.ConfigFile), domain, certPEM, keyPEM); err != nil {
+		return err
+	}
+	return m.table.Update(func(map[string]proxy.Route) {}, m.apply)
+}
+
+// RemoveCertificate stops serving a domain's uploaded certificate
+func (m *Manager) RemoveCertificate(ctx context.Context, domain string) error {
+	m.log.Info("removing nginx certificate", "domain", domain)
+
+	if err := routefile.RemoveCertificate(routefile.CertificateDir(m.opts.ConfigFile), domain); err != nil {
+		return err
+	}
+	return m.table.Update(func(map[string]proxy.Route) {}, m.apply)
+}
+
+// ProvisionSSL provisions SSL for a domain
+func (m *Manager) ProvisionSSL(ctx context.Context, domain string) error {
+	// nginx does not request certificates; it serves the ones found in cert_dir
+	if cert, _ := m.certificate(domain); cert == "" {
+		m.log.Warn("no certificate for domain, provide one in the certificate directory",
+			"domain", domain,
+			"cert_dir", m.opts.CertDir,
+		)
+	}
+	return nil
+}
+
+// HealthCheck checks that nginx accepts its configuration
+func (m *Manager) HealthCheck(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	if err := m.run(ctx, m.opts.TestCommand); err != nil {
+		return fmt.Errorf("nginx health check failed: %w", err)
+	}
+	return nil
+}
+
+// ReloadConfig renders the configuration again from Nebula's routes and reloads nginx
+func (m *Manager) ReloadConfig(ctx context.Context) error {
+	return m.table.Update(func(map[string]proxy.Route) {}, m.apply)
+}
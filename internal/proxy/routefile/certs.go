@@ -0,0 +1,64 @@
+package routefile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CertificateDir returns the directory holding uploaded certificates of a
+// proxy configured through configFile, next to it so the proxy can read them
+func CertificateDir(configFile string) string {
+	return filepath.Join(filepath.Dir(configFile), "nebula-certs")
+}
+
+// CertificateFiles returns the certificate and key files of a domain in dir,
+// or empty strings when they are not in place
+func CertificateFiles(dir, domain string) (string, string) {
+	if dir == "" {
+		return "", ""
+	}
+	cert := filepath.Join(dir, domain, "fullchain.pem")
+	key := filepath.Join(dir, domain, "privkey.pem")
+	for _, path := range []string{cert, key} {
+		if _, err := os.Stat(path); err != nil {
+			return "", ""
+		}
+	}
+	return cert, key
+}
+
+// WriteCertificate stores a domain's certificate chain and private key in dir
+func WriteCertificate(dir, domain string, certPEM, keyPEM []byte) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+	cert := filepath.Join(dir, domain, "fullchain.pem")
+	key := filepath.Join(dir, domain, "privkey.pem")
+	// The key is never readable by others, not even while being replaced
+	if err := writeFile(key, keyPEM, 0600); err != nil {
+		return err
+	}
+	return WriteFile(cert, certPEM)
+}
+
+// RemoveCertificate deletes a domain's certificate from dir
+func RemoveCertificate(dir, domain string) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(dir, domain)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove certificate of %s: %w", domain, err)
+	}
+	return nil
+}
+
+// checkDomain keeps a domain from naming a path outside the certificate dir
+func checkDomain(domain string) error {
+	if domain == "" || strings.ContainsAny(domain, `/\`) || strings.HasPrefix(domain, ".") {
+		return fmt.Errorf("invalid certificate domain %q", domain)
+	}
+	return nil
+}
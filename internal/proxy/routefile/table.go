@@ -0,0 +1,244 @@
+// Package routefile supports proxies configured through rendered files, such
+// as Traefik's file provider and nginx. Nebula keeps their routes in a JSON
+// table and renders the proxy's configuration from it.
+package routefile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/victalejo/nebula/internal/core/proxy"
+)
+
+// Table holds a proxy's routes, persisted as JSON so the configuration can be
+// rendered again after a restart without parsing the proxy's own format
+type Table struct {
+	path   string
+	mu     sync.Mutex
+	routes map[string]proxy.Route // keyed by Key, loaded on first use
+}
+
+// NewTable creates a route table stored at path
+func NewTable(path string) *Table {
+	return &Table{path: path}
+}
+
+// Key identifies the route of a domain and path prefix
+func Key(domain, pathPrefix string) string {
+	if pathPrefix == "" {
+		pathPrefix = "/"
+	}
+	return domain + pathPrefix
+}
+
+// Routes returns the routes, longest path prefixes first
+func (t *Table) Routes() ([]proxy.Route, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return sorted(t.routes), nil
+}
+
+// Get returns the route of a domain and path prefix, or nil
+func (t *Table) Get(domain, pathPrefix string) (*proxy.Route, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	route, ok := t.routes[Key(domain, pathPrefix)]
+	if !ok {
+		return nil, nil
+	}
+	return &route, nil
+}
+
+// Update changes the routes and renders the result with apply. The change is
+// kept only when apply succeeds.
+func (t *Table) Update(change func(routes map[string]proxy.Route), apply func(routes []proxy.Route) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(); err != nil {
+		return err
+	}
+
+	routes := make(map[string]proxy.Route, len(t.routes))
+	for key, route := range t.routes {
+		routes[key] = route
+	}
+	change(routes)
+
+	if err := apply(sorted(routes)); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(sorted(routes), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal routes: %w", err)
+	}
+	if err := WriteFile(t.path, data); err != nil {
+		return err
+	}
+	t.routes = routes
+	return nil
+}
+
+// Replace makes routes the complete route set
+func (t *Table) Replace(routes []proxy.Route, apply func(routes []proxy.Route) error) error {
+	return t.Update(func(current map[string]proxy.Route) {
+		for key := range current {
+			delete(current, key)
+		}
+		for _, route := range routes {
+			current[Key(route.Domain, route.PathPrefix)] = route
+		}
+	}, apply)
+}
+
+// Diff reports how the desired routes differ from the table. render returns
+// the proxy configuration of a route, which is what the changes show.
+func (t *Table) Diff(desired []proxy.Route, render func(route proxy.Route) (json.RawMessage, error)) ([]proxy.RouteChange, error) {
+	current, err := t.Routes()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]proxy.Route, len(desired))
+	for _, route := range desired {
+		wanted[Key(route.Domain, route.PathPrefix)] = route
+	}
+
+	var changes []proxy.RouteChange
+	for _, route := range current {
+		key := Key(route.Domain, route.PathPrefix)
+		currentConfig, err := render(route)
+		if err != nil {
+			return nil, err
+		}
+		change := proxy.RouteChange{
+			Domain:     route.Domain,
+			PathPrefix: route.PathPrefix,
+			Current:    currentConfig,
+		}
+
+		want, ok := wanted[key]
+		delete(wanted, key)
+		if !ok {
+			change.Action = proxy.RouteRemoved
+			changes = append(changes, change)
+			continue
+		}
+		desiredConfig, err := render(want)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(route, want) {
+			change.Action = proxy.RouteUpdated
+			change.Desired = desiredConfig
+			changes = append(changes, change)
+		}
+	}
+
+	for _, route := range sorted(wanted) {
+		desiredConfig, err := render(route)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, proxy.RouteChange{
+			Action:     proxy.RouteAdded,
+			Domain:     route.Domain,
+			PathPrefix: route.PathPrefix,
+			Desired:    desiredConfig,
+		})
+	}
+	return changes, nil
+}
+
+// load reads the table from disk on first use
+func (t *Table) load() error {
+	if t.routes != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.routes = make(map[string]proxy.Route)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read routes: %w", err)
+	}
+
+	var routes []proxy.Route
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return fmt.Errorf("failed to parse routes %s: %w", t.path, err)
+	}
+	t.routes = make(map[string]proxy.Route, len(routes))
+	for _, route := range routes {
+		t.routes[Key(route.Domain, route.PathPrefix)] = route
+	}
+	return nil
+}
+
+// sorted orders routes the way proxies that run the first match need them:
+// longer path prefixes before shorter ones, then by domain
+func sorted(routes map[string]proxy.Route) []proxy.Route {
+	result := make([]proxy.Route, 0, len(routes))
+	for _, route := range routes {
+		result = append(result, route)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		pi, pj := result[i].PathPrefix, result[j].PathPrefix
+		if len(pi) != len(pj) {
+			return len(pi) > len(pj)
+		}
+		return Key(result[i].Domain, pi) < Key(result[j].Domain, pj)
+	})
+	return result
+}
+
+// WriteFile replaces a file atomically, so a proxy watching it never reads a
+// partial configuration
+func WriteFile(path string, data []byte) error {
+	return writeFile(path, data, 0644)
+}
+
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,665 @@
+// Package traefik implements the ProxyManager interface for Traefik, through
+// a dynamic configuration file watched by Traefik's file provider.
+package traefik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/victalejo/nebula/internal/core/container"
+	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/proxy/dockernet"
+	"github.com/victalejo/nebula/internal/proxy/routefile"
+)
+
+// Options configures the Traefik manager
+type Options struct {
+	ConfigFile      string // dynamic configuration file watched by the file provider
+	StateFile       string // where Nebula keeps the routes it rendered
+	APIURL          string // Traefik's API, for health checks (optional)
+	HTTPEntrypoint  string // entrypoint serving plain HTTP, e.g. "web"
+	HTTPSEntrypoint string // entrypoint serving TLS, e.g. "websecure"
+	CertResolver    string // ACME resolver for TLS routes, empty for the default certificate
+	Container       string // Traefik's container, empty when Traefik runs on the host
+}
+
+// Manager implements the ProxyManager interface for Traefik
+type Manager struct {
+	opts    Options
+	table   *routefile.Table
+	runtime container.ContainerRuntime
+	client  *http.Client
+	log     logger.Logger
+}
+
+// NewManager creates a new Traefik manager
+func NewManager(opts Options, runtime container.ContainerRuntime, log logger.Logger) *Manager {
+	return &Manager{
+		opts:    opts,
+		table:   routefile.NewTable(opts.StateFile),
+		runtime: runtime,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		log: log,
+	}
+}
+
+// dynamicConfig is Traefik's dynamic configuration, limited to what Nebula renders
+type dynamicConfig struct {
+	HTTP httpConfig `yaml:"http" json:"http"`
+	TLS  *tlsConfig `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+// tlsConfig lists the uploaded certificates Traefik serves
+type tlsConfig struct {
+	Certificates []tlsCertificate `yaml:"certificates" json:"certificates"`
+}
+
+type tlsCertificate struct {
+	CertFile string `yaml:"certFile" json:"certFile"`
+	KeyFile  string `yaml:"keyFile" json:"keyFile"`
+}
+
+type httpConfig struct {
+	Routers     map[string]*router     `yaml:"routers,omitempty" json:"routers,omitempty"`
+	Middlewares map[string]*middleware `yaml:"middlewares,omitempty" json:"middlewares,omitempty"`
+	Services    map[string]*service    `yaml:"services,omitempty" json:"services,omitempty"`
+}
+
+type router struct {
+	Rule        string     `yaml:"rule" json:"rule"`
+	EntryPoints []string   `yaml:"entryPoints,omitempty" json:"entryPoints,omitempty"`
+	Middlewares []string   `yaml:"middlewares,omitempty" json:"middlewares,omitempty"`
+	Service     string     `yaml:"service" json:"service"`
+	Priority    int        `yaml:"priority,omitempty" json:"priority,omitempty"`
+	TLS         *routerTLS `yaml:"tls,omitempty" json:"tls,omitempty"`
+}
+
+type routerTLS struct {
+	CertResolver string      `yaml:"certResolver,omitempty" json:"certResolver,omitempty"`
+	Domains      []tlsDomain `yaml:"domains,omitempty" json:"domains,omitempty"`
+}
+
+type tlsDomain struct {
+	Main string `yaml:"main" json:"main"`
+}
+
+type middleware struct {
+	StripPrefix      *stripPrefix      `yaml:"stripPrefix,omitempty" json:"stripPrefix,omitempty"`
+	RedirectScheme   *redirectScheme   `yaml:"redirectScheme,omitempty" json:"redirectScheme,omitempty"`
+	RedirectRegex    *redirectRegex    `yaml:"redirectRegex,omitempty" json:"redirectRegex,omitempty"`
+	ReplacePathRegex *replacePathRegex `yaml:"replacePathRegex,omitempty" json:"replacePathRegex,omitempty"`
+	IPAllowList      *ipAllowList      `yaml:"ipAllowList,omitempty" json:"ipAllowList,omitempty"`
+	RateLimit        *rateLimit        `yaml:"rateLimit,omitempty" json:"rateLimit,omitempty"`
+	BasicAuth        *basicAuth        `yaml:"basicAuth,omitempty" json:"basicAuth,omitempty"`
+	Headers          *headers          `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+type stripPrefix struct {
+	Prefixes []string `yaml:"prefixes" json:"prefixes"`
+}
+
+type redirectScheme struct {
+	Scheme    string `yaml:"scheme" json:"scheme"`
+	Permanent bool   `yaml:"permanent" json:"permanent"`
+}
+
+// redirectRegex matches the full request URL, scheme://host/path?query
+type redirectRegex struct {
+	Regex       string `yaml:"regex" json:"regex"`
+	Replacement string `yaml:"replacement" json:"replacement"`
+	Permanent   bool   `yaml:"permanent" json:"permanent"`
+}
+
+type replacePathRegex struct {
+	Regex       string `yaml:"regex" json:"regex"`
+	Replacement string `yaml:"replacement" json:"replacement"`
+}
+
+type ipAllowList struct {
+	SourceRange []string `yaml:"sourceRange" json:"sourceRange"`
+}
+
+// rateLimit allows average requests per period per client IP, in bursts of
+// up to burst requests
+type rateLimit struct {
+	Average int    `yaml:"average" json:"average"`
+	Period  string `yaml:"period" json:"period"`
+	Burst   int    `yaml:"burst" json:"burst"`
+}
+
+// basicAuth users are "name:hash" in htpasswd format
+type basicAuth struct {
+	Users []string `yaml:"users" json:"users"`
+	Realm string   `yaml:"realm,omitempty" json:"realm,omitempty"`
+}
+
+// headers sets response headers; an empty value removes the header. Traefik
+// answers CORS preflight requests itself.
+type headers struct {
+	CustomResponseHeaders         map[string]string `yaml:"customResponseHeaders,omitempty" json:"customResponseHeaders,omitempty"`
+	AccessControlAllowOriginList  []string          `yaml:"accessControlAllowOriginList,omitempty" json:"accessControlAllowOriginList,omitempty"`
+	AccessControlAllowMethods     []string          `yaml:"accessControlAllowMethods,omitempty" json:"accessControlAllowMethods,omitempty"`
+	AccessControlAllowHeaders     []string          `yaml:"accessControlAllowHeaders,omitempty" json:"accessControlAllowHeaders,omitempty"`
+	AccessControlExposeHeaders    []string          `yaml:"accessControlExposeHeaders,omitempty" json:"accessControlExposeHeaders,omitempty"`
+	AccessControlAllowCredentials bool              `yaml:"accessControlAllowCredentials,omitempty" json:"accessControlAllowCredentials,omitempty"`
+	AccessControlMaxAge           int               `yaml:"accessControlMaxAge,omitempty" json:"accessControlMaxAge,omitempty"`
+	AddVaryHeader                 bool              `yaml:"addVaryHeader,omitempty" json:"addVaryHeader,omitempty"`
+}
+
+type service struct {
+	LoadBalancer *loadBalancer `yaml:"loadBalancer,omitempty" json:"loadBalancer,omitempty"`
+	Weighted     *weighted     `yaml:"weighted,omitempty" json:"weighted,omitempty"`
+}
+
+type loadBalancer struct {
+	Servers []server `yaml:"servers" json:"servers"`
+}
+
+type server struct {
+	URL string `yaml:"url" json:"url"`
+}
+
+type weighted struct {
+	Services []weightedService `yaml:"services" json:"services"`
+}
+
+type weightedService struct {
+	Name   string `yaml:"name" json:"name"`
+	Weight int    `yaml:"weight" json:"weight"`
+}
+
+// routeName returns the name of the routers and services of a route. The
+// hash keeps names unique when domains and prefixes sanitize alike.
+func routeName(domain, pathPrefix string) string {
+	key := routefile.Key(domain, pathPrefix)
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	sanitized := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(key))
+	return fmt.Sprintf("nebula-%s-%08x", strings.Trim(sanitized, "-"), h.Sum32())
+}
+
+// rule matches the route's hosts and, for path routes, the prefix itself and
+// everything below it. Wildcard hosts match one label with HostRegexp.
+func rule(route proxy.Route) string {
+	hosts := make([]string, 0, len(route.Aliases)+1)
+	for _, host := range route.Hosts() {
+		if base, ok := strings.CutPrefix(host, "*."); ok {
+			hosts = append(hosts, fmt.Sprintf("HostRegexp(`^[^.]+\\.%s$`)", regexp.QuoteMeta(base)))
+			continue
+		}
+		hosts = append(hosts, fmt.Sprintf("Host(`%s`)", host))
+	}
+	host := strings.Join(hosts, " || ")
+	if len(hosts) > 1 {
+		host = "(" + host + ")"
+	}
+	if !route.PathMatched() {
+		return host
+	}
+	return fmt.Sprintf("%s && (Path(`%s`) || PathPrefix(`%s/`))", host, route.PathPrefix, route.PathPrefix)
+}
+
+// permanent maps a redirect status to Traefik's choice between permanent
+// (301/308) and temporary (302/307) redirects
+func permanent(status int) bool {
+	return status == 301 || status == 308
+}
+
+// urlPrefix captures the scheme and host of the URLs redirectRegex matches
+const urlPrefix = "^(https?://[^/]+)"
+
+// redirectMiddlewares answers requests for redirected paths; the query string
+// is kept
+func redirectMiddlewares(redirect proxy.Redirect) (exact, below *middleware) {
+	origin := ""
+	if strings.HasPrefix(redirect.To, "/") {
+		origin = "${1}"
+	}
+	exactPattern, belowPattern := proxy.PathPatterns(redirect.From)
+
+	exact = &middleware{RedirectRegex: &redirectRegex{
+		Regex:       urlPrefix + exactPattern + `(\?.*)?$`,
+		Replacement: origin + redirect.To + "${2}",
+		Permanent:   permanent(redirect.Status),
+	}}
+	below = &middleware{RedirectRegex: &redirectRegex{
+		Regex:       urlPrefix + belowPattern + `(\?.*)?$`,
+		Replacement: origin + proxy.JoinPath(redirect.To, "${2}") + "${3}",
+		Permanent:   permanent(redirect.Status),
+	}}
+	return exact, below
+}
+
+func upstreamService(upstream *proxy.Upstream) *service {
+	return &service{
+		LoadBalancer: &loadBalancer{
+			Servers: []server{{URL: fmt.Sprintf("http://%s:%d", upstream.Host, upstream.Port)}},
+		},
+	}
+}
+
+// render adds the routers, middlewares and services of a route to config.
+// Canary releases split traffic with a weighted service over both slots.
+func (m *Manager) render(config *httpConfig, route proxy.Route) error {
+	name := routeName(route.Domain, route.PathPrefix)
+
+	if route.Weighted() {
+		config.Services[name+"-blue"] = upstreamService(route.BlueTarget)
+		config.Services[name+"-green"] = upstreamService(route.GreenTarget)
+		config.Services[name] = &service{
+			Weighted: &weighted{Services: []weightedService{
+				{Name: name + "-blue", Weight: route.BlueWeight},
+				{Name: name + "-green", Weight: route.GreenWeight},
+			}},
+		}
+	} else {
+		upstream := route.BlueTarget
+		if route.ActiveSlot == proxy.SlotGreen {
+			upstream = route.GreenTarget
+		}
+		if upstream == nil {
+			return fmt.Errorf("route %s%s: no active upstream configured", route.Domain, route.PathPrefix)
+		}
+		config.Services[name] = upstreamService(upstream)
+	}
+
+	// Middlewares run in order: redirects, headers and CORS, access control,
+	// rewrites (paths below a prefix before the prefix itself), then prefix
+	// stripping
+	var middlewares []string
+	for i, redirect := range route.Redirects {
+		exact, below := redirectMiddlewares(redirect)
+		config.Middlewares[fmt.Sprintf("%s-redirect-%d", name, i)] = exact
+		config.Middlewares[fmt.Sprintf("%s-redirect-%d-below", name, i)] = below
+		middlewares = append(middlewares, fmt.Sprintf("%s-redirect-%d", name, i), fmt.Sprintf("%s-redirect-%d-below", name, i))
+	}
+	if h := headersMiddleware(route); h != nil {
+		config.Middlewares[name+"-headers"] = &middleware{Headers: h}
+		middlewares = append(middlewares, name+"-headers")
+	}
+	if err := m.ValidateRoute(route); err != nil {
+		return err
+	}
+	if len(route.AllowCIDRs) > 0 {
+		config.Middlewares[name+"-allow"] = &middleware{IPAllowList: &ipAllowList{SourceRange: route.AllowCIDRs}}
+		middlewares = append(middlewares, name+"-allow")
+	}
+	if route.RateLimit != nil {
+		config.Middlewares[name+"-ratelimit"] = &middleware{RateLimit: &rateLimit{
+			Average: route.RateLimit.Requests,
+			Period:  route.RateLimit.Window.String(),
+			Burst:   route.RateLimit.Requests,
+		}}
+		middlewares = append(middlewares, name+"-ratelimit")
+	}
+	if len(route.BasicAuth) > 0 {
+		auth := &basicAuth{Realm: "restricted"}
+		for _, user := range route.BasicAuth {
+			auth.Users = append(auth.Users, user.Username+":"+user.PasswordHash)
+		}
+		config.Middlewares[name+"-auth"] = &middleware{BasicAuth: auth}
+		middlewares = append(middlewares, name+"-auth")
+	}
+	for i, rewrite := range route.Rewrites {
+		exact, below := proxy.PathPatterns(rewrite.From)
+		config.Middlewares[fmt.Sprintf("%s-rewrite-%d-below", name, i)] = &middleware{ReplacePathRegex: &replacePathRegex{
+			Regex:       "^" + below + "$",
+			Replacement: proxy.JoinPath(rewrite.To, "${1}"),
+		}}
+		config.Middlewares[fmt.Sprintf("%s-rewrite-%d", name, i)] = &middleware{ReplacePathRegex: &replacePathRegex{
+			Regex:       "^" + exact + "$",
+			Replacement: rewrite.To,
+		}}
+		middlewares = append(middlewares, fmt.Sprintf("%s-rewrite-%d-below", name, i), fmt.Sprintf("%s-rewrite-%d", name, i))
+	}
+	if route.PathMatched() && route.StripPrefix {
+		config.Middlewares[name+"-strip"] = &middleware{
+			StripPrefix: &stripPrefix{Prefixes: []string{route.PathPrefix}},
+		}
+		middlewares = append(middlewares, name+"-strip")
+	}
+
+	httpMiddlewares := middlewares
+	if route.ForceHTTPS {
+		config.Middlewares[name+"-https"] = &middleware{
+			RedirectScheme: &redirectScheme{Scheme: "https", Permanent: true},
+		}
+		httpMiddlewares = append([]string{name + "-https"}, middlewares...)
+	}
+
+	config.Routers[name] = &router{
+		Rule:        rule(route),
+		EntryPoints: []string{m.opts.HTTPEntrypoint},
+		Middlewares: httpMiddlewares,
+		Service:     name,
+		Priority:    priority(route),
+	}
+	m.renderHostRedirects(config, route, name)
+	if route.SSLEnabled || route.ForceHTTPS {
+		tls := m.routerTLS(route.Domain)
+		if route.Wildcard() && tls.CertResolver != "" {
+			// The resolver cannot tell the certificate's name from HostRegexp
+			tls.Domains = []tlsDomain{{Main: route.Domain}}
+		}
+		config.Routers[name+"-tls"] = &router{
+			Rule:        rule(route),
+			EntryPoints: []string{m.opts.HTTPSEntrypoint},
+			Middlewares: middlewares,
+			Service:     name,
+			Priority:    priority(route),
+			TLS:         tls,
+		}
+	}
+	return nil
+}
+
+// priority ranks wildcard routers below the routers of exact hosts, which
+// Traefik ranks by rule length, keeping longer path prefixes first. Other
+// routers keep Traefik's default.
+func priority(route proxy.Route) int {
+	if !route.Wildcard() {
+		return 0
+	}
+	if route.PathMatched() {
+		return 1 + len(route.PathPrefix)
+	}
+	return 1
+}
+
+// headersMiddleware returns the response headers and CORS policy of a
+// route, or nil without either
+func headersMiddleware(route proxy.Route) *headers {
+	if len(route.SetHeaders) == 0 && len(route.RemoveHeaders) == 0 && route.CORS == nil {
+		return nil
+	}
+
+	h := &headers{}
+	if len(route.SetHeaders) > 0 || len(route.RemoveHeaders) > 0 {
+		h.CustomResponseHeaders = make(map[string]string)
+		for name, value := range route.SetHeaders {
+			h.CustomResponseHeaders[name] = value
+		}
+		for _, name := range route.RemoveHeaders {
+			h.CustomResponseHeaders[name] = ""
+		}
+	}
+	if cors := route.CORS; cors != nil {
+		h.AccessControlAllowOriginList = cors.AllowOrigins
+		h.AccessControlAllowMethods = cors.AllowMethods
+		h.AccessControlAllowHeaders = cors.AllowHeaders
+		h.AccessControlExposeHeaders = cors.ExposeHeaders
+		h.AccessControlAllowCredentials = cors.AllowCredentials
+		h.AccessControlMaxAge = cors.MaxAge
+		h.AddVaryHeader = true
+	}
+	return h
+}
+
+// ValidateRoute rejects IP deny lists, which Traefik has no middleware for
+func (m *Manager) ValidateRoute(route proxy.Route) error {
+	if len(route.DenyCIDRs) > 0 {
+		return fmt.Errorf("route %s%s: traefik cannot deny client IPs, use an allow list instead", route.Domain, route.PathPrefix)
+	}
+	return nil
+}
+
+// renderHostRedirects adds a router per host redirected to the route's domain
+func (m *Manager) renderHostRedirects(config *httpConfig, route proxy.Route, name string) {
+	for i, redirect := range route.HostRedirects {
+		redirectName := fmt.Sprintf("%s-host-%d", name, i)
+		replacement := "${1}://" + route.Domain + "${2}"
+		if route.SSLEnabled || route.ForceHTTPS {
+			replacement = "https://" + route.Domain + "${2}"
+		}
+		config.Middlewares[redirectName] = &middleware{RedirectRegex: &redirectRegex{
+			Regex:       "^(https?)://[^/]+(.*)$",
+			Replacement: replacement,
+			Permanent:   permanent(redirect.Status),
+		}}
+
+		config.Routers[redirectName] = &router{
+			Rule:        fmt.Sprintf("Host(`%s`)", redirect.Host),
+			EntryPoints: []string{m.opts.HTTPEntrypoint},
+			Middlewares: []string{redirectName},
+			Service:     "noop@internal",
+		}
+		if route.SSLEnabled {
+			config.Routers[redirectName+"-tls"] = &router{
+				Rule:        fmt.Sprintf("Host(`%s`)", redirect.Host),
+				EntryPoints: []string{m.opts.HTTPSEntrypoint},
+				Middlewares: []string{redirectName},
+				Service:     "noop@internal",
+				TLS:         m.routerTLS(redirect.Host),
+			}
+		}
+	}
+}
+
+// routerTLS serves a host's uploaded certificate, or one from the resolver
+func (m *Manager) routerTLS(host string) *routerTLS {
+	if cert, _ := routefile.CertificateFiles(m.certDir(), host); cert != "" {
+		return &routerTLS{}
+	}
+	return &routerTLS{CertResolver: m.opts.CertResolver}
+}
+
+// certDir holds uploaded certificates, next to the dynamic configuration
+func (m *Manager) certDir() string {
+	return routefile.CertificateDir(m.opts.ConfigFile)
+}
+
+// tlsCertificates lists the uploaded certificates of the routes' hosts
+func (m *Manager) tlsCertificates(routes []proxy.Route) *tlsConfig {
+	seen := make(map[string]bool)
+	config := &tlsConfig{}
+	for _, route := range routes {
+		hosts := route.Hosts()
+		for _, redirect := range route.HostRedirects {
+			hosts = append(hosts, redirect.Host)
+		}
+		for _, host := range hosts {
+			if seen[host] {
+				continue
+			}
+			seen[host] = true
+			if cert, key := routefile.CertificateFiles(m.certDir(), host); cert != "" {
+				config.Certificates = append(config.Certificates, tlsCertificate{CertFile: cert, KeyFile: key})
+			}
+		}
+	}
+	if len(config.Certificates) == 0 {
+		return nil
+	}
+	return config
+}
+
+func newHTTPConfig() httpConfig {
+	return httpConfig{
+		Routers:     make(map[string]*router),
+		Middlewares: make(map[string]*middleware),
+		Services:    make(map[string]*service),
+	}
+}
+
+// apply renders the routes into Traefik's dynamic configuration file, which
+// Traefik reloads on change
+func (m *Manager) apply(routes []proxy.Route) error {
+	config := dynamicConfig{HTTP: newHTTPConfig(), TLS: m.tlsCertificates(routes)}
+	for _, route := range routes {
+		if err := m.render(&config.HTTP, route); err != nil {
+			return err
+		}
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal traefik config: %w", err)
+	}
+	header := "# Managed by Nebula - manual changes will be overwritten\n"
+	return routefile.WriteFile(m.opts.ConfigFile, append([]byte(header), data...))
+}
+
+// renderRoute returns the configuration of a single route, for diffs
+func (m *Manager) renderRoute(route proxy.Route) (json.RawMessage, error) {
+	config := newHTTPConfig()
+	if err := m.render(&config, route); err != nil {
+		return nil, err
+	}
+	return json.Marshal(config)
+}
+
+// AttachNetwork connects Traefik's container to a Docker network, so it can
+// reach the containers on it by name
+func (m *Manager) AttachNetwork(ctx context.Context, network string) error {
+	attached, err := dockernet.Attach(ctx, m.runtime, m.opts.Container, network)
+	if err != nil {
+		return err
+	}
+	if attached {
+		m.log.Info("traefik attached to network", "network", network)
+	}
+	return nil
+}
+
+// AddRoute adds a route, replacing any route serving the same domain and path prefix
+func (m *Manager) AddRoute(ctx context.Context, route proxy.Route) error {
+	return m.UpdateRoute(ctx, route)
+}
+
+// UpdateRoute creates or replaces the route of a domain and path prefix
+func (m *Manager) UpdateRoute(ctx context.Context, route proxy.Route) error {
+	m.log.Info("updating traefik route",
+		"domain", route.Domain,
+		"path_prefix", route.PathPrefix,
+		"app_id", route.AppID,
+	)
+
+	return m.table.Update(func(routes map[string]proxy.Route) {
+		routes[routefile.Key(route.Domain, route.PathPrefix)] = route
+	}, m.apply)
+}
+
+// RemoveRoute removes the route of a domain and path prefix
+func (m *Manager) RemoveRoute(ctx context.Context, domain, pathPrefix string) error {
+	m.log.Info("removing traefik route", "domain", domain, "path_prefix", pathPrefix)
+
+	return m.table.Update(func(routes map[string]proxy.Route) {
+		delete(routes, routefile.Key(domain, pathPrefix))
+	}, m.apply)
+}
+
+// GetRoute retrieves the route of a domain and path prefix
+func (m *Manager) GetRoute(ctx context.Context, domain, pathPrefix string) (*proxy.Route, error) {
+	return m.table.Get(domain, pathPrefix)
+}
+
+// ListRoutes returns all routes
+func (m *Manager) ListRoutes(ctx context.Context) ([]proxy.Route, error) {
+	return m.table.Routes()
+}
+
+// SyncRoutes makes routes the complete set of routes in the configuration file
+func (m *Manager) SyncRoutes(ctx context.Context, routes []proxy.Route) error {
+	m.log.Info("syncing traefik routes", "routes", len(routes))
+	return m.table.Replace(routes, m.apply)
+}
+
+// DiffRoutes reports the changes SyncRoutes would make
+func (m *Manager) DiffRoutes(ctx context.Context, routes []proxy.Route) ([]proxy.RouteChange, error) {
+	return m.table.Diff(routes, m.renderRoute)
+}
+
+// SwitchTraffic points every route of a domain at another slot
+func (m *Manager) SwitchTraffic(ctx context.Context, domain string, targetSlot proxy.Slot) error {
+	m.log.Info("switching traffic", "domain", domain, "target_slot", targetSlot)
+
+	return m.table.Update(func(routes map[string]proxy.Route) {
+		for key, route := range routes {
+			if route.Domain == domain {
+				route.ActiveSlot = targetSlot
+				route.BlueWeight, route.GreenWeight = 0, 0
+				routes[key] = route
+			}
+		}
+	}, m.apply)
+}
+
+// InstallCertificate serves an uploaded certificate for a domain
+func (m *Manager) InstallCertificate(ctx context.Context, domain string, certPEM, keyPEM []byte) error {
+	m.log.Info("installing traefik certificate", "domain", domain)
+
+	if err := routefile.WriteCertificate(m.certDir(), domain, certPEM, keyPEM); err != nil {
+		return err
+	}
+	return m.table.Update(func(map[string]proxy.Route) {}, m.apply)
+}
+
+// RemoveCertificate stops serving a domain's uploaded certificate
+func (m *Manager) RemoveCertificate(ctx context.Context, domain string) error {
+	m.log.Info("removing traefik certificate", "domain", domain)
+
+	if err := routefile.RemoveCertificate(m.certDir(), domain); err != nil {
+		return err
+	}
+	return m.table.Update(func(map[string]proxy.Route) {}, m.apply)
+}
+
+// ProvisionSSL provisions SSL for a domain
+func (m *Manager) ProvisionSSL(ctx context.Context, domain string) error {
+	// Traefik requests certificates through the configured resolver
+	m.log.Info("SSL will be provisioned by traefik", "domain", domain, "cert_resolver", m.opts.CertResolver)
+	return nil
+}
+
+// HealthCheck checks that Traefik answers on its API, or that its
+// configuration directory is in place when no API is configured
+func (m *Manager) HealthCheck(ctx context.Context) error {
+	if m.opts.APIURL == "" {
+		dir := filepath.Dir(m.opts.ConfigFile)
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("traefik config directory unavailable: %w", err)
+		}
+		return nil
+	}
+
+	url := fmt.Sprintf("%s/api/version", strings.TrimSuffix(m.opts.APIURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("traefik health check failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("traefik returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ReloadConfig renders the configuration file again from Nebula's routes
+func (m *Manager) ReloadConfig(ctx context.Context) error {
+	return m.table.Update(func(map[string]proxy.Route) {}, m.apply)
+}
@@ -53,6 +53,10 @@ type AppResponse struct {
 	Environment    map[string]string       `json:"environment"`
 	CreatedAt      string                  `json:"created_at"`
 	UpdatedAt      string                  `json:"updated_at"`
+
+	// Environments: ParentID is set when this project is an environment of another
+	ParentID        string `json:"parent_id,omitempty"`
+	EnvironmentName string `json:"environment_name"`
 }
 
 // Create creates a new application/project
@@ -86,6 +90,8 @@ func (s *AppService) Create(ctx context.Context, req CreateAppRequest) (*AppResp
 		GitRepo:     req.GitRepo,
 		GitBranch:   req.GitBranch,
 		Environment: envJSON,
+
+		EnvironmentName: DefaultEnvironmentName,
 	}
 
 	if err := s.store.Apps().Create(ctx, project); err != nil {
@@ -251,6 +257,9 @@ func (s *AppService) toResponse(ctx context.Context, project *storage.Project) *
 		Environment: env,
 		CreatedAt:   project.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:   project.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+
+		ParentID:        project.ParentID,
+		EnvironmentName: project.EnvironmentName,
 	}
 
 	// Legacy compatibility: get info from "main" service if exists
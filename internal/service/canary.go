@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/storage"
+	"github.com/victalejo/nebula/internal/deployer/health"
+)
+
+// Canary defaults and limits
+const (
+	defaultCanaryStepDuration = 2 * time.Minute
+	minCanaryStepDuration     = 10 * time.Second
+	defaultCanaryMaxErrorRate = 10.0
+	canaryProbeInterval       = 5 * time.Second
+)
+
+var defaultCanarySteps = []int{10, 50, 100}
+
+// CanaryPolicy configures a progressive rollout. The new deployment receives
+// an increasing share of traffic while it is probed; it is rolled back when it
+// stops running or fails too many probes or requests during a step.
+type CanaryPolicy struct {
+	Steps             []int    `json:"steps,omitempty"`               // traffic percentages, e.g. [5, 25, 100]
+	StepDuration      string   `json:"step_duration,omitempty"`       // observation time per step, e.g. "2m"
+	MaxErrorRate      *float64 `json:"max_error_rate,omitempty"`      // percentage of failed health probes tolerated per step
+	MaxFailedRequests int      `json:"max_failed_requests,omitempty"` // 5xx responses tolerated per step, 0 disables the check
+}
+
+// canaryPolicy is a validated CanaryPolicy
+type canaryPolicy struct {
+	steps             []int
+	stepDuration      time.Duration
+	maxErrorRate      float64
+	maxFailedRequests int
+}
+
+// validate checks the policy and fills in defaults
+func (p *CanaryPolicy) validate() (*canaryPolicy, error) {
+	policy := &canaryPolicy{
+		steps:             p.Steps,
+		stepDuration:      defaultCanaryStepDuration,
+		maxErrorRate:      defaultCanaryMaxErrorRate,
+		maxFailedRequests: p.MaxFailedRequests,
+	}
+	if len(policy.steps) == 0 {
+		policy.steps = defaultCanarySteps
+	}
+
+	prev := 0
+	for _, step := range policy.steps {
+		if step <= prev || step > 100 {
+			return nil, apperrors.NewValidationError("canary.steps must be increasing percentages between 1 and 100", map[string]interface{}{
+				"steps": p.Steps,
+			})
+		}
+		prev = step
+	}
+	if prev != 100 {
+		policy.steps = append(append([]int{}, policy.steps...), 100)
+	}
+
+	if p.StepDuration != "" {
+		d, err := time.ParseDuration(p.StepDuration)
+		if err != nil || d < minCanaryStepDuration {
+			return nil, apperrors.NewValidationError(fmt.Sprintf("canary.step_duration must be a duration of at least %s", minCanaryStepDuration), nil)
+		}
+		policy.stepDuration = d
+	}
+
+	if p.MaxErrorRate != nil {
+		if *p.MaxErrorRate < 0 || *p.MaxErrorRate > 100 {
+			return nil, apperrors.NewValidationError("canary.max_error_rate must be between 0 and 100", nil)
+		}
+		policy.maxErrorRate = *p.MaxErrorRate
+	}
+
+	if p.MaxFailedRequests < 0 {
+		return nil, apperrors.NewValidationError("canary.max_failed_requests must not be negative", nil)
+	}
+
+	return policy, nil
+}
+
+// canaryControl lets operators promote or abort a rollout in progress
+type canaryControl struct {
+	deploymentID  string
+	failureWindow time.Duration // window the proxy counts canary failures over
+	promote       chan struct{}
+	abort         chan struct{}
+}
+
+func (s *DeployService) registerCanary(serviceID, deploymentID string, failureWindow time.Duration) *canaryControl {
+	s.canaryMu.Lock()
+	defer s.canaryMu.Unlock()
+
+	if s.canaries == nil {
+		s.canaries = make(map[string]*canaryControl)
+	}
+	control := &canaryControl{
+		deploymentID:  deploymentID,
+		failureWindow: failureWindow,
+		promote:       make(chan struct{}, 1),
+		abort:         make(chan struct{}, 1),
+	}
+	s.canaries[serviceID] = control
+	return control
+}
+
+func (s *DeployService) unregisterCanary(serviceID string) {
+	s.canaryMu.Lock()
+	defer s.canaryMu.Unlock()
+	delete(s.canaries, serviceID)
+}
+
+// canaryFailureWindow returns the failure window of a service's rollout in
+// progress, or 0
+func (s *DeployService) canaryFailureWindow(serviceID string) time.Duration {
+	s.canaryMu.Lock()
+	defer s.canaryMu.Unlock()
+	if control := s.canaries[serviceID]; control != nil {
+		return control.failureWindow
+	}
+	return 0
+}
+
+// runCanary shifts traffic to the new deployment step by step. It reports
+// whether a rollout took place: services without a live deployment or without
+// domains are deployed as usual.
+func (s *DeployService) runCanary(
+	ctx context.Context,
+	project *storage.Project,
+	service *storage.Service,
+	deployment *storage.Deployment,
+	spec *deployer.DeploymentSpec,
+	result *deployer.DeploymentResult,
+	policy *canaryPolicy,
+) (bool, error) {
+	stableSlot := spec.TargetSlot.Opposite()
+	stable, err := s.store.Deployments().GetByServiceIDAndSlot(ctx, service.ID, string(stableSlot))
+	if err != nil || stable == nil {
+		s.log.Info("no live deployment to compare against, skipping canary", "service_id", service.ID)
+		return false, nil
+	}
+	stableUpstream := s.deploymentUpstream(ctx, stable.ID)
+
+	domains, err := s.routedDomains(ctx, service.ID)
+	if err != nil || len(domains) == 0 || stableUpstream == nil {
+		s.log.Info("service receives no routed traffic, skipping canary", "service_id", service.ID)
+		return false, nil
+	}
+
+	canaryUpstream := resultUpstream(result)
+	control := s.registerCanary(service.ID, deployment.ID, policy.stepDuration)
+	defer s.unregisterCanary(service.ID)
+
+	for _, weight := range policy.steps {
+		if weight == 100 {
+			break
+		}
+
+		// Record the weight along with the split, so a route sync renders it
+		s.routeMu.Lock()
+		deployment.Status = string(deployer.StatusCanary)
+		deployment.TrafficWeight = weight
+		_ = s.store.Deployments().Update(ctx, deployment)
+		err := s.splitServiceTraffic(ctx, domains, stableSlot, stableUpstream, canaryUpstream, weight, policy.stepDuration)
+		s.routeMu.Unlock()
+		if err != nil {
+			return true, fmt.Errorf("failed to shift traffic: %w", err)
+		}
+
+		s.publishDeploymentStatus(project.ID, service.ID, deployment.ID, deployment.Status, "")
+
+		s.log.Info("canary receiving traffic",
+			"deployment_id", deployment.ID,
+			"service_id", service.ID,
+			"weight", weight,
+		)
+
+		promoted, err := s.observeCanary(ctx, control, policy, result, canaryUpstream)
+		if err != nil {
+			return true, fmt.Errorf("at %d%% traffic, %w", weight, err)
+		}
+		if promoted {
+			s.log.Info("canary promoted by operator", "deployment_id", deployment.ID)
+			break
+		}
+	}
+
+	deployment.TrafficWeight = 100
+	return true, nil
+}
+
+// observeCanary probes the canary for one step. It returns early when the
+// canary is promoted, aborted, stops running or exceeds the policy's limits.
+func (s *DeployService) observeCanary(
+	ctx context.Context,
+	control *canaryControl,
+	policy *canaryPolicy,
+	result *deployer.DeploymentResult,
+	canaryUpstream *proxy.Upstream,
+) (bool, error) {
+	prober := health.NewProber(s.runtime)
+	target := health.ResultTarget(result)
+
+	// Failures are checked as they happen against the budget for the whole step
+	expected := int(policy.stepDuration / canaryProbeInterval)
+	allowed := int(policy.maxErrorRate * float64(expected) / 100)
+	upstream := fmt.Sprintf("%s:%d", canaryUpstream.Host, canaryUpstream.Port)
+
+	ticker := time.NewTicker(canaryProbeInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(policy.stepDuration)
+	defer timer.Stop()
+
+	// The proxy counts failures over a sliding window that reaches back into
+	// the previous step, so only increases since the step started count
+	lastFails := 0
+	if policy.maxFailedRequests > 0 {
+		lastFails = s.upstreamFails(ctx, upstream)
+	}
+
+	probes, failures, failedRequests := 0, 0, 0
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-control.promote:
+			return true, nil
+		case <-control.abort:
+			return false, errors.New("aborted by operator")
+		case <-timer.C:
+			return false, nil
+		case <-ticker.C:
+		}
+
+		for _, id := range result.ContainerIDs {
+			info, err := s.runtime.InspectContainer(ctx, id)
+			if err != nil || info.State != "running" {
+				return false, errors.New("canary container is no longer running")
+			}
+		}
+
+		probes++
+		if err := prober.Probe(ctx, result.HealthCheck, target); err != nil {
+			failures++
+			s.log.Warn("canary health probe failed", "error", err)
+		}
+		if failures > allowed {
+			return false, fmt.Errorf("%d of %d health probes failed, above the %.1f%% error rate limit",
+				failures, probes, policy.maxErrorRate)
+		}
+
+		if policy.maxFailedRequests > 0 {
+			fails := s.upstreamFails(ctx, upstream)
+			if fails > lastFails {
+				failedRequests += fails - lastFails
+			}
+			lastFails = fails
+			if failedRequests > policy.maxFailedRequests {
+				return false, fmt.Errorf("%d failed requests, above the limit of %d", failedRequests, policy.maxFailedRequests)
+			}
+		}
+	}
+}
+
+// upstreamFails returns the failures the proxy counted for an upstream, or 0
+// when the proxy does not report them
+func (s *DeployService) upstreamFails(ctx context.Context, address string) int {
+	reporter, ok := s.proxyManager.(proxy.StatsReporter)
+	if !ok {
+		return 0
+	}
+	stats, err := reporter.UpstreamStats(ctx)
+	if err != nil {
+		s.log.Warn("failed to read upstream stats", "error", err)
+		return 0
+	}
+	for _, st := range stats {
+		if st.Address == address {
+			return st.Fails
+		}
+	}
+	return 0
+}
+
+// rollbackCanary sends all traffic back to the stable deployment and removes
+// the canary. The service keeps running on the stable deployment.
+func (s *DeployService) rollbackCanary(
+	ctx context.Context,
+	project *storage.Project,
+	service *storage.Service,
+	deployment *storage.Deployment,
+	dep deployer.Deployer,
+	spec *deployer.DeploymentSpec,
+	result *deployer.DeploymentResult,
+	cause error,
+) {
+	stableSlot := spec.TargetSlot.Opposite()
+	s.routeMu.Lock()
+	deployment.TrafficWeight = 0
+	_ = s.store.Deployments().Update(ctx, deployment)
+	if stable, err := s.store.Deployments().GetByServiceIDAndSlot(ctx, service.ID, string(stableSlot)); err == nil && stable != nil {
+		if err := s.routeServiceDomains(ctx, service.ID, string(stableSlot), s.deploymentUpstream(ctx, stable.ID)); err != nil {
+			s.log.Error("failed to restore traffic to stable deployment", "service_id", service.ID, "error", err)
+		}
+	}
+	s.routeMu.Unlock()
+
+	deployment.Logs = s.captureContainerLogs(ctx, result.ContainerIDs)
+	_ = dep.Destroy(ctx, result.ContainerIDs)
+
+	finishedAt := time.Now()
+	deployment.Status = string(deployer.StatusFailed)
+	deployment.ErrorMessage = fmt.Sprintf("canary rolled back: %s", cause)
+	deployment.FinishedAt = &finishedAt
+	_ = s.store.Deployments().Update(ctx, deployment)
+	s.publishDeploymentStatus(project.ID, service.ID, deployment.ID, deployment.Status, deployment.ErrorMessage)
+
+	service.Status = "running"
+	_ = s.store.Services().Update(ctx, service)
+	s.publishServiceStatus(project.ID, service.ID, service.Status)
+
+	s.log.Warn("canary rolled back",
+		"deployment_id", deployment.ID,
+		"service_id", service.ID,
+		"reason", cause,
+	)
+}
+
+// PromoteCanary ends the observation of a canary and sends it all traffic
+func (s *DeployService) PromoteCanary(ctx context.Context, projectID, serviceName string) (*DeploymentResponse, error) {
+	return s.signalCanary(ctx, projectID, serviceName, func(c *canaryControl) chan struct{} { return c.promote })
+}
+
+// AbortCanary rolls a canary back to the stable deployment
+func (s *DeployService) AbortCanary(ctx context.Context, projectID, serviceName string) (*DeploymentResponse, error) {
+	return s.signalCanary(ctx, projectID, serviceName, func(c *canaryControl) chan struct{} { return c.abort })
+}
+
+func (s *DeployService) signalCanary(ctx context.Context, projectID, serviceName string, signal func(*canaryControl) chan struct{}) (*DeploymentResponse, error) {
+	_, service, err := s.resolveService(ctx, projectID, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.canaryMu.Lock()
+	control := s.canaries[service.ID]
+	s.canaryMu.Unlock()
+	if control == nil {
+		return nil, apperrors.NewConflictError("no canary rollout in progress")
+	}
+
+	select {
+	case signal(control) <- struct{}{}:
+	default:
+	}
+
+	return s.GetDeployment(ctx, control.deploymentID)
+}
+
+// splitServiceTraffic sends weight percent of the domains' traffic to the
+// canary and the rest to the stable deployment
+func (s *DeployService) splitServiceTraffic(
+	ctx context.Context,
+	domains []*storage.Domain,
+	stableSlot deployer.Slot,
+	stable, canary *proxy.Upstream,
+	weight int,
+	failureWindow time.Duration,
+) error {
+	for _, domain := range domains {
+		route := domainRoute(domain)
+		route.ActiveSlot = proxy.Slot(stableSlot)
+		route.FailureWindow = failureWindow
+		if stableSlot == deployer.SlotBlue {
+			route.BlueTarget, route.GreenTarget = stable, canary
+			route.BlueWeight, route.GreenWeight = 100-weight, weight
+		} else {
+			route.BlueTarget, route.GreenTarget = canary, stable
+			route.BlueWeight, route.GreenWeight = weight, 100-weight
+		}
+		if err := s.proxyManager.UpdateRoute(ctx, route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
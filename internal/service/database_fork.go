@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// Forking waits for the new database to accept connections
+const (
+	forkAttempts = 10
+	forkInterval = 3 * time.Second
+)
+
+// forkDatabase copies the data of a database service into the database of
+// the same name in another environment. The source container joins the
+// target's network for the copy, which runs inside the target container.
+func (s *DeployService) forkDatabase(ctx context.Context, source, target *storage.Service, network string) error {
+	script, err := forkScript(source, target)
+	if err != nil {
+		return err
+	}
+	if script == "" {
+		s.log.Info("database type has no data to fork", "service", target.Name, "type", target.DatabaseType)
+		return nil
+	}
+
+	sourceID, err := s.liveContainer(ctx, source.ID)
+	if err != nil {
+		return fmt.Errorf("source database: %w", err)
+	}
+	targetID, err := s.liveContainer(ctx, target.ID)
+	if err != nil {
+		return fmt.Errorf("target database: %w", err)
+	}
+
+	info, err := s.runtime.InspectContainer(ctx, sourceID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect source database: %w", err)
+	}
+	script = strings.ReplaceAll(script, "{{source}}", strings.TrimPrefix(info.Name, "/"))
+
+	if err := s.runtime.ConnectToNetwork(ctx, sourceID, network); err != nil {
+		return fmt.Errorf("failed to reach source database: %w", err)
+	}
+	defer func() {
+		if err := s.runtime.DisconnectFromNetwork(context.Background(), sourceID, network); err != nil {
+			s.log.Warn("failed to disconnect source database", "network", network, "error", err)
+		}
+	}()
+
+	var output string
+	for attempt := 1; attempt <= forkAttempts; attempt++ {
+		result, err := s.runtime.ExecContainer(ctx, targetID, []string{"sh", "-c", script})
+		if err == nil && result.ExitCode == 0 {
+			s.log.Info("database forked", "source", source.ID, "target", target.ID)
+			return nil
+		}
+		if err != nil {
+			output = err.Error()
+		} else {
+			output = result.Output
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(forkInterval):
+		}
+	}
+	return fmt.Errorf("failed to fork database: %s", lastLines(output, 5))
+}
+
+// forkScript returns the shell command that copies a database from the
+// {{source}} host into the local one, or "" for types without data to copy
+func forkScript(source, target *storage.Service) (string, error) {
+	q := shellQuote
+	switch target.DatabaseType {
+	case "postgres":
+		return fmt.Sprintf("PGPASSWORD=%s pg_dump -h {{source}} -U %s --no-owner --no-acl %s | PGPASSWORD=%s psql -q -v ON_ERROR_STOP=1 -U %s -d %s",
+			q(source.DatabasePassword), q(source.DatabaseUser), q(source.DatabaseName),
+			q(target.DatabasePassword), q(target.DatabaseUser), q(target.DatabaseName)), nil
+	case "mysql":
+		return fmt.Sprintf("mysqldump -h {{source}} -u %s -p%s --single-transaction %s | mysql -u %s -p%s %s",
+			q(source.DatabaseUser), q(source.DatabasePassword), q(source.DatabaseName),
+			q(target.DatabaseUser), q(target.DatabasePassword), q(target.DatabaseName)), nil
+	case "mongodb":
+		return fmt.Sprintf("mongodump --host {{source}} -u %s -p %s --authenticationDatabase admin --archive | mongorestore -u %s -p %s --authenticationDatabase admin --archive --drop",
+			q(source.DatabaseUser), q(source.DatabasePassword),
+			q(target.DatabaseUser), q(target.DatabasePassword)), nil
+	case "redis":
+		// Caches are not worth copying; previews start with an empty one
+		return "", nil
+	}
+	return "", fmt.Errorf("unsupported database type %q", target.DatabaseType)
+}
+
+// liveContainer returns the container of a service's running deployment
+func (s *DeployService) liveContainer(ctx context.Context, serviceID string) (string, error) {
+	deployments, err := s.store.Deployments().ListByServiceID(ctx, serviceID)
+	if err != nil {
+		return "", err
+	}
+	for _, d := range deployments {
+		if d.Status != string(deployer.StatusRunning) {
+			continue
+		}
+		containers, err := s.store.Containers().ListByDeploymentID(ctx, d.ID)
+		if err != nil {
+			return "", err
+		}
+		if len(containers) > 0 {
+			return containers[0].ContainerID, nil
+		}
+	}
+	return "", fmt.Errorf("no running container")
+}
+
+// shellQuote quotes a value for sh
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// lastLines returns the last n lines of output
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// requestApproval records a deployment of a service that requires approval.
+// The request is stored and carried out by ApproveDeployment.
+func (s *DeployService) requestApproval(ctx context.Context, project *storage.Project, service *storage.Service, req DeployServiceRequest) (*DeploymentResponse, error) {
+	requestJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to encode deploy request", err)
+	}
+
+	deployment := &storage.Deployment{
+		ID:             uuid.New().String(),
+		AppID:          project.ID,
+		ServiceID:      service.ID,
+		Version:        fmt.Sprintf("v%d", time.Now().Unix()),
+		Status:         string(deployer.StatusAwaitingApproval),
+		SourceConfig:   "{}",
+		Environment:    "{}",
+		ReleaseID:      req.releaseID,
+		Request:        string(requestJSON),
+		RequestedBy:    req.RequestedBy,
+		FreezeOverride: req.FreezeOverride,
+	}
+	if err := s.store.Deployments().Create(ctx, deployment); err != nil {
+		return nil, apperrors.NewInternalError("failed to create deployment record", err)
+	}
+
+	s.log.Info("deployment awaiting approval",
+		"deployment_id", deployment.ID,
+		"service_id", service.ID,
+		"requested_by", req.RequestedBy,
+	)
+	s.publishDeploymentStatus(project.ID, service.ID, deployment.ID, deployment.Status, "")
+
+	return s.deploymentResponse(ctx, deployment), nil
+}
+
+// ApproveDeployment carries out a deployment awaiting approval
+func (s *DeployService) ApproveDeployment(ctx context.Context, deploymentID, approver string) (*DeploymentResponse, error) {
+	deployment, service, err := s.awaitingDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var req DeployServiceRequest
+	if deployment.Request != "" {
+		if err := json.Unmarshal([]byte(deployment.Request), &req); err != nil {
+			return nil, apperrors.NewInternalError("failed to decode deploy request", err)
+		}
+	}
+	req.RequestedBy = deployment.RequestedBy
+	req.releaseID = deployment.ReleaseID
+	req.approved = deployment
+	deployment.ApprovedBy = approver
+
+	resp, err := s.DeployServiceByName(ctx, deployment.AppID, service.Name, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, deployment.AppID, approver, AuditDeploymentApproved, deployment.ID, "")
+
+	if deployment.ReleaseID != "" {
+		go s.resumeRelease(context.Background(), deployment.ReleaseID, DeployProjectRequest{
+			FreezeOverride: req.FreezeOverride,
+			RequestedBy:    req.RequestedBy,
+		})
+	}
+	return resp, nil
+}
+
+// RejectDeployment declines a deployment awaiting approval
+func (s *DeployService) RejectDeployment(ctx context.Context, deploymentID, approver, reason string) (*DeploymentResponse, error) {
+	deployment, service, err := s.awaitingDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	finishedAt := time.Now()
+	deployment.Status = string(deployer.StatusRejected)
+	deployment.ApprovedBy = approver
+	deployment.ErrorMessage = "rejected by " + approver
+	if reason != "" {
+		deployment.ErrorMessage += ": " + reason
+	}
+	deployment.FinishedAt = &finishedAt
+	if err := s.store.Deployments().Update(ctx, deployment); err != nil {
+		return nil, apperrors.NewInternalError("failed to update deployment", err)
+	}
+
+	s.audit(ctx, deployment.AppID, approver, AuditDeploymentRejected, deployment.ID, reason)
+	s.publishDeploymentStatus(deployment.AppID, service.ID, deployment.ID, deployment.Status, deployment.ErrorMessage)
+
+	if deployment.ReleaseID != "" {
+		s.failBlockedRelease(ctx, deployment.ReleaseID, fmt.Sprintf("%s: %s", service.Name, deployment.ErrorMessage))
+	}
+	return s.deploymentResponse(ctx, deployment), nil
+}
+
+// awaitingDeployment loads a deployment that is awaiting approval and its service
+func (s *DeployService) awaitingDeployment(ctx context.Context, deploymentID string) (*storage.Deployment, *storage.Service, error) {
+	deployment, err := s.getDeploymentRecord(ctx, deploymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deployment.Status != string(deployer.StatusAwaitingApproval) {
+		return nil, nil, apperrors.NewConflictError(fmt.Sprintf("deployment is %s, not awaiting approval", deployment.Status))
+	}
+
+	service, err := s.store.Services().GetByID(ctx, deployment.ServiceID)
+	if err != nil {
+		return nil, nil, apperrors.NewInternalError("failed to get service", err)
+	}
+	if service == nil {
+		return nil, nil, apperrors.NewNotFoundError("service", deployment.ServiceID)
+	}
+	return deployment, service, nil
+}
+
+// saveServiceDeployment stores a new service deployment record, or fills in
+// the awaiting record when the deployment was approved
+func (s *DeployService) saveServiceDeployment(ctx context.Context, deployment *storage.Deployment, req DeployServiceRequest) error {
+	deployment.RequestedBy = req.RequestedBy
+	deployment.FreezeOverride = req.FreezeOverride
+
+	if req.approved == nil {
+		return s.store.Deployments().Create(ctx, deployment)
+	}
+
+	deployment.ID = req.approved.ID
+	deployment.Version = req.approved.Version
+	deployment.ReleaseID = req.approved.ReleaseID
+	deployment.Request = req.approved.Request
+	deployment.ApprovedBy = req.approved.ApprovedBy
+	deployment.CreatedAt = req.approved.CreatedAt
+	return s.store.Deployments().Update(ctx, deployment)
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,17 +19,40 @@ import (
 	"github.com/victalejo/nebula/internal/core/events"
 	"github.com/victalejo/nebula/internal/core/logger"
 	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/secrets"
 	"github.com/victalejo/nebula/internal/core/storage"
 )
 
 // DeployService handles deployment business logic
 type DeployService struct {
-	store        storage.Store
-	registry     *deployer.DeployerRegistry
-	proxyManager proxy.ProxyManager
-	runtime      nebulacontainer.ContainerRuntime
-	eventBus     *events.EventBus
-	log          logger.Logger
+	store          storage.Store
+	registry       *deployer.DeployerRegistry
+	proxyManager   proxy.ProxyManager
+	runtime        nebulacontainer.ContainerRuntime
+	gitCredentials *GitCredentialService
+	cipher         *secrets.Cipher
+	eventBus       *events.EventBus
+	log            logger.Logger
+
+	// serverContainer is Nebula's own container, empty when it runs on the
+	// host. It joins deployment networks to health check the apps.
+	serverContainer string
+
+	// retireMu serializes retiring standby deployments and rollbacks
+	retireMu sync.Mutex
+
+	// releaseMu serializes blocking project releases on approvals with
+	// resuming or failing them
+	releaseMu sync.Mutex
+
+	// routeMu serializes writing routes to the proxy with recording the
+	// deployment state they are rendered from, so a route sync never renders
+	// a state the proxy has already moved past
+	routeMu sync.Mutex
+
+	// canaries holds the rollouts in progress, keyed by service ID
+	canaryMu sync.Mutex
+	canaries map[string]*canaryControl
 }
 
 // NewDeployService creates a new deploy service
@@ -36,16 +61,22 @@ func NewDeployService(
 	registry *deployer.DeployerRegistry,
 	proxyManager proxy.ProxyManager,
 	runtime nebulacontainer.ContainerRuntime,
+	serverContainer string,
+	gitCredentials *GitCredentialService,
+	cipher *secrets.Cipher,
 	eventBus *events.EventBus,
 	log logger.Logger,
 ) *DeployService {
 	return &DeployService{
-		store:        store,
-		registry:     registry,
-		proxyManager: proxyManager,
-		runtime:      runtime,
-		eventBus:     eventBus,
-		log:          log,
+		store:           store,
+		registry:        registry,
+		proxyManager:    proxyManager,
+		runtime:         runtime,
+		serverContainer: serverContainer,
+		gitCredentials:  gitCredentials,
+		cipher:          cipher,
+		eventBus:        eventBus,
+		log:             log,
 	}
 }
 
@@ -68,6 +99,23 @@ type DeployGitRequest struct {
 // DeployServiceRequest represents a request to deploy a specific service
 type DeployServiceRequest struct {
 	Environment map[string]string `json:"environment"`
+	Canary      *CanaryPolicy     `json:"canary,omitempty"`
+
+	// FreezeOverride is the reason for deploying during a freeze window; it is audited
+	FreezeOverride string `json:"freeze_override,omitempty"`
+
+	// RequestedBy is the user requesting the deployment
+	RequestedBy string `json:"-"`
+
+	// Promotion deploys an image already built for another environment
+	// instead of building the service
+	Promotion *PromotedImage `json:"promotion,omitempty"`
+
+	// releaseID links the deployment to a project release
+	releaseID string
+
+	// approved is the awaiting deployment being carried out after approval
+	approved *storage.Deployment
 }
 
 // RegistryAuthReq represents registry authentication
@@ -78,15 +126,23 @@ type RegistryAuthReq struct {
 
 // DeploymentResponse represents a deployment response
 type DeploymentResponse struct {
-	ID           string `json:"id"`
-	AppID        string `json:"app_id"`
-	ServiceID    string `json:"service_id,omitempty"`
-	Version      string `json:"version"`
-	Slot         string `json:"slot"`
-	Status       string `json:"status"`
-	ErrorMessage string `json:"error_message,omitempty"`
-	CreatedAt    string `json:"created_at"`
-	FinishedAt   string `json:"finished_at,omitempty"`
+	ID            string `json:"id"`
+	AppID         string `json:"app_id"`
+	ServiceID     string `json:"service_id,omitempty"`
+	ServiceName   string `json:"service_name,omitempty"`
+	ReleaseID     string `json:"release_id,omitempty"`
+	Version       string `json:"version"`
+	Slot          string `json:"slot"`
+	Status        string `json:"status"`
+	ErrorMessage  string `json:"error_message,omitempty"`
+	TrafficWeight int    `json:"traffic_weight,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	FinishedAt    string `json:"finished_at,omitempty"`
+
+	// Deploy guardrails
+	RequestedBy    string `json:"requested_by,omitempty"`
+	ApprovedBy     string `json:"approved_by,omitempty"`
+	FreezeOverride string `json:"freeze_override,omitempty"`
 }
 
 // DeployImage deploys an application from a Docker image
@@ -272,15 +328,30 @@ func (s *DeployService) DeployGit(ctx context.Context, appID string, req DeployG
 		env[k] = v
 	}
 
+	// Resolve credentials for private repositories
+	gitAuth, err := s.gitCredentials.ResolveGitAuth(ctx, project.ID, mainService, gitRepo)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to resolve git credentials", err)
+	}
+
 	// Create deployment spec
 	spec := &deployer.DeploymentSpec{
 		AppID:       project.ID,
 		AppName:     project.Name,
+		Source:      gitSourceConfig(mainService, gitRepo, branch, env),
 		GitRepo:     gitRepo,
 		GitBranch:   branch,
+		GitAuth:     gitAuth,
 		Environment: env,
 		TargetSlot:  targetSlot,
 	}
+	if mainService != nil {
+		spec.BuildCacheKey = mainService.BuildCacheKey
+		spec.BuildSecrets, err = decodeBuildSecrets(s.cipher, mainService.BuildSecrets)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to decrypt build secrets", err)
+		}
+	}
 
 	// Validate
 	if err := gitDeployer.Validate(ctx, spec); err != nil {
@@ -290,10 +361,7 @@ func (s *DeployService) DeployGit(ctx context.Context, appID string, req DeployG
 	}
 
 	// Create deployment record
-	sourceJSON, _ := json.Marshal(map[string]string{
-		"git_repo":   gitRepo,
-		"git_branch": branch,
-	})
+	sourceJSON, _ := json.Marshal(spec.Source)
 	envJSON, _ := json.Marshal(env)
 
 	serviceID := ""
@@ -330,6 +398,36 @@ func (s *DeployService) DeployGit(ctx context.Context, appID string, req DeployG
 	}, nil
 }
 
+// gitSourceConfig builds the source config for a git deployment,
+// applying the service's clone and build options when the service is known.
+// Build arg values may reference the deployment environment as $VAR or ${VAR}.
+func gitSourceConfig(service *storage.Service, gitRepo, gitBranch string, env map[string]string) deployer.SourceConfig {
+	source := deployer.SourceConfig{
+		GitURL:    gitRepo,
+		GitBranch: gitBranch,
+		GitDepth:  1,
+	}
+	if service != nil {
+		source.GitSubmodules = service.GitSubmodules
+		source.GitLFS = service.GitLFS
+		source.GitDepth = service.GitDepth
+		source.Builder = string(service.Builder)
+		source.Port = service.Port // 0 lets the builder detect it
+
+		var buildArgs map[string]string
+		if service.BuildArgs != "" {
+			_ = json.Unmarshal([]byte(service.BuildArgs), &buildArgs)
+		}
+		if len(buildArgs) > 0 {
+			source.BuildArgs = make(map[string]string, len(buildArgs))
+			for k, v := range buildArgs {
+				source.BuildArgs[k] = os.Expand(v, func(name string) string { return env[name] })
+			}
+		}
+	}
+	return source
+}
+
 // executeDeployment runs the deployment process
 func (s *DeployService) executeDeployment(
 	ctx context.Context,
@@ -356,6 +454,7 @@ func (s *DeployService) executeDeployment(
 		s.failDeployment(ctx, deployment, project.ID, err)
 		return
 	}
+	s.recordResolvedSource(deployment, spec)
 
 	// Update status to deploying
 	deployment.Status = string(deployer.StatusDeploying)
@@ -369,20 +468,26 @@ func (s *DeployService) executeDeployment(
 		return
 	}
 
-	// Store container info
-	for _, containerID := range result.ContainerIDs {
-		port := 0
-		if p, ok := result.Ports["main"]; ok {
-			port = p
+	// Health checks reach unpublished containers over their network
+	if result.Host != "" {
+		if err := s.attachServer(ctx, result.Network); err != nil {
+			s.failDeployment(ctx, deployment, project.ID, err)
+			_ = dep.Destroy(ctx, result.ContainerIDs)
+			return
 		}
+	}
 
+	// Store container info
+	for _, containerID := range result.ContainerIDs {
 		container := &storage.Container{
 			ID:           uuid.New().String(),
 			DeploymentID: deployment.ID,
 			ContainerID:  containerID,
 			Name:         fmt.Sprintf("%s-%s", project.Name, spec.TargetSlot),
 			Status:       "running",
-			Port:         port,
+			Port:         result.Ports["main"],
+			Host:         result.Host,
+			AppPort:      result.AppPort,
 		}
 		_ = s.store.Containers().Create(ctx, container)
 	}
@@ -409,24 +514,12 @@ func (s *DeployService) executeDeployment(
 	// Check if project has domains configured
 	domains, _ := s.store.Domains().ListByProjectID(ctx, project.ID)
 	if len(domains) > 0 {
-		mainPort := 0
-		if p, ok := result.Ports["main"]; ok {
-			mainPort = p
+		if err := s.attachProxy(ctx, result.Network); err != nil {
+			s.log.Error("failed to attach proxy to network", "error", err, "network", result.Network)
 		}
 
 		for _, domain := range domains {
-			route := proxy.Route{
-				Domain: domain.Domain,
-				AppID:  project.ID,
-				BlueTarget: &proxy.Upstream{
-					Host: "localhost",
-					Port: mainPort,
-				},
-				ActiveSlot: proxy.Slot(spec.TargetSlot),
-				SSLEnabled: domain.SSLEnabled,
-			}
-
-			if err := s.proxyManager.AddRoute(ctx, route); err != nil {
+			if err := s.routeDomain(ctx, domain, string(spec.TargetSlot), resultUpstream(result)); err != nil {
 				s.log.Error("failed to update route", "error", err, "domain", domain.Domain)
 			}
 		}
@@ -460,6 +553,14 @@ func (s *DeployService) executeDeployment(
 	)
 }
 
+// recordResolvedSource stores the source config as resolved by Prepare,
+// e.g. the application port detected by the builder
+func (s *DeployService) recordResolvedSource(deployment *storage.Deployment, spec *deployer.DeploymentSpec) {
+	if sourceJSON, err := json.Marshal(spec.Source); err == nil {
+		deployment.SourceConfig = string(sourceJSON)
+	}
+}
+
 // failDeployment marks a deployment as failed
 func (s *DeployService) failDeployment(ctx context.Context, deployment *storage.Deployment, projectID string, err error) {
 	s.log.Error("deployment failed",
@@ -492,7 +593,7 @@ func (s *DeployService) stopOldDeployment(ctx context.Context, appID string, slo
 		containerIDs[i] = c.ContainerID
 	}
 
-	if err := dep.Stop(ctx, containerIDs); err != nil {
+	if err := dep.Stop(ctx, containerIDs, 0); err != nil {
 		s.log.Warn("failed to stop old containers", "error", err)
 	}
 
@@ -525,13 +626,14 @@ func (s *DeployService) GetDeployment(ctx context.Context, id string) (*Deployme
 	}
 
 	return &DeploymentResponse{
-		ID:        deployment.ID,
-		AppID:     deployment.AppID,
-		ServiceID: deployment.ServiceID,
-		Version:   deployment.Version,
-		Slot:      deployment.Slot,
-		Status:    deployment.Status,
-		CreatedAt: deployment.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:            deployment.ID,
+		AppID:         deployment.AppID,
+		ServiceID:     deployment.ServiceID,
+		Version:       deployment.Version,
+		Slot:          deployment.Slot,
+		Status:        deployment.Status,
+		TrafficWeight: deployment.TrafficWeight,
+		CreatedAt:     deployment.CreatedAt.Format("2006-01-02T15:04:05Z"),
 	}, nil
 }
 
@@ -608,14 +710,19 @@ func (s *DeployService) ListServiceDeployments(ctx context.Context, projectID, s
 	responses := make([]*DeploymentResponse, len(deployments))
 	for i, d := range deployments {
 		responses[i] = &DeploymentResponse{
-			ID:           d.ID,
-			AppID:        d.AppID,
-			ServiceID:    d.ServiceID,
-			Version:      d.Version,
-			Slot:         d.Slot,
-			Status:       d.Status,
-			ErrorMessage: d.ErrorMessage,
-			CreatedAt:    d.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			ID:            d.ID,
+			AppID:         d.AppID,
+			ServiceID:     d.ServiceID,
+			Version:       d.Version,
+			Slot:          d.Slot,
+			Status:        d.Status,
+			ErrorMessage:  d.ErrorMessage,
+			TrafficWeight: d.TrafficWeight,
+			CreatedAt:     d.CreatedAt.Format("2006-01-02T15:04:05Z"),
+
+			RequestedBy:    d.RequestedBy,
+			ApprovedBy:     d.ApprovedBy,
+			FreezeOverride: d.FreezeOverride,
 		}
 		if d.FinishedAt != nil {
 			responses[i].FinishedAt = d.FinishedAt.Format("2006-01-02T15:04:05Z")
@@ -656,11 +763,37 @@ func (s *DeployService) DeployServiceByName(ctx context.Context, projectID, serv
 		return nil, apperrors.NewNotFoundError("service", serviceName)
 	}
 
+	// Refuse deploys during freeze windows unless overridden
+	actor := req.RequestedBy
+	if req.approved != nil {
+		actor = req.approved.ApprovedBy
+	}
+	if req.FreezeOverride, err = s.checkFreeze(ctx, project.ID, service.ID, req.FreezeOverride, actor); err != nil {
+		return nil, err
+	}
+
+	if service.RequiresApproval && req.approved == nil {
+		return s.requestApproval(ctx, project, service, req)
+	}
+
 	// Handle database type differently
 	if service.Type == storage.ServiceTypeDatabase {
+		if req.Canary != nil {
+			return nil, apperrors.NewValidationError("canary rollouts are not supported for database services", nil)
+		}
+		if req.Promotion != nil {
+			return nil, apperrors.NewValidationError("database services cannot be promoted", nil)
+		}
 		return s.deployDatabaseService(ctx, project, service, req)
 	}
 
+	var canary *canaryPolicy
+	if req.Canary != nil {
+		if canary, err = req.Canary.validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Determine deployer based on builder
 	var dep deployer.Deployer
 	var spec *deployer.DeploymentSpec
@@ -668,9 +801,9 @@ func (s *DeployService) DeployServiceByName(ctx context.Context, projectID, serv
 	targetSlot := s.getTargetSlotForService(ctx, service.ID)
 
 	// Merge environment variables
-	env := make(map[string]string)
-	if project.Environment != "" {
-		_ = json.Unmarshal([]byte(project.Environment), &env)
+	env, err := projectVariables(ctx, s.store, project)
+	if err != nil {
+		return nil, err
 	}
 	if service.Environment != "" {
 		var svcEnv map[string]string
@@ -683,22 +816,34 @@ func (s *DeployService) DeployServiceByName(ctx context.Context, projectID, serv
 		env[k] = v
 	}
 
-	switch service.Builder {
+	// Promoted services run the image of the source environment, whatever their builder
+	builder := service.Builder
+	if req.Promotion != nil {
+		builder = storage.BuilderDockerImage
+	}
+
+	switch builder {
 	case storage.BuilderDockerImage:
 		dep, err = s.registry.Get(deployer.ModeImage)
 		if err != nil {
 			return nil, apperrors.NewInternalError("image deployer not available", err)
 		}
+		source := deployer.SourceConfig{
+			Image: service.DockerImage,
+			Port:  service.Port,
+		}
+		if req.Promotion != nil {
+			source = req.Promotion.sourceConfig(service.Port)
+		}
 		spec = &deployer.DeploymentSpec{
-			AppID:      project.ID,
-			AppName:    project.Name,
-			ServiceID:  service.ID,
-			Source: deployer.SourceConfig{
-				Image: service.DockerImage,
-				Port:  service.Port,
-			},
+			AppID:       project.ID,
+			AppName:     project.Name,
+			ServiceID:   service.ID,
+			Source:      source,
 			Environment: env,
 			TargetSlot:  targetSlot,
+			HealthCheck: healthCheckConfig(service.HealthCheck),
+			Network:     environmentNetwork(project),
 		}
 
 	case storage.BuilderNixpacks, storage.BuilderDockerfile, storage.BuilderBuildpacks:
@@ -723,14 +868,31 @@ func (s *DeployService) DeployServiceByName(ctx context.Context, projectID, serv
 			return nil, apperrors.NewValidationError("git repository URL is not configured", nil)
 		}
 
+		gitAuth, err := s.gitCredentials.ResolveGitAuth(ctx, rootProjectID(project), service, gitRepo)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to resolve git credentials", err)
+		}
+
+		buildSecrets, err := decodeBuildSecrets(s.cipher, service.BuildSecrets)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to decrypt build secrets", err)
+		}
+
 		spec = &deployer.DeploymentSpec{
-			AppID:       project.ID,
-			AppName:     project.Name,
-			ServiceID:   service.ID,
-			GitRepo:     gitRepo,
-			GitBranch:   gitBranch,
-			Environment: env,
-			TargetSlot:  targetSlot,
+			AppID:         project.ID,
+			AppName:       project.Name,
+			ServiceID:     service.ID,
+			ServiceName:   service.Name,
+			Source:        gitSourceConfig(service, gitRepo, gitBranch, env),
+			GitRepo:       gitRepo,
+			GitBranch:     gitBranch,
+			GitAuth:       gitAuth,
+			BuildCacheKey: service.BuildCacheKey,
+			BuildSecrets:  buildSecrets,
+			Environment:   env,
+			TargetSlot:    targetSlot,
+			HealthCheck:   healthCheckConfig(service.HealthCheck),
+			Network:       environmentNetwork(project),
 		}
 
 	default:
@@ -759,9 +921,10 @@ func (s *DeployService) DeployServiceByName(ctx context.Context, projectID, serv
 		Status:       string(deployer.StatusPending),
 		SourceConfig: string(sourceJSON),
 		Environment:  string(envJSON),
+		ReleaseID:    req.releaseID,
 	}
 
-	if err := s.store.Deployments().Create(ctx, deployment); err != nil {
+	if err := s.saveServiceDeployment(ctx, deployment, req); err != nil {
 		return nil, apperrors.NewInternalError("failed to create deployment record", err)
 	}
 
@@ -771,7 +934,7 @@ func (s *DeployService) DeployServiceByName(ctx context.Context, projectID, serv
 	s.publishServiceStatus(project.ID, service.ID, service.Status)
 
 	// Execute deployment asynchronously
-	go s.executeServiceDeployment(context.Background(), project, service, deployment, dep, spec)
+	go s.executeServiceDeployment(context.Background(), project, service, deployment, dep, spec, canary)
 
 	return &DeploymentResponse{
 		ID:        deployment.ID,
@@ -905,6 +1068,7 @@ func (s *DeployService) deployDatabaseService(ctx context.Context, project *stor
 			MaxAttempts:   15, // Give databases more time to start
 			Interval:      3 * time.Second,
 		},
+		Network: environmentNetwork(project),
 	}
 
 	// Create deployment record
@@ -920,9 +1084,10 @@ func (s *DeployService) deployDatabaseService(ctx context.Context, project *stor
 		Status:       string(deployer.StatusPending),
 		SourceConfig: string(sourceJSON),
 		Environment:  string(envJSON),
+		ReleaseID:    req.releaseID,
 	}
 
-	if err := s.store.Deployments().Create(ctx, deployment); err != nil {
+	if err := s.saveServiceDeployment(ctx, deployment, req); err != nil {
 		return nil, apperrors.NewInternalError("failed to create deployment record", err)
 	}
 
@@ -933,7 +1098,7 @@ func (s *DeployService) deployDatabaseService(ctx context.Context, project *stor
 	s.publishServiceStatus(project.ID, service.ID, service.Status)
 
 	// Execute deployment asynchronously
-	go s.executeServiceDeployment(context.Background(), project, service, deployment, dep, spec)
+	go s.executeServiceDeployment(context.Background(), project, service, deployment, dep, spec, nil)
 
 	return &DeploymentResponse{
 		ID:        deployment.ID,
@@ -954,6 +1119,7 @@ func (s *DeployService) executeServiceDeployment(
 	deployment *storage.Deployment,
 	dep deployer.Deployer,
 	spec *deployer.DeploymentSpec,
+	canary *canaryPolicy,
 ) {
 	s.log.Info("executing service deployment",
 		"deployment_id", deployment.ID,
@@ -973,12 +1139,16 @@ func (s *DeployService) executeServiceDeployment(
 		s.failServiceDeployment(ctx, project.ID, service, deployment, err)
 		return
 	}
+	s.recordResolvedSource(deployment, spec)
 
 	// Update status to deploying
 	deployment.Status = string(deployer.StatusDeploying)
 	_ = s.store.Deployments().Update(ctx, deployment)
 	s.publishDeploymentStatus(project.ID, service.ID, deployment.ID, deployment.Status, "")
 
+	// A deployment kept warm in the target slot gives way to the new one
+	s.clearServiceSlot(ctx, service.ID, string(spec.TargetSlot))
+
 	// Deploy (create and start container)
 	result, err := dep.Deploy(ctx, spec)
 	if err != nil {
@@ -986,20 +1156,32 @@ func (s *DeployService) executeServiceDeployment(
 		return
 	}
 
-	// Store container info
-	for _, containerID := range result.ContainerIDs {
-		port := 0
-		if p, ok := result.Ports["main"]; ok {
-			port = p
+	// The proxy and health checks reach unpublished containers over their
+	// network
+	if result.Host != "" {
+		if err := s.attachProxy(ctx, result.Network); err != nil {
+			s.failServiceDeployment(ctx, project.ID, service, deployment, fmt.Errorf("failed to attach proxy to network %s: %w", result.Network, err))
+			_ = dep.Destroy(ctx, result.ContainerIDs)
+			return
+		}
+		if err := s.attachServer(ctx, result.Network); err != nil {
+			s.failServiceDeployment(ctx, project.ID, service, deployment, err)
+			_ = dep.Destroy(ctx, result.ContainerIDs)
+			return
 		}
+	}
 
+	// Store container info
+	for _, containerID := range result.ContainerIDs {
 		container := &storage.Container{
 			ID:           uuid.New().String(),
 			DeploymentID: deployment.ID,
 			ContainerID:  containerID,
 			Name:         fmt.Sprintf("%s-%s-%s", project.Name, service.Name, spec.TargetSlot),
 			Status:       "running",
-			Port:         port,
+			Port:         result.Ports["main"],
+			Host:         result.Host,
+			AppPort:      result.AppPort,
 		}
 		_ = s.store.Containers().Create(ctx, container)
 	}
@@ -1022,11 +1204,36 @@ func (s *DeployService) executeServiceDeployment(
 		return
 	}
 
+	// Shift traffic progressively when a canary rollout was requested
+	rolledOut := false
+	if canary != nil {
+		rolledOut, err = s.runCanary(ctx, project, service, deployment, spec, result, canary)
+		if err != nil {
+			s.rollbackCanary(ctx, project, service, deployment, dep, spec, result, err)
+			return
+		}
+	}
+
+	// Point the service's domains at the new deployment
+	s.routeMu.Lock()
+	if err := s.routeServiceDomains(ctx, service.ID, string(spec.TargetSlot), resultUpstream(result)); err != nil {
+		s.routeMu.Unlock()
+		if rolledOut {
+			s.rollbackCanary(ctx, project, service, deployment, dep, spec, result, fmt.Errorf("failed to switch traffic: %w", err))
+			return
+		}
+		s.restoreServiceRoutes(ctx, service.ID)
+		s.failServiceDeployment(ctx, project.ID, service, deployment, fmt.Errorf("failed to route domains: %w", err))
+		_ = dep.Destroy(ctx, result.ContainerIDs)
+		return
+	}
+
 	// Mark deployment as running
 	finishedAt := time.Now()
 	deployment.Status = string(deployer.StatusRunning)
 	deployment.FinishedAt = &finishedAt
 	_ = s.store.Deployments().Update(ctx, deployment)
+	s.routeMu.Unlock()
 	s.publishDeploymentStatus(project.ID, service.ID, deployment.ID, deployment.Status, "")
 
 	// Update service status
@@ -1034,13 +1241,18 @@ func (s *DeployService) executeServiceDeployment(
 	_ = s.store.Services().Update(ctx, service)
 	s.publishServiceStatus(project.ID, service.ID, service.Status)
 
-	// Stop old deployment for this service
-	s.stopOldServiceDeployment(ctx, service.ID, string(spec.TargetSlot.Opposite()), dep)
+	// Drain the previous deployment and keep it warm for rollback
+	s.retireServiceDeployment(ctx, service, string(spec.TargetSlot.Opposite()))
 
 	s.log.Info("service deployment completed successfully",
 		"deployment_id", deployment.ID,
 		"service_id", service.ID,
 	)
+
+	// Roll back if the new deployment starts crash-looping shortly after
+	if service.WatchSeconds > 0 {
+		s.watchServiceDeployment(ctx, project, service, deployment, dep, result)
+	}
 }
 
 // failServiceDeployment marks a service deployment as failed
@@ -1099,31 +1311,6 @@ func (s *DeployService) captureContainerLogs(ctx context.Context, containerIDs [
 	return allLogs.String()
 }
 
-// stopOldServiceDeployment stops the old deployment for a service
-func (s *DeployService) stopOldServiceDeployment(ctx context.Context, serviceID string, slot string, dep deployer.Deployer) {
-	oldDeployment, err := s.store.Deployments().GetByServiceIDAndSlot(ctx, serviceID, slot)
-	if err != nil || oldDeployment == nil {
-		return
-	}
-
-	containers, err := s.store.Containers().ListByDeploymentID(ctx, oldDeployment.ID)
-	if err != nil {
-		return
-	}
-
-	containerIDs := make([]string, len(containers))
-	for i, c := range containers {
-		containerIDs[i] = c.ContainerID
-	}
-
-	if err := dep.Stop(ctx, containerIDs); err != nil {
-		s.log.Warn("failed to stop old service containers", "error", err)
-	}
-
-	oldDeployment.Status = string(deployer.StatusStopped)
-	_ = s.store.Deployments().Update(ctx, oldDeployment)
-}
-
 // publishDeploymentStatus publishes a deployment status change event
 func (s *DeployService) publishDeploymentStatus(projectID, serviceID, deploymentID, status, errorMessage string) {
 	if s.eventBus == nil {
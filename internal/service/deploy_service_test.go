@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	nebulacontainer "github.com/victalejo/nebula/internal/core/container"
+	"github.com/victalejo/nebula/internal/core/deployer"
+	"github.com/victalejo/nebula/internal/core/events"
+	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/storage"
+	"github.com/victalejo/nebula/internal/storage/sqlite"
+)
+
+const serverContainer = "nebula-server"
+
+// networkRuntime records which containers are attached to which networks.
+// Other runtime calls are not expected.
+type networkRuntime struct {
+	nebulacontainer.ContainerRuntime
+
+	mu       sync.Mutex
+	networks map[string]map[string]bool // network -> attached containers
+	calls    []string
+}
+
+func newNetworkRuntime() *networkRuntime {
+	return &networkRuntime{networks: make(map[string]map[string]bool)}
+}
+
+func (r *networkRuntime) CreateNetwork(ctx context.Context, name string, opts nebulacontainer.NetworkOptions) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.networks[name] == nil {
+		r.networks[name] = make(map[string]bool)
+	}
+	return name, nil
+}
+
+func (r *networkRuntime) ConnectToNetwork(ctx context.Context, containerID, networkID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.networks[networkID][containerID] = true
+	r.calls = append(r.calls, "connect "+containerID)
+	return nil
+}
+
+func (r *networkRuntime) DisconnectFromNetwork(ctx context.Context, containerID, networkID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.networks[networkID], containerID)
+	r.calls = append(r.calls, "disconnect "+containerID)
+	return nil
+}
+
+func (r *networkRuntime) RemoveNetwork(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.networks, id)
+	r.calls = append(r.calls, "remove "+id)
+	return nil
+}
+
+func (r *networkRuntime) attached(network, containerID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.networks[network][containerID]
+}
+
+// networkDeployer deploys an unpublished container on the spec's network
+// and is healthy only when the server can reach that network
+type networkDeployer struct {
+	deployer.Deployer
+
+	runtime *networkRuntime
+	probed  bool
+}
+
+func (d *networkDeployer) Mode() deployer.DeploymentMode {
+	return deployer.ModeImage
+}
+
+func (d *networkDeployer) Prepare(ctx context.Context, spec *deployer.DeploymentSpec) (*deployer.PrepareResult, error) {
+	return &deployer.PrepareResult{}, nil
+}
+
+func (d *networkDeployer) Deploy(ctx context.Context, spec *deployer.DeploymentSpec) (*deployer.DeploymentResult, error) {
+	_, _ = d.runtime.CreateNetwork(ctx, spec.Network, nebulacontainer.NetworkOptions{})
+	return &deployer.DeploymentResult{
+		ContainerIDs: []string{"web-" + string(spec.TargetSlot)},
+		Ports:        map[string]int{"main": 0},
+		AppPort:      3000,
+		Host:         "shop-staging-web-" + string(spec.TargetSlot),
+		Network:      spec.Network,
+		IP:           "172.20.0.5",
+	}, nil
+}
+
+func (d *networkDeployer) HealthCheck(ctx context.Context, result *deployer.DeploymentResult) (*deployer.HealthCheckResult, error) {
+	d.probed = true
+	if !d.runtime.attached(result.Network, serverContainer) {
+		return &deployer.HealthCheckResult{Message: "server is not on network " + result.Network}, nil
+	}
+	return &deployer.HealthCheckResult{Healthy: true}, nil
+}
+
+func (d *networkDeployer) Destroy(ctx context.Context, containerIDs []string) error {
+	return nil
+}
+
+// noopProxy accepts route writes without routing anything
+type noopProxy struct {
+	proxy.ProxyManager
+}
+
+func (noopProxy) RemoveRoute(ctx context.Context, domain, pathPrefix string) error {
+	return nil
+}
+
+func TestEnvironmentDeploymentsAreHealthCheckedOverTheirNetwork(t *testing.T) {
+	ctx := context.Background()
+	store, err := sqlite.NewStore(filepath.Join(t.TempDir(), "nebula.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	runtime := newNetworkRuntime()
+	dep := &networkDeployer{runtime: runtime}
+	registry := deployer.NewRegistry()
+	registry.Register(dep)
+	s := NewDeployService(store, registry, noopProxy{}, runtime, serverContainer, nil, nil, events.NewEventBus(), logger.New("error"))
+
+	project := &storage.Project{ID: uuid.New().String(), Name: "shop"}
+	env := &storage.Project{ID: uuid.New().String(), Name: "shop-staging", ParentID: project.ID, EnvironmentName: "staging"}
+	for _, p := range []*storage.Project{project, env} {
+		if err := store.Apps().Create(ctx, p); err != nil {
+			t.Fatalf("create project %s: %v", p.Name, err)
+		}
+	}
+	service := &storage.Service{ID: uuid.New().String(), ProjectID: env.ID, Name: "web", Type: storage.ServiceTypeWeb, Builder: storage.BuilderDockerImage}
+	if err := store.Services().Create(ctx, service); err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+	deployment := &storage.Deployment{ID: uuid.New().String(), AppID: env.ID, ServiceID: service.ID, Slot: string(deployer.SlotBlue), Status: string(deployer.StatusPending), CreatedAt: time.Now()}
+	if err := store.Deployments().Create(ctx, deployment); err != nil {
+		t.Fatalf("create deployment: %v", err)
+	}
+
+	network := environmentNetwork(env)
+	spec := &deployer.DeploymentSpec{TargetSlot: deployer.SlotBlue, Network: network}
+	s.executeServiceDeployment(ctx, env, service, deployment, dep, spec, nil)
+
+	if !dep.probed {
+		t.Fatal("deployment was not health checked")
+	}
+	if deployment.Status != string(deployer.StatusRunning) {
+		t.Fatalf("deployment status = %s (%s), want running", deployment.Status, deployment.ErrorMessage)
+	}
+	if !runtime.attached(network, serverContainer) {
+		t.Errorf("server is not attached to %s", network)
+	}
+
+	// Tearing the environment down detaches the server so the network can go
+	if err := s.teardownEnvironment(ctx, env); err != nil {
+		t.Fatalf("teardown: %v", err)
+	}
+	want := []string{"connect " + serverContainer, "disconnect " + serverContainer, "remove " + network}
+	if !reflect.DeepEqual(runtime.calls, want) {
+		t.Errorf("runtime calls = %q, want %q", runtime.calls, want)
+	}
+}
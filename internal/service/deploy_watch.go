@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/storage"
+	"github.com/victalejo/nebula/internal/deployer/health"
+)
+
+// Post-deploy watch settings
+const (
+	watchInterval         = 5 * time.Second
+	watchMaxRestarts      = 2 // restarts tolerated within the window
+	watchMaxProbeFailures = 3 // consecutive failed health probes tolerated
+)
+
+// watchServiceDeployment follows a deployment that just went live for the
+// service's watch window. When it crash-loops or stops passing its health
+// check, traffic is switched back to the previous slot, which is kept on
+// standby for at least as long.
+func (s *DeployService) watchServiceDeployment(
+	ctx context.Context,
+	project *storage.Project,
+	service *storage.Service,
+	deployment *storage.Deployment,
+	dep deployer.Deployer,
+	result *deployer.DeploymentResult,
+) {
+	window := time.Duration(service.WatchSeconds) * time.Second
+	deadline := time.Now().Add(window)
+
+	// Restarts are counted from the moment the deployment went live
+	baseline := make(map[string]int, len(result.ContainerIDs))
+	for _, id := range result.ContainerIDs {
+		if info, err := s.runtime.InspectContainer(ctx, id); err == nil {
+			baseline[id] = info.RestartCount
+		}
+	}
+
+	prober := health.NewProber(s.runtime)
+	target := health.ResultTarget(result)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	probeFailures := 0
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Stop watching once the deployment was replaced or rolled back by hand
+		current, err := s.store.Deployments().GetByID(ctx, deployment.ID)
+		if err != nil || current == nil || current.Status != string(deployer.StatusRunning) {
+			return
+		}
+
+		cause := s.checkContainers(ctx, result.ContainerIDs, baseline, window)
+		if cause == nil && target.Port != 0 {
+			if err := prober.Probe(ctx, result.HealthCheck, target); err != nil {
+				probeFailures++
+				if probeFailures >= watchMaxProbeFailures {
+					cause = fmt.Errorf("health check failed %d times in a row: %w", probeFailures, err)
+				}
+			} else {
+				probeFailures = 0
+			}
+		}
+
+		if cause != nil {
+			s.rollbackUnhealthyDeployment(ctx, project, service, deployment, dep, result, cause)
+			return
+		}
+	}
+
+	s.log.Info("deployment passed its watch window",
+		"deployment_id", deployment.ID,
+		"service_id", service.ID,
+		"window", window,
+	)
+}
+
+// checkContainers reports why a watched deployment's containers are unhealthy
+func (s *DeployService) checkContainers(ctx context.Context, containerIDs []string, baseline map[string]int, window time.Duration) error {
+	for _, id := range containerIDs {
+		info, err := s.runtime.InspectContainer(ctx, id)
+		if err != nil {
+			return fmt.Errorf("container %s is gone: %w", shortID(id), err)
+		}
+		if restarts := info.RestartCount - baseline[id]; restarts > watchMaxRestarts {
+			return fmt.Errorf("container restarted %d times within %s of going live", restarts, window)
+		}
+		switch {
+		case info.State == "exited" || info.State == "dead":
+			return fmt.Errorf("container %s is %s", shortID(id), info.State)
+		case info.Health == "unhealthy":
+			return fmt.Errorf("container %s reports unhealthy", shortID(id))
+		}
+	}
+	return nil
+}
+
+// rollbackUnhealthyDeployment switches traffic back to the standby deployment
+// and marks the unhealthy one rolled back
+func (s *DeployService) rollbackUnhealthyDeployment(
+	ctx context.Context,
+	project *storage.Project,
+	service *storage.Service,
+	deployment *storage.Deployment,
+	dep deployer.Deployer,
+	result *deployer.DeploymentResult,
+	cause error,
+) {
+	s.log.Warn("deployment unhealthy after going live",
+		"deployment_id", deployment.ID,
+		"service_id", service.ID,
+		"reason", cause,
+	)
+
+	s.retireMu.Lock()
+	standby, upstream := s.warmStandby(ctx, service.ID, deployment.Slot)
+	if standby == nil {
+		s.retireMu.Unlock()
+
+		// Nothing to go back to; leave the deployment in place and report it
+		deployment.ErrorMessage = fmt.Sprintf("unhealthy after going live, no previous deployment to roll back to: %s", cause)
+		_ = s.store.Deployments().Update(ctx, deployment)
+		s.publishDeploymentStatus(project.ID, service.ID, deployment.ID, deployment.Status, deployment.ErrorMessage)
+
+		service.Status = "failed"
+		_ = s.store.Services().Update(ctx, service)
+		s.publishServiceStatus(project.ID, service.ID, service.Status)
+		return
+	}
+
+	s.routeMu.Lock()
+	if err := s.routeServiceDomains(ctx, service.ID, standby.Slot, upstream); err != nil {
+		s.routeMu.Unlock()
+		s.retireMu.Unlock()
+		s.log.Error("failed to switch traffic back", "service_id", service.ID, "error", err)
+		return
+	}
+
+	standby.Status = string(deployer.StatusRunning)
+	standby.RetireAt = nil
+	_ = s.store.Deployments().Update(ctx, standby)
+	deployment.Status = string(deployer.StatusRolledBack)
+	deployment.ErrorMessage = fmt.Sprintf("rolled back to %s: %s", standby.Version, cause)
+	_ = s.store.Deployments().Update(ctx, deployment)
+	s.routeMu.Unlock()
+	s.retireMu.Unlock()
+	s.publishDeploymentStatus(project.ID, service.ID, standby.ID, standby.Status, "")
+
+	deployment.Logs = s.captureContainerLogs(ctx, result.ContainerIDs)
+	_ = s.store.Deployments().Update(ctx, deployment)
+	s.publishDeploymentStatus(project.ID, service.ID, deployment.ID, deployment.Status, deployment.ErrorMessage)
+
+	_ = dep.Destroy(ctx, result.ContainerIDs)
+
+	service.Status = "running"
+	_ = s.store.Services().Update(ctx, service)
+	s.publishServiceStatus(project.ID, service.ID, service.Status)
+
+	s.log.Info("rolled back unhealthy deployment",
+		"deployment_id", deployment.ID,
+		"restored_deployment_id", standby.ID,
+		"service_id", service.ID,
+	)
+}
+
+// warmStandby returns the service's standby deployment in the slot opposite
+// to slot and its host port, if its containers are still running
+func (s *DeployService) warmStandby(ctx context.Context, serviceID, slot string) (*storage.Deployment, *proxy.Upstream) {
+	deployments, err := s.store.Deployments().ListByServiceID(ctx, serviceID)
+	if err != nil {
+		return nil, nil
+	}
+
+	for _, d := range deployments {
+		if d.Status != string(deployer.StatusStandby) || d.Slot == slot {
+			continue
+		}
+
+		containers, err := s.store.Containers().ListByDeploymentID(ctx, d.ID)
+		if err != nil || len(containers) == 0 {
+			return nil, nil
+		}
+		var upstream *proxy.Upstream
+		for _, c := range containers {
+			info, err := s.runtime.InspectContainer(ctx, c.ContainerID)
+			if err != nil || info.State != "running" {
+				return nil, nil
+			}
+			if upstream == nil {
+				upstream = containerUpstream(c)
+			}
+		}
+		return d, upstream
+	}
+	return nil, nil
+}
+
+// shortID shortens a container ID for messages
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
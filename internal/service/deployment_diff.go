@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// maskedValue replaces secret values in diffs
+const maskedValue = "********"
+
+// secretKeyMarkers identify environment variables and build args whose values are masked
+var secretKeyMarkers = []string{
+	"PASSWORD", "PASSWD", "SECRET", "TOKEN", "KEY", "PRIVATE",
+	"CREDENTIAL", "AUTH", "DSN", "DATABASE_URL", "CONNECTION",
+}
+
+// DeploymentDiff describes what changed between two deployments
+type DeploymentDiff struct {
+	From        *DeploymentResponse `json:"from"`
+	To          *DeploymentResponse `json:"to"`
+	Source      []FieldChange       `json:"source,omitempty"`  // repository, branch, commit, image and digest
+	Commits     *CommitRange        `json:"commits,omitempty"` // set when both deployments were built from git
+	Builder     []FieldChange       `json:"builder,omitempty"`
+	Runtime     []FieldChange       `json:"runtime,omitempty"`
+	Environment []EnvChange         `json:"environment,omitempty"`
+}
+
+// FieldChange is a changed configuration value
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// EnvChange is an added, removed or changed environment variable. Values of
+// secret-looking variables are masked.
+type EnvChange struct {
+	Key    string `json:"key"`
+	Change string `json:"change"` // added, removed, changed
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// CommitRange is the git history between two deployments
+type CommitRange struct {
+	Repository string `json:"repository"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	CompareURL string `json:"compare_url,omitempty"`
+}
+
+// DiffDeployments compares deployment a with deployment b, a being the older side
+func (s *DeployService) DiffDeployments(ctx context.Context, a, b string) (*DeploymentDiff, error) {
+	from, err := s.getDeploymentRecord(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.getDeploymentRecord(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromSource, toSource deployer.SourceConfig
+	_ = json.Unmarshal([]byte(from.SourceConfig), &fromSource)
+	_ = json.Unmarshal([]byte(to.SourceConfig), &toSource)
+
+	var fromEnv, toEnv map[string]string
+	_ = json.Unmarshal([]byte(from.Environment), &fromEnv)
+	_ = json.Unmarshal([]byte(to.Environment), &toEnv)
+
+	diff := &DeploymentDiff{
+		From: s.deploymentResponse(ctx, from),
+		To:   s.deploymentResponse(ctx, to),
+	}
+
+	diff.Source = changedFields([][3]string{
+		{"git_url", fromSource.GitURL, toSource.GitURL},
+		{"git_branch", fromSource.GitBranch, toSource.GitBranch},
+		{"git_commit", fromSource.GitCommit, toSource.GitCommit},
+		{"image", fromSource.Image, toSource.Image},
+		{"image_digest", fromSource.ImageDigest, toSource.ImageDigest},
+	})
+
+	if fromSource.GitCommit != "" && toSource.GitCommit != "" && fromSource.GitCommit != toSource.GitCommit {
+		diff.Commits = &CommitRange{
+			Repository: toSource.GitURL,
+			From:       fromSource.GitCommit,
+			To:         toSource.GitCommit,
+		}
+		if fromSource.GitURL == toSource.GitURL {
+			diff.Commits.CompareURL = compareURL(toSource.GitURL, fromSource.GitCommit, toSource.GitCommit)
+		}
+	}
+
+	diff.Builder = changedFields([][3]string{
+		{"builder", fromSource.Builder, toSource.Builder},
+		{"dockerfile_path", fromSource.DockerfilePath, toSource.DockerfilePath},
+		{"git_depth", fmt.Sprint(fromSource.GitDepth), fmt.Sprint(toSource.GitDepth)},
+		{"git_submodules", fmt.Sprint(fromSource.GitSubmodules), fmt.Sprint(toSource.GitSubmodules)},
+		{"git_lfs", fmt.Sprint(fromSource.GitLFS), fmt.Sprint(toSource.GitLFS)},
+	})
+	for _, change := range diffVariables(fromSource.BuildArgs, toSource.BuildArgs) {
+		diff.Builder = append(diff.Builder, FieldChange{
+			Field: "build_args." + change.Key,
+			From:  change.From,
+			To:    change.To,
+		})
+	}
+
+	diff.Runtime = changedFields([][3]string{
+		{"slot", from.Slot, to.Slot},
+		{"port", fmt.Sprint(fromSource.Port), fmt.Sprint(toSource.Port)},
+		{"pull_policy", fromSource.PullPolicy, toSource.PullPolicy},
+		{"registry", registryOf(fromSource), registryOf(toSource)},
+		{"compose_services", strings.Join(fromSource.Services, ","), strings.Join(toSource.Services, ",")},
+		{"compose_content", contentHash(fromSource.ComposeContent), contentHash(toSource.ComposeContent)},
+	})
+
+	diff.Environment = diffVariables(fromEnv, toEnv)
+
+	return diff, nil
+}
+
+func (s *DeployService) getDeploymentRecord(ctx context.Context, id string) (*storage.Deployment, error) {
+	deployment, err := s.store.Deployments().GetByID(ctx, id)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get deployment", err)
+	}
+	if deployment == nil {
+		return nil, apperrors.NewNotFoundError("deployment", id)
+	}
+	return deployment, nil
+}
+
+func (s *DeployService) deploymentResponse(ctx context.Context, d *storage.Deployment) *DeploymentResponse {
+	resp := &DeploymentResponse{
+		ID:           d.ID,
+		AppID:        d.AppID,
+		ServiceID:    d.ServiceID,
+		ReleaseID:    d.ReleaseID,
+		Version:      d.Version,
+		Slot:         d.Slot,
+		Status:       d.Status,
+		ErrorMessage: d.ErrorMessage,
+		CreatedAt:    d.CreatedAt.Format("2006-01-02T15:04:05Z"),
+
+		RequestedBy:    d.RequestedBy,
+		ApprovedBy:     d.ApprovedBy,
+		FreezeOverride: d.FreezeOverride,
+	}
+	if d.ServiceID != "" {
+		if service, err := s.store.Services().GetByID(ctx, d.ServiceID); err == nil && service != nil {
+			resp.ServiceName = service.Name
+		}
+	}
+	if d.FinishedAt != nil {
+		resp.FinishedAt = d.FinishedAt.Format("2006-01-02T15:04:05Z")
+	}
+	return resp
+}
+
+// changedFields returns the fields whose values differ
+func changedFields(fields [][3]string) []FieldChange {
+	var changes []FieldChange
+	for _, f := range fields {
+		if f[1] != f[2] {
+			changes = append(changes, FieldChange{Field: f[0], From: f[1], To: f[2]})
+		}
+	}
+	return changes
+}
+
+// diffVariables compares two sets of variables, masking secret values
+func diffVariables(from, to map[string]string) []EnvChange {
+	keys := make(map[string]bool, len(from)+len(to))
+	for k := range from {
+		keys[k] = true
+	}
+	for k := range to {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []EnvChange
+	for _, key := range sorted {
+		oldValue, hadOld := from[key]
+		newValue, hasNew := to[key]
+
+		change := EnvChange{Key: key}
+		switch {
+		case !hadOld:
+			change.Change = "added"
+			change.To = maskSecret(key, newValue)
+		case !hasNew:
+			change.Change = "removed"
+			change.From = maskSecret(key, oldValue)
+		case oldValue != newValue:
+			change.Change = "changed"
+			change.From = maskSecret(key, oldValue)
+			change.To = maskSecret(key, newValue)
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// maskSecret hides the value of secret-looking variables
+func maskSecret(key, value string) string {
+	upper := strings.ToUpper(key)
+	for _, marker := range secretKeyMarkers {
+		if strings.Contains(upper, marker) {
+			return maskedValue
+		}
+	}
+	return value
+}
+
+// registryOf describes the registry login of a source without its credentials
+func registryOf(source deployer.SourceConfig) string {
+	if source.RegistryAuth == nil {
+		return ""
+	}
+	if source.RegistryAuth.Username == "" {
+		return source.RegistryAuth.Registry
+	}
+	return source.RegistryAuth.Username + "@" + source.RegistryAuth.Registry
+}
+
+// contentHash summarizes large content such as compose files
+func contentHash(content string) string {
+	if content == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// compareURL links to the commit range on well-known git hosts
+func compareURL(repoURL, from, to string) string {
+	repo := strings.TrimSuffix(repoURL, ".git")
+	if strings.HasPrefix(repo, "git@") {
+		// git@github.com:owner/repo -> https://github.com/owner/repo
+		repo = "https://" + strings.Replace(strings.TrimPrefix(repo, "git@"), ":", "/", 1)
+	}
+
+	switch {
+	case strings.HasPrefix(repo, "https://github.com/"), strings.HasPrefix(repo, "https://gitea.com/"):
+		return fmt.Sprintf("%s/compare/%s...%s", repo, from, to)
+	case strings.HasPrefix(repo, "https://gitlab.com/"):
+		return fmt.Sprintf("%s/-/compare/%s...%s", repo, from, to)
+	case strings.HasPrefix(repo, "https://bitbucket.org/"):
+		return fmt.Sprintf("%s/branches/compare/%s..%s", repo, to, from)
+	}
+	return ""
+}
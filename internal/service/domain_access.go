@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// DomainAccess limits who may reach a domain
+type DomainAccess struct {
+	BasicAuth  []BasicAuthUser    `json:"basic_auth,omitempty"`
+	AllowCIDRs []string           `json:"allow_cidrs,omitempty"` // when set, every other client is rejected
+	DenyCIDRs  []string           `json:"deny_cidrs,omitempty"`
+	RateLimit  *RateLimitSettings `json:"rate_limit,omitempty"`
+}
+
+// BasicAuthUser is a basic auth user. The password is only accepted on
+// updates; an existing user sent without one keeps their password.
+type BasicAuthUser struct {
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+}
+
+// RateLimitSettings allow each client IP a number of requests per window
+type RateLimitSettings struct {
+	Requests int    `json:"requests"`
+	Window   string `json:"window,omitempty"` // e.g. "1s", "1m" (default)
+}
+
+// storedAccess is the stored form of DomainAccess, with password hashes
+type storedAccess struct {
+	BasicAuth  []storedUser       `json:"basic_auth,omitempty"`
+	AllowCIDRs []string           `json:"allow_cidrs,omitempty"`
+	DenyCIDRs  []string           `json:"deny_cidrs,omitempty"`
+	RateLimit  *RateLimitSettings `json:"rate_limit,omitempty"`
+}
+
+type storedUser struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// GetAccess returns the access control of a domain, without password hashes
+func (s *DomainService) GetAccess(ctx context.Context, domainName, pathPrefix string) (*DomainAccess, error) {
+	domain, err := s.findDomain(ctx, domainName, pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDomainAccess(domain.Access).public(), nil
+}
+
+// UpdateAccess replaces the access control of a domain and applies it to
+// its route
+func (s *DomainService) UpdateAccess(ctx context.Context, domainName, pathPrefix string, req DomainAccess) (*DomainAccess, error) {
+	domain, err := s.findDomain(ctx, domainName, pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := req.normalize(decodeDomainAccess(domain.Access))
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := encodeDomainAccess(access)
+	if err != nil {
+		return nil, err
+	}
+	domain.Access = encoded
+
+	if err := s.deploys.ValidateDomainRoute(domain); err != nil {
+		return nil, err
+	}
+	if err := s.store.Domains().Update(ctx, domain); err != nil {
+		return nil, apperrors.NewInternalError("failed to update domain access", err)
+	}
+
+	s.log.Info("domain access updated", "id", domain.ID, "domain", domain.Domain, "path_prefix", domain.PathPrefix)
+
+	if err := s.deploys.RouteDomain(ctx, domain); err != nil {
+		s.log.Warn("failed to route domain", "domain", domain.Domain, "error", err)
+	}
+
+	return access.public(), nil
+}
+
+// normalize validates an access update and hashes its passwords. Users sent
+// without a password keep their current hash.
+func (a *DomainAccess) normalize(current *storedAccess) (*storedAccess, error) {
+	hashes := make(map[string]string)
+	if current != nil {
+		for _, user := range current.BasicAuth {
+			hashes[user.Username] = user.PasswordHash
+		}
+	}
+
+	access := &storedAccess{}
+	seen := make(map[string]bool)
+	for _, user := range a.BasicAuth {
+		username := strings.TrimSpace(user.Username)
+		if username == "" || strings.ContainsAny(username, ": \t\n") {
+			return nil, accessError("basic_auth", "username must be set and cannot contain colons or spaces")
+		}
+		if seen[username] {
+			return nil, accessError("basic_auth", "user "+username+" is listed more than once")
+		}
+		seen[username] = true
+
+		hash := hashes[username]
+		if user.Password != "" {
+			hashed, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, accessError("basic_auth", "invalid password for "+username+": "+err.Error())
+			}
+			hash = string(hashed)
+		}
+		if hash == "" {
+			return nil, accessError("basic_auth", "password is required for new user "+username)
+		}
+		access.BasicAuth = append(access.BasicAuth, storedUser{Username: username, PasswordHash: hash})
+	}
+
+	var err error
+	if access.AllowCIDRs, err = normalizeCIDRs(a.AllowCIDRs, "allow_cidrs"); err != nil {
+		return nil, err
+	}
+	if access.DenyCIDRs, err = normalizeCIDRs(a.DenyCIDRs, "deny_cidrs"); err != nil {
+		return nil, err
+	}
+
+	if a.RateLimit != nil {
+		if a.RateLimit.Requests <= 0 {
+			return nil, accessError("rate_limit", "requests must be positive")
+		}
+		window := a.RateLimit.Window
+		if window == "" {
+			window = "1m"
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil || d < time.Second {
+			return nil, accessError("rate_limit", "window must be a duration of at least 1s")
+		}
+		access.RateLimit = &RateLimitSettings{Requests: a.RateLimit.Requests, Window: d.String()}
+	}
+	return access, nil
+}
+
+// normalizeCIDRs validates IP ranges; single addresses become /32 or /128
+func normalizeCIDRs(cidrs []string, field string) ([]string, error) {
+	var normalized []string
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if ip := net.ParseIP(cidr); ip != nil {
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, accessError(field, "invalid IP range "+cidr)
+		}
+		normalized = append(normalized, network.String())
+	}
+	return normalized, nil
+}
+
+func accessError(field, message string) error {
+	return apperrors.NewValidationError("invalid domain access", map[string]interface{}{
+		field: message,
+	})
+}
+
+// public returns the access control without password hashes
+func (a *storedAccess) public() *DomainAccess {
+	access := &DomainAccess{}
+	if a == nil {
+		return access
+	}
+	for _, user := range a.BasicAuth {
+		access.BasicAuth = append(access.BasicAuth, BasicAuthUser{Username: user.Username})
+	}
+	access.AllowCIDRs = a.AllowCIDRs
+	access.DenyCIDRs = a.DenyCIDRs
+	access.RateLimit = a.RateLimit
+	return access
+}
+
+// encodeDomainAccess encodes access control for storage; no restrictions are
+// stored as nothing
+func encodeDomainAccess(a *storedAccess) (string, error) {
+	if a == nil || (len(a.BasicAuth) == 0 && len(a.AllowCIDRs) == 0 && len(a.DenyCIDRs) == 0 && a.RateLimit == nil) {
+		return "", nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return "", apperrors.NewInternalError("failed to encode domain access", err)
+	}
+	return string(data), nil
+}
+
+// decodeDomainAccess decodes stored access control
+func decodeDomainAccess(encoded string) *storedAccess {
+	if encoded == "" {
+		return nil
+	}
+	var a storedAccess
+	if err := json.Unmarshal([]byte(encoded), &a); err != nil {
+		return nil
+	}
+	return &a
+}
+
+// applyDomainAccess adds a domain's stored access control to its proxy route
+func applyDomainAccess(route *proxy.Route, domain *storage.Domain) {
+	access := decodeDomainAccess(domain.Access)
+	if access == nil {
+		return
+	}
+	for _, user := range access.BasicAuth {
+		route.BasicAuth = append(route.BasicAuth, proxy.BasicAuthUser{Username: user.Username, PasswordHash: user.PasswordHash})
+	}
+	route.AllowCIDRs = access.AllowCIDRs
+	route.DenyCIDRs = access.DenyCIDRs
+	if access.RateLimit != nil {
+		window, err := time.ParseDuration(access.RateLimit.Window)
+		if err != nil {
+			window = time.Minute
+		}
+		route.RateLimit = &proxy.RateLimit{Requests: access.RateLimit.Requests, Window: window}
+	}
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+const (
+	certificateSourceCustom  = "custom"
+	certificateSourceManaged = "managed"
+
+	// certificateCheckInterval is how often certificates are checked for expiry
+	certificateCheckInterval = 6 * time.Hour
+	// certificateProbeTimeout bounds fetching the certificate a host serves
+	certificateProbeTimeout = 10 * time.Second
+)
+
+// certificateExpiryNotices are the days before expiry at which an expiring
+// certificate is reported, once each
+var certificateExpiryNotices = []int{14, 7, 3, 1}
+
+// UploadCertificateRequest is a PEM encoded certificate chain and key
+type UploadCertificateRequest struct {
+	Certificate string `json:"certificate" binding:"required"` // leaf first, then intermediates
+	PrivateKey  string `json:"private_key" binding:"required"`
+}
+
+// CertificateResponse reports the certificate of a domain host
+type CertificateResponse struct {
+	Domain        string   `json:"domain"`
+	Source        string   `json:"source"` // "custom" or "managed"
+	Issuer        string   `json:"issuer,omitempty"`
+	Names         []string `json:"names,omitempty"`
+	NotBefore     string   `json:"not_before,omitempty"`
+	NotAfter      string   `json:"not_after,omitempty"`
+	DaysRemaining *int     `json:"days_remaining,omitempty"`
+	LastError     string   `json:"last_error,omitempty"`
+	CheckedAt     string   `json:"checked_at,omitempty"`
+}
+
+// GetCertificate returns the certificate status of a domain's host. The
+// certificate a managed host serves is fetched again.
+func (s *DomainService) GetCertificate(ctx context.Context, domainName string) (*CertificateResponse, error) {
+	domain, err := s.findDomain(ctx, domainName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := s.store.Certificates().Get(ctx, domain.Domain)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get certificate", err)
+	}
+	if cert == nil {
+		cert = &storage.Certificate{Domain: domain.Domain, Source: certificateSourceManaged, ExpiryNotice: -1}
+	}
+	if cert.Source == certificateSourceManaged {
+		probeCertificate(ctx, cert)
+		if err := s.store.Certificates().Save(ctx, cert); err != nil {
+			return nil, apperrors.NewInternalError("failed to save certificate", err)
+		}
+	}
+	return toCertificateResponse(cert), nil
+}
+
+// UploadCertificate makes the proxy serve an uploaded certificate for a
+// domain's host instead of obtaining one
+func (s *DomainService) UploadCertificate(ctx context.Context, domainName string, req UploadCertificateRequest) (*CertificateResponse, error) {
+	domain, err := s.findDomain(ctx, domainName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := tls.X509KeyPair([]byte(req.Certificate), []byte(req.PrivateKey))
+	if err != nil {
+		return nil, certificateError("certificate and private_key are not a matching PEM pair: " + err.Error())
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, certificateError("invalid certificate: " + err.Error())
+	}
+	if err := leaf.VerifyHostname(domain.Domain); err != nil {
+		return nil, certificateError("certificate does not cover " + domain.Domain)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return nil, certificateError("certificate expired on " + leaf.NotAfter.Format("2006-01-02"))
+	}
+
+	key, err := s.cipher.Encrypt(req.PrivateKey)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to encrypt private key", err)
+	}
+
+	if err := s.deploys.InstallCertificate(ctx, domain.Domain, []byte(req.Certificate), []byte(req.PrivateKey)); err != nil {
+		return nil, err
+	}
+
+	cert := &storage.Certificate{
+		Domain:       domain.Domain,
+		Source:       certificateSourceCustom,
+		CertPEM:      req.Certificate,
+		KeyPEM:       key,
+		ExpiryNotice: -1,
+	}
+	setCertificateLeaf(cert, leaf)
+	if err := s.store.Certificates().Save(ctx, cert); err != nil {
+		return nil, apperrors.NewInternalError("failed to save certificate", err)
+	}
+
+	s.log.Info("certificate uploaded", "domain", domain.Domain, "issuer", cert.Issuer, "not_after", leaf.NotAfter)
+
+	return toCertificateResponse(cert), nil
+}
+
+// DeleteCertificate removes the uploaded certificate of a domain's host; the
+// proxy obtains a certificate for it again
+func (s *DomainService) DeleteCertificate(ctx context.Context, domainName string) error {
+	domain, err := s.findDomain(ctx, domainName, "")
+	if err != nil {
+		return err
+	}
+
+	cert, err := s.store.Certificates().Get(ctx, domain.Domain)
+	if err != nil {
+		return apperrors.NewInternalError("failed to get certificate", err)
+	}
+	if cert == nil || cert.Source != certificateSourceCustom {
+		return apperrors.NewNotFoundError("uploaded certificate", domain.Domain)
+	}
+
+	if err := s.deploys.RemoveCertificate(ctx, domain.Domain); err != nil {
+		return err
+	}
+	if err := s.store.Certificates().Delete(ctx, domain.Domain); err != nil {
+		return apperrors.NewInternalError("failed to delete certificate", err)
+	}
+
+	s.log.Info("certificate removed", "domain", domain.Domain)
+
+	return nil
+}
+
+// forgetCertificate drops the certificate of a host no domain routes anymore
+func (s *DomainService) forgetCertificate(ctx context.Context, host string) {
+	if remaining, err := s.store.Domains().GetByDomain(ctx, host); err != nil || remaining != nil {
+		return
+	}
+	cert, err := s.store.Certificates().Get(ctx, host)
+	if err != nil || cert == nil {
+		return
+	}
+	if cert.Source == certificateSourceCustom {
+		if err := s.deploys.RemoveCertificate(ctx, host); err != nil {
+			s.log.Warn("failed to remove certificate", "domain", host, "error", err)
+		}
+	}
+	if err := s.store.Certificates().Delete(ctx, host); err != nil {
+		s.log.Warn("failed to delete certificate", "domain", host, "error", err)
+	}
+}
+
+// StartCertificateMonitor reinstalls uploaded certificates in the proxy, then
+// periodically records the certificate of every TLS host and reports the
+// ones about to expire
+func (s *DomainService) StartCertificateMonitor(ctx context.Context) {
+	s.installCertificates(ctx)
+
+	ticker := time.NewTicker(certificateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.checkCertificates(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// installCertificates hands the stored uploaded certificates to the proxy,
+// which may have lost them on restart
+func (s *DomainService) installCertificates(ctx context.Context) {
+	certs, err := s.store.Certificates().List(ctx)
+	if err != nil {
+		s.log.Warn("failed to list certificates", "error", err)
+		return
+	}
+	for _, cert := range certs {
+		if cert.Source != certificateSourceCustom {
+			continue
+		}
+		key, err := s.cipher.Decrypt(cert.KeyPEM)
+		if err != nil {
+			s.log.Warn("failed to decrypt certificate key", "domain", cert.Domain, "error", err)
+			continue
+		}
+		if err := s.deploys.InstallCertificate(ctx, cert.Domain, []byte(cert.CertPEM), []byte(key)); err != nil {
+			s.log.Warn("failed to install certificate", "domain", cert.Domain, "error", err)
+		}
+	}
+}
+
+func (s *DomainService) checkCertificates(ctx context.Context) {
+	domains, err := s.store.Domains().List(ctx)
+	if err != nil {
+		s.log.Warn("failed to list domains", "error", err)
+		return
+	}
+
+	// Path prefixes of a host share its certificate
+	checked := make(map[string]bool)
+	for _, domain := range domains {
+		if !domain.SSLEnabled || checked[domain.Domain] {
+			continue
+		}
+		checked[domain.Domain] = true
+
+		cert, err := s.store.Certificates().Get(ctx, domain.Domain)
+		if err != nil {
+			s.log.Warn("failed to get certificate", "domain", domain.Domain, "error", err)
+			continue
+		}
+		if cert == nil {
+			cert = &storage.Certificate{Domain: domain.Domain, Source: certificateSourceManaged, ExpiryNotice: -1}
+		}
+		if cert.Source == certificateSourceManaged {
+			probeCertificate(ctx, cert)
+		}
+		s.notifyExpiry(domain.ProjectID, cert)
+
+		if err := s.store.Certificates().Save(ctx, cert); err != nil {
+			s.log.Warn("failed to save certificate", "domain", domain.Domain, "error", err)
+		}
+	}
+}
+
+// notifyExpiry publishes an event when a certificate crosses the next expiry
+// threshold. A renewed certificate starts over.
+func (s *DomainService) notifyExpiry(projectID string, cert *storage.Certificate) {
+	if cert.NotAfter == nil {
+		return
+	}
+
+	remaining := time.Until(*cert.NotAfter)
+	days := int(math.Floor(remaining.Hours() / 24))
+	threshold := -1
+	if remaining <= 0 {
+		threshold = 0
+	} else {
+		for _, notice := range certificateExpiryNotices {
+			if days < notice {
+				threshold = notice
+			}
+		}
+	}
+
+	if threshold == -1 {
+		cert.ExpiryNotice = -1
+		return
+	}
+	if cert.ExpiryNotice != -1 && cert.ExpiryNotice <= threshold {
+		return
+	}
+	cert.ExpiryNotice = threshold
+
+	status := "expiring"
+	message := fmt.Sprintf("certificate expires in %d days, on %s", days, cert.NotAfter.Format("2006-01-02"))
+	if threshold == 0 {
+		status = "expired"
+		message = "certificate expired on " + cert.NotAfter.Format("2006-01-02")
+	}
+	if cert.LastError != "" {
+		message += " (last error: " + cert.LastError + ")"
+	}
+
+	s.log.Warn("certificate "+status, "domain", cert.Domain, "not_after", cert.NotAfter)
+	s.eventBus.PublishCertificateStatus(projectID, cert.Domain, status, message)
+}
+
+// probeCertificate records the certificate a host serves on port 443. A
+// certificate that does not verify, such as the proxy's fallback after a
+// failed renewal, is recorded with the reason. Wildcard hosts are probed
+// through a name they cover.
+func probeCertificate(ctx context.Context, cert *storage.Certificate) {
+	now := time.Now()
+	cert.CheckedAt = &now
+
+	ctx, cancel := context.WithTimeout(ctx, certificateProbeTimeout)
+	defer cancel()
+
+	host := cert.Domain
+	if base, ok := strings.CutPrefix(host, "*."); ok {
+		host = "nebula-probe." + base
+	}
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: host, InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, "443"))
+	if err != nil {
+		cert.LastError = "failed to fetch certificate: " + err.Error()
+		return
+	}
+	defer conn.Close()
+
+	chain := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		cert.LastError = "no certificate served"
+		return
+	}
+	setCertificateLeaf(cert, chain[0])
+
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	cert.LastError = ""
+	if _, err := chain[0].Verify(x509.VerifyOptions{DNSName: host, Intermediates: intermediates}); err != nil {
+		cert.LastError = err.Error()
+	}
+}
+
+// setCertificateLeaf records the details of a certificate
+func setCertificateLeaf(cert *storage.Certificate, leaf *x509.Certificate) {
+	issuer := leaf.Issuer.CommonName
+	if len(leaf.Issuer.Organization) > 0 {
+		issuer = leaf.Issuer.Organization[0]
+		if leaf.Issuer.CommonName != "" {
+			issuer += " (" + leaf.Issuer.CommonName + ")"
+		}
+	}
+	cert.Issuer = issuer
+	cert.Names = strings.Join(leaf.DNSNames, ",")
+	notBefore, notAfter := leaf.NotBefore, leaf.NotAfter
+	cert.NotBefore = &notBefore
+	cert.NotAfter = &notAfter
+}
+
+func certificateError(message string) error {
+	return apperrors.NewValidationError("invalid certificate", map[string]interface{}{
+		"certificate": message,
+	})
+}
+
+func toCertificateResponse(cert *storage.Certificate) *CertificateResponse {
+	resp := &CertificateResponse{
+		Domain:    cert.Domain,
+		Source:    cert.Source,
+		Issuer:    cert.Issuer,
+		LastError: cert.LastError,
+	}
+	if cert.Names != "" {
+		resp.Names = strings.Split(cert.Names, ",")
+	}
+	if cert.NotBefore != nil {
+		resp.NotBefore = cert.NotBefore.Format("2006-01-02T15:04:05Z")
+	}
+	if cert.NotAfter != nil {
+		resp.NotAfter = cert.NotAfter.Format("2006-01-02T15:04:05Z")
+		days := int(math.Floor(time.Until(*cert.NotAfter).Hours() / 24))
+		resp.DaysRemaining = &days
+	}
+	if cert.CheckedAt != nil {
+		resp.CheckedAt = cert.CheckedAt.Format("2006-01-02T15:04:05Z")
+	}
+	return resp
+}
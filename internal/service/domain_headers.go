@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// DomainHeaders is the response header policy of a domain. Headers are
+// applied in order: the preset, HSTS, then Set, so Set overrides the others.
+type DomainHeaders struct {
+	Preset string            `json:"preset,omitempty"` // "basic" or "strict" security headers
+	HSTS   *HSTSSettings     `json:"hsts,omitempty"`
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+	CORS   *CORSSettings     `json:"cors,omitempty"`
+}
+
+// HSTSSettings configure the Strict-Transport-Security header
+type HSTSSettings struct {
+	MaxAge            int  `json:"max_age,omitempty"` // seconds, defaults to a year
+	IncludeSubdomains bool `json:"include_subdomains"`
+	Preload           bool `json:"preload"`
+}
+
+// CORSSettings configure cross-origin requests, answered by the proxy
+type CORSSettings struct {
+	AllowOrigins     []string `json:"allow_origins"` // "*" allows any origin
+	AllowMethods     []string `json:"allow_methods,omitempty"`
+	AllowHeaders     []string `json:"allow_headers,omitempty"`
+	ExposeHeaders    []string `json:"expose_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age,omitempty"` // seconds
+}
+
+// hstsPreloadMinAge is the shortest max-age accepted by the HSTS preload list
+const hstsPreloadMinAge = 31536000
+
+// securityPresets are the headers of each security preset
+var securityPresets = map[string]map[string]string{
+	"basic": {
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "SAMEORIGIN",
+		"Referrer-Policy":        "strict-origin-when-cross-origin",
+	},
+	"strict": {
+		"X-Content-Type-Options":     "nosniff",
+		"X-Frame-Options":            "DENY",
+		"Referrer-Policy":            "no-referrer",
+		"Permissions-Policy":         "camera=(), microphone=(), geolocation=()",
+		"Cross-Origin-Opener-Policy": "same-origin",
+	},
+}
+
+var headerNamePattern = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// GetHeaders returns the response header policy of a domain
+func (s *DomainService) GetHeaders(ctx context.Context, domainName, pathPrefix string) (*DomainHeaders, error) {
+	domain, err := s.findDomain(ctx, domainName, pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+	headers := decodeDomainHeaders(domain.Headers)
+	if headers == nil {
+		headers = &DomainHeaders{}
+	}
+	return headers, nil
+}
+
+// UpdateHeaders replaces the response header policy of a domain and applies
+// it to its route
+func (s *DomainService) UpdateHeaders(ctx context.Context, domainName, pathPrefix string, headers DomainHeaders) (*DomainHeaders, error) {
+	domain, err := s.findDomain(ctx, domainName, pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := headers.normalize(); err != nil {
+		return nil, err
+	}
+	encoded, err := encodeDomainHeaders(&headers)
+	if err != nil {
+		return nil, err
+	}
+	domain.Headers = encoded
+	if err := s.store.Domains().Update(ctx, domain); err != nil {
+		return nil, apperrors.NewInternalError("failed to update domain headers", err)
+	}
+
+	s.log.Info("domain headers updated", "id", domain.ID, "domain", domain.Domain, "path_prefix", domain.PathPrefix)
+
+	if err := s.deploys.RouteDomain(ctx, domain); err != nil {
+		s.log.Warn("failed to route domain", "domain", domain.Domain, "error", err)
+	}
+
+	return &headers, nil
+}
+
+// normalize validates a header policy and puts it in canonical form
+func (h *DomainHeaders) normalize() error {
+	if h.Preset != "" && securityPresets[h.Preset] == nil {
+		return headersError("preset", "must be basic or strict")
+	}
+
+	if h.HSTS != nil {
+		if h.HSTS.MaxAge == 0 {
+			h.HSTS.MaxAge = hstsPreloadMinAge
+		}
+		if h.HSTS.MaxAge < 0 {
+			return headersError("hsts", "max_age cannot be negative")
+		}
+		if h.HSTS.Preload && (h.HSTS.MaxAge < hstsPreloadMinAge || !h.HSTS.IncludeSubdomains) {
+			return headersError("hsts", "preload requires include_subdomains and a max_age of at least a year")
+		}
+	}
+
+	set := make(map[string]string, len(h.Set))
+	for name, value := range h.Set {
+		if !headerNamePattern.MatchString(name) {
+			return headersError("set", "invalid header name "+name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return headersError("set", "header "+name+" contains a line break")
+		}
+		set[http.CanonicalHeaderKey(name)] = value
+	}
+	h.Set = nil
+	if len(set) > 0 {
+		h.Set = set
+	}
+	for i, name := range h.Remove {
+		if !headerNamePattern.MatchString(name) {
+			return headersError("remove", "invalid header name "+name)
+		}
+		h.Remove[i] = http.CanonicalHeaderKey(name)
+		if _, ok := h.Set[h.Remove[i]]; ok {
+			return headersError("remove", "header "+h.Remove[i]+" is both set and removed")
+		}
+	}
+
+	if h.CORS != nil {
+		return h.CORS.normalize()
+	}
+	return nil
+}
+
+// normalize validates a CORS policy and fills in its defaults
+func (c *CORSSettings) normalize() error {
+	if len(c.AllowOrigins) == 0 {
+		return headersError("cors", "allow_origins is required")
+	}
+	for i, origin := range c.AllowOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return headersError("cors", "allow_credentials cannot be used with any origin (*)")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return headersError("cors", "origin must be * or scheme://host[:port], got "+origin)
+		}
+		c.AllowOrigins[i] = strings.ToLower(u.Scheme + "://" + u.Host)
+	}
+
+	if len(c.AllowMethods) == 0 {
+		c.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	}
+	for i, method := range c.AllowMethods {
+		c.AllowMethods[i] = strings.ToUpper(method)
+		if !headerNamePattern.MatchString(method) {
+			return headersError("cors", "invalid method "+method)
+		}
+	}
+	for _, list := range [][]string{c.AllowHeaders, c.ExposeHeaders} {
+		for _, name := range list {
+			if !headerNamePattern.MatchString(name) {
+				return headersError("cors", "invalid header name "+name)
+			}
+		}
+	}
+	if c.MaxAge < 0 {
+		return headersError("cors", "max_age cannot be negative")
+	}
+	return nil
+}
+
+// responseHeaders returns the headers set on responses: the preset's, HSTS,
+// then the explicitly set ones, less the removed ones
+func (h *DomainHeaders) responseHeaders() map[string]string {
+	headers := make(map[string]string)
+	for name, value := range securityPresets[h.Preset] {
+		headers[name] = value
+	}
+	if h.HSTS != nil {
+		value := fmt.Sprintf("max-age=%d", h.HSTS.MaxAge)
+		if h.HSTS.IncludeSubdomains {
+			value += "; includeSubDomains"
+		}
+		if h.HSTS.Preload {
+			value += "; preload"
+		}
+		headers["Strict-Transport-Security"] = value
+	}
+	for name, value := range h.Set {
+		headers[name] = value
+	}
+	// Removing a preset header opts out of it
+	for _, name := range h.Remove {
+		delete(headers, name)
+	}
+	return headers
+}
+
+func headersError(field, message string) error {
+	return apperrors.NewValidationError("invalid domain headers", map[string]interface{}{
+		field: message,
+	})
+}
+
+// encodeDomainHeaders encodes a header policy for storage; an empty policy
+// is stored as nothing
+func encodeDomainHeaders(h *DomainHeaders) (string, error) {
+	if h == nil || (h.Preset == "" && h.HSTS == nil && len(h.Set) == 0 && len(h.Remove) == 0 && h.CORS == nil) {
+		return "", nil
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return "", apperrors.NewInternalError("failed to encode domain headers", err)
+	}
+	return string(data), nil
+}
+
+// decodeDomainHeaders decodes a stored header policy
+func decodeDomainHeaders(encoded string) *DomainHeaders {
+	if encoded == "" {
+		return nil
+	}
+	var h DomainHeaders
+	if err := json.Unmarshal([]byte(encoded), &h); err != nil {
+		return nil
+	}
+	return &h
+}
+
+// applyDomainHeaders adds a domain's stored header policy to its proxy route
+func applyDomainHeaders(route *proxy.Route, domain *storage.Domain) {
+	headers := decodeDomainHeaders(domain.Headers)
+	if headers == nil {
+		return
+	}
+	if set := headers.responseHeaders(); len(set) > 0 {
+		route.SetHeaders = set
+	}
+	route.RemoveHeaders = headers.Remove
+	if c := headers.CORS; c != nil {
+		route.CORS = &proxy.CORS{
+			AllowOrigins:     c.AllowOrigins,
+			AllowMethods:     c.AllowMethods,
+			AllowHeaders:     c.AllowHeaders,
+			ExposeHeaders:    c.ExposeHeaders,
+			AllowCredentials: c.AllowCredentials,
+			MaxAge:           c.MaxAge,
+		}
+	}
+}
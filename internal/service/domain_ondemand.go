@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// TLSAllowlistResponse lists the hosts of a project approved for on-demand
+// certificates, and the service they are routed to
+type TLSAllowlistResponse struct {
+	ProjectID  string   `json:"project_id"`
+	Patterns   []string `json:"patterns"`
+	Service    string   `json:"service,omitempty"`
+	PathPrefix string   `json:"path_prefix,omitempty"`
+	UpdatedAt  string   `json:"updated_at,omitempty"`
+}
+
+// UpdateTLSAllowlistRequest replaces the allowlist of a project. With a
+// service, the hosts are routed to it without a domain of their own;
+// without one, they must be routed some other way, such as the Caddyfile.
+type UpdateTLSAllowlistRequest struct {
+	Patterns   []string `json:"patterns"`    // hosts such as shop.example.com, or *.customers.example.com
+	Service    string   `json:"service"`     // service the hosts are routed to
+	PathPrefix string   `json:"path_prefix"` // "/" for root, "/api" for path-based routing
+}
+
+// GetTLSAllowlist returns the on-demand TLS allowlist of a project
+func (s *DomainService) GetTLSAllowlist(ctx context.Context, projectID string) (*TLSAllowlistResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowlist, err := s.store.TLSAllowlists().Get(ctx, project.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get tls allowlist", err)
+	}
+	if allowlist == nil {
+		allowlist = &storage.TLSAllowlist{ProjectID: project.ID}
+	}
+	return s.toTLSAllowlistResponse(ctx, allowlist), nil
+}
+
+// UpdateTLSAllowlist replaces the on-demand TLS allowlist of a project
+func (s *DomainService) UpdateTLSAllowlist(ctx context.Context, projectID string, req UpdateTLSAllowlistRequest) (*TLSAllowlistResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(req.Patterns))
+	var patterns []string
+	for _, pattern := range req.Patterns {
+		pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+		if !hostnamePattern.MatchString(strings.TrimPrefix(pattern, "*.")) {
+			return nil, apperrors.NewValidationError("invalid tls allowlist", map[string]interface{}{
+				"patterns": "invalid pattern " + pattern + ": use a host such as shop.example.com, or a wildcard such as *.customers.example.com",
+			})
+		}
+		if !seen[pattern] {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	allowlist := &storage.TLSAllowlist{ProjectID: project.ID, Patterns: strings.Join(patterns, ",")}
+	if req.Service != "" {
+		service, err := s.store.Services().GetByProjectIDAndName(ctx, project.ID, req.Service)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to get service", err)
+		}
+		if service == nil {
+			return nil, apperrors.NewNotFoundError("service", req.Service)
+		}
+		if allowlist.PathPrefix, err = normalizePathPrefix(req.PathPrefix); err != nil {
+			return nil, err
+		}
+		allowlist.ServiceID = service.ID
+
+		for _, domain := range allowlistDomains(allowlist) {
+			if err := s.deploys.ValidateDomainRoute(domain); err != nil {
+				return nil, err
+			}
+		}
+
+		// Routed hosts must not take over the route of a domain
+		for _, pattern := range patterns {
+			domain, err := s.store.Domains().GetByDomainAndPath(ctx, pattern, allowlist.PathPrefix)
+			if err != nil {
+				return nil, apperrors.NewInternalError("failed to check existing domain", err)
+			}
+			if domain != nil {
+				return nil, apperrors.NewConflictError("host " + pattern + " is already a domain; leave it out of the allowlist")
+			}
+		}
+	}
+
+	previous, err := s.store.TLSAllowlists().Get(ctx, project.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get tls allowlist", err)
+	}
+	if err := s.store.TLSAllowlists().Save(ctx, allowlist); err != nil {
+		return nil, apperrors.NewInternalError("failed to save tls allowlist", err)
+	}
+
+	s.log.Info("tls allowlist updated", "project_id", project.ID, "patterns", len(patterns), "service_id", allowlist.ServiceID)
+
+	// Route the allowlisted hosts, and drop the routes of hosts no longer routed
+	routed := make(map[string]bool)
+	for _, domain := range allowlistDomains(allowlist) {
+		routed[domain.Domain+domain.PathPrefix] = true
+	}
+	if previous != nil {
+		for _, domain := range allowlistDomains(previous) {
+			if routed[domain.Domain+domain.PathPrefix] {
+				continue
+			}
+			if err := s.deploys.UnrouteDomain(ctx, domain); err != nil {
+				s.log.Warn("failed to unroute allowlisted host", "domain", domain.Domain, "error", err)
+			}
+		}
+	}
+	for _, domain := range allowlistDomains(allowlist) {
+		if err := s.deploys.RouteDomain(ctx, domain); err != nil {
+			s.log.Warn("failed to route allowlisted host", "domain", domain.Domain, "error", err)
+		}
+	}
+
+	return s.toTLSAllowlistResponse(ctx, allowlist), nil
+}
+
+// allowlistDomains returns the hosts an allowlist routes to its service, as
+// domains without records of their own
+func allowlistDomains(allowlist *storage.TLSAllowlist) []*storage.Domain {
+	if allowlist.ServiceID == "" || allowlist.Patterns == "" {
+		return nil
+	}
+	pathPrefix := allowlist.PathPrefix
+	if pathPrefix == "" {
+		pathPrefix = "/"
+	}
+
+	var domains []*storage.Domain
+	for _, pattern := range strings.Split(allowlist.Patterns, ",") {
+		domains = append(domains, &storage.Domain{
+			ProjectID:  allowlist.ProjectID,
+			ServiceID:  allowlist.ServiceID,
+			Domain:     pattern,
+			PathPrefix: pathPrefix,
+			ActiveSlot: "blue",
+			SSLEnabled: true,
+		})
+	}
+	return domains
+}
+
+// AllowCertificate reports whether the proxy may obtain an on-demand
+// certificate for a host: a domain's host, a host one of its wildcards
+// covers, an alias or redirected host of its rules, or a host matching a
+// project's allowlist
+func (s *DomainService) AllowCertificate(ctx context.Context, host string) (bool, error) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if !hostnamePattern.MatchString(host) {
+		return false, nil
+	}
+
+	domain, err := s.store.Domains().GetByDomain(ctx, host)
+	if err != nil {
+		return false, apperrors.NewInternalError("failed to get domain", err)
+	}
+	if domain != nil {
+		return true, nil
+	}
+
+	domains, err := s.store.Domains().List(ctx)
+	if err != nil {
+		return false, apperrors.NewInternalError("failed to list domains", err)
+	}
+	for _, domain := range domains {
+		if hostMatches(domain.Domain, host) {
+			return true, nil
+		}
+		if rules := decodeDomainRules(domain.Rules); rules != nil {
+			for _, claimed := range rules.hosts(domain) {
+				if claimed == host {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	allowlists, err := s.store.TLSAllowlists().List(ctx)
+	if err != nil {
+		return false, apperrors.NewInternalError("failed to list tls allowlists", err)
+	}
+	for _, allowlist := range allowlists {
+		for _, pattern := range strings.Split(allowlist.Patterns, ",") {
+			if hostMatches(pattern, host) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// hostMatches reports whether a host is the pattern's host or, for a
+// wildcard such as *.example.com, one label below its base
+func hostMatches(pattern, host string) bool {
+	base, ok := strings.CutPrefix(pattern, "*.")
+	if !ok {
+		return pattern == host
+	}
+	label, ok := strings.CutSuffix(host, "."+base)
+	return ok && label != "" && !strings.Contains(label, ".")
+}
+
+func (s *DomainService) toTLSAllowlistResponse(ctx context.Context, allowlist *storage.TLSAllowlist) *TLSAllowlistResponse {
+	resp := &TLSAllowlistResponse{ProjectID: allowlist.ProjectID, Patterns: []string{}}
+	if allowlist.Patterns != "" {
+		resp.Patterns = strings.Split(allowlist.Patterns, ",")
+	}
+	if allowlist.ServiceID != "" {
+		if service, err := s.store.Services().GetByID(ctx, allowlist.ServiceID); err == nil && service != nil {
+			resp.Service = service.Name
+			resp.PathPrefix = allowlist.PathPrefix
+		}
+	}
+	if !allowlist.UpdatedAt.IsZero() {
+		resp.UpdatedAt = allowlist.UpdatedAt.Format("2006-01-02T15:04:05Z")
+	}
+	return resp
+}
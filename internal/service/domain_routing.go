@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/storage"
+	"github.com/victalejo/nebula/internal/proxy/dockernet"
+)
+
+// domainRoute returns the proxy route of a domain, without upstreams
+func domainRoute(domain *storage.Domain) proxy.Route {
+	route := proxy.Route{
+		Domain:      domain.Domain,
+		AppID:       domain.ProjectID,
+		PathPrefix:  domain.PathPrefix,
+		StripPrefix: domain.StripPrefix,
+		SSLEnabled:  domain.SSLEnabled,
+	}
+	applyDomainRules(&route, domain)
+	applyDomainAccess(&route, domain)
+	applyDomainHeaders(&route, domain)
+	return route
+}
+
+// normalizePathPrefix turns "api/" or "/api/" into "/api"; empty means the
+// whole host
+func normalizePathPrefix(prefix string) (string, error) {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" || prefix == "/" {
+		return "/", nil
+	}
+	if strings.ContainsAny(prefix, "*?# \t") || strings.Contains(prefix, "//") {
+		return "", apperrors.NewValidationError("invalid path prefix", map[string]interface{}{
+			"path_prefix": "must be a plain path such as /api",
+		})
+	}
+	return "/" + strings.Trim(prefix, "/"), nil
+}
+
+// normalizeDomainName lowercases and validates a domain's host. A wildcard
+// such as *.app.example.com covers one label below a domain of its own.
+func normalizeDomainName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !hostnamePattern.MatchString(strings.TrimPrefix(name, "*.")) {
+		return "", apperrors.NewValidationError("invalid domain", map[string]interface{}{
+			"domain": "must be a host name such as app.example.com, or a wildcard such as *.app.example.com",
+		})
+	}
+	return name, nil
+}
+
+// resultUpstream returns the proxy upstream of a new deployment
+func resultUpstream(result *deployer.DeploymentResult) *proxy.Upstream {
+	host, port := result.Upstream()
+	return &proxy.Upstream{Host: host, Port: port}
+}
+
+// containerUpstream returns the proxy upstream of a recorded container, or nil.
+// Containers deployed before routing over the network only have a host port.
+func containerUpstream(c *storage.Container) *proxy.Upstream {
+	if c.Host != "" {
+		return &proxy.Upstream{Host: c.Host, Port: c.AppPort}
+	}
+	if c.Port != 0 {
+		return &proxy.Upstream{Host: "localhost", Port: c.Port}
+	}
+	return nil
+}
+
+// deploymentUpstream returns the proxy upstream of a deployment's main container
+func (s *DeployService) deploymentUpstream(ctx context.Context, deploymentID string) *proxy.Upstream {
+	containers, err := s.store.Containers().ListByDeploymentID(ctx, deploymentID)
+	if err != nil {
+		return nil
+	}
+	for _, c := range containers {
+		if upstream := containerUpstream(c); upstream != nil {
+			return upstream
+		}
+	}
+	return nil
+}
+
+// attachProxy connects a containerized proxy to a deployment's network
+func (s *DeployService) attachProxy(ctx context.Context, network string) error {
+	attacher, ok := s.proxyManager.(proxy.NetworkAttacher)
+	if !ok {
+		return nil
+	}
+	return attacher.AttachNetwork(ctx, network)
+}
+
+// attachServer connects Nebula's own container to a deployment's network so
+// health checks reach the unpublished containers on it
+func (s *DeployService) attachServer(ctx context.Context, network string) error {
+	attached, err := dockernet.Attach(ctx, s.runtime, s.serverContainer, network)
+	if err != nil {
+		return err
+	}
+	if attached {
+		s.log.Info("attached server to network", "container", s.serverContainer, "network", network)
+	}
+	return nil
+}
+
+// routeDomain points a domain at the given slot and upstream
+func (s *DeployService) routeDomain(ctx context.Context, domain *storage.Domain, slot string, upstream *proxy.Upstream) error {
+	route := domainRoute(domain)
+	route.ActiveSlot = proxy.Slot(slot)
+	if route.ActiveSlot == proxy.SlotBlue {
+		route.BlueTarget = upstream
+	} else {
+		route.GreenTarget = upstream
+	}
+	if err := s.proxyManager.UpdateRoute(ctx, route); err != nil {
+		return err
+	}
+
+	domain.ActiveSlot = slot
+	if domain.ID == "" {
+		return nil // routed by an allowlist, without a record
+	}
+	return s.store.Domains().Update(ctx, domain)
+}
+
+// routedDomains returns the domains routed to a service: its own, and the
+// hosts its project's TLS allowlist routes to it
+func (s *DeployService) routedDomains(ctx context.Context, serviceID string) ([]*storage.Domain, error) {
+	domains, err := s.store.Domains().ListByServiceID(ctx, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	allowlists, err := s.store.TLSAllowlists().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, allowlist := range allowlists {
+		if allowlist.ServiceID == serviceID {
+			domains = append(domains, allowlistDomains(allowlist)...)
+		}
+	}
+	return domains, nil
+}
+
+// RouteDomain points a domain at its service's running deployment, split with
+// the canary while a rollout is in progress. Domains of services that are not
+// running are routed by their next deployment.
+func (s *DeployService) RouteDomain(ctx context.Context, domain *storage.Domain) error {
+	s.routeMu.Lock()
+	defer s.routeMu.Unlock()
+
+	deployments, err := s.store.Deployments().ListByServiceID(ctx, domain.ServiceID)
+	if err != nil {
+		return err
+	}
+	route, ok := s.deploymentsRoute(ctx, domain, deployments)
+	if !ok {
+		return nil
+	}
+	if err := s.proxyManager.UpdateRoute(ctx, route); err != nil {
+		return err
+	}
+
+	domain.ActiveSlot = string(route.ActiveSlot)
+	if domain.ID == "" {
+		return nil
+	}
+	return s.store.Domains().Update(ctx, domain)
+}
+
+// ValidateDomainRoute checks that the proxy can render a domain's route
+func (s *DeployService) ValidateDomainRoute(domain *storage.Domain) error {
+	validator, ok := s.proxyManager.(proxy.RouteValidator)
+	if !ok {
+		return nil
+	}
+	if err := validator.ValidateRoute(domainRoute(domain)); err != nil {
+		return apperrors.NewValidationError(err.Error(), nil)
+	}
+	return nil
+}
+
+// InstallCertificate makes the proxy serve an uploaded certificate for a host
+func (s *DeployService) InstallCertificate(ctx context.Context, host string, certPEM, keyPEM []byte) error {
+	installer, ok := s.proxyManager.(proxy.CertificateInstaller)
+	if !ok {
+		return apperrors.NewValidationError("the configured proxy does not support uploaded certificates", nil)
+	}
+	if err := installer.InstallCertificate(ctx, host, certPEM, keyPEM); err != nil {
+		return apperrors.NewInternalError("failed to install certificate", err)
+	}
+	return nil
+}
+
+// RemoveCertificate stops the proxy serving an uploaded certificate for a
+// host, which goes back to a managed certificate
+func (s *DeployService) RemoveCertificate(ctx context.Context, host string) error {
+	installer, ok := s.proxyManager.(proxy.CertificateInstaller)
+	if !ok {
+		return nil
+	}
+	if err := installer.RemoveCertificate(ctx, host); err != nil {
+		return apperrors.NewInternalError("failed to remove certificate", err)
+	}
+	return nil
+}
+
+// UnrouteDomain removes the proxy route of a domain
+func (s *DeployService) UnrouteDomain(ctx context.Context, domain *storage.Domain) error {
+	return s.proxyManager.RemoveRoute(ctx, domain.Domain, domain.PathPrefix)
+}
+
+// restoreServiceRoutes points the service's domains back at its running
+// deployment after a new one failed to take over
+func (s *DeployService) restoreServiceRoutes(ctx context.Context, serviceID string) {
+	domains, err := s.routedDomains(ctx, serviceID)
+	if err != nil {
+		return
+	}
+	for _, domain := range domains {
+		if err := s.RouteDomain(ctx, domain); err != nil {
+			s.log.Warn("failed to restore route", "domain", domain.Domain, "path_prefix", domain.PathPrefix, "error", err)
+		}
+	}
+}
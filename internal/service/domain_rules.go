@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// DomainRules are the redirects, rewrites and aliases of a domain
+type DomainRules struct {
+	ForceHTTPS    bool               `json:"force_https"`
+	RedirectWWW   bool               `json:"redirect_www"` // send www.<domain> to <domain>, or the apex to a www domain
+	Aliases       []string           `json:"aliases,omitempty"`
+	HostRedirects []HostRedirectRule `json:"host_redirects,omitempty"`
+	Redirects     []PathRedirectRule `json:"redirects,omitempty"`
+	Rewrites      []PathRewriteRule  `json:"rewrites,omitempty"`
+}
+
+// HostRedirectRule redirects every request for a host to the domain
+type HostRedirectRule struct {
+	Host   string `json:"host"`
+	Status int    `json:"status,omitempty"` // 301 (default), 302, 307 or 308
+}
+
+// PathRedirectRule redirects a path, and the paths below it, to a path or URL
+type PathRedirectRule struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status,omitempty"` // 301 (default), 302, 307 or 308
+}
+
+// PathRewriteRule replaces a path prefix before the request is proxied
+type PathRewriteRule struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+var hostnamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// GetRules returns the redirect and rewrite rules of a domain
+func (s *DomainService) GetRules(ctx context.Context, domainName, pathPrefix string) (*DomainRules, error) {
+	domain, err := s.findDomain(ctx, domainName, pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+	rules := decodeDomainRules(domain.Rules)
+	if rules == nil {
+		rules = &DomainRules{}
+	}
+	return rules, nil
+}
+
+// UpdateRules replaces the redirect and rewrite rules of a domain and
+// applies them to its route
+func (s *DomainService) UpdateRules(ctx context.Context, domainName, pathPrefix string, rules DomainRules) (*DomainRules, error) {
+	domain, err := s.findDomain(ctx, domainName, pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rules.normalize(domain); err != nil {
+		return nil, err
+	}
+	if err := s.checkRuleHosts(ctx, domain, &rules); err != nil {
+		return nil, err
+	}
+
+	encoded, err := encodeDomainRules(&rules)
+	if err != nil {
+		return nil, err
+	}
+	domain.Rules = encoded
+	if err := s.store.Domains().Update(ctx, domain); err != nil {
+		return nil, apperrors.NewInternalError("failed to update domain rules", err)
+	}
+
+	s.log.Info("domain rules updated", "id", domain.ID, "domain", domain.Domain, "path_prefix", domain.PathPrefix)
+
+	if err := s.deploys.RouteDomain(ctx, domain); err != nil {
+		s.log.Warn("failed to route domain", "domain", domain.Domain, "error", err)
+	}
+
+	return &rules, nil
+}
+
+// normalize validates the rules of a domain and puts them in canonical form
+func (r *DomainRules) normalize(domain *storage.Domain) error {
+	root := domain.PathPrefix == "/"
+	if !root && (r.RedirectWWW || len(r.HostRedirects) > 0) {
+		return apperrors.NewValidationError("invalid domain rules", map[string]interface{}{
+			"host_redirects": "host redirects can only be set on a domain's root path",
+		})
+	}
+	if r.RedirectWWW && strings.HasPrefix(domain.Domain, "*.") {
+		return apperrors.NewValidationError("invalid domain rules", map[string]interface{}{
+			"redirect_www": "wildcard domains have no www counterpart",
+		})
+	}
+
+	for i, alias := range r.Aliases {
+		host, err := normalizeRuleHost(alias, "aliases")
+		if err != nil {
+			return err
+		}
+		r.Aliases[i] = host
+	}
+	for i := range r.HostRedirects {
+		host, err := normalizeRuleHost(r.HostRedirects[i].Host, "host_redirects")
+		if err != nil {
+			return err
+		}
+		r.HostRedirects[i].Host = host
+		if r.HostRedirects[i].Status, err = redirectStatus(r.HostRedirects[i].Status, "host_redirects"); err != nil {
+			return err
+		}
+	}
+
+	seen := map[string]bool{domain.Domain: true}
+	for _, host := range r.hosts(domain) {
+		if seen[host] {
+			return apperrors.NewValidationError("invalid domain rules", map[string]interface{}{
+				"aliases": "host " + host + " is listed more than once",
+			})
+		}
+		seen[host] = true
+	}
+
+	for i := range r.Redirects {
+		from, err := rulePath(r.Redirects[i].From, domain.PathPrefix, "redirects")
+		if err != nil {
+			return err
+		}
+		r.Redirects[i].From = from
+		if !validRedirectTarget(r.Redirects[i].To) {
+			return apperrors.NewValidationError("invalid domain rules", map[string]interface{}{
+				"redirects": "to must be a path or an http(s) URL",
+			})
+		}
+		if r.Redirects[i].Status, err = redirectStatus(r.Redirects[i].Status, "redirects"); err != nil {
+			return err
+		}
+	}
+	for i := range r.Rewrites {
+		from, err := rulePath(r.Rewrites[i].From, domain.PathPrefix, "rewrites")
+		if err != nil {
+			return err
+		}
+		r.Rewrites[i].From = from
+		if !strings.HasPrefix(r.Rewrites[i].To, "/") || strings.ContainsAny(r.Rewrites[i].To, "?# \t"+placeholderChars) {
+			return apperrors.NewValidationError("invalid domain rules", map[string]interface{}{
+				"rewrites": "to must be a plain path such as /v2",
+			})
+		}
+	}
+	return nil
+}
+
+// hosts returns the aliases and redirected hosts of the rules
+func (r *DomainRules) hosts(domain *storage.Domain) []string {
+	hosts := append([]string{}, r.Aliases...)
+	for _, redirect := range r.hostRedirects(domain) {
+		hosts = append(hosts, redirect.Host)
+	}
+	return hosts
+}
+
+// hostRedirects returns the host redirects, including the www counterpart
+// of the domain when RedirectWWW is set
+func (r *DomainRules) hostRedirects(domain *storage.Domain) []HostRedirectRule {
+	redirects := append([]HostRedirectRule{}, r.HostRedirects...)
+	if r.RedirectWWW {
+		host := "www." + domain.Domain
+		if apex, ok := strings.CutPrefix(domain.Domain, "www."); ok {
+			host = apex
+		}
+		redirects = append(redirects, HostRedirectRule{Host: host, Status: 301})
+	}
+	return redirects
+}
+
+// checkRuleHosts rejects aliases and redirected hosts that another domain
+// already serves
+func (s *DomainService) checkRuleHosts(ctx context.Context, domain *storage.Domain, rules *DomainRules) error {
+	hosts := rules.hosts(domain)
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	domains, err := s.store.Domains().List(ctx)
+	if err != nil {
+		return apperrors.NewInternalError("failed to list domains", err)
+	}
+
+	aliases := make(map[string]bool, len(rules.Aliases))
+	for _, alias := range rules.Aliases {
+		aliases[alias] = true
+	}
+	for _, other := range domains {
+		if other.ID == domain.ID {
+			continue
+		}
+		otherRules := decodeDomainRules(other.Rules)
+		for _, host := range hosts {
+			if host == other.Domain {
+				return apperrors.NewConflictError("host " + host + " is already routed as a domain")
+			}
+			if otherRules == nil {
+				continue
+			}
+			// Path prefixes of one host may share aliases
+			for _, alias := range otherRules.Aliases {
+				if alias == host && (other.Domain != domain.Domain || !aliases[host]) {
+					return apperrors.NewConflictError("host " + host + " is already an alias of " + other.Domain)
+				}
+			}
+			for _, redirect := range otherRules.hostRedirects(other) {
+				if redirect.Host == host {
+					return apperrors.NewConflictError("host " + host + " already redirects to " + other.Domain)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkHostUnclaimed rejects a new domain whose host is an alias or a
+// redirected host of another domain
+func (s *DomainService) checkHostUnclaimed(ctx context.Context, host string) error {
+	domains, err := s.store.Domains().List(ctx)
+	if err != nil {
+		return apperrors.NewInternalError("failed to list domains", err)
+	}
+	for _, other := range domains {
+		rules := decodeDomainRules(other.Rules)
+		if rules == nil || other.Domain == host {
+			continue
+		}
+		for _, claimed := range rules.hosts(other) {
+			if claimed == host {
+				return apperrors.NewConflictError("host " + host + " is already served by " + other.Domain)
+			}
+		}
+	}
+
+	allowlists, err := s.store.TLSAllowlists().List(ctx)
+	if err != nil {
+		return apperrors.NewInternalError("failed to list tls allowlists", err)
+	}
+	for _, allowlist := range allowlists {
+		for _, routed := range allowlistDomains(allowlist) {
+			if routed.Domain == host {
+				return apperrors.NewConflictError("host " + host + " is already routed by a tls allowlist")
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeRuleHost lowercases and validates a host name
+func normalizeRuleHost(host, field string) (string, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if !hostnamePattern.MatchString(host) {
+		return "", apperrors.NewValidationError("invalid domain rules", map[string]interface{}{
+			field: "invalid host " + host,
+		})
+	}
+	return host, nil
+}
+
+// rulePath normalizes a rule's source path, which must lie within the
+// domain's path prefix
+func rulePath(path, prefix, field string) (string, error) {
+	path, err := normalizePathPrefix(path)
+	if err != nil {
+		return "", apperrors.NewValidationError("invalid domain rules", map[string]interface{}{
+			field: "from must be a plain path such as /old",
+		})
+	}
+	if prefix != "/" && path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		return "", apperrors.NewValidationError("invalid domain rules", map[string]interface{}{
+			field: "from must be within the domain's path prefix " + prefix,
+		})
+	}
+	return path, nil
+}
+
+// redirectStatus validates a redirect status code, defaulting to 301
+func redirectStatus(status int, field string) (int, error) {
+	switch status {
+	case 0:
+		return 301, nil
+	case 301, 302, 307, 308:
+		return status, nil
+	}
+	return 0, apperrors.NewValidationError("invalid domain rules", map[string]interface{}{
+		field: "status must be 301, 302, 307 or 308",
+	})
+}
+
+// placeholderChars are expanded by Caddy in redirect and rewrite targets:
+// {placeholders} such as {env.SECRET}, and $1 regexp groups
+const placeholderChars = "{}$"
+
+// validRedirectTarget reports whether a redirect goes to a local path or an
+// http(s) URL, without placeholders
+func validRedirectTarget(target string) bool {
+	if strings.ContainsAny(target, " \t\""+placeholderChars) {
+		return false
+	}
+	if strings.HasPrefix(target, "/") {
+		return !strings.HasPrefix(target, "//")
+	}
+	u, err := url.Parse(target)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// encodeDomainRules encodes domain rules for storage; empty rules are stored
+// as nothing
+func encodeDomainRules(r *DomainRules) (string, error) {
+	if r == nil || (!r.ForceHTTPS && !r.RedirectWWW && len(r.Aliases) == 0 &&
+		len(r.HostRedirects) == 0 && len(r.Redirects) == 0 && len(r.Rewrites) == 0) {
+		return "", nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", apperrors.NewInternalError("failed to encode domain rules", err)
+	}
+	return string(data), nil
+}
+
+// decodeDomainRules decodes stored domain rules
+func decodeDomainRules(encoded string) *DomainRules {
+	if encoded == "" {
+		return nil
+	}
+	var r DomainRules
+	if err := json.Unmarshal([]byte(encoded), &r); err != nil {
+		return nil
+	}
+	return &r
+}
+
+// applyDomainRules adds a domain's stored rules to its proxy route
+func applyDomainRules(route *proxy.Route, domain *storage.Domain) {
+	rules := decodeDomainRules(domain.Rules)
+	if rules == nil {
+		return
+	}
+	route.ForceHTTPS = rules.ForceHTTPS
+	route.Aliases = rules.Aliases
+	for _, redirect := range rules.hostRedirects(domain) {
+		route.HostRedirects = append(route.HostRedirects, proxy.HostRedirect{Host: redirect.Host, Status: redirect.Status})
+	}
+	for _, redirect := range rules.Redirects {
+		route.Redirects = append(route.Redirects, proxy.Redirect{From: redirect.From, To: redirect.To, Status: redirect.Status})
+	}
+	for _, rewrite := range rules.Rewrites {
+		route.Rewrites = append(route.Rewrites, proxy.Rewrite{From: rewrite.From, To: rewrite.To})
+	}
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+func TestDomainRulesRefusePlaceholderTargets(t *testing.T) {
+	domain := &storage.Domain{Domain: "app.example.com", PathPrefix: "/"}
+
+	for _, to := range []string{"{env.SECRET}", "/{env.SECRET}", "https://example.com/{http.request.uri}", "/new/$1"} {
+		rules := DomainRules{Redirects: []PathRedirectRule{{From: "/old", To: to}}}
+		if err := rules.normalize(domain); err == nil {
+			t.Errorf("redirect to %q was accepted", to)
+		}
+
+		rules = DomainRules{Rewrites: []PathRewriteRule{{From: "/old", To: to}}}
+		if err := rules.normalize(domain); err == nil {
+			t.Errorf("rewrite to %q was accepted", to)
+		}
+	}
+
+	rules := DomainRules{
+		Redirects: []PathRedirectRule{{From: "/old", To: "https://example.com/new"}},
+		Rewrites:  []PathRewriteRule{{From: "/api", To: "/v2"}},
+	}
+	if err := rules.normalize(domain); err != nil {
+		t.Errorf("plain targets were refused: %v", err)
+	}
+}
@@ -2,45 +2,58 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/google/uuid"
 
 	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/events"
 	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/secrets"
 	"github.com/victalejo/nebula/internal/core/storage"
 )
 
 // DomainService handles domain business logic
 type DomainService struct {
-	store storage.Store
-	log   logger.Logger
+	store    storage.Store
+	deploys  *DeployService
+	cipher   *secrets.Cipher
+	eventBus *events.EventBus
+	log      logger.Logger
 }
 
 // NewDomainService creates a new domain service
-func NewDomainService(store storage.Store, log logger.Logger) *DomainService {
+func NewDomainService(store storage.Store, deploys *DeployService, cipher *secrets.Cipher, eventBus *events.EventBus, log logger.Logger) *DomainService {
 	return &DomainService{
-		store: store,
-		log:   log,
+		store:    store,
+		deploys:  deploys,
+		cipher:   cipher,
+		eventBus: eventBus,
+		log:      log,
 	}
 }
 
 // CreateDomainRequest represents a request to create a domain
 type CreateDomainRequest struct {
-	Domain     string `json:"domain" binding:"required"`
-	PathPrefix string `json:"path_prefix"` // "/" for root, "/api" for path-based routing
-	SSLEnabled *bool  `json:"ssl_enabled"`
+	Domain      string `json:"domain" binding:"required"`
+	PathPrefix  string `json:"path_prefix"`  // "/" for root, "/api" for path-based routing
+	StripPrefix bool   `json:"strip_prefix"` // proxy "/api/users" as "/users"
+	SSLEnabled  *bool  `json:"ssl_enabled"`
 }
 
 // DomainResponse represents a domain response
 type DomainResponse struct {
-	ID         string `json:"id"`
-	ProjectID  string `json:"project_id"`
-	ServiceID  string `json:"service_id"`
-	Domain     string `json:"domain"`
-	PathPrefix string `json:"path_prefix"`
-	ActiveSlot string `json:"active_slot"`
-	SSLEnabled bool   `json:"ssl_enabled"`
-	CreatedAt  string `json:"created_at"`
+	ID          string       `json:"id"`
+	ProjectID   string       `json:"project_id"`
+	ServiceID   string       `json:"service_id"`
+	Domain      string       `json:"domain"`
+	PathPrefix  string       `json:"path_prefix"`
+	StripPrefix bool         `json:"strip_prefix"`
+	ActiveSlot  string       `json:"active_slot"`
+	SSLEnabled  bool         `json:"ssl_enabled"`
+	Rules       *DomainRules `json:"rules,omitempty"`
+	CreatedAt   string       `json:"created_at"`
 }
 
 // Create creates a new domain for a service
@@ -62,20 +75,28 @@ func (s *DomainService) Create(ctx context.Context, projectID, serviceName strin
 		return nil, apperrors.NewNotFoundError("service", serviceName)
 	}
 
-	// Check if domain already exists
-	existing, err := s.store.Domains().GetByDomain(ctx, req.Domain)
+	name, err := normalizeDomainName(req.Domain)
+	if err != nil {
+		return nil, err
+	}
+	pathPrefix, err := normalizePathPrefix(req.PathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// A host can route different path prefixes to different services
+	existing, err := s.store.Domains().GetByDomainAndPath(ctx, name, pathPrefix)
 	if err != nil {
 		return nil, apperrors.NewInternalError("failed to check existing domain", err)
 	}
 	if existing != nil {
 		return nil, apperrors.NewConflictError("domain already exists")
 	}
+	if err := s.checkHostUnclaimed(ctx, name); err != nil {
+		return nil, err
+	}
 
 	// Set defaults
-	pathPrefix := req.PathPrefix
-	if pathPrefix == "" {
-		pathPrefix = "/"
-	}
 
 	sslEnabled := true
 	if req.SSLEnabled != nil {
@@ -83,32 +104,38 @@ func (s *DomainService) Create(ctx context.Context, projectID, serviceName strin
 	}
 
 	domain := &storage.Domain{
-		ID:         uuid.New().String(),
-		ProjectID:  project.ID,
-		ServiceID:  service.ID,
-		Domain:     req.Domain,
-		PathPrefix: pathPrefix,
-		ActiveSlot: "blue",
-		SSLEnabled: sslEnabled,
+		ID:          uuid.New().String(),
+		ProjectID:   project.ID,
+		ServiceID:   service.ID,
+		Domain:      name,
+		PathPrefix:  pathPrefix,
+		StripPrefix: req.StripPrefix && pathPrefix != "/",
+		ActiveSlot:  "blue",
+		SSLEnabled:  sslEnabled,
+	}
+	if err := s.deploys.ValidateDomainRoute(domain); err != nil {
+		return nil, err
 	}
 
 	if err := s.store.Domains().Create(ctx, domain); err != nil {
 		return nil, apperrors.NewInternalError("failed to create domain", err)
 	}
 
-	s.log.Info("domain created", "id", domain.ID, "domain", domain.Domain, "service_id", service.ID)
+	s.log.Info("domain created", "id", domain.ID, "domain", domain.Domain, "path_prefix", domain.PathPrefix, "service_id", service.ID)
+
+	// A running service receives traffic right away
+	if err := s.deploys.RouteDomain(ctx, domain); err != nil {
+		s.log.Warn("failed to route domain", "domain", domain.Domain, "error", err)
+	}
 
 	return s.toResponse(domain), nil
 }
 
-// Get retrieves a domain by domain name
-func (s *DomainService) Get(ctx context.Context, domainName string) (*DomainResponse, error) {
-	domain, err := s.store.Domains().GetByDomain(ctx, domainName)
+// Get retrieves a domain by domain name and path prefix
+func (s *DomainService) Get(ctx context.Context, domainName, pathPrefix string) (*DomainResponse, error) {
+	domain, err := s.findDomain(ctx, domainName, pathPrefix)
 	if err != nil {
-		return nil, apperrors.NewInternalError("failed to get domain", err)
-	}
-	if domain == nil {
-		return nil, apperrors.NewNotFoundError("domain", domainName)
+		return nil, err
 	}
 
 	return s.toResponse(domain), nil
@@ -180,27 +207,45 @@ func (s *DomainService) ListByService(ctx context.Context, projectID, serviceNam
 
 // UpdateDomainRequest represents a request to update a domain
 type UpdateDomainRequest struct {
-	PathPrefix *string `json:"path_prefix"`
-	SSLEnabled *bool   `json:"ssl_enabled"`
+	PathPrefix  *string `json:"path_prefix"`
+	StripPrefix *bool   `json:"strip_prefix"`
+	SSLEnabled  *bool   `json:"ssl_enabled"`
 }
 
 // Update updates a domain
-func (s *DomainService) Update(ctx context.Context, domainName string, req UpdateDomainRequest) (*DomainResponse, error) {
-	domain, err := s.store.Domains().GetByDomain(ctx, domainName)
+func (s *DomainService) Update(ctx context.Context, domainName, pathPrefix string, req UpdateDomainRequest) (*DomainResponse, error) {
+	domain, err := s.findDomain(ctx, domainName, pathPrefix)
 	if err != nil {
-		return nil, apperrors.NewInternalError("failed to get domain", err)
-	}
-	if domain == nil {
-		return nil, apperrors.NewNotFoundError("domain", domainName)
+		return nil, err
 	}
+	previous := *domain
 
 	// Apply updates
 	if req.PathPrefix != nil {
-		domain.PathPrefix = *req.PathPrefix
+		prefix, err := normalizePathPrefix(*req.PathPrefix)
+		if err != nil {
+			return nil, err
+		}
+		if prefix != domain.PathPrefix {
+			existing, err := s.store.Domains().GetByDomainAndPath(ctx, domain.Domain, prefix)
+			if err != nil {
+				return nil, apperrors.NewInternalError("failed to check existing domain", err)
+			}
+			if existing != nil {
+				return nil, apperrors.NewConflictError("domain already exists")
+			}
+		}
+		domain.PathPrefix = prefix
+	}
+	if req.StripPrefix != nil {
+		domain.StripPrefix = *req.StripPrefix
 	}
 	if req.SSLEnabled != nil {
 		domain.SSLEnabled = *req.SSLEnabled
 	}
+	if domain.PathPrefix == "/" {
+		domain.StripPrefix = false
+	}
 
 	if err := s.store.Domains().Update(ctx, domain); err != nil {
 		return nil, apperrors.NewInternalError("failed to update domain", err)
@@ -208,6 +253,16 @@ func (s *DomainService) Update(ctx context.Context, domainName string, req Updat
 
 	s.log.Info("domain updated", "id", domain.ID, "domain", domain.Domain)
 
+	// Move the route when the prefix changed, then apply the new settings
+	if previous.PathPrefix != domain.PathPrefix {
+		if err := s.deploys.UnrouteDomain(ctx, &previous); err != nil {
+			s.log.Warn("failed to remove old route", "domain", domain.Domain, "path_prefix", previous.PathPrefix, "error", err)
+		}
+	}
+	if err := s.deploys.RouteDomain(ctx, domain); err != nil {
+		s.log.Warn("failed to route domain", "domain", domain.Domain, "error", err)
+	}
+
 	return s.toResponse(domain), nil
 }
 
@@ -230,24 +285,49 @@ func (s *DomainService) UpdateActiveSlot(ctx context.Context, domainName, slot s
 }
 
 // Delete deletes a domain
-func (s *DomainService) Delete(ctx context.Context, domainName string) error {
-	domain, err := s.store.Domains().GetByDomain(ctx, domainName)
+func (s *DomainService) Delete(ctx context.Context, domainName, pathPrefix string) error {
+	domain, err := s.findDomain(ctx, domainName, pathPrefix)
 	if err != nil {
-		return apperrors.NewInternalError("failed to get domain", err)
-	}
-	if domain == nil {
-		return apperrors.NewNotFoundError("domain", domainName)
+		return err
 	}
 
 	if err := s.store.Domains().Delete(ctx, domain.ID); err != nil {
 		return apperrors.NewInternalError("failed to delete domain", err)
 	}
 
+	if err := s.deploys.UnrouteDomain(ctx, domain); err != nil {
+		s.log.Warn("failed to remove route", "domain", domain.Domain, "path_prefix", domain.PathPrefix, "error", err)
+	}
+	s.forgetCertificate(ctx, domain.Domain)
+
 	s.log.Info("domain deleted", "id", domain.ID, "domain", domain.Domain)
 
 	return nil
 }
 
+// findDomain looks up a domain by host and path prefix. Without a prefix the
+// host's shortest prefix is used, which is its root route when it has one.
+func (s *DomainService) findDomain(ctx context.Context, domainName, pathPrefix string) (*storage.Domain, error) {
+	var domain *storage.Domain
+	var err error
+	if pathPrefix == "" {
+		domain, err = s.store.Domains().GetByDomain(ctx, domainName)
+	} else {
+		pathPrefix, err = normalizePathPrefix(pathPrefix)
+		if err != nil {
+			return nil, err
+		}
+		domain, err = s.store.Domains().GetByDomainAndPath(ctx, domainName, pathPrefix)
+	}
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get domain", err)
+	}
+	if domain == nil {
+		return nil, apperrors.NewNotFoundError("domain", domainName+pathPrefix)
+	}
+	return domain, nil
+}
+
 func (s *DomainService) resolveProject(ctx context.Context, projectIDOrName string) (*storage.Project, error) {
 	project, err := s.store.Projects().GetByID(ctx, projectIDOrName)
 	if err != nil {
@@ -267,13 +347,57 @@ func (s *DomainService) resolveProject(ctx context.Context, projectIDOrName stri
 
 func (s *DomainService) toResponse(domain *storage.Domain) *DomainResponse {
 	return &DomainResponse{
-		ID:         domain.ID,
-		ProjectID:  domain.ProjectID,
-		ServiceID:  domain.ServiceID,
-		Domain:     domain.Domain,
-		PathPrefix: domain.PathPrefix,
-		ActiveSlot: domain.ActiveSlot,
-		SSLEnabled: domain.SSLEnabled,
-		CreatedAt:  domain.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:          domain.ID,
+		ProjectID:   domain.ProjectID,
+		ServiceID:   domain.ServiceID,
+		Domain:      domain.Domain,
+		PathPrefix:  domain.PathPrefix,
+		StripPrefix: domain.StripPrefix,
+		ActiveSlot:  domain.ActiveSlot,
+		SSLEnabled:  domain.SSLEnabled,
+		Rules:       decodeDomainRules(domain.Rules),
+		CreatedAt:   domain.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// RouteChangeResponse represents a pending or applied proxy route change
+type RouteChangeResponse struct {
+	Action     string          `json:"action"`
+	Domain     string          `json:"domain"`
+	PathPrefix string          `json:"path_prefix"`
+	Current    json.RawMessage `json:"current,omitempty"`
+	Desired    json.RawMessage `json:"desired,omitempty"`
+}
+
+// DiffRoutes shows how the proxy's routes differ from the domains in the
+// database, without changing anything
+func (s *DomainService) DiffRoutes(ctx context.Context) ([]*RouteChangeResponse, error) {
+	changes, err := s.deploys.DiffRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toRouteChangeResponses(changes), nil
+}
+
+// SyncRoutes rewrites the proxy's routes from the domains in the database
+func (s *DomainService) SyncRoutes(ctx context.Context) ([]*RouteChangeResponse, error) {
+	changes, err := s.deploys.SyncRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toRouteChangeResponses(changes), nil
+}
+
+func toRouteChangeResponses(changes []proxy.RouteChange) []*RouteChangeResponse {
+	responses := make([]*RouteChangeResponse, len(changes))
+	for i, change := range changes {
+		responses[i] = &RouteChangeResponse{
+			Action:     string(change.Action),
+			Domain:     change.Domain,
+			PathPrefix: change.PathPrefix,
+			Current:    change.Current,
+			Desired:    change.Desired,
+		}
 	}
+	return responses
 }
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// teardownEnvironment removes the containers, routes and network of an
+// environment, then the environment itself with its services and deployments
+func (s *DeployService) teardownEnvironment(ctx context.Context, env *storage.Project) error {
+	if env.ParentID == "" {
+		return apperrors.NewValidationError("only environments can be torn down", nil)
+	}
+
+	domains, err := s.store.Domains().ListByProjectID(ctx, env.ID)
+	if err != nil {
+		return apperrors.NewInternalError("failed to list domains", err)
+	}
+	if allowlist, err := s.store.TLSAllowlists().Get(ctx, env.ID); err == nil && allowlist != nil {
+		domains = append(domains, allowlistDomains(allowlist)...)
+	}
+	for _, domain := range domains {
+		if err := s.UnrouteDomain(ctx, domain); err != nil {
+			s.log.Warn("failed to remove route", "domain", domain.Domain, "path_prefix", domain.PathPrefix, "error", err)
+		}
+	}
+
+	services, err := s.store.Services().ListByProjectID(ctx, env.ID)
+	if err != nil {
+		return apperrors.NewInternalError("failed to list services", err)
+	}
+	for _, service := range services {
+		dep, err := s.deployerForService(service)
+		if err != nil {
+			continue
+		}
+		deployments, err := s.store.Deployments().ListByServiceID(ctx, service.ID)
+		if err != nil {
+			continue
+		}
+		for _, d := range deployments {
+			containers, err := s.store.Containers().ListByDeploymentID(ctx, d.ID)
+			if err != nil || len(containers) == 0 {
+				continue
+			}
+			containerIDs := make([]string, len(containers))
+			for i, c := range containers {
+				containerIDs[i] = c.ContainerID
+			}
+			if err := dep.Destroy(ctx, containerIDs); err != nil {
+				s.log.Warn("failed to remove containers", "deployment_id", d.ID, "error", err)
+			}
+		}
+	}
+
+	if network := environmentNetwork(env); network != "" {
+		// The network can't be removed while Nebula is still attached to it
+		if s.serverContainer != "" {
+			if err := s.runtime.DisconnectFromNetwork(ctx, s.serverContainer, network); err != nil {
+				s.log.Warn("failed to detach server from network", "network", network, "error", err)
+			}
+		}
+		if err := s.runtime.RemoveNetwork(ctx, network); err != nil {
+			s.log.Warn("failed to remove network", "network", network, "error", err)
+		}
+	}
+
+	if err := s.store.Apps().Delete(ctx, env.ID); err != nil {
+		return apperrors.NewInternalError("failed to delete environment", err)
+	}
+
+	s.log.Info("environment torn down", "project_id", env.ParentID, "environment", env.EnvironmentName)
+	return nil
+}
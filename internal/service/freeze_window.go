@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // freeze windows may name any IANA timezone
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// Audit actions
+const (
+	AuditDeploymentApproved = "deployment.approved"
+	AuditDeploymentRejected = "deployment.rejected"
+	AuditFreezeOverride     = "freeze.override"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// FreezeWindowRequest creates a freeze window. One-off windows set starts_at
+// and ends_at; recurring windows set weekdays and/or start_time and end_time.
+type FreezeWindowRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Weekdays  []string   `json:"weekdays,omitempty"`   // mon, tue, ... (empty = every day)
+	StartTime string     `json:"start_time,omitempty"` // HH:MM
+	EndTime   string     `json:"end_time,omitempty"`   // HH:MM, may be before start_time to span midnight
+	Timezone  string     `json:"timezone,omitempty"`   // IANA name (default UTC)
+}
+
+// FreezeWindowResponse represents a freeze window
+type FreezeWindowResponse struct {
+	ID        string   `json:"id"`
+	ProjectID string   `json:"project_id"`
+	Name      string   `json:"name"`
+	StartsAt  string   `json:"starts_at,omitempty"`
+	EndsAt    string   `json:"ends_at,omitempty"`
+	Weekdays  []string `json:"weekdays,omitempty"`
+	StartTime string   `json:"start_time,omitempty"`
+	EndTime   string   `json:"end_time,omitempty"`
+	Timezone  string   `json:"timezone,omitempty"`
+	Active    bool     `json:"active"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// AuditEntryResponse represents an audit log entry
+type AuditEntryResponse struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"project_id"`
+	Actor     string `json:"actor"`
+	Action    string `json:"action"`
+	TargetID  string `json:"target_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateFreezeWindow adds a freeze window to a project
+func (s *DeployService) CreateFreezeWindow(ctx context.Context, projectID string, req FreezeWindowRequest) (*FreezeWindowResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	window := &storage.FreezeWindow{
+		ID:        uuid.New().String(),
+		ProjectID: project.ID,
+		Name:      req.Name,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		Weekdays:  strings.ToLower(strings.Join(req.Weekdays, ",")),
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Timezone:  req.Timezone,
+	}
+	if err := validateFreezeWindow(window); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.FreezeWindows().Create(ctx, window); err != nil {
+		return nil, apperrors.NewInternalError("failed to create freeze window", err)
+	}
+
+	return freezeWindowResponse(window, time.Now()), nil
+}
+
+// ListFreezeWindows returns the freeze windows of a project
+func (s *DeployService) ListFreezeWindows(ctx context.Context, projectID string) ([]*FreezeWindowResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	windows, err := s.store.FreezeWindows().ListByProjectID(ctx, project.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list freeze windows", err)
+	}
+
+	now := time.Now()
+	result := make([]*FreezeWindowResponse, 0, len(windows))
+	for _, w := range windows {
+		result = append(result, freezeWindowResponse(w, now))
+	}
+	return result, nil
+}
+
+// DeleteFreezeWindow removes a freeze window from a project
+func (s *DeployService) DeleteFreezeWindow(ctx context.Context, projectID, windowID string) error {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	window, err := s.store.FreezeWindows().GetByID(ctx, windowID)
+	if err != nil {
+		return apperrors.NewInternalError("failed to get freeze window", err)
+	}
+	if window == nil || window.ProjectID != project.ID {
+		return apperrors.NewNotFoundError("freeze window", windowID)
+	}
+
+	if err := s.store.FreezeWindows().Delete(ctx, window.ID); err != nil {
+		return apperrors.NewInternalError("failed to delete freeze window", err)
+	}
+	return nil
+}
+
+// ListAuditLog returns the audit log of a project
+func (s *DeployService) ListAuditLog(ctx context.Context, projectID string) ([]*AuditEntryResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.store.Audit().ListByProjectID(ctx, project.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list audit log", err)
+	}
+
+	result := make([]*AuditEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, &AuditEntryResponse{
+			ID:        e.ID,
+			ProjectID: e.ProjectID,
+			Actor:     e.Actor,
+			Action:    e.Action,
+			TargetID:  e.TargetID,
+			Reason:    e.Reason,
+			CreatedAt: e.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+	return result, nil
+}
+
+// audit records a user action in the project's audit log
+func (s *DeployService) audit(ctx context.Context, projectID, actor, action, targetID, reason string) {
+	if actor == "" {
+		actor = "unknown"
+	}
+	entry := &storage.AuditEntry{
+		ID:        uuid.New().String(),
+		ProjectID: projectID,
+		Actor:     actor,
+		Action:    action,
+		TargetID:  targetID,
+		Reason:    reason,
+	}
+	if err := s.store.Audit().Create(ctx, entry); err != nil {
+		s.log.Error("failed to record audit entry", "action", action, "target_id", targetID, "error", err)
+		return
+	}
+	s.log.Info("audit", "project_id", projectID, "actor", actor, "action", action, "target_id", targetID, "reason", reason)
+}
+
+// activeFreezeWindow returns the project's freeze window in effect at now, if any
+func (s *DeployService) activeFreezeWindow(ctx context.Context, projectID string, now time.Time) (*storage.FreezeWindow, error) {
+	windows, err := s.store.FreezeWindows().ListByProjectID(ctx, projectID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list freeze windows", err)
+	}
+	for _, w := range windows {
+		if freezeWindowActive(w, now) {
+			return w, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkFreeze refuses a deployment while a freeze window is in effect unless
+// an override reason is given, in which case the override is audited. It
+// returns the override reason to record on the deployment.
+func (s *DeployService) checkFreeze(ctx context.Context, projectID, targetID, override, actor string) (string, error) {
+	window, err := s.activeFreezeWindow(ctx, projectID, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if window == nil {
+		return "", nil
+	}
+
+	override = strings.TrimSpace(override)
+	if override == "" {
+		return "", apperrors.NewConflictError(fmt.Sprintf(
+			"deployments are frozen by window %q; supply freeze_override with a reason to deploy anyway", window.Name,
+		))
+	}
+
+	s.audit(ctx, projectID, actor, AuditFreezeOverride, targetID, fmt.Sprintf("%s (window %q)", override, window.Name))
+	return override, nil
+}
+
+// validateFreezeWindow checks that a window is either one-off or recurring and well formed
+func validateFreezeWindow(w *storage.FreezeWindow) error {
+	oneOff := w.StartsAt != nil || w.EndsAt != nil
+	recurring := w.Weekdays != "" || w.StartTime != "" || w.EndTime != ""
+
+	switch {
+	case oneOff && recurring:
+		return apperrors.NewValidationError("a freeze window is either one-off (starts_at, ends_at) or recurring (weekdays, start_time, end_time)", nil)
+	case !oneOff && !recurring:
+		return apperrors.NewValidationError("a freeze window needs starts_at and ends_at, or weekdays and/or start_time and end_time", nil)
+	case oneOff:
+		if w.StartsAt == nil || w.EndsAt == nil || !w.EndsAt.After(*w.StartsAt) {
+			return apperrors.NewValidationError("ends_at must be after starts_at", nil)
+		}
+		return nil
+	}
+
+	if w.Weekdays != "" {
+		for _, day := range strings.Split(w.Weekdays, ",") {
+			if _, ok := weekdayNames[strings.TrimSpace(day)]; !ok {
+				return apperrors.NewValidationError("invalid weekday", map[string]interface{}{
+					"weekday": day,
+					"allowed": "mon, tue, wed, thu, fri, sat, sun",
+				})
+			}
+		}
+	}
+
+	if (w.StartTime == "") != (w.EndTime == "") {
+		return apperrors.NewValidationError("start_time and end_time must be set together", nil)
+	}
+	if w.StartTime != "" {
+		start, err := time.Parse("15:04", w.StartTime)
+		if err != nil {
+			return apperrors.NewValidationError("start_time must be HH:MM", nil)
+		}
+		end, err := time.Parse("15:04", w.EndTime)
+		if err != nil {
+			return apperrors.NewValidationError("end_time must be HH:MM", nil)
+		}
+		if start.Equal(end) {
+			return apperrors.NewValidationError("start_time and end_time must differ; omit both to freeze whole days", nil)
+		}
+	}
+
+	if w.Timezone != "" {
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return apperrors.NewValidationError("unknown timezone", map[string]interface{}{
+				"timezone": w.Timezone,
+			})
+		}
+	}
+	return nil
+}
+
+// freezeWindowActive reports whether a freeze window is in effect at now
+func freezeWindowActive(w *storage.FreezeWindow, now time.Time) bool {
+	if w.StartsAt != nil && w.EndsAt != nil {
+		return !now.Before(*w.StartsAt) && now.Before(*w.EndsAt)
+	}
+
+	loc := time.UTC
+	if w.Timezone != "" {
+		if l, err := time.LoadLocation(w.Timezone); err == nil {
+			loc = l
+		}
+	}
+	local := now.In(loc)
+
+	if w.StartTime == "" {
+		return freezesWeekday(w, local.Weekday())
+	}
+
+	start, err1 := time.Parse("15:04", w.StartTime)
+	end, err2 := time.Parse("15:04", w.EndTime)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	if startMinute < endMinute {
+		return freezesWeekday(w, local.Weekday()) && minute >= startMinute && minute < endMinute
+	}
+	// The window spans midnight: it started either today or yesterday
+	yesterday := (local.Weekday() + 6) % 7
+	return (freezesWeekday(w, local.Weekday()) && minute >= startMinute) ||
+		(freezesWeekday(w, yesterday) && minute < endMinute)
+}
+
+// freezesWeekday reports whether a recurring window starts on day
+func freezesWeekday(w *storage.FreezeWindow, day time.Weekday) bool {
+	if w.Weekdays == "" {
+		return true
+	}
+	for _, name := range strings.Split(w.Weekdays, ",") {
+		if weekdayNames[strings.TrimSpace(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func freezeWindowResponse(w *storage.FreezeWindow, now time.Time) *FreezeWindowResponse {
+	resp := &FreezeWindowResponse{
+		ID:        w.ID,
+		ProjectID: w.ProjectID,
+		Name:      w.Name,
+		StartTime: w.StartTime,
+		EndTime:   w.EndTime,
+		Timezone:  w.Timezone,
+		Active:    freezeWindowActive(w, now),
+		CreatedAt: w.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if w.StartsAt != nil {
+		resp.StartsAt = w.StartsAt.Format(time.RFC3339)
+	}
+	if w.EndsAt != nil {
+		resp.EndsAt = w.EndsAt.Format(time.RFC3339)
+	}
+	if w.Weekdays != "" {
+		resp.Weekdays = strings.Split(w.Weekdays, ",")
+	}
+	return resp
+}
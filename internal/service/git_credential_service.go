@@ -0,0 +1,407 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/core/secrets"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// GitCredentialService handles git credential business logic
+type GitCredentialService struct {
+	store  storage.Store
+	cipher *secrets.Cipher
+	log    logger.Logger
+}
+
+// NewGitCredentialService creates a new git credential service
+func NewGitCredentialService(store storage.Store, cipher *secrets.Cipher, log logger.Logger) *GitCredentialService {
+	return &GitCredentialService{
+		store:  store,
+		cipher: cipher,
+		log:    log,
+	}
+}
+
+// CreateGitCredentialRequest represents a request to create a git credential
+type CreateGitCredentialRequest struct {
+	Name       string `json:"name" binding:"required"`
+	Type       string `json:"type" binding:"required"` // ssh_key, https_token
+	Host       string `json:"host" binding:"required"` // github.com, gitlab.example.com
+	Username   string `json:"username"`                // https_token only
+	Token      string `json:"token"`                   // https_token only
+	PrivateKey string `json:"private_key"`             // ssh_key only, generated if empty
+	KnownHosts string `json:"known_hosts"`             // ssh_key only
+}
+
+// UpdateGitCredentialRequest represents a request to update a git credential
+type UpdateGitCredentialRequest struct {
+	Name       *string `json:"name"`
+	Host       *string `json:"host"`
+	Username   *string `json:"username"`
+	Token      *string `json:"token"`
+	KnownHosts *string `json:"known_hosts"`
+}
+
+// GitCredentialResponse represents a git credential response.
+// Secrets are never returned; only the SSH public key is exposed.
+type GitCredentialResponse struct {
+	ID         string `json:"id"`
+	ProjectID  string `json:"project_id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Host       string `json:"host"`
+	Username   string `json:"username,omitempty"`
+	PublicKey  string `json:"public_key,omitempty"`
+	KnownHosts string `json:"known_hosts,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+// Create creates a new git credential for a project
+func (s *GitCredentialService) Create(ctx context.Context, projectID string, req CreateGitCredentialRequest) (*GitCredentialResponse, error) {
+	s.log.Info("creating git credential", "project_id", projectID, "name", req.Name, "type", req.Type)
+
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &storage.GitCredential{
+		ID:        uuid.New().String(),
+		ProjectID: project.ID,
+		Name:      req.Name,
+		Type:      storage.GitCredentialType(req.Type),
+		Host:      normalizeGitHost(req.Host),
+	}
+
+	var secret string
+	switch credential.Type {
+	case storage.GitCredentialHTTPSToken:
+		if req.Token == "" {
+			return nil, apperrors.NewValidationError("token is required for https_token credentials", nil)
+		}
+		credential.Username = req.Username
+		secret = req.Token
+
+	case storage.GitCredentialSSHKey:
+		privateKey, publicKey, err := loadOrGenerateSSHKey(req.PrivateKey)
+		if err != nil {
+			return nil, apperrors.NewValidationError("invalid private key", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		credential.PublicKey = publicKey
+		credential.KnownHosts = req.KnownHosts
+		secret = privateKey
+
+	default:
+		return nil, apperrors.NewValidationError("unsupported credential type", map[string]interface{}{
+			"type": req.Type,
+		})
+	}
+
+	credential.Secret, err = s.cipher.Encrypt(secret)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to encrypt credential", err)
+	}
+
+	if err := s.store.GitCredentials().Create(ctx, credential); err != nil {
+		return nil, apperrors.NewInternalError("failed to create git credential", err)
+	}
+
+	s.log.Info("git credential created", "id", credential.ID, "project_id", project.ID)
+
+	return s.toResponse(credential), nil
+}
+
+// Get retrieves a git credential by ID
+func (s *GitCredentialService) Get(ctx context.Context, credentialID string) (*GitCredentialResponse, error) {
+	credential, err := s.getCredential(ctx, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	return s.toResponse(credential), nil
+}
+
+// List returns all git credentials for a project
+func (s *GitCredentialService) List(ctx context.Context, projectID string) ([]*GitCredentialResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.store.GitCredentials().ListByProjectID(ctx, project.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list git credentials", err)
+	}
+
+	responses := make([]*GitCredentialResponse, len(credentials))
+	for i, c := range credentials {
+		responses[i] = s.toResponse(c)
+	}
+
+	return responses, nil
+}
+
+// Update updates a git credential
+func (s *GitCredentialService) Update(ctx context.Context, credentialID string, req UpdateGitCredentialRequest) (*GitCredentialResponse, error) {
+	credential, err := s.getCredential(ctx, credentialID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		credential.Name = *req.Name
+	}
+	if req.Host != nil {
+		credential.Host = normalizeGitHost(*req.Host)
+	}
+	if req.KnownHosts != nil {
+		credential.KnownHosts = *req.KnownHosts
+	}
+	if credential.Type == storage.GitCredentialHTTPSToken {
+		if req.Username != nil {
+			credential.Username = *req.Username
+		}
+		if req.Token != nil {
+			credential.Secret, err = s.cipher.Encrypt(*req.Token)
+			if err != nil {
+				return nil, apperrors.NewInternalError("failed to encrypt credential", err)
+			}
+		}
+	}
+
+	if err := s.store.GitCredentials().Update(ctx, credential); err != nil {
+		return nil, apperrors.NewInternalError("failed to update git credential", err)
+	}
+
+	s.log.Info("git credential updated", "id", credential.ID)
+
+	return s.toResponse(credential), nil
+}
+
+// ScanKnownHosts fetches the SSH host keys of the credential's host with ssh-keyscan
+// and stores them as the credential's known_hosts
+func (s *GitCredentialService) ScanKnownHosts(ctx context.Context, credentialID string) (*GitCredentialResponse, error) {
+	credential, err := s.getCredential(ctx, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if credential.Type != storage.GitCredentialSSHKey {
+		return nil, apperrors.NewValidationError("known_hosts only apply to ssh_key credentials", nil)
+	}
+
+	scanCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(scanCtx, "ssh-keyscan", "-T", "10", credential.Host).Output()
+	if err != nil || len(strings.TrimSpace(string(output))) == 0 {
+		return nil, apperrors.NewValidationError("failed to scan host keys", map[string]interface{}{
+			"host": credential.Host,
+		})
+	}
+
+	credential.KnownHosts = string(output)
+	if err := s.store.GitCredentials().Update(ctx, credential); err != nil {
+		return nil, apperrors.NewInternalError("failed to update git credential", err)
+	}
+
+	s.log.Info("known hosts updated", "id", credential.ID, "host", credential.Host)
+
+	return s.toResponse(credential), nil
+}
+
+// Delete deletes a git credential
+func (s *GitCredentialService) Delete(ctx context.Context, credentialID string) error {
+	credential, err := s.getCredential(ctx, credentialID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.GitCredentials().Delete(ctx, credential.ID); err != nil {
+		return apperrors.NewInternalError("failed to delete git credential", err)
+	}
+
+	s.log.Info("git credential deleted", "id", credential.ID)
+
+	return nil
+}
+
+// ResolveGitAuth returns the decrypted credentials used to clone repoURL for a service.
+// The service's explicit credential wins; otherwise the first project credential
+// matching the repository host is used. Returns nil when no credential applies.
+func (s *GitCredentialService) ResolveGitAuth(ctx context.Context, projectID string, service *storage.Service, repoURL string) (*deployer.GitAuth, error) {
+	var credential *storage.GitCredential
+
+	if service != nil && service.GitCredentialID != "" {
+		c, err := s.store.GitCredentials().GetByID(ctx, service.GitCredentialID)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil || c.ProjectID != projectID {
+			return nil, fmt.Errorf("git credential %s not found", service.GitCredentialID)
+		}
+		credential = c
+	} else {
+		credentials, err := s.store.GitCredentials().ListByProjectID(ctx, projectID)
+		if err != nil {
+			return nil, err
+		}
+		host := gitRepoHost(repoURL)
+		for _, c := range credentials {
+			if c.Host == host && c.Type == gitCredentialTypeForURL(repoURL) {
+				credential = c
+				break
+			}
+		}
+	}
+
+	if credential == nil {
+		return nil, nil
+	}
+
+	secret, err := s.cipher.Decrypt(credential.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt git credential %s: %w", credential.Name, err)
+	}
+
+	auth := &deployer.GitAuth{
+		Type:       deployer.GitAuthType(credential.Type),
+		Username:   credential.Username,
+		KnownHosts: credential.KnownHosts,
+	}
+	if credential.Type == storage.GitCredentialSSHKey {
+		auth.PrivateKey = secret
+	} else {
+		auth.Token = secret
+	}
+
+	return auth, nil
+}
+
+func (s *GitCredentialService) getCredential(ctx context.Context, credentialID string) (*storage.GitCredential, error) {
+	credential, err := s.store.GitCredentials().GetByID(ctx, credentialID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get git credential", err)
+	}
+	if credential == nil {
+		return nil, apperrors.NewNotFoundError("git credential", credentialID)
+	}
+	return credential, nil
+}
+
+func (s *GitCredentialService) resolveProject(ctx context.Context, projectIDOrName string) (*storage.Project, error) {
+	project, err := s.store.Projects().GetByID(ctx, projectIDOrName)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get project", err)
+	}
+	if project == nil {
+		project, err = s.store.Projects().GetByName(ctx, projectIDOrName)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to get project", err)
+		}
+	}
+	if project == nil {
+		return nil, apperrors.NewNotFoundError("project", projectIDOrName)
+	}
+	return project, nil
+}
+
+func (s *GitCredentialService) toResponse(credential *storage.GitCredential) *GitCredentialResponse {
+	return &GitCredentialResponse{
+		ID:         credential.ID,
+		ProjectID:  credential.ProjectID,
+		Name:       credential.Name,
+		Type:       string(credential.Type),
+		Host:       credential.Host,
+		Username:   credential.Username,
+		PublicKey:  credential.PublicKey,
+		KnownHosts: credential.KnownHosts,
+		CreatedAt:  credential.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:  credential.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// loadOrGenerateSSHKey parses a PEM private key, or generates a new ed25519 deploy key
+// when none is supplied. It returns the PEM private key and the authorized_keys public key.
+func loadOrGenerateSSHKey(privateKeyPEM string) (string, string, error) {
+	if privateKeyPEM != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(privateKeyPEM))
+		if err != nil {
+			return "", "", err
+		}
+		publicKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+		return privateKeyPEM, publicKey, nil
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "nebula-deploy-key")
+	if err != nil {
+		return "", "", err
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", "", err
+	}
+
+	publicKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub))) + " nebula-deploy-key"
+	return string(pem.EncodeToMemory(block)), publicKey, nil
+}
+
+// normalizeGitHost strips schemes, users and paths from a host value
+func normalizeGitHost(host string) string {
+	host = strings.TrimSpace(host)
+	if strings.Contains(host, "://") || strings.Contains(host, "@") {
+		return gitRepoHost(host)
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "/"))
+}
+
+// gitRepoHost extracts the host name from an HTTPS, ssh:// or scp-style git URL
+func gitRepoHost(repoURL string) string {
+	if strings.Contains(repoURL, "://") {
+		u, err := url.Parse(repoURL)
+		if err != nil {
+			return ""
+		}
+		return strings.ToLower(u.Hostname())
+	}
+
+	// scp-style: git@github.com:user/repo.git
+	host := repoURL
+	if i := strings.Index(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if i := strings.IndexAny(host, ":/"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.ToLower(host)
+}
+
+// gitCredentialTypeForURL returns the credential type able to clone a repository URL
+func gitCredentialTypeForURL(repoURL string) storage.GitCredentialType {
+	if strings.HasPrefix(repoURL, "http://") || strings.HasPrefix(repoURL, "https://") {
+		return storage.GitCredentialHTTPSToken
+	}
+	return storage.GitCredentialSSHKey
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+)
+
+// HealthCheckSettings configures how Nebula decides a new deployment is healthy.
+// Probes run from the host, so images need no curl or similar tools.
+type HealthCheckSettings struct {
+	Type        string            `json:"type"`                   // http, tcp (default), exec, none
+	Path        string            `json:"path,omitempty"`         // http: request path, defaults to "/"
+	StatusMin   int               `json:"status_min,omitempty"`   // http: lowest accepted status, defaults to 200
+	StatusMax   int               `json:"status_max,omitempty"`   // http: highest accepted status, defaults to 399
+	Headers     map[string]string `json:"headers,omitempty"`      // http: extra request headers
+	Command     []string          `json:"command,omitempty"`      // exec: command run inside the container
+	Interval    string            `json:"interval,omitempty"`     // time between probes, e.g. "5s"
+	Timeout     string            `json:"timeout,omitempty"`      // per-probe timeout
+	Retries     int               `json:"retries,omitempty"`      // failed probes tolerated after the start period
+	StartPeriod string            `json:"start_period,omitempty"` // failures during this period are not counted
+}
+
+// validate checks the settings and returns them as deployer configuration
+func (h *HealthCheckSettings) validate() (*deployer.HealthCheckConfig, error) {
+	cfg := &deployer.HealthCheckConfig{
+		Type:      deployer.HealthCheckType(h.Type),
+		Path:      h.Path,
+		StatusMin: h.StatusMin,
+		StatusMax: h.StatusMax,
+		Headers:   h.Headers,
+		Command:   h.Command,
+		Retries:   h.Retries,
+	}
+
+	switch cfg.Type {
+	case "", deployer.HealthCheckTCP, deployer.HealthCheckNone:
+	case deployer.HealthCheckHTTP:
+		if h.Path != "" && h.Path[0] != '/' {
+			return nil, apperrors.NewValidationError("health_check.path must start with /", nil)
+		}
+		if !validStatus(h.StatusMin) || !validStatus(h.StatusMax) {
+			return nil, apperrors.NewValidationError("health_check status range must be between 100 and 599", nil)
+		}
+		if h.StatusMin > 0 && h.StatusMax > 0 && h.StatusMin > h.StatusMax {
+			return nil, apperrors.NewValidationError("health_check.status_min must not be greater than status_max", nil)
+		}
+	case deployer.HealthCheckExec:
+		if len(h.Command) == 0 {
+			return nil, apperrors.NewValidationError("health_check.command is required for exec health checks", nil)
+		}
+	default:
+		return nil, apperrors.NewValidationError("health_check.type must be http, tcp, exec or none", nil)
+	}
+
+	if h.Retries < 0 {
+		return nil, apperrors.NewValidationError("health_check.retries must not be negative", nil)
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"interval", h.Interval, &cfg.Interval},
+		{"timeout", h.Timeout, &cfg.Timeout},
+		{"start_period", h.StartPeriod, &cfg.StartPeriod},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return nil, apperrors.NewValidationError(fmt.Sprintf("health_check.%s must be a duration such as 5s", d.name), nil)
+		}
+		*d.dst = v
+	}
+
+	return cfg, nil
+}
+
+// validStatus reports whether an HTTP status bound is unset or a valid status code
+func validStatus(status int) bool {
+	return status == 0 || (status >= 100 && status <= 599)
+}
+
+// encodeHealthCheck validates the settings and encodes them for storage.
+// nil settings are stored as empty, meaning the default TCP check.
+func encodeHealthCheck(h *HealthCheckSettings) (string, error) {
+	if h == nil {
+		return "", nil
+	}
+	if _, err := h.validate(); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return "", apperrors.NewInternalError("failed to encode health check", err)
+	}
+	return string(data), nil
+}
+
+// decodeHealthCheck decodes stored health check settings
+func decodeHealthCheck(encoded string) *HealthCheckSettings {
+	if encoded == "" {
+		return nil
+	}
+	var h HealthCheckSettings
+	if err := json.Unmarshal([]byte(encoded), &h); err != nil {
+		return nil
+	}
+	return &h
+}
+
+// healthCheckConfig returns the deployer health check for a stored service
+func healthCheckConfig(encoded string) *deployer.HealthCheckConfig {
+	h := decodeHealthCheck(encoded)
+	if h == nil {
+		return nil
+	}
+	cfg, err := h.validate()
+	if err != nil {
+		return nil
+	}
+	return cfg
+}
@@ -57,9 +57,15 @@ func (s *NotificationService) listenEvents(ctx context.Context, sub *events.Subs
 			if !ok {
 				return
 			}
-			// Only notify on deployment status changes for running and failed
+			// Only notify on deployment status changes for running, failed, rolled back and awaiting approval
 			if event.Type == events.EventDeploymentStatus {
-				if event.Status == "running" || event.Status == "failed" {
+				if event.Status == "running" || event.Status == "failed" || event.Status == "rolled_back" || event.Status == "awaiting_approval" {
+					s.sendNotification(event)
+				}
+			}
+			// Certificates are notified before and when they expire
+			if event.Type == events.EventCertificateStatus {
+				if event.Status == "expiring" || event.Status == "expired" {
 					s.sendNotification(event)
 				}
 			}
@@ -108,6 +114,10 @@ func (s *NotificationService) sendNotification(event events.StatusEvent) {
 }
 
 func (s *NotificationService) formatMessage(event events.StatusEvent) string {
+	if event.Type == events.EventCertificateStatus {
+		return s.formatCertificateMessage(event)
+	}
+
 	switch event.Status {
 	case "running":
 		return "✅ *Despliegue exitoso*\n" +
@@ -121,7 +131,33 @@ func (s *NotificationService) formatMessage(event events.StatusEvent) string {
 			msg += "\n⚠️ Error: " + event.ErrorMessage
 		}
 		return msg
+	case "rolled_back":
+		msg := "↩️ *Despliegue revertido*\n" +
+			"📦 Deployment: " + event.DeploymentID + "\n" +
+			"🕐 " + event.Timestamp
+		if event.ErrorMessage != "" {
+			msg += "\n⚠️ Motivo: " + event.ErrorMessage
+		}
+		return msg
+	case "awaiting_approval":
+		return "⏳ *Despliegue pendiente de aprobación*\n" +
+			"📦 Deployment: " + event.DeploymentID + "\n" +
+			"🕐 " + event.Timestamp
 	default:
 		return "📢 Deployment " + event.DeploymentID + ": " + event.Status
 	}
 }
+
+func (s *NotificationService) formatCertificateMessage(event events.StatusEvent) string {
+	title := "🔒 *Certificado por vencer*\n"
+	if event.Status == "expired" {
+		title = "🚨 *Certificado vencido*\n"
+	}
+	msg := title +
+		"🌐 Dominio: " + event.Domain + "\n" +
+		"🕐 " + event.Timestamp
+	if event.ErrorMessage != "" {
+		msg += "\n⚠️ " + event.ErrorMessage
+	}
+	return msg
+}
@@ -0,0 +1,589 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/core/secrets"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// previewReaperInterval is how often expired previews are torn down
+const previewReaperInterval = 5 * time.Minute
+
+// previewRequester is recorded as the requester of preview deployments
+const previewRequester = "preview"
+
+// PreviewService deploys pull requests as environments of their project
+type PreviewService struct {
+	store   storage.Store
+	apps    *AppService
+	deploys *DeployService
+	cipher  *secrets.Cipher
+	log     logger.Logger
+
+	// mu serializes opening and closing previews, so limits hold
+	mu sync.Mutex
+}
+
+// NewPreviewService creates a new preview service
+func NewPreviewService(store storage.Store, apps *AppService, deploys *DeployService, cipher *secrets.Cipher, log logger.Logger) *PreviewService {
+	return &PreviewService{
+		store:   store,
+		apps:    apps,
+		deploys: deploys,
+		cipher:  cipher,
+		log:     log,
+	}
+}
+
+// UpdatePreviewConfigRequest represents a request to change preview settings
+type UpdatePreviewConfigRequest struct {
+	Enabled          *bool   `json:"enabled"`
+	BaseDomain       *string `json:"base_domain"`
+	ForkDatabases    *bool   `json:"fork_databases"`
+	TTLHours         *int    `json:"ttl_hours"`
+	MaxPreviews      *int    `json:"max_previews"`
+	WebhookSecret    *string `json:"webhook_secret"`
+	RegenerateSecret bool    `json:"regenerate_secret"`
+}
+
+// PreviewConfigResponse represents the preview settings of a project
+type PreviewConfigResponse struct {
+	ProjectID     string `json:"project_id"`
+	Enabled       bool   `json:"enabled"`
+	BaseDomain    string `json:"base_domain"`
+	ForkDatabases bool   `json:"fork_databases"`
+	TTLHours      int    `json:"ttl_hours"`
+	MaxPreviews   int    `json:"max_previews"`
+	WebhookPath   string `json:"webhook_path"`
+	// WebhookSecret is only returned when it was generated by the request
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+	HasSecret     bool   `json:"has_secret"`
+	UpdatedAt     string `json:"updated_at,omitempty"`
+}
+
+// PreviewResponse represents a pull request preview
+type PreviewResponse struct {
+	ID          string   `json:"id"`
+	PRNumber    int      `json:"pr_number"`
+	Title       string   `json:"title"`
+	Branch      string   `json:"branch"`
+	CommitSHA   string   `json:"commit_sha,omitempty"`
+	Environment string   `json:"environment"`
+	ProjectName string   `json:"project_name"` // the environment's project, used in service routes
+	URLs        []string `json:"urls"`
+	ReleaseID   string   `json:"release_id,omitempty"`
+	Status      string   `json:"status,omitempty"` // status of the latest release
+	ExpiresAt   string   `json:"expires_at,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+// GetConfig returns the preview settings of a project
+func (s *PreviewService) GetConfig(ctx context.Context, projectID string) (*PreviewConfigResponse, error) {
+	root, err := s.apps.resolveRootProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.config(ctx, root.ID)
+	if err != nil {
+		return nil, err
+	}
+	return previewConfigResponse(root, config), nil
+}
+
+// UpdateConfig changes the preview settings of a project. Enabling previews
+// without a webhook secret generates one, returned in the response.
+func (s *PreviewService) UpdateConfig(ctx context.Context, projectID string, req UpdatePreviewConfigRequest) (*PreviewConfigResponse, error) {
+	root, err := s.apps.resolveRootProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.config(ctx, root.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Enabled != nil {
+		config.Enabled = *req.Enabled
+	}
+	if req.BaseDomain != nil {
+		config.BaseDomain = strings.Trim(strings.ToLower(*req.BaseDomain), ".")
+	}
+	if req.ForkDatabases != nil {
+		config.ForkDatabases = *req.ForkDatabases
+	}
+	if req.TTLHours != nil {
+		config.TTLHours = *req.TTLHours
+	}
+	if req.MaxPreviews != nil {
+		config.MaxPreviews = *req.MaxPreviews
+	}
+	if config.TTLHours < 0 || config.MaxPreviews < 0 {
+		return nil, apperrors.NewValidationError("ttl_hours and max_previews must not be negative", nil)
+	}
+
+	var generated string
+	switch {
+	case req.WebhookSecret != nil && *req.WebhookSecret != "":
+		if config.WebhookSecret, err = s.cipher.Encrypt(*req.WebhookSecret); err != nil {
+			return nil, apperrors.NewInternalError("failed to encrypt webhook secret", err)
+		}
+	case req.RegenerateSecret || (config.Enabled && config.WebhookSecret == ""):
+		generated = generatePassword(40)
+		if config.WebhookSecret, err = s.cipher.Encrypt(generated); err != nil {
+			return nil, apperrors.NewInternalError("failed to encrypt webhook secret", err)
+		}
+	}
+
+	if err := s.store.PreviewConfigs().Save(ctx, config); err != nil {
+		return nil, apperrors.NewInternalError("failed to save preview settings", err)
+	}
+
+	s.log.Info("preview settings updated", "project_id", root.ID, "enabled", config.Enabled)
+
+	resp := previewConfigResponse(root, config)
+	resp.WebhookSecret = generated
+	return resp, nil
+}
+
+// HandleWebhook opens, updates or closes the preview of a pull request. It
+// returns nil for deliveries that need no action.
+func (s *PreviewService) HandleWebhook(ctx context.Context, projectID string, hook GitWebhook) (*PreviewResponse, error) {
+	root, err := s.apps.resolveRootProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.config(ctx, root.ID)
+	if err != nil {
+		return nil, err
+	}
+	if config.WebhookSecret == "" {
+		return nil, apperrors.NewUnauthorizedError("webhook secret is not configured")
+	}
+	secret, err := s.cipher.Decrypt(config.WebhookSecret)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to decrypt webhook secret", err)
+	}
+	if err := hook.verify(secret); err != nil {
+		return nil, err
+	}
+
+	event, err := hook.pullRequestEvent()
+	if err != nil || event == nil {
+		return nil, err
+	}
+
+	if event.Action == PullRequestClosed {
+		return nil, s.closePreview(ctx, root, event.Number)
+	}
+	if !config.Enabled {
+		return nil, nil
+	}
+	if event.Fork {
+		// Code from forks is not deployed without review
+		s.log.Info("ignoring pull request from a fork", "project_id", root.ID, "pr", event.Number)
+		return nil, nil
+	}
+	return s.openPreview(ctx, root, config, event)
+}
+
+// List returns the previews of a project
+func (s *PreviewService) List(ctx context.Context, projectID string) ([]*PreviewResponse, error) {
+	root, err := s.apps.resolveRootProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	previews, err := s.store.Previews().ListByProjectID(ctx, root.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list previews", err)
+	}
+
+	result := make([]*PreviewResponse, 0, len(previews))
+	for _, p := range previews {
+		result = append(result, s.previewResponse(ctx, p))
+	}
+	return result, nil
+}
+
+// Delete tears down the preview of a pull request
+func (s *PreviewService) Delete(ctx context.Context, projectID string, number int) error {
+	root, err := s.apps.resolveRootProject(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	preview, err := s.store.Previews().GetByPullRequest(ctx, root.ID, number)
+	if err != nil {
+		return apperrors.NewInternalError("failed to get preview", err)
+	}
+	if preview == nil {
+		return apperrors.NewNotFoundError("preview", fmt.Sprintf("pr-%d", number))
+	}
+	return s.closePreview(ctx, root, number)
+}
+
+// Cleanup tears down the previews of a project whose TTL ran out, or all of
+// them, and returns the removed previews
+func (s *PreviewService) Cleanup(ctx context.Context, projectID string, all bool) ([]*PreviewResponse, error) {
+	root, err := s.apps.resolveRootProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	previews, err := s.store.Previews().ListByProjectID(ctx, root.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list previews", err)
+	}
+
+	now := time.Now()
+	removed := make([]*PreviewResponse, 0)
+	for _, p := range previews {
+		if !all && (p.ExpiresAt == nil || p.ExpiresAt.After(now)) {
+			continue
+		}
+		resp := s.previewResponse(ctx, p)
+		if err := s.closePreview(ctx, root, p.PRNumber); err != nil {
+			return removed, err
+		}
+		removed = append(removed, resp)
+	}
+	return removed, nil
+}
+
+// StartPreviewReaper periodically tears down previews whose TTL ran out
+func (s *PreviewService) StartPreviewReaper(ctx context.Context) {
+	ticker := time.NewTicker(previewReaperInterval)
+	defer ticker.Stop()
+
+	for {
+		s.reapExpiredPreviews(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PreviewService) reapExpiredPreviews(ctx context.Context) {
+	previews, err := s.store.Previews().ListExpired(ctx, time.Now())
+	if err != nil {
+		s.log.Warn("failed to list expired previews", "error", err)
+		return
+	}
+	for _, p := range previews {
+		root, err := s.store.Apps().GetByID(ctx, p.ProjectID)
+		if err != nil || root == nil {
+			continue
+		}
+		if err := s.closePreview(ctx, root, p.PRNumber); err != nil {
+			s.log.Warn("failed to tear down expired preview", "project_id", p.ProjectID, "pr", p.PRNumber, "error", err)
+		}
+	}
+}
+
+// openPreview creates the preview of a pull request, or redeploys it when
+// new commits were pushed
+func (s *PreviewService) openPreview(ctx context.Context, root *storage.Project, config *storage.PreviewConfig, event *PullRequestEvent) (*PreviewResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	preview, err := s.store.Previews().GetByPullRequest(ctx, root.ID, event.Number)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get preview", err)
+	}
+
+	if preview == nil {
+		if event.Action == PullRequestEdited {
+			return nil, nil
+		}
+		return s.createPreview(ctx, root, config, event)
+	}
+
+	preview.Title = event.Title
+	if event.Action == PullRequestEdited {
+		if err := s.store.Previews().Update(ctx, preview); err != nil {
+			return nil, apperrors.NewInternalError("failed to update preview", err)
+		}
+		return s.previewResponse(ctx, preview), nil
+	}
+
+	env, err := s.store.Apps().GetByID(ctx, preview.EnvironmentID)
+	if err != nil || env == nil {
+		return nil, apperrors.NewInternalError("failed to get preview environment", err)
+	}
+	if env.GitBranch != event.Branch {
+		env.GitBranch = event.Branch
+		if err := s.store.Apps().Update(ctx, env); err != nil {
+			return nil, apperrors.NewInternalError("failed to update preview environment", err)
+		}
+	}
+
+	release, err := s.deploys.DeployProject(ctx, env.ID, DeployProjectRequest{RequestedBy: previewRequester})
+	if err != nil {
+		return nil, err
+	}
+
+	preview.Branch = event.Branch
+	preview.CommitSHA = event.CommitSHA
+	preview.ReleaseID = release.ID
+	preview.ExpiresAt = previewExpiry(config)
+	if err := s.store.Previews().Update(ctx, preview); err != nil {
+		return nil, apperrors.NewInternalError("failed to update preview", err)
+	}
+
+	s.log.Info("preview redeployed", "project_id", root.ID, "pr", event.Number, "commit", event.CommitSHA)
+	return s.previewResponse(ctx, preview), nil
+}
+
+func (s *PreviewService) createPreview(ctx context.Context, root *storage.Project, config *storage.PreviewConfig, event *PullRequestEvent) (*PreviewResponse, error) {
+	if config.MaxPreviews > 0 {
+		previews, err := s.store.Previews().ListByProjectID(ctx, root.ID)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to list previews", err)
+		}
+		if len(previews) >= config.MaxPreviews {
+			return nil, apperrors.NewConflictError(fmt.Sprintf(
+				"project already has %d previews, the maximum; close a pull request or clean up previews first", len(previews),
+			))
+		}
+	}
+
+	envResp, err := s.apps.CreateEnvironment(ctx, root.ID, CreateEnvironmentRequest{
+		Name:         fmt.Sprintf("pr-%d", event.Number),
+		GitBranch:    event.Branch,
+		serviceTypes: []storage.ServiceType{storage.ServiceTypeWeb, storage.ServiceTypeDatabase},
+	})
+	if err != nil {
+		return nil, err
+	}
+	env, err := s.store.Apps().GetByID(ctx, envResp.ProjectID)
+	if err != nil || env == nil {
+		return nil, apperrors.NewInternalError("failed to get preview environment", err)
+	}
+
+	if err := s.preparePreviewServices(ctx, root, env, config, event.Number); err != nil {
+		_ = s.deploys.teardownEnvironment(ctx, env)
+		return nil, err
+	}
+
+	preview := &storage.Preview{
+		ID:            uuid.New().String(),
+		ProjectID:     root.ID,
+		EnvironmentID: env.ID,
+		PRNumber:      event.Number,
+		Title:         event.Title,
+		Branch:        event.Branch,
+		CommitSHA:     event.CommitSHA,
+		ExpiresAt:     previewExpiry(config),
+	}
+	if err := s.store.Previews().Create(ctx, preview); err != nil {
+		_ = s.deploys.teardownEnvironment(ctx, env)
+		return nil, apperrors.NewInternalError("failed to create preview", err)
+	}
+
+	req := DeployProjectRequest{RequestedBy: previewRequester}
+	if config.ForkDatabases {
+		req.onRunning = func(ctx context.Context, service *storage.Service) error {
+			return s.forkPreviewDatabase(ctx, root, env, service)
+		}
+	}
+	release, err := s.deploys.DeployProject(ctx, env.ID, req)
+	if err != nil {
+		_ = s.deploys.teardownEnvironment(ctx, env)
+		return nil, err
+	}
+
+	preview.ReleaseID = release.ID
+	_ = s.store.Previews().Update(ctx, preview)
+
+	s.log.Info("preview created", "project_id", root.ID, "pr", event.Number, "branch", event.Branch)
+	return s.previewResponse(ctx, preview), nil
+}
+
+// preparePreviewServices assigns subdomains to the preview's web services and
+// lets its deployments run without approval
+func (s *PreviewService) preparePreviewServices(ctx context.Context, root, env *storage.Project, config *storage.PreviewConfig, number int) error {
+	services, err := s.store.Services().ListByProjectID(ctx, env.ID)
+	if err != nil {
+		return apperrors.NewInternalError("failed to list services", err)
+	}
+
+	var web []*storage.Service
+	for _, svc := range services {
+		if svc.RequiresApproval {
+			svc.RequiresApproval = false
+			if err := s.store.Services().Update(ctx, svc); err != nil {
+				return apperrors.NewInternalError("failed to update service", err)
+			}
+		}
+		if svc.Type == storage.ServiceTypeWeb {
+			web = append(web, svc)
+		}
+	}
+
+	if config.BaseDomain == "" {
+		return nil
+	}
+	for _, svc := range web {
+		host := fmt.Sprintf("pr-%d-%s.%s", number, root.Name, config.BaseDomain)
+		if len(web) > 1 {
+			host = fmt.Sprintf("pr-%d-%s-%s.%s", number, svc.Name, root.Name, config.BaseDomain)
+		}
+
+		existing, err := s.store.Domains().GetByDomain(ctx, host)
+		if err != nil {
+			return apperrors.NewInternalError("failed to check existing domain", err)
+		}
+		if existing != nil {
+			return apperrors.NewConflictError(fmt.Sprintf("domain %s already exists", host))
+		}
+
+		domain := &storage.Domain{
+			ID:         uuid.New().String(),
+			ProjectID:  env.ID,
+			ServiceID:  svc.ID,
+			Domain:     host,
+			PathPrefix: "/",
+			ActiveSlot: "blue",
+			SSLEnabled: true,
+		}
+		if err := s.store.Domains().Create(ctx, domain); err != nil {
+			return apperrors.NewInternalError("failed to create domain", err)
+		}
+	}
+	return nil
+}
+
+// forkPreviewDatabase copies the data of the project's database into the
+// preview's database of the same name once it is running
+func (s *PreviewService) forkPreviewDatabase(ctx context.Context, root, env *storage.Project, service *storage.Service) error {
+	if service.Type != storage.ServiceTypeDatabase {
+		return nil
+	}
+
+	source, err := s.store.Services().GetByProjectIDAndName(ctx, root.ID, service.Name)
+	if err != nil || source == nil {
+		s.log.Warn("no database to fork", "project_id", root.ID, "service", service.Name)
+		return nil
+	}
+	// Reload the service for the credentials stored by its deployment
+	target, err := s.store.Services().GetByID(ctx, service.ID)
+	if err != nil || target == nil {
+		return fmt.Errorf("failed to get service")
+	}
+	return s.deploys.forkDatabase(ctx, source, target, environmentNetwork(env))
+}
+
+// closePreview tears down the preview of a pull request, if any
+func (s *PreviewService) closePreview(ctx context.Context, root *storage.Project, number int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	preview, err := s.store.Previews().GetByPullRequest(ctx, root.ID, number)
+	if err != nil {
+		return apperrors.NewInternalError("failed to get preview", err)
+	}
+	if preview == nil {
+		return nil
+	}
+
+	env, err := s.store.Apps().GetByID(ctx, preview.EnvironmentID)
+	if err != nil {
+		return apperrors.NewInternalError("failed to get preview environment", err)
+	}
+	if env != nil {
+		// Removing the environment removes the preview record with it
+		if err := s.deploys.teardownEnvironment(ctx, env); err != nil {
+			return err
+		}
+	} else if err := s.store.Previews().Delete(ctx, preview.ID); err != nil {
+		return apperrors.NewInternalError("failed to delete preview", err)
+	}
+
+	s.log.Info("preview torn down", "project_id", root.ID, "pr", number)
+	return nil
+}
+
+// config returns the preview settings of a project, or the defaults
+func (s *PreviewService) config(ctx context.Context, projectID string) (*storage.PreviewConfig, error) {
+	config, err := s.store.PreviewConfigs().Get(ctx, projectID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get preview settings", err)
+	}
+	if config == nil {
+		config = &storage.PreviewConfig{ProjectID: projectID}
+	}
+	return config, nil
+}
+
+// previewExpiry returns when a preview deployed now expires, or nil
+func previewExpiry(config *storage.PreviewConfig) *time.Time {
+	if config.TTLHours <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(time.Duration(config.TTLHours) * time.Hour)
+	return &expiresAt
+}
+
+func previewConfigResponse(root *storage.Project, config *storage.PreviewConfig) *PreviewConfigResponse {
+	resp := &PreviewConfigResponse{
+		ProjectID:     root.ID,
+		Enabled:       config.Enabled,
+		BaseDomain:    config.BaseDomain,
+		ForkDatabases: config.ForkDatabases,
+		TTLHours:      config.TTLHours,
+		MaxPreviews:   config.MaxPreviews,
+		WebhookPath:   "/api/v1/webhooks/git/" + root.ID,
+		HasSecret:     config.WebhookSecret != "",
+	}
+	if !config.UpdatedAt.IsZero() {
+		resp.UpdatedAt = config.UpdatedAt.Format("2006-01-02T15:04:05Z")
+	}
+	return resp
+}
+
+func (s *PreviewService) previewResponse(ctx context.Context, p *storage.Preview) *PreviewResponse {
+	resp := &PreviewResponse{
+		ID:          p.ID,
+		PRNumber:    p.PRNumber,
+		Title:       p.Title,
+		Branch:      p.Branch,
+		CommitSHA:   p.CommitSHA,
+		Environment: fmt.Sprintf("pr-%d", p.PRNumber),
+		URLs:        []string{},
+		ReleaseID:   p.ReleaseID,
+		CreatedAt:   p.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:   p.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if p.ExpiresAt != nil {
+		resp.ExpiresAt = p.ExpiresAt.Format("2006-01-02T15:04:05Z")
+	}
+	if env, err := s.store.Apps().GetByID(ctx, p.EnvironmentID); err == nil && env != nil {
+		resp.Environment = env.EnvironmentName
+		resp.ProjectName = env.Name
+	}
+	if domains, err := s.store.Domains().ListByProjectID(ctx, p.EnvironmentID); err == nil {
+		for _, d := range domains {
+			scheme := "http://"
+			if d.SSLEnabled {
+				scheme = "https://"
+			}
+			resp.URLs = append(resp.URLs, scheme+d.Domain)
+		}
+	}
+	if p.ReleaseID != "" {
+		if release, err := s.store.Releases().GetByID(ctx, p.ReleaseID); err == nil && release != nil {
+			resp.Status = release.Status
+		}
+	}
+	return resp
+}
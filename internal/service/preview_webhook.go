@@ -0,0 +1,157 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+)
+
+// Pull request actions that previews react to
+const (
+	PullRequestOpened  = "opened"
+	PullRequestUpdated = "updated" // new commits were pushed
+	PullRequestEdited  = "edited"  // title or description changed
+	PullRequestClosed  = "closed"
+)
+
+// GitWebhook is a webhook delivery from a git host. GitHub deliveries set
+// Event and Signature, GitLab deliveries set Event and Token.
+type GitWebhook struct {
+	Event     string // X-GitHub-Event or X-Gitlab-Event
+	Signature string // X-Hub-Signature-256
+	Token     string // X-Gitlab-Token
+	Body      []byte
+}
+
+// PullRequestEvent is a pull (or merge) request change, whatever the git host
+type PullRequestEvent struct {
+	Action    string
+	Number    int
+	Title     string
+	Branch    string
+	CommitSHA string
+	Fork      bool // the branch lives in another repository
+}
+
+// verify checks that the delivery was signed with the project's secret
+func (h GitWebhook) verify(secret string) error {
+	switch {
+	case h.Signature != "":
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(h.Body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if hmac.Equal([]byte(expected), []byte(h.Signature)) {
+			return nil
+		}
+	case h.Token != "":
+		if subtle.ConstantTimeCompare([]byte(h.Token), []byte(secret)) == 1 {
+			return nil
+		}
+	}
+	return apperrors.NewUnauthorizedError("invalid webhook signature")
+}
+
+// pullRequestEvent decodes a delivery. Deliveries of other events return nil.
+func (h GitWebhook) pullRequestEvent() (*PullRequestEvent, error) {
+	switch h.Event {
+	case "pull_request":
+		return githubPullRequestEvent(h.Body)
+	case "Merge Request Hook":
+		return gitlabMergeRequestEvent(h.Body)
+	}
+	return nil, nil
+}
+
+func githubPullRequestEvent(body []byte) (*PullRequestEvent, error) {
+	var payload struct {
+		Action      string `json:"action"`
+		Number      int    `json:"number"`
+		PullRequest struct {
+			Title string `json:"title"`
+			Head  struct {
+				Ref  string `json:"ref"`
+				SHA  string `json:"sha"`
+				Repo struct {
+					FullName string `json:"full_name"`
+				} `json:"repo"`
+			} `json:"head"`
+			Base struct {
+				Repo struct {
+					FullName string `json:"full_name"`
+				} `json:"repo"`
+			} `json:"base"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, apperrors.NewValidationError("invalid pull request payload", nil)
+	}
+
+	event := &PullRequestEvent{
+		Number:    payload.Number,
+		Title:     payload.PullRequest.Title,
+		Branch:    payload.PullRequest.Head.Ref,
+		CommitSHA: payload.PullRequest.Head.SHA,
+		Fork:      !strings.EqualFold(payload.PullRequest.Head.Repo.FullName, payload.PullRequest.Base.Repo.FullName),
+	}
+	switch payload.Action {
+	case "opened", "reopened":
+		event.Action = PullRequestOpened
+	case "synchronize":
+		event.Action = PullRequestUpdated
+	case "edited":
+		event.Action = PullRequestEdited
+	case "closed":
+		event.Action = PullRequestClosed
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
+
+func gitlabMergeRequestEvent(body []byte) (*PullRequestEvent, error) {
+	var payload struct {
+		ObjectAttributes struct {
+			IID             int    `json:"iid"`
+			Title           string `json:"title"`
+			Action          string `json:"action"`
+			SourceBranch    string `json:"source_branch"`
+			SourceProjectID int    `json:"source_project_id"`
+			TargetProjectID int    `json:"target_project_id"`
+			OldRev          string `json:"oldrev"` // set when commits were pushed
+			LastCommit      struct {
+				ID string `json:"id"`
+			} `json:"last_commit"`
+		} `json:"object_attributes"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, apperrors.NewValidationError("invalid merge request payload", nil)
+	}
+
+	attrs := payload.ObjectAttributes
+	event := &PullRequestEvent{
+		Number:    attrs.IID,
+		Title:     attrs.Title,
+		Branch:    attrs.SourceBranch,
+		CommitSHA: attrs.LastCommit.ID,
+		Fork:      attrs.SourceProjectID != attrs.TargetProjectID,
+	}
+	switch attrs.Action {
+	case "open", "reopen":
+		event.Action = PullRequestOpened
+	case "update":
+		event.Action = PullRequestEdited
+		if attrs.OldRev != "" {
+			event.Action = PullRequestUpdated
+		}
+	case "close", "merge":
+		event.Action = PullRequestClosed
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
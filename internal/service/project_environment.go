@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// DefaultEnvironmentName names the environment of a project without a parent
+const DefaultEnvironmentName = "production"
+
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,30}$`)
+
+// CreateEnvironmentRequest represents a request to add an environment to a project
+type CreateEnvironmentRequest struct {
+	Name        string            `json:"name" binding:"required"` // e.g. staging
+	GitBranch   string            `json:"git_branch"`              // defaults to the project's branch
+	Environment map[string]string `json:"environment"`             // overrides the project's env vars
+	CloneFrom   string            `json:"clone_from"`              // environment whose services are copied (default production)
+
+	// serviceTypes limits the copied services to these types (nil = all)
+	serviceTypes []storage.ServiceType
+}
+
+// EnvironmentResponse represents an environment of a project
+type EnvironmentResponse struct {
+	Name        string            `json:"name"`
+	ProjectID   string            `json:"project_id"`   // the environment's own project, holding its services
+	ProjectName string            `json:"project_name"` // used in service and domain routes
+	GitBranch   string            `json:"git_branch,omitempty"`
+	Environment map[string]string `json:"environment"`
+	Network     string            `json:"network,omitempty"` // empty for the shared network
+	Services    []string          `json:"services"`
+	CreatedAt   string            `json:"created_at"`
+}
+
+// environmentNetwork returns the Docker network an environment's containers
+// join. Projects without environments stay on the shared network.
+func environmentNetwork(project *storage.Project) string {
+	if project.ParentID == "" {
+		return ""
+	}
+	return "nebula-" + project.Name
+}
+
+// rootProjectID returns the ID of the project an environment belongs to
+func rootProjectID(project *storage.Project) string {
+	if project.ParentID != "" {
+		return project.ParentID
+	}
+	return project.ID
+}
+
+// ListEnvironments returns the environments of a project, the project's own first
+func (s *AppService) ListEnvironments(ctx context.Context, idOrName string) ([]*EnvironmentResponse, error) {
+	root, err := s.resolveRootProject(ctx, idOrName)
+	if err != nil {
+		return nil, err
+	}
+
+	envs, err := s.environments(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*EnvironmentResponse, 0, len(envs))
+	for _, env := range envs {
+		result = append(result, s.environmentResponse(ctx, env))
+	}
+	return result, nil
+}
+
+// CreateEnvironment adds an environment to a project. The services of the
+// cloned environment are copied without their domains, deployments or
+// database credentials.
+func (s *AppService) CreateEnvironment(ctx context.Context, idOrName string, req CreateEnvironmentRequest) (*EnvironmentResponse, error) {
+	root, err := s.resolveRootProject(ctx, idOrName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !environmentNamePattern.MatchString(req.Name) {
+		return nil, apperrors.NewValidationError("environment name must be lowercase letters, digits and dashes", map[string]interface{}{
+			"name": req.Name,
+		})
+	}
+
+	envs, err := s.environments(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	cloneFrom := req.CloneFrom
+	if cloneFrom == "" {
+		cloneFrom = root.EnvironmentName
+	}
+	var source *storage.Project
+	for _, env := range envs {
+		if env.EnvironmentName == req.Name {
+			return nil, apperrors.NewConflictError(fmt.Sprintf("environment %q already exists", req.Name))
+		}
+		if env.EnvironmentName == cloneFrom {
+			source = env
+		}
+	}
+	if source == nil {
+		return nil, apperrors.NewNotFoundError("environment", cloneFrom)
+	}
+
+	name := root.Name + "-" + req.Name
+	existing, err := s.store.Apps().GetByName(ctx, name)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to check existing project", err)
+	}
+	if existing != nil {
+		return nil, apperrors.NewConflictError(fmt.Sprintf("project %q already exists", name))
+	}
+
+	envJSON := "{}"
+	if len(req.Environment) > 0 {
+		data, err := json.Marshal(req.Environment)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to encode environment", err)
+		}
+		envJSON = string(data)
+	}
+
+	gitBranch := req.GitBranch
+	if gitBranch == "" {
+		gitBranch = root.GitBranch
+	}
+
+	env := &storage.Project{
+		ID:              uuid.New().String(),
+		Name:            name,
+		DisplayName:     root.DisplayName,
+		Description:     root.Description,
+		GitRepo:         root.GitRepo,
+		GitBranch:       gitBranch,
+		Environment:     envJSON,
+		ParentID:        root.ID,
+		EnvironmentName: req.Name,
+	}
+	if err := s.store.Apps().Create(ctx, env); err != nil {
+		return nil, apperrors.NewInternalError("failed to create environment", err)
+	}
+
+	services, err := s.store.Services().ListByProjectID(ctx, source.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list services", err)
+	}
+	copied := 0
+	for _, svc := range services {
+		if req.serviceTypes != nil && !slices.Contains(req.serviceTypes, svc.Type) {
+			continue
+		}
+
+		clone := *svc
+		clone.ID = uuid.New().String()
+		clone.ProjectID = env.ID
+		clone.BuildCacheKey = uuid.New().String()
+		clone.Status = "stopped"
+		clone.DatabaseHost = ""
+		clone.DatabasePort = 0
+		clone.DatabaseUser = ""
+		clone.DatabasePassword = ""
+		clone.DatabaseName = ""
+		// Services building from the project's repository follow the environment's branch
+		if req.GitBranch != "" && (clone.GitRepo == "" || clone.GitRepo == root.GitRepo) {
+			clone.GitBranch = ""
+		}
+		if err := s.store.Services().Create(ctx, &clone); err != nil {
+			return nil, apperrors.NewInternalError("failed to copy service "+svc.Name, err)
+		}
+		copied++
+	}
+
+	s.log.Info("environment created",
+		"project_id", root.ID,
+		"environment", req.Name,
+		"cloned_from", source.EnvironmentName,
+		"services", copied,
+	)
+
+	return s.environmentResponse(ctx, env), nil
+}
+
+// DeleteEnvironment removes an environment and its services from a project
+func (s *AppService) DeleteEnvironment(ctx context.Context, idOrName, name string) error {
+	root, err := s.resolveRootProject(ctx, idOrName)
+	if err != nil {
+		return err
+	}
+	if name == root.EnvironmentName {
+		return apperrors.NewValidationError("the project's own environment cannot be deleted; delete the project instead", nil)
+	}
+
+	env, err := s.findEnvironment(ctx, root, name)
+	if err != nil {
+		return err
+	}
+	return s.Delete(ctx, env.ID)
+}
+
+// resolveRootProject looks up a project by ID or name; environments resolve
+// to the project they belong to
+func (s *AppService) resolveRootProject(ctx context.Context, idOrName string) (*storage.Project, error) {
+	project, err := s.store.Apps().GetByID(ctx, idOrName)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get project", err)
+	}
+	if project == nil {
+		project, err = s.store.Apps().GetByName(ctx, idOrName)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to get project", err)
+		}
+	}
+	if project == nil {
+		return nil, apperrors.NewNotFoundError("project", idOrName)
+	}
+	return rootProject(ctx, s.store, project)
+}
+
+func (s *AppService) environments(ctx context.Context, root *storage.Project) ([]*storage.Project, error) {
+	return projectEnvironments(ctx, s.store, root)
+}
+
+func (s *AppService) findEnvironment(ctx context.Context, root *storage.Project, name string) (*storage.Project, error) {
+	return findEnvironment(ctx, s.store, root, name)
+}
+
+// rootProject returns the project an environment belongs to, or the project
+// itself when it has no parent
+func rootProject(ctx context.Context, store storage.Store, project *storage.Project) (*storage.Project, error) {
+	if project.ParentID == "" {
+		return project, nil
+	}
+	parent, err := store.Apps().GetByID(ctx, project.ParentID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get project", err)
+	}
+	if parent == nil {
+		return nil, apperrors.NewNotFoundError("project", project.ParentID)
+	}
+	return parent, nil
+}
+
+// projectEnvironments returns a project followed by its environments
+func projectEnvironments(ctx context.Context, store storage.Store, root *storage.Project) ([]*storage.Project, error) {
+	children, err := store.Projects().ListByParentID(ctx, root.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list environments", err)
+	}
+	return append([]*storage.Project{root}, children...), nil
+}
+
+// findEnvironment returns the environment of a project with the given name
+func findEnvironment(ctx context.Context, store storage.Store, root *storage.Project, name string) (*storage.Project, error) {
+	envs, err := projectEnvironments(ctx, store, root)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range envs {
+		if env.EnvironmentName == name {
+			return env, nil
+		}
+	}
+	return nil, apperrors.NewNotFoundError("environment", name)
+}
+
+func (s *AppService) environmentResponse(ctx context.Context, env *storage.Project) *EnvironmentResponse {
+	var vars map[string]string
+	if env.Environment != "" {
+		_ = json.Unmarshal([]byte(env.Environment), &vars)
+	}
+
+	resp := &EnvironmentResponse{
+		Name:        env.EnvironmentName,
+		ProjectID:   env.ID,
+		ProjectName: env.Name,
+		GitBranch:   env.GitBranch,
+		Environment: vars,
+		Network:     environmentNetwork(env),
+		Services:    []string{},
+		CreatedAt:   env.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if services, err := s.store.Services().ListByProjectID(ctx, env.ID); err == nil {
+		for _, svc := range services {
+			resp.Services = append(resp.Services, svc.Name)
+		}
+	}
+	return resp
+}
+
+// projectVariables returns the environment variables of a project, layered
+// over those of the project it belongs to
+func projectVariables(ctx context.Context, store storage.Store, project *storage.Project) (map[string]string, error) {
+	env := make(map[string]string)
+	if project.ParentID != "" {
+		parent, err := rootProject(ctx, store, project)
+		if err != nil {
+			return nil, err
+		}
+		if parent.Environment != "" {
+			_ = json.Unmarshal([]byte(parent.Environment), &env)
+		}
+	}
+	if project.Environment != "" {
+		var vars map[string]string
+		_ = json.Unmarshal([]byte(project.Environment), &vars)
+		for k, v := range vars {
+			env[k] = v
+		}
+	}
+	return env, nil
+}
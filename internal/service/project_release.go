@@ -0,0 +1,609 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// DeployProjectRequest represents a request to deploy all services of a project
+type DeployProjectRequest struct {
+	// FreezeOverride is the reason for deploying during a freeze window; it is audited
+	FreezeOverride string `json:"freeze_override,omitempty"`
+
+	// RequestedBy is the user requesting the release
+	RequestedBy string `json:"-"`
+
+	// onRunning is called once a service of the release is running, before
+	// the services that depend on it are deployed
+	onRunning func(ctx context.Context, service *storage.Service) error
+}
+
+// Release statuses
+const (
+	ReleaseStatusDeploying = "deploying"
+	ReleaseStatusBlocked   = "blocked" // waiting for service deployments to be approved
+	ReleaseStatusRunning   = "running"
+	ReleaseStatusFailed    = "failed"
+)
+
+// errAwaitingApproval reports a service deployment of a release that is held
+// until it is approved
+var errAwaitingApproval = errors.New("awaiting approval")
+
+// releasePollInterval is how often a release checks on its service deployments
+const releasePollInterval = 2 * time.Second
+
+// ReleaseResponse represents a project release: the service deployments of a
+// project rolled out together, layer by layer
+type ReleaseResponse struct {
+	ID           string                `json:"id"`
+	ProjectID    string                `json:"project_id"`
+	Status       string                `json:"status"`
+	Layers       [][]string            `json:"layers"`
+	ErrorMessage string                `json:"error_message,omitempty"`
+	Deployments  []*DeploymentResponse `json:"deployments"`
+	CreatedAt    string                `json:"created_at"`
+	FinishedAt   string                `json:"finished_at,omitempty"`
+}
+
+// decodeDependsOn decodes the dependencies stored on a service
+func decodeDependsOn(encoded string) []string {
+	var names []string
+	if encoded != "" {
+		_ = json.Unmarshal([]byte(encoded), &names)
+	}
+	return names
+}
+
+// encodeDependsOn validates a service's dependencies against the other
+// services of its project and encodes them for storage
+func (s *ServiceService) encodeDependsOn(ctx context.Context, service *storage.Service, dependsOn []string) (string, error) {
+	if len(dependsOn) == 0 {
+		return "[]", nil
+	}
+
+	services, err := s.store.Services().ListByProjectID(ctx, service.ProjectID)
+	if err != nil {
+		return "", apperrors.NewInternalError("failed to list services", err)
+	}
+	byName := make(map[string]*storage.Service, len(services))
+	for _, svc := range services {
+		byName[svc.Name] = svc
+	}
+
+	seen := make(map[string]bool, len(dependsOn))
+	for _, name := range dependsOn {
+		dep, ok := byName[name]
+		switch {
+		case name == service.Name:
+			return "", apperrors.NewValidationError("a service cannot depend on itself", nil)
+		case !ok:
+			return "", apperrors.NewValidationError("depends_on references an unknown service", map[string]interface{}{
+				"service": name,
+			})
+		case service.Type == storage.ServiceTypeDatabase && dep.Type != storage.ServiceTypeDatabase:
+			return "", apperrors.NewValidationError("databases can only depend on other databases", map[string]interface{}{
+				"service": name,
+			})
+		case seen[name]:
+			return "", apperrors.NewValidationError("depends_on lists a service twice", map[string]interface{}{
+				"service": name,
+			})
+		}
+		seen[name] = true
+	}
+
+	data, err := json.Marshal(dependsOn)
+	if err != nil {
+		return "", apperrors.NewInternalError("failed to encode depends_on", err)
+	}
+
+	// Check the project graph with the new dependencies in place
+	candidate := *service
+	candidate.DependsOn = string(data)
+	graph := []*storage.Service{&candidate}
+	for _, svc := range services {
+		if svc.Name != service.Name {
+			graph = append(graph, svc)
+		}
+	}
+	if _, err := releasePlan(graph); err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// releasePlan orders a project's services into layers that can be deployed
+// in parallel. Databases come first; every other service is deployed after
+// the services it depends on. Dependencies on services that no longer exist
+// are ignored.
+func releasePlan(services []*storage.Service) ([][]*storage.Service, error) {
+	byName := make(map[string]*storage.Service, len(services))
+	for _, svc := range services {
+		byName[svc.Name] = svc
+	}
+
+	isDatabase := func(svc *storage.Service) bool { return svc.Type == storage.ServiceTypeDatabase }
+
+	levels := make(map[string]int, len(services))
+	visiting := make(map[string]bool)
+	appFloor := 0
+
+	var visit func(svc *storage.Service, path []string) (int, error)
+	visit = func(svc *storage.Service, path []string) (int, error) {
+		if level, ok := levels[svc.Name]; ok {
+			return level, nil
+		}
+		path = append(path, svc.Name)
+		if visiting[svc.Name] {
+			return 0, apperrors.NewValidationError("dependency cycle: "+strings.Join(path, " -> "), nil)
+		}
+		visiting[svc.Name] = true
+		defer delete(visiting, svc.Name)
+
+		level := 0
+		if !isDatabase(svc) {
+			level = appFloor
+		}
+		for _, name := range decodeDependsOn(svc.DependsOn) {
+			dep, ok := byName[name]
+			if !ok {
+				continue
+			}
+			depLevel, err := visit(dep, path)
+			if err != nil {
+				return 0, err
+			}
+			if depLevel+1 > level {
+				level = depLevel + 1
+			}
+		}
+		levels[svc.Name] = level
+		return level, nil
+	}
+
+	// Databases are placed first, so every other service can start after them
+	for _, svc := range services {
+		if !isDatabase(svc) {
+			continue
+		}
+		level, err := visit(svc, nil)
+		if err != nil {
+			return nil, err
+		}
+		if level+1 > appFloor {
+			appFloor = level + 1
+		}
+	}
+	for _, svc := range services {
+		if _, err := visit(svc, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var layers [][]*storage.Service
+	for _, svc := range services {
+		level := levels[svc.Name]
+		for len(layers) <= level {
+			layers = append(layers, nil)
+		}
+		layers[level] = append(layers[level], svc)
+	}
+
+	// Drop empty layers and keep each layer in a stable order
+	plan := layers[:0]
+	for _, layer := range layers {
+		if len(layer) == 0 {
+			continue
+		}
+		sort.Slice(layer, func(i, j int) bool { return layer[i].Name < layer[j].Name })
+		plan = append(plan, layer)
+	}
+	return plan, nil
+}
+
+// DeployProject deploys all services of a project in dependency order. The
+// services of a layer are deployed in parallel, and the next layer starts
+// once all of them are running and healthy.
+func (s *DeployService) DeployProject(ctx context.Context, projectID string, req DeployProjectRequest) (*ReleaseResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail fast during a freeze window; each service deployment audits the override
+	if req.FreezeOverride == "" {
+		window, err := s.activeFreezeWindow(ctx, project.ID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if window != nil {
+			return nil, apperrors.NewConflictError(fmt.Sprintf(
+				"deployments are frozen by window %q; supply freeze_override with a reason to deploy anyway", window.Name,
+			))
+		}
+	}
+
+	services, err := s.store.Services().ListByProjectID(ctx, project.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list services", err)
+	}
+	if len(services) == 0 {
+		return nil, apperrors.NewValidationError("project has no services to deploy", nil)
+	}
+
+	layers, err := releasePlan(services)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([][]string, len(layers))
+	for i, layer := range layers {
+		for _, svc := range layer {
+			names[i] = append(names[i], svc.Name)
+		}
+	}
+	planJSON, _ := json.Marshal(names)
+
+	release := &storage.Release{
+		ID:        uuid.New().String(),
+		ProjectID: project.ID,
+		Status:    ReleaseStatusDeploying,
+		Plan:      string(planJSON),
+	}
+	if err := s.store.Releases().Create(ctx, release); err != nil {
+		return nil, apperrors.NewInternalError("failed to create release record", err)
+	}
+
+	s.log.Info("starting project release",
+		"project_id", project.ID,
+		"release_id", release.ID,
+		"layers", names,
+	)
+	s.publishReleaseStatus(project.ID, release.ID, release.Status, "")
+
+	go s.executeRelease(context.Background(), project, release, layers, req)
+
+	return s.releaseResponse(ctx, release)
+}
+
+// executeRelease deploys the release layer by layer and stops at the first
+// layer with a failed service. A layer with deployments awaiting approval
+// blocks the release; approving one of them resumes it.
+func (s *DeployService) executeRelease(ctx context.Context, project *storage.Project, release *storage.Release, layers [][]*storage.Service, req DeployProjectRequest) {
+	for i := 0; i < len(layers); i++ {
+		layer := layers[i]
+		s.log.Info("deploying release layer",
+			"release_id", release.ID,
+			"layer", i+1,
+			"services", len(layer),
+		)
+
+		deployed, err := s.releaseDeployments(ctx, release.ID)
+		if err != nil {
+			s.finishRelease(ctx, release, ReleaseStatusFailed, "failed to list release deployments: "+err.Error())
+			return
+		}
+
+		errs := make([]error, len(layer))
+		var wg sync.WaitGroup
+		for j, svc := range layer {
+			wg.Add(1)
+			go func(j int, svc *storage.Service) {
+				defer wg.Done()
+				errs[j] = s.deployAndWait(ctx, project, svc, deployed[svc.ID], release.ID, req)
+			}(j, svc)
+		}
+		wg.Wait()
+
+		var failures []string
+		var awaiting []*storage.Service
+		for j, err := range errs {
+			switch {
+			case errors.Is(err, errAwaitingApproval):
+				awaiting = append(awaiting, layer[j])
+			case err != nil:
+				failures = append(failures, err.Error())
+			}
+		}
+		if len(failures) > 0 {
+			s.finishRelease(ctx, release, ReleaseStatusFailed,
+				fmt.Sprintf("layer %d failed: %s", i+1, strings.Join(failures, "; ")))
+			return
+		}
+		if len(awaiting) > 0 {
+			if s.blockRelease(ctx, release, awaiting) {
+				return
+			}
+			// The deployments were approved or rejected meanwhile; go over the layer again
+			i--
+		}
+	}
+
+	s.finishRelease(ctx, release, ReleaseStatusRunning, "")
+}
+
+// deployAndWait deploys a service as part of a release, unless the release
+// already holds a deployment of it, and waits until the deployment is running
+// or has failed. Deployments awaiting approval return errAwaitingApproval.
+func (s *DeployService) deployAndWait(ctx context.Context, project *storage.Project, service *storage.Service, existing *storage.Deployment, releaseID string, req DeployProjectRequest) error {
+	var deploymentID string
+	if existing != nil {
+		if existing.Status == string(deployer.StatusRunning) {
+			return nil
+		}
+		deploymentID = existing.ID
+	} else {
+		resp, err := s.DeployServiceByName(ctx, project.ID, service.Name, DeployServiceRequest{
+			FreezeOverride: req.FreezeOverride,
+			RequestedBy:    req.RequestedBy,
+			releaseID:      releaseID,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", service.Name, err)
+		}
+		deploymentID = resp.ID
+	}
+
+	ticker := time.NewTicker(releasePollInterval)
+	defer ticker.Stop()
+
+	for {
+		deployment, err := s.store.Deployments().GetByID(ctx, deploymentID)
+		if err != nil || deployment == nil {
+			return fmt.Errorf("%s: deployment record lost", service.Name)
+		}
+
+		switch deployer.DeploymentStatus(deployment.Status) {
+		case deployer.StatusRunning:
+			if req.onRunning != nil {
+				if err := req.onRunning(ctx, service); err != nil {
+					return fmt.Errorf("%s: %w", service.Name, err)
+				}
+			}
+			return nil
+		case deployer.StatusAwaitingApproval:
+			return fmt.Errorf("%s: %w", service.Name, errAwaitingApproval)
+		case deployer.StatusFailed, deployer.StatusRolledBack, deployer.StatusStopped, deployer.StatusRejected:
+			return fmt.Errorf("%s: %s", service.Name, deployment.ErrorMessage)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", service.Name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseDeployments returns the latest deployment of each service in a
+// release, keyed by service ID
+func (s *DeployService) releaseDeployments(ctx context.Context, releaseID string) (map[string]*storage.Deployment, error) {
+	deployments, err := s.store.Deployments().ListByReleaseID(ctx, releaseID)
+	if err != nil {
+		return nil, err
+	}
+	byService := make(map[string]*storage.Deployment, len(deployments))
+	for _, d := range deployments {
+		byService[d.ServiceID] = d
+	}
+	return byService, nil
+}
+
+// blockRelease marks a release blocked on the approval of some of its
+// services. It reports false when none of their deployments is still awaiting
+// approval, in which case the release goes on.
+func (s *DeployService) blockRelease(ctx context.Context, release *storage.Release, services []*storage.Service) bool {
+	s.releaseMu.Lock()
+	defer s.releaseMu.Unlock()
+
+	deployed, err := s.releaseDeployments(ctx, release.ID)
+	var names []string
+	for _, svc := range services {
+		if d := deployed[svc.ID]; err != nil || d != nil && d.Status == string(deployer.StatusAwaitingApproval) {
+			names = append(names, svc.Name)
+		}
+	}
+	if len(names) == 0 {
+		return false
+	}
+
+	release.Status = ReleaseStatusBlocked
+	release.ErrorMessage = "waiting for approval of " + strings.Join(names, ", ")
+	_ = s.store.Releases().Update(ctx, release)
+	s.publishReleaseStatus(release.ProjectID, release.ID, release.Status, release.ErrorMessage)
+
+	s.log.Info("project release blocked", "release_id", release.ID, "awaiting", names)
+	return true
+}
+
+// resumeRelease carries on with a blocked release once one of its deployments
+// has been approved
+func (s *DeployService) resumeRelease(ctx context.Context, releaseID string, req DeployProjectRequest) {
+	s.releaseMu.Lock()
+	release, err := s.store.Releases().GetByID(ctx, releaseID)
+	if err != nil || release == nil || release.Status != ReleaseStatusBlocked {
+		s.releaseMu.Unlock()
+		return
+	}
+	project, err := s.store.Apps().GetByID(ctx, release.ProjectID)
+	if err != nil || project == nil {
+		s.releaseMu.Unlock()
+		return
+	}
+	layers, err := s.releaseLayers(ctx, release)
+	if err != nil {
+		s.releaseMu.Unlock()
+		s.finishRelease(ctx, release, ReleaseStatusFailed, err.Error())
+		return
+	}
+
+	release.Status = ReleaseStatusDeploying
+	release.ErrorMessage = ""
+	_ = s.store.Releases().Update(ctx, release)
+	s.releaseMu.Unlock()
+
+	s.publishReleaseStatus(release.ProjectID, release.ID, release.Status, "")
+	s.log.Info("resuming project release", "release_id", release.ID)
+
+	s.executeRelease(ctx, project, release, layers, req)
+}
+
+// failBlockedRelease fails a release blocked on a deployment that was rejected
+func (s *DeployService) failBlockedRelease(ctx context.Context, releaseID, errorMessage string) {
+	s.releaseMu.Lock()
+	defer s.releaseMu.Unlock()
+
+	release, err := s.store.Releases().GetByID(ctx, releaseID)
+	if err != nil || release == nil || release.Status != ReleaseStatusBlocked {
+		return
+	}
+	s.finishRelease(ctx, release, ReleaseStatusFailed, errorMessage)
+}
+
+// releaseLayers returns the services of a release's plan, layer by layer.
+// Services deleted since the release started are left out.
+func (s *DeployService) releaseLayers(ctx context.Context, release *storage.Release) ([][]*storage.Service, error) {
+	var names [][]string
+	if err := json.Unmarshal([]byte(release.Plan), &names); err != nil {
+		return nil, fmt.Errorf("invalid release plan: %w", err)
+	}
+	services, err := s.store.Services().ListByProjectID(ctx, release.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	byName := make(map[string]*storage.Service, len(services))
+	for _, svc := range services {
+		byName[svc.Name] = svc
+	}
+
+	var layers [][]*storage.Service
+	for _, layerNames := range names {
+		var layer []*storage.Service
+		for _, name := range layerNames {
+			if svc := byName[name]; svc != nil {
+				layer = append(layer, svc)
+			}
+		}
+		if len(layer) > 0 {
+			layers = append(layers, layer)
+		}
+	}
+	return layers, nil
+}
+
+func (s *DeployService) finishRelease(ctx context.Context, release *storage.Release, status, errorMessage string) {
+	finishedAt := time.Now()
+	release.Status = status
+	release.ErrorMessage = errorMessage
+	release.FinishedAt = &finishedAt
+	_ = s.store.Releases().Update(ctx, release)
+	s.publishReleaseStatus(release.ProjectID, release.ID, release.Status, release.ErrorMessage)
+
+	s.log.Info("project release finished",
+		"release_id", release.ID,
+		"status", status,
+		"error", errorMessage,
+	)
+}
+
+// GetRelease returns a project release with its service deployments
+func (s *DeployService) GetRelease(ctx context.Context, projectID, releaseID string) (*ReleaseResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	release, err := s.store.Releases().GetByID(ctx, releaseID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get release", err)
+	}
+	if release == nil || release.ProjectID != project.ID {
+		return nil, apperrors.NewNotFoundError("release", releaseID)
+	}
+	return s.releaseResponse(ctx, release)
+}
+
+// ListReleases returns the releases of a project, newest first
+func (s *DeployService) ListReleases(ctx context.Context, projectID string) ([]*ReleaseResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := s.store.Releases().ListByProjectID(ctx, project.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list releases", err)
+	}
+
+	responses := make([]*ReleaseResponse, 0, len(releases))
+	for _, release := range releases {
+		resp, err := s.releaseResponse(ctx, release)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+func (s *DeployService) releaseResponse(ctx context.Context, release *storage.Release) (*ReleaseResponse, error) {
+	deployments, err := s.store.Deployments().ListByReleaseID(ctx, release.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list release deployments", err)
+	}
+
+	resp := &ReleaseResponse{
+		ID:           release.ID,
+		ProjectID:    release.ProjectID,
+		Status:       release.Status,
+		ErrorMessage: release.ErrorMessage,
+		Deployments:  make([]*DeploymentResponse, len(deployments)),
+		CreatedAt:    release.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	_ = json.Unmarshal([]byte(release.Plan), &resp.Layers)
+	if release.FinishedAt != nil {
+		resp.FinishedAt = release.FinishedAt.Format("2006-01-02T15:04:05Z")
+	}
+
+	for i, d := range deployments {
+		resp.Deployments[i] = &DeploymentResponse{
+			ID:           d.ID,
+			AppID:        d.AppID,
+			ServiceID:    d.ServiceID,
+			ReleaseID:    d.ReleaseID,
+			Version:      d.Version,
+			Slot:         d.Slot,
+			Status:       d.Status,
+			ErrorMessage: d.ErrorMessage,
+			CreatedAt:    d.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		}
+		if service, err := s.store.Services().GetByID(ctx, d.ServiceID); err == nil && service != nil {
+			resp.Deployments[i].ServiceName = service.Name
+		}
+		if d.FinishedAt != nil {
+			resp.Deployments[i].FinishedAt = d.FinishedAt.Format("2006-01-02T15:04:05Z")
+		}
+	}
+	return resp, nil
+}
+
+// publishReleaseStatus publishes a release status change event
+func (s *DeployService) publishReleaseStatus(projectID, releaseID, status, errorMessage string) {
+	if s.eventBus == nil {
+		return
+	}
+	s.eventBus.PublishReleaseStatus(projectID, releaseID, status, errorMessage)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// PromoteRequest represents a request to promote the images running in one
+// environment of a project to another
+type PromoteRequest struct {
+	From     string   `json:"from" binding:"required"`
+	To       string   `json:"to" binding:"required"`
+	Services []string `json:"services"` // defaults to every deployed service of the source environment
+
+	// FreezeOverride is the reason for promoting during a freeze window; it is audited
+	FreezeOverride string `json:"freeze_override,omitempty"`
+
+	// RequestedBy is the user requesting the promotion
+	RequestedBy string `json:"-"`
+}
+
+// PromotedImage is the image of a deployment in another environment. It is
+// deployed as-is, without rebuilding.
+type PromotedImage struct {
+	Image            string `json:"image"` // local reference, pinned to the promoted image
+	Port             int    `json:"port"`
+	FromEnvironment  string `json:"from_environment"`
+	FromDeploymentID string `json:"from_deployment_id"`
+
+	// Provenance of git-built images, kept for deployment diffs
+	GitURL    string `json:"git_url,omitempty"`
+	GitBranch string `json:"git_branch,omitempty"`
+	GitCommit string `json:"git_commit,omitempty"`
+}
+
+// sourceConfig returns the source of a deployment running the promoted image
+func (p *PromotedImage) sourceConfig(port int) deployer.SourceConfig {
+	if p.Port > 0 {
+		port = p.Port
+	}
+	return deployer.SourceConfig{
+		Image:      p.Image,
+		PullPolicy: deployer.PullNever,
+		Port:       port,
+		GitURL:     p.GitURL,
+		GitBranch:  p.GitBranch,
+		GitCommit:  p.GitCommit,
+	}
+}
+
+// PromoteEnvironment deploys the images running in one environment of a
+// project to the services of the same name in another environment
+func (s *DeployService) PromoteEnvironment(ctx context.Context, projectID string, req PromoteRequest) ([]*DeploymentResponse, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rootProject(ctx, s.store, project)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.From == req.To {
+		return nil, apperrors.NewValidationError("source and target environments must differ", nil)
+	}
+	from, err := findEnvironment(ctx, s.store, root, req.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := findEnvironment(ctx, s.store, root, req.To)
+	if err != nil {
+		return nil, err
+	}
+
+	names := req.Services
+	if len(names) == 0 {
+		services, err := s.store.Services().ListByProjectID(ctx, from.ID)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to list services", err)
+		}
+		for _, svc := range services {
+			if svc.Type != storage.ServiceTypeDatabase {
+				names = append(names, svc.Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, apperrors.NewValidationError("environment has no services to promote", map[string]interface{}{
+			"environment": req.From,
+		})
+	}
+
+	// Resolve every image before deploying anything, so a missing service or
+	// deployment does not leave the target half promoted
+	promotions := make([]*PromotedImage, 0, len(names))
+	for _, name := range names {
+		promotion, err := s.promotedImage(ctx, from, to, name)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, promotion)
+	}
+
+	var result []*DeploymentResponse
+	for i, name := range names {
+		resp, err := s.DeployServiceByName(ctx, to.ID, name, DeployServiceRequest{
+			FreezeOverride: req.FreezeOverride,
+			RequestedBy:    req.RequestedBy,
+			Promotion:      promotions[i],
+		})
+		if err != nil {
+			return result, err
+		}
+		resp.ServiceName = name
+		result = append(result, resp)
+	}
+
+	s.log.Info("environment promoted",
+		"project_id", root.ID,
+		"from", req.From,
+		"to", req.To,
+		"services", len(result),
+	)
+
+	return result, nil
+}
+
+// promotedImage pins the image running for a service of the source
+// environment so it can be deployed to the target environment
+func (s *DeployService) promotedImage(ctx context.Context, from, to *storage.Project, name string) (*PromotedImage, error) {
+	service, err := s.store.Services().GetByProjectIDAndName(ctx, from.ID, name)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get service", err)
+	}
+	if service == nil {
+		return nil, apperrors.NewNotFoundError("service", name)
+	}
+	if service.Type == storage.ServiceTypeDatabase {
+		return nil, apperrors.NewValidationError("database services cannot be promoted", map[string]interface{}{
+			"service": name,
+		})
+	}
+
+	target, err := s.store.Services().GetByProjectIDAndName(ctx, to.ID, name)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get service", err)
+	}
+	if target == nil {
+		return nil, apperrors.NewNotFoundError("service", to.EnvironmentName+"/"+name)
+	}
+
+	deployments, err := s.store.Deployments().ListByServiceID(ctx, service.ID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list deployments", err)
+	}
+	var live *storage.Deployment
+	for _, d := range deployments {
+		if d.Status == string(deployer.StatusRunning) {
+			live = d
+			break
+		}
+	}
+	if live == nil {
+		return nil, apperrors.NewValidationError("service has no running deployment to promote", map[string]interface{}{
+			"service":     name,
+			"environment": from.EnvironmentName,
+		})
+	}
+
+	var source deployer.SourceConfig
+	_ = json.Unmarshal([]byte(live.SourceConfig), &source)
+	if source.ImageDigest == "" {
+		return nil, apperrors.NewValidationError("deployment did not record its image", map[string]interface{}{
+			"deployment_id": live.ID,
+		})
+	}
+
+	promotion := &PromotedImage{
+		Image:            source.ImageDigest,
+		Port:             source.Port,
+		FromEnvironment:  from.EnvironmentName,
+		FromDeploymentID: live.ID,
+		GitURL:           source.GitURL,
+		GitBranch:        source.GitBranch,
+		GitCommit:        source.GitCommit,
+	}
+
+	// Registry digests already pin the image. Built images are only known by
+	// ID, and their tags move with every build, so give them a tag of their own.
+	if !strings.Contains(source.ImageDigest, "@") {
+		img, err := s.runtime.InspectImage(ctx, source.ImageDigest)
+		if err != nil {
+			return nil, apperrors.NewValidationError("image of the running deployment is no longer available", map[string]interface{}{
+				"deployment_id": live.ID,
+				"image":         source.ImageDigest,
+			})
+		}
+		tag := fmt.Sprintf("nebula/%s-%s:%s", to.Name, name, live.Version)
+		if err := s.runtime.TagImage(ctx, img.ID, tag); err != nil {
+			return nil, apperrors.NewInternalError("failed to tag promoted image", err)
+		}
+		promotion.Image = tag
+	}
+
+	return promotion, nil
+}
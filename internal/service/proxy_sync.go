@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// desiredRoutes renders the proxy routes of every domain from the database,
+// and of the hosts TLS allowlists route to a service. Domains point at their
+// service's running deployment, split with the canary while a rollout is in
+// progress; domains without a running deployment have no route.
+func (s *DeployService) desiredRoutes(ctx context.Context) ([]proxy.Route, error) {
+	domains, err := s.store.Domains().List(ctx)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list domains", err)
+	}
+	allowlists, err := s.store.TLSAllowlists().List(ctx)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to list tls allowlists", err)
+	}
+	for _, allowlist := range allowlists {
+		domains = append(domains, allowlistDomains(allowlist)...)
+	}
+
+	var routes []proxy.Route
+	for _, domain := range domains {
+		var deployments []*storage.Deployment
+		if domain.ServiceID != "" {
+			deployments, err = s.store.Deployments().ListByServiceID(ctx, domain.ServiceID)
+		} else {
+			deployments, err = s.store.Deployments().ListByAppID(ctx, domain.ProjectID)
+		}
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to list deployments", err)
+		}
+		if route, ok := s.deploymentsRoute(ctx, domain, deployments); ok {
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+// deploymentsRoute renders the route of a domain to the deployments of its
+// service, newest first
+func (s *DeployService) deploymentsRoute(ctx context.Context, domain *storage.Domain, deployments []*storage.Deployment) (proxy.Route, bool) {
+	var stable, canary *storage.Deployment
+	for _, d := range deployments {
+		switch d.Status {
+		case string(deployer.StatusRunning):
+			if stable == nil {
+				stable = d
+			}
+		case string(deployer.StatusCanary):
+			if canary == nil {
+				canary = d
+			}
+		}
+	}
+	if stable == nil {
+		return proxy.Route{}, false
+	}
+	upstream := s.deploymentUpstream(ctx, stable.ID)
+	if upstream == nil {
+		return proxy.Route{}, false
+	}
+
+	route := domainRoute(domain)
+	route.ActiveSlot = proxy.Slot(stable.Slot)
+	if route.ActiveSlot == proxy.SlotBlue {
+		route.BlueTarget = upstream
+	} else {
+		route.GreenTarget = upstream
+	}
+
+	if canary != nil && canary.Slot != stable.Slot && canary.TrafficWeight > 0 && canary.TrafficWeight < 100 {
+		if canaryUpstream := s.deploymentUpstream(ctx, canary.ID); canaryUpstream != nil {
+			route.FailureWindow = s.canaryFailureWindow(domain.ServiceID)
+			if route.ActiveSlot == proxy.SlotBlue {
+				route.GreenTarget = canaryUpstream
+				route.BlueWeight, route.GreenWeight = 100-canary.TrafficWeight, canary.TrafficWeight
+			} else {
+				route.BlueTarget = canaryUpstream
+				route.BlueWeight, route.GreenWeight = canary.TrafficWeight, 100-canary.TrafficWeight
+			}
+		}
+	}
+	return route, true
+}
+
+// legacyRoutesAdoptedKey is the setting recording that the proxy's legacy
+// routes were adopted
+const legacyRoutesAdoptedKey = "proxy_legacy_routes_adopted"
+
+// AdoptLegacyRoutes has the proxy take over the routes Nebula added before it
+// tagged its routes, once. Only routes serving a domain in the database are
+// adopted, so routes configured by hand survive.
+func (s *DeployService) AdoptLegacyRoutes(ctx context.Context) error {
+	adopter, ok := s.proxyManager.(proxy.LegacyRouteAdopter)
+	if !ok {
+		return nil
+	}
+	done, err := s.store.Settings().Get(ctx, legacyRoutesAdoptedKey)
+	if err != nil {
+		return apperrors.NewInternalError("failed to get setting", err)
+	}
+	if done != "" {
+		return nil
+	}
+
+	domains, err := s.store.Domains().List(ctx)
+	if err != nil {
+		return apperrors.NewInternalError("failed to list domains", err)
+	}
+	hosts := make([]string, len(domains))
+	for i, domain := range domains {
+		hosts[i] = domain.Domain
+	}
+
+	adopted, err := adopter.AdoptLegacyRoutes(ctx, hosts)
+	if err != nil {
+		return apperrors.NewInternalError("failed to adopt legacy proxy routes", err)
+	}
+	if err := s.store.Settings().Set(ctx, legacyRoutesAdoptedKey, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return apperrors.NewInternalError("failed to save setting", err)
+	}
+
+	s.log.Info("legacy proxy routes adopted", "routes", adopted)
+	return nil
+}
+
+// routeSyncer returns the proxy as a RouteSyncer, if it is one
+func (s *DeployService) routeSyncer() (proxy.RouteSyncer, error) {
+	syncer, ok := s.proxyManager.(proxy.RouteSyncer)
+	if !ok {
+		return nil, apperrors.NewValidationError("the configured proxy does not support route sync", nil)
+	}
+	return syncer, nil
+}
+
+// DiffRoutes reports the changes a route sync would make to the proxy
+func (s *DeployService) DiffRoutes(ctx context.Context) ([]proxy.RouteChange, error) {
+	syncer, err := s.routeSyncer()
+	if err != nil {
+		return nil, err
+	}
+	routes, err := s.desiredRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := syncer.DiffRoutes(ctx, routes)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to diff proxy routes", err)
+	}
+	return changes, nil
+}
+
+// SyncRoutes replaces the proxy's routes with the ones rendered from the
+// database and returns the changes it made
+func (s *DeployService) SyncRoutes(ctx context.Context) ([]proxy.RouteChange, error) {
+	syncer, err := s.routeSyncer()
+	if err != nil {
+		return nil, err
+	}
+
+	s.routeMu.Lock()
+	defer s.routeMu.Unlock()
+
+	routes, err := s.desiredRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := syncer.DiffRoutes(ctx, routes)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to diff proxy routes", err)
+	}
+	if err := syncer.SyncRoutes(ctx, routes); err != nil {
+		return nil, apperrors.NewInternalError("failed to sync proxy routes", err)
+	}
+
+	s.log.Info("proxy routes synced", "routes", len(routes), "changes", len(changes))
+	return changes, nil
+}
@@ -3,64 +3,101 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"regexp"
+	"sort"
 
 	"github.com/google/uuid"
 
+	"github.com/victalejo/nebula/internal/core/deployer"
 	apperrors "github.com/victalejo/nebula/internal/core/errors"
 	"github.com/victalejo/nebula/internal/core/logger"
+	"github.com/victalejo/nebula/internal/core/secrets"
 	"github.com/victalejo/nebula/internal/core/storage"
 )
 
+// buildNamePattern matches valid build argument and build secret names
+var buildNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // ServiceService handles service business logic
 type ServiceService struct {
-	store storage.Store
-	log   logger.Logger
+	store     storage.Store
+	deployers deployer.Registry
+	cipher    *secrets.Cipher
+	log       logger.Logger
 }
 
 // NewServiceService creates a new service service
-func NewServiceService(store storage.Store, log logger.Logger) *ServiceService {
+func NewServiceService(store storage.Store, deployers deployer.Registry, cipher *secrets.Cipher, log logger.Logger) *ServiceService {
 	return &ServiceService{
-		store: store,
-		log:   log,
+		store:     store,
+		deployers: deployers,
+		cipher:    cipher,
+		log:       log,
 	}
 }
 
 // CreateServiceRequest represents a request to create a service
 type CreateServiceRequest struct {
-	Name            string            `json:"name" binding:"required"`
-	Type            string            `json:"type"`             // web, worker, cron, database
-	Builder         string            `json:"builder"`          // nixpacks, railpacks, dockerfile, docker_image, buildpacks
-	GitRepo         string            `json:"git_repo"`         // override project's repo
-	GitBranch       string            `json:"git_branch"`       // override project's branch
-	Subdirectory    string            `json:"subdirectory"`     // for monorepos
-	DockerImage     string            `json:"docker_image"`     // if builder=docker_image
-	DatabaseType    string            `json:"database_type"`    // postgres, mysql, redis, mongodb
-	DatabaseVersion string            `json:"database_version"` // version for database
-	Port            int               `json:"port"`
-	Command         string            `json:"command"`
-	Environment     map[string]string `json:"environment"`
+	Name             string               `json:"name" binding:"required"`
+	Type             string               `json:"type"`               // web, worker, cron, database
+	Builder          string               `json:"builder"`            // nixpacks, railpacks, dockerfile, docker_image, buildpacks
+	GitRepo          string               `json:"git_repo"`           // override project's repo
+	GitBranch        string               `json:"git_branch"`         // override project's branch
+	Subdirectory     string               `json:"subdirectory"`       // for monorepos
+	DockerImage      string               `json:"docker_image"`       // if builder=docker_image
+	GitCredentialID  string               `json:"git_credential_id"`  // credential for private repos
+	GitSubmodules    bool                 `json:"git_submodules"`     // clone submodules recursively
+	GitLFS           bool                 `json:"git_lfs"`            // fetch Git LFS objects
+	GitDepth         *int                 `json:"git_depth"`          // fetch depth (default 1, 0 = full history)
+	BuildArgs        map[string]string    `json:"build_args"`         // values may reference env vars: ${API_URL}
+	BuildSecrets     map[string]string    `json:"build_secrets"`      // mounted as BuildKit secrets
+	HealthCheck      *HealthCheckSettings `json:"health_check"`       // defaults to a TCP check on the port
+	DrainSeconds     *int                 `json:"drain_seconds"`      // wait before stopping the old slot (default 10)
+	StopGraceSeconds *int                 `json:"stop_grace_seconds"` // SIGTERM grace period (default 30)
+	KeepWarmMinutes  int                  `json:"keep_warm_minutes"`  // keep the old slot running for rollback
+	WatchSeconds     *int                 `json:"watch_seconds"`      // roll back if the new slot crashes within this window (default 120, 0 = off)
+	DependsOn        []string             `json:"depends_on"`         // services deployed before this one in a project release
+	RequiresApproval bool                 `json:"requires_approval"`  // hold deployments in awaiting_approval until approved
+	DatabaseType     string               `json:"database_type"`      // postgres, mysql, redis, mongodb
+	DatabaseVersion  string               `json:"database_version"`   // version for database
+	Port             int                  `json:"port"`
+	Command          string               `json:"command"`
+	Environment      map[string]string    `json:"environment"`
 }
 
 // ServiceResponse represents a service response
 type ServiceResponse struct {
-	ID              string            `json:"id"`
-	ProjectID       string            `json:"project_id"`
-	Name            string            `json:"name"`
-	Type            string            `json:"type"`
-	Builder         string            `json:"builder"`
-	GitRepo         string            `json:"git_repo,omitempty"`
-	GitBranch       string            `json:"git_branch,omitempty"`
-	Subdirectory    string            `json:"subdirectory,omitempty"`
-	DockerImage     string            `json:"docker_image,omitempty"`
-	DatabaseType    string            `json:"database_type,omitempty"`
-	DatabaseVersion string            `json:"database_version,omitempty"`
+	ID               string               `json:"id"`
+	ProjectID        string               `json:"project_id"`
+	Name             string               `json:"name"`
+	Type             string               `json:"type"`
+	Builder          string               `json:"builder"`
+	GitRepo          string               `json:"git_repo,omitempty"`
+	GitBranch        string               `json:"git_branch,omitempty"`
+	Subdirectory     string               `json:"subdirectory,omitempty"`
+	DockerImage      string               `json:"docker_image,omitempty"`
+	GitCredentialID  string               `json:"git_credential_id,omitempty"`
+	GitSubmodules    bool                 `json:"git_submodules,omitempty"`
+	GitLFS           bool                 `json:"git_lfs,omitempty"`
+	GitDepth         int                  `json:"git_depth"`
+	BuildArgs        map[string]string    `json:"build_args,omitempty"`
+	BuildSecrets     []string             `json:"build_secrets,omitempty"` // names only
+	HealthCheck      *HealthCheckSettings `json:"health_check,omitempty"`
+	DrainSeconds     int                  `json:"drain_seconds"`
+	StopGraceSeconds int                  `json:"stop_grace_seconds"`
+	KeepWarmMinutes  int                  `json:"keep_warm_minutes"`
+	WatchSeconds     int                  `json:"watch_seconds"`
+	DependsOn        []string             `json:"depends_on,omitempty"`
+	RequiresApproval bool                 `json:"requires_approval"`
+	DatabaseType     string               `json:"database_type,omitempty"`
+	DatabaseVersion  string               `json:"database_version,omitempty"`
 	// Database connection info (only for type=database)
-	DatabaseHost     string `json:"database_host,omitempty"`
-	DatabasePort     int    `json:"database_port,omitempty"`
-	DatabaseUser     string `json:"database_user,omitempty"`
-	DatabasePassword string `json:"database_password,omitempty"`
-	DatabaseName     string `json:"database_name,omitempty"`
-	DatabaseExposed  bool   `json:"database_exposed,omitempty"`
+	DatabaseHost     string            `json:"database_host,omitempty"`
+	DatabasePort     int               `json:"database_port,omitempty"`
+	DatabaseUser     string            `json:"database_user,omitempty"`
+	DatabasePassword string            `json:"database_password,omitempty"`
+	DatabaseName     string            `json:"database_name,omitempty"`
+	DatabaseExposed  bool              `json:"database_exposed,omitempty"`
 	Port             int               `json:"port"`
 	Command          string            `json:"command,omitempty"`
 	Environment      map[string]string `json:"environment"`
@@ -98,6 +135,12 @@ func (s *ServiceService) Create(ctx context.Context, projectID string, req Creat
 		return nil, apperrors.NewConflictError("service with this name already exists in project")
 	}
 
+	if req.GitCredentialID != "" {
+		if err := s.validateGitCredential(ctx, project.ID, req.GitCredentialID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Set defaults
 	serviceType := storage.ServiceType(req.Type)
 	if serviceType == "" {
@@ -113,16 +156,53 @@ func (s *ServiceService) Create(ctx context.Context, projectID string, req Creat
 		}
 	}
 
+	if req.Port < 0 || req.Port > 65535 {
+		return nil, apperrors.NewValidationError("port must be between 1 and 65535, or 0 to detect it from the build", nil)
+	}
+
+	// Git-built services may leave the port unset; it is detected from the build
 	port := req.Port
-	if port == 0 {
+	if port == 0 && (builder == storage.BuilderDockerImage || serviceType == storage.ServiceTypeDatabase) {
 		port = 8080
 	}
 
+	gitDepth := 1
+	if req.GitDepth != nil {
+		if *req.GitDepth < 0 {
+			return nil, apperrors.NewValidationError("git_depth must be 0 (full history) or greater", nil)
+		}
+		gitDepth = *req.GitDepth
+	}
+
 	subdirectory := req.Subdirectory
 	if subdirectory == "" {
 		subdirectory = "."
 	}
 
+	buildArgsJSON, buildSecrets, err := s.encodeBuildConfig(req.BuildArgs, req.BuildSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	healthCheck, err := encodeHealthCheck(req.HealthCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	drainSeconds, stopGraceSeconds, watchSeconds := 10, 30, 120
+	if req.DrainSeconds != nil {
+		drainSeconds = *req.DrainSeconds
+	}
+	if req.StopGraceSeconds != nil {
+		stopGraceSeconds = *req.StopGraceSeconds
+	}
+	if req.WatchSeconds != nil {
+		watchSeconds = *req.WatchSeconds
+	}
+	if err := validateRetirement(drainSeconds, stopGraceSeconds, req.KeepWarmMinutes, watchSeconds); err != nil {
+		return nil, err
+	}
+
 	// Encode environment
 	envJSON := "{}"
 	if len(req.Environment) > 0 {
@@ -134,21 +214,37 @@ func (s *ServiceService) Create(ctx context.Context, projectID string, req Creat
 	}
 
 	service := &storage.Service{
-		ID:              uuid.New().String(),
-		ProjectID:       project.ID,
-		Name:            req.Name,
-		Type:            serviceType,
-		Builder:         builder,
-		GitRepo:         req.GitRepo,
-		GitBranch:       req.GitBranch,
-		Subdirectory:    subdirectory,
-		DockerImage:     req.DockerImage,
-		DatabaseType:    req.DatabaseType,
-		DatabaseVersion: req.DatabaseVersion,
-		Port:            port,
-		Command:         req.Command,
-		Environment:     envJSON,
-		Status:          "stopped",
+		ID:               uuid.New().String(),
+		ProjectID:        project.ID,
+		Name:             req.Name,
+		Type:             serviceType,
+		Builder:          builder,
+		GitRepo:          req.GitRepo,
+		GitBranch:        req.GitBranch,
+		Subdirectory:     subdirectory,
+		DockerImage:      req.DockerImage,
+		GitCredentialID:  req.GitCredentialID,
+		GitSubmodules:    req.GitSubmodules,
+		GitLFS:           req.GitLFS,
+		GitDepth:         gitDepth,
+		BuildArgs:        buildArgsJSON,
+		BuildSecrets:     buildSecrets,
+		HealthCheck:      healthCheck,
+		DrainSeconds:     drainSeconds,
+		StopGraceSeconds: stopGraceSeconds,
+		KeepWarmMinutes:  req.KeepWarmMinutes,
+		WatchSeconds:     watchSeconds,
+		RequiresApproval: req.RequiresApproval,
+		DatabaseType:     req.DatabaseType,
+		DatabaseVersion:  req.DatabaseVersion,
+		Port:             port,
+		Command:          req.Command,
+		Environment:      envJSON,
+		Status:           "stopped",
+	}
+
+	if service.DependsOn, err = s.encodeDependsOn(ctx, service, req.DependsOn); err != nil {
+		return nil, err
 	}
 
 	if err := s.store.Services().Create(ctx, service); err != nil {
@@ -215,15 +311,28 @@ func (s *ServiceService) List(ctx context.Context, projectID string) ([]*Service
 
 // UpdateServiceRequest represents a request to update a service
 type UpdateServiceRequest struct {
-	Builder         *string           `json:"builder"`
-	GitRepo         *string           `json:"git_repo"`
-	GitBranch       *string           `json:"git_branch"`
-	Subdirectory    *string           `json:"subdirectory"`
-	DockerImage     *string           `json:"docker_image"`
-	DatabaseVersion *string           `json:"database_version"`
-	Port            *int              `json:"port"`
-	Command         *string           `json:"command"`
-	Environment     map[string]string `json:"environment"`
+	Builder          *string              `json:"builder"`
+	GitRepo          *string              `json:"git_repo"`
+	GitBranch        *string              `json:"git_branch"`
+	Subdirectory     *string              `json:"subdirectory"`
+	DockerImage      *string              `json:"docker_image"`
+	GitCredentialID  *string              `json:"git_credential_id"`
+	GitSubmodules    *bool                `json:"git_submodules"`
+	GitLFS           *bool                `json:"git_lfs"`
+	GitDepth         *int                 `json:"git_depth"`
+	BuildArgs        map[string]string    `json:"build_args"`    // replaces all build args
+	BuildSecrets     map[string]string    `json:"build_secrets"` // replaces all build secrets
+	HealthCheck      *HealthCheckSettings `json:"health_check"`  // replaces the health check; {} restores the default
+	DrainSeconds     *int                 `json:"drain_seconds"`
+	StopGraceSeconds *int                 `json:"stop_grace_seconds"`
+	KeepWarmMinutes  *int                 `json:"keep_warm_minutes"`
+	WatchSeconds     *int                 `json:"watch_seconds"`
+	DependsOn        []string             `json:"depends_on"` // replaces all dependencies
+	RequiresApproval *bool                `json:"requires_approval"`
+	DatabaseVersion  *string              `json:"database_version"`
+	Port             *int                 `json:"port"`
+	Command          *string              `json:"command"`
+	Environment      map[string]string    `json:"environment"`
 }
 
 // Update updates a service
@@ -258,10 +367,75 @@ func (s *ServiceService) Update(ctx context.Context, projectID, serviceName stri
 	if req.DockerImage != nil {
 		service.DockerImage = *req.DockerImage
 	}
+	if req.GitCredentialID != nil {
+		if *req.GitCredentialID != "" {
+			if err := s.validateGitCredential(ctx, project.ID, *req.GitCredentialID); err != nil {
+				return nil, err
+			}
+		}
+		service.GitCredentialID = *req.GitCredentialID
+	}
+	if req.GitSubmodules != nil {
+		service.GitSubmodules = *req.GitSubmodules
+	}
+	if req.GitLFS != nil {
+		service.GitLFS = *req.GitLFS
+	}
+	if req.GitDepth != nil {
+		if *req.GitDepth < 0 {
+			return nil, apperrors.NewValidationError("git_depth must be 0 (full history) or greater", nil)
+		}
+		service.GitDepth = *req.GitDepth
+	}
+	if req.BuildArgs != nil || req.BuildSecrets != nil {
+		buildArgsJSON, buildSecrets, err := s.encodeBuildConfig(req.BuildArgs, req.BuildSecrets)
+		if err != nil {
+			return nil, err
+		}
+		if req.BuildArgs != nil {
+			service.BuildArgs = buildArgsJSON
+		}
+		if req.BuildSecrets != nil {
+			service.BuildSecrets = buildSecrets
+		}
+	}
+	if req.DrainSeconds != nil {
+		service.DrainSeconds = *req.DrainSeconds
+	}
+	if req.StopGraceSeconds != nil {
+		service.StopGraceSeconds = *req.StopGraceSeconds
+	}
+	if req.KeepWarmMinutes != nil {
+		service.KeepWarmMinutes = *req.KeepWarmMinutes
+	}
+	if req.WatchSeconds != nil {
+		service.WatchSeconds = *req.WatchSeconds
+	}
+	if err := validateRetirement(service.DrainSeconds, service.StopGraceSeconds, service.KeepWarmMinutes, service.WatchSeconds); err != nil {
+		return nil, err
+	}
+	if req.HealthCheck != nil {
+		healthCheck, err := encodeHealthCheck(req.HealthCheck)
+		if err != nil {
+			return nil, err
+		}
+		service.HealthCheck = healthCheck
+	}
+	if req.DependsOn != nil {
+		if service.DependsOn, err = s.encodeDependsOn(ctx, service, req.DependsOn); err != nil {
+			return nil, err
+		}
+	}
+	if req.RequiresApproval != nil {
+		service.RequiresApproval = *req.RequiresApproval
+	}
 	if req.DatabaseVersion != nil {
 		service.DatabaseVersion = *req.DatabaseVersion
 	}
 	if req.Port != nil {
+		if *req.Port < 0 || *req.Port > 65535 {
+			return nil, apperrors.NewValidationError("port must be between 1 and 65535, or 0 to detect it from the build", nil)
+		}
 		service.Port = *req.Port
 	}
 	if req.Command != nil {
@@ -344,6 +518,125 @@ func (s *ServiceService) UpdateStatus(ctx context.Context, serviceID, status str
 	return nil
 }
 
+// ClearBuildCache discards the persistent build caches of a service. The
+// caches kept for the current key are removed and the key is rotated, so the
+// next build ignores cache mounts and previous images; BuildKit garbage
+// collects the orphaned cache mounts.
+func (s *ServiceService) ClearBuildCache(ctx context.Context, projectID, serviceName string) error {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	service, err := s.store.Services().GetByProjectIDAndName(ctx, project.ID, serviceName)
+	if err != nil {
+		return apperrors.NewInternalError("failed to get service", err)
+	}
+	if service == nil {
+		return apperrors.NewNotFoundError("service", serviceName)
+	}
+
+	// Services created before cache keys existed use their ID as the key
+	cacheKey := service.BuildCacheKey
+	if cacheKey == "" {
+		cacheKey = service.ID
+	}
+	if d, err := s.deployers.Get(deployer.ModeGit); err == nil {
+		if cleaner, ok := d.(deployer.BuildCacheCleaner); ok {
+			if err := cleaner.ClearBuildCache(ctx, cacheKey); err != nil {
+				return apperrors.NewInternalError("failed to clear build cache", err)
+			}
+		}
+	}
+
+	service.BuildCacheKey = uuid.New().String()
+	if err := s.store.Services().Update(ctx, service); err != nil {
+		return apperrors.NewInternalError("failed to update service", err)
+	}
+
+	s.log.Info("build cache cleared", "id", service.ID, "name", service.Name)
+
+	return nil
+}
+
+// encodeBuildConfig validates build arg and secret names and encodes them for storage.
+// Secrets are encrypted as a whole; their values are never returned by the API.
+func (s *ServiceService) encodeBuildConfig(buildArgs, buildSecrets map[string]string) (string, string, error) {
+	for name := range buildArgs {
+		if !buildNamePattern.MatchString(name) {
+			return "", "", apperrors.NewValidationError("invalid build arg name", map[string]interface{}{
+				"name": name,
+			})
+		}
+	}
+	for name := range buildSecrets {
+		if !buildNamePattern.MatchString(name) {
+			return "", "", apperrors.NewValidationError("invalid build secret name", map[string]interface{}{
+				"name": name,
+			})
+		}
+	}
+
+	argsJSON := "{}"
+	if len(buildArgs) > 0 {
+		data, err := json.Marshal(buildArgs)
+		if err != nil {
+			return "", "", apperrors.NewInternalError("failed to encode build args", err)
+		}
+		argsJSON = string(data)
+	}
+
+	encryptedSecrets := ""
+	if len(buildSecrets) > 0 {
+		data, err := json.Marshal(buildSecrets)
+		if err != nil {
+			return "", "", apperrors.NewInternalError("failed to encode build secrets", err)
+		}
+		encryptedSecrets, err = s.cipher.Encrypt(string(data))
+		if err != nil {
+			return "", "", apperrors.NewInternalError("failed to encrypt build secrets", err)
+		}
+	}
+
+	return argsJSON, encryptedSecrets, nil
+}
+
+// validateRetirement checks how the previous slot is retired after a deployment
+func validateRetirement(drainSeconds, stopGraceSeconds, keepWarmMinutes, watchSeconds int) error {
+	if drainSeconds < 0 || stopGraceSeconds < 0 || keepWarmMinutes < 0 || watchSeconds < 0 {
+		return apperrors.NewValidationError("drain_seconds, stop_grace_seconds, keep_warm_minutes and watch_seconds must not be negative", nil)
+	}
+	return nil
+}
+
+// decodeBuildSecrets decrypts the build secrets stored on a service
+func decodeBuildSecrets(cipher *secrets.Cipher, encrypted string) (map[string]string, error) {
+	if encrypted == "" {
+		return nil, nil
+	}
+	data, err := cipher.Decrypt(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	var buildSecrets map[string]string
+	if err := json.Unmarshal([]byte(data), &buildSecrets); err != nil {
+		return nil, err
+	}
+	return buildSecrets, nil
+}
+
+// validateGitCredential checks that a git credential exists and belongs to the project
+func (s *ServiceService) validateGitCredential(ctx context.Context, projectID, credentialID string) error {
+	credential, err := s.store.GitCredentials().GetByID(ctx, credentialID)
+	if err != nil {
+		return apperrors.NewInternalError("failed to get git credential", err)
+	}
+	if credential == nil || credential.ProjectID != projectID {
+		return apperrors.NewNotFoundError("git credential", credentialID)
+	}
+	return nil
+}
+
 func (s *ServiceService) resolveProject(ctx context.Context, projectIDOrName string) (*storage.Project, error) {
 	project, err := s.store.Projects().GetByID(ctx, projectIDOrName)
 	if err != nil {
@@ -367,6 +660,19 @@ func (s *ServiceService) toResponse(service *storage.Service) *ServiceResponse {
 		_ = json.Unmarshal([]byte(service.Environment), &env)
 	}
 
+	var buildArgs map[string]string
+	if service.BuildArgs != "" {
+		_ = json.Unmarshal([]byte(service.BuildArgs), &buildArgs)
+	}
+
+	var buildSecretNames []string
+	if buildSecrets, err := decodeBuildSecrets(s.cipher, service.BuildSecrets); err == nil {
+		for name := range buildSecrets {
+			buildSecretNames = append(buildSecretNames, name)
+		}
+		sort.Strings(buildSecretNames)
+	}
+
 	return &ServiceResponse{
 		ID:               service.ID,
 		ProjectID:        service.ProjectID,
@@ -377,6 +683,19 @@ func (s *ServiceService) toResponse(service *storage.Service) *ServiceResponse {
 		GitBranch:        service.GitBranch,
 		Subdirectory:     service.Subdirectory,
 		DockerImage:      service.DockerImage,
+		GitCredentialID:  service.GitCredentialID,
+		GitSubmodules:    service.GitSubmodules,
+		GitLFS:           service.GitLFS,
+		GitDepth:         service.GitDepth,
+		BuildArgs:        buildArgs,
+		BuildSecrets:     buildSecretNames,
+		HealthCheck:      decodeHealthCheck(service.HealthCheck),
+		DrainSeconds:     service.DrainSeconds,
+		StopGraceSeconds: service.StopGraceSeconds,
+		KeepWarmMinutes:  service.KeepWarmMinutes,
+		WatchSeconds:     service.WatchSeconds,
+		DependsOn:        decodeDependsOn(service.DependsOn),
+		RequiresApproval: service.RequiresApproval,
 		DatabaseType:     service.DatabaseType,
 		DatabaseVersion:  service.DatabaseVersion,
 		DatabaseHost:     service.DatabaseHost,
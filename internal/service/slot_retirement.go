@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/deployer"
+	apperrors "github.com/victalejo/nebula/internal/core/errors"
+	"github.com/victalejo/nebula/internal/core/proxy"
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// slotReaperInterval is how often standby deployments are checked for retirement
+const slotReaperInterval = 30 * time.Second
+
+// resolveProject looks up a project by ID or name
+func (s *DeployService) resolveProject(ctx context.Context, projectID string) (*storage.Project, error) {
+	project, err := s.store.Apps().GetByID(ctx, projectID)
+	if err != nil {
+		return nil, apperrors.NewInternalError("failed to get project", err)
+	}
+	if project == nil {
+		project, err = s.store.Apps().GetByName(ctx, projectID)
+		if err != nil {
+			return nil, apperrors.NewInternalError("failed to get project", err)
+		}
+	}
+	if project == nil {
+		return nil, apperrors.NewNotFoundError("project", projectID)
+	}
+	return project, nil
+}
+
+// resolveService looks up a project by ID or name and one of its services by name
+func (s *DeployService) resolveService(ctx context.Context, projectID, serviceName string) (*storage.Project, *storage.Service, error) {
+	project, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	service, err := s.store.Services().GetByProjectIDAndName(ctx, project.ID, serviceName)
+	if err != nil {
+		return nil, nil, apperrors.NewInternalError("failed to get service", err)
+	}
+	if service == nil {
+		return nil, nil, apperrors.NewNotFoundError("service", serviceName)
+	}
+
+	return project, service, nil
+}
+
+// deployerForService returns the deployer that manages the service's containers
+func (s *DeployService) deployerForService(service *storage.Service) (deployer.Deployer, error) {
+	if service.Type == storage.ServiceTypeDatabase || service.Builder == storage.BuilderDockerImage {
+		return s.registry.Get(deployer.ModeImage)
+	}
+	return s.registry.Get(deployer.ModeGit)
+}
+
+// retireServiceDeployment takes the service's running deployment in slot out of
+// service once traffic has moved away from it. The deployment stays on standby
+// for the drain period, plus the keep-warm period during which a rollback is
+// only a proxy switch, and is stopped afterwards. It is kept at least for the
+// watch window, so a crash-looping successor can still be rolled back.
+func (s *DeployService) retireServiceDeployment(ctx context.Context, service *storage.Service, slot string) {
+	oldDeployment, err := s.store.Deployments().GetByServiceIDAndSlot(ctx, service.ID, slot)
+	if err != nil || oldDeployment == nil || oldDeployment.Status != string(deployer.StatusRunning) {
+		return
+	}
+
+	standby := time.Duration(service.DrainSeconds)*time.Second + time.Duration(service.KeepWarmMinutes)*time.Minute
+	if watch := time.Duration(service.WatchSeconds) * time.Second; watch > standby {
+		standby = watch
+	}
+	retireAt := time.Now().Add(standby)
+
+	oldDeployment.Status = string(deployer.StatusStandby)
+	oldDeployment.RetireAt = &retireAt
+	_ = s.store.Deployments().Update(ctx, oldDeployment)
+	s.publishDeploymentStatus(service.ProjectID, service.ID, oldDeployment.ID, oldDeployment.Status, "")
+
+	s.log.Info("previous deployment on standby",
+		"deployment_id", oldDeployment.ID,
+		"service_id", service.ID,
+		"retire_at", retireAt,
+	)
+
+	// The reaper catches retirements that were pending across a restart;
+	// short ones are stopped on time here
+	deploymentID := oldDeployment.ID
+	time.AfterFunc(standby, func() {
+		s.stopStandbyDeployment(context.Background(), deploymentID, false)
+	})
+}
+
+// stopStandbyDeployment stops a standby deployment once it is due for retirement,
+// or immediately when force is set
+func (s *DeployService) stopStandbyDeployment(ctx context.Context, deploymentID string, force bool) {
+	s.retireMu.Lock()
+	defer s.retireMu.Unlock()
+
+	deployment, err := s.store.Deployments().GetByID(ctx, deploymentID)
+	if err != nil || deployment == nil || deployment.Status != string(deployer.StatusStandby) {
+		return
+	}
+	if !force && deployment.RetireAt != nil && time.Now().Before(*deployment.RetireAt) {
+		return
+	}
+
+	service, err := s.store.Services().GetByID(ctx, deployment.ServiceID)
+	if err != nil || service == nil {
+		return
+	}
+
+	dep, err := s.deployerForService(service)
+	if err != nil {
+		s.log.Warn("no deployer for standby deployment", "deployment_id", deployment.ID, "error", err)
+		return
+	}
+
+	containers, err := s.store.Containers().ListByDeploymentID(ctx, deployment.ID)
+	if err != nil {
+		return
+	}
+	containerIDs := make([]string, len(containers))
+	for i, c := range containers {
+		containerIDs[i] = c.ContainerID
+	}
+
+	gracePeriod := time.Duration(service.StopGraceSeconds) * time.Second
+	if err := dep.Stop(ctx, containerIDs, gracePeriod); err != nil {
+		s.log.Warn("failed to stop standby containers", "deployment_id", deployment.ID, "error", err)
+	}
+
+	deployment.Status = string(deployer.StatusStopped)
+	deployment.RetireAt = nil
+	_ = s.store.Deployments().Update(ctx, deployment)
+	s.publishDeploymentStatus(service.ProjectID, service.ID, deployment.ID, deployment.Status, "")
+
+	s.log.Info("standby deployment stopped", "deployment_id", deployment.ID, "service_id", service.ID)
+}
+
+// clearServiceSlot stops any standby deployment occupying slot, so a new
+// deployment can take its place
+func (s *DeployService) clearServiceSlot(ctx context.Context, serviceID string, slot string) {
+	deployments, err := s.store.Deployments().ListByServiceID(ctx, serviceID)
+	if err != nil {
+		return
+	}
+	for _, d := range deployments {
+		if d.Slot == slot && d.Status == string(deployer.StatusStandby) {
+			s.stopStandbyDeployment(ctx, d.ID, true)
+		}
+	}
+}
+
+// StartSlotReaper periodically stops standby deployments whose retirement is due
+func (s *DeployService) StartSlotReaper(ctx context.Context) {
+	ticker := time.NewTicker(slotReaperInterval)
+	defer ticker.Stop()
+
+	for {
+		s.reapStandbyDeployments(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *DeployService) reapStandbyDeployments(ctx context.Context) {
+	deployments, err := s.store.Deployments().ListByStatus(ctx, string(deployer.StatusStandby))
+	if err != nil {
+		s.log.Warn("failed to list standby deployments", "error", err)
+		return
+	}
+	for _, d := range deployments {
+		if d.RetireAt == nil || time.Now().After(*d.RetireAt) {
+			s.stopStandbyDeployment(ctx, d.ID, false)
+		}
+	}
+}
+
+// routeServiceDomains points the service's domains at the given slot and port
+func (s *DeployService) routeServiceDomains(ctx context.Context, serviceID, slot string, upstream *proxy.Upstream) error {
+	domains, err := s.routedDomains(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	for _, domain := range domains {
+		if err := s.routeDomain(ctx, domain, slot, upstream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RollbackService switches a service back to its warm standby deployment.
+// Because the standby containers are still running this is only a proxy
+// switch; the deployment that was live goes on standby in turn.
+func (s *DeployService) RollbackService(ctx context.Context, projectID, serviceName string) (*DeploymentResponse, error) {
+	project, service, err := s.resolveService(ctx, projectID, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.retireMu.Lock()
+	deployments, err := s.store.Deployments().ListByServiceID(ctx, service.ID)
+	if err != nil {
+		s.retireMu.Unlock()
+		return nil, apperrors.NewInternalError("failed to list deployments", err)
+	}
+
+	var current, standby *storage.Deployment
+	for _, d := range deployments {
+		switch d.Status {
+		case string(deployer.StatusRunning):
+			if current == nil {
+				current = d
+			}
+		case string(deployer.StatusStandby):
+			if standby == nil {
+				standby = d
+			}
+		}
+	}
+	if standby == nil {
+		s.retireMu.Unlock()
+		return nil, apperrors.NewConflictError("no warm deployment to roll back to; redeploy a previous version instead")
+	}
+
+	containers, err := s.store.Containers().ListByDeploymentID(ctx, standby.ID)
+	if err != nil {
+		s.retireMu.Unlock()
+		return nil, apperrors.NewInternalError("failed to list containers", err)
+	}
+	if len(containers) == 0 {
+		s.retireMu.Unlock()
+		return nil, apperrors.NewConflictError("standby deployment has no containers; redeploy instead")
+	}
+	var upstream *proxy.Upstream
+	for _, c := range containers {
+		info, err := s.runtime.InspectContainer(ctx, c.ContainerID)
+		if err != nil || info.State != "running" {
+			s.retireMu.Unlock()
+			return nil, apperrors.NewConflictError("standby deployment is no longer running; redeploy instead")
+		}
+		if upstream == nil {
+			upstream = containerUpstream(c)
+		}
+	}
+
+	s.routeMu.Lock()
+	if err := s.routeServiceDomains(ctx, service.ID, standby.Slot, upstream); err != nil {
+		s.routeMu.Unlock()
+		s.retireMu.Unlock()
+		return nil, apperrors.NewInternalError("failed to switch traffic", err)
+	}
+
+	standby.Status = string(deployer.StatusRunning)
+	standby.RetireAt = nil
+	_ = s.store.Deployments().Update(ctx, standby)
+	if current != nil {
+		s.retireServiceDeployment(ctx, service, current.Slot)
+	}
+	s.routeMu.Unlock()
+	s.retireMu.Unlock()
+
+	s.publishDeploymentStatus(project.ID, service.ID, standby.ID, standby.Status, "")
+	s.log.Info("rolled back service", "service_id", service.ID, "deployment_id", standby.ID)
+
+	service.Status = "running"
+	_ = s.store.Services().Update(ctx, service)
+	s.publishServiceStatus(project.ID, service.ID, service.Status)
+
+	return &DeploymentResponse{
+		ID:        standby.ID,
+		AppID:     standby.AppID,
+		ServiceID: standby.ServiceID,
+		Version:   standby.Version,
+		Slot:      standby.Slot,
+		Status:    standby.Status,
+		CreatedAt: standby.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}, nil
+}
@@ -21,8 +21,8 @@ func NewAppRepository(db *sql.DB) *AppRepository {
 // Create creates a new application/project
 func (r *AppRepository) Create(ctx context.Context, app *storage.Project) error {
 	query := `
-		INSERT INTO applications (id, name, display_name, description, deployment_mode, domain, git_repo, git_branch, environment, created_at, updated_at)
-		VALUES (?, ?, ?, ?, 'git', '', ?, ?, ?, ?, ?)
+		INSERT INTO applications (id, name, display_name, description, deployment_mode, domain, git_repo, git_branch, environment, created_at, updated_at, parent_id, environment_name)
+		VALUES (?, ?, ?, ?, 'git', '', ?, ?, ?, ?, ?, ?, ?)
 	`
 	now := time.Now()
 	app.CreatedAt = now
@@ -38,6 +38,8 @@ func (r *AppRepository) Create(ctx context.Context, app *storage.Project) error
 		app.Environment,
 		app.CreatedAt,
 		app.UpdatedAt,
+		nullString(app.ParentID),
+		nullString(app.EnvironmentName),
 	)
 	return err
 }
@@ -52,24 +54,12 @@ func nullString(s string) sql.NullString {
 // GetByID retrieves a project by ID
 func (r *AppRepository) GetByID(ctx context.Context, id string) (*storage.Project, error) {
 	query := `
-		SELECT id, name, COALESCE(display_name, ''), COALESCE(description, ''),
-		       COALESCE(git_repo, ''), COALESCE(git_branch, ''), COALESCE(environment, '{}'),
-		       created_at, updated_at
+		SELECT ` + projectColumns + `
 		FROM applications
 		WHERE id = ?
 	`
 	project := &storage.Project{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&project.ID,
-		&project.Name,
-		&project.DisplayName,
-		&project.Description,
-		&project.GitRepo,
-		&project.GitBranch,
-		&project.Environment,
-		&project.CreatedAt,
-		&project.UpdatedAt,
-	)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(projectFields(project)...)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -82,24 +72,12 @@ func (r *AppRepository) GetByID(ctx context.Context, id string) (*storage.Projec
 // GetByName retrieves a project by name
 func (r *AppRepository) GetByName(ctx context.Context, name string) (*storage.Project, error) {
 	query := `
-		SELECT id, name, COALESCE(display_name, ''), COALESCE(description, ''),
-		       COALESCE(git_repo, ''), COALESCE(git_branch, ''), COALESCE(environment, '{}'),
-		       created_at, updated_at
+		SELECT ` + projectColumns + `
 		FROM applications
 		WHERE name = ?
 	`
 	project := &storage.Project{}
-	err := r.db.QueryRowContext(ctx, query, name).Scan(
-		&project.ID,
-		&project.Name,
-		&project.DisplayName,
-		&project.Description,
-		&project.GitRepo,
-		&project.GitBranch,
-		&project.Environment,
-		&project.CreatedAt,
-		&project.UpdatedAt,
-	)
+	err := r.db.QueryRowContext(ctx, query, name).Scan(projectFields(project)...)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -140,9 +118,7 @@ func (r *AppRepository) Delete(ctx context.Context, id string) error {
 // List returns all projects
 func (r *AppRepository) List(ctx context.Context) ([]*storage.Project, error) {
 	query := `
-		SELECT id, name, COALESCE(display_name, ''), COALESCE(description, ''),
-		       COALESCE(git_repo, ''), COALESCE(git_branch, ''), COALESCE(environment, '{}'),
-		       created_at, updated_at
+		SELECT ` + projectColumns + `
 		FROM applications
 		ORDER BY created_at DESC
 	`
@@ -155,17 +131,7 @@ func (r *AppRepository) List(ctx context.Context) ([]*storage.Project, error) {
 	var projects []*storage.Project
 	for rows.Next() {
 		project := &storage.Project{}
-		if err := rows.Scan(
-			&project.ID,
-			&project.Name,
-			&project.DisplayName,
-			&project.Description,
-			&project.GitRepo,
-			&project.GitBranch,
-			&project.Environment,
-			&project.CreatedAt,
-			&project.UpdatedAt,
-		); err != nil {
+		if err := rows.Scan(projectFields(project)...); err != nil {
 			return nil, err
 		}
 		projects = append(projects, project)
@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// AuditRepository is the SQLite implementation of AuditRepository
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new audit log repository
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Create records an audit entry
+func (r *AuditRepository) Create(ctx context.Context, entry *storage.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (id, project_id, actor, action, target_id, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	entry.CreatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		entry.ID,
+		entry.ProjectID,
+		entry.Actor,
+		entry.Action,
+		nullString(entry.TargetID),
+		nullString(entry.Reason),
+		entry.CreatedAt,
+	)
+	return err
+}
+
+// ListByProjectID returns the audit log of a project, newest first
+func (r *AuditRepository) ListByProjectID(ctx context.Context, projectID string) ([]*storage.AuditEntry, error) {
+	query := `
+		SELECT id, project_id, actor, action, COALESCE(target_id, ''), COALESCE(reason, ''), created_at
+		FROM audit_log
+		WHERE project_id = ?
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*storage.AuditEntry
+	for rows.Next() {
+		entry := &storage.AuditEntry{}
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.ProjectID,
+			&entry.Actor,
+			&entry.Action,
+			&entry.TargetID,
+			&entry.Reason,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+const certificateColumns = `domain, source, COALESCE(cert_pem, ''), COALESCE(key_pem, ''), COALESCE(issuer, ''),
+		       COALESCE(names, ''), not_before, not_after, checked_at, COALESCE(last_error, ''),
+		       COALESCE(expiry_notice, -1), updated_at`
+
+// CertificateRepository is the SQLite implementation of CertificateRepository
+type CertificateRepository struct {
+	db *sql.DB
+}
+
+// NewCertificateRepository creates a new domain certificate repository
+func NewCertificateRepository(db *sql.DB) *CertificateRepository {
+	return &CertificateRepository{db: db}
+}
+
+// Get retrieves the certificate of a domain host
+func (r *CertificateRepository) Get(ctx context.Context, domain string) (*storage.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE domain = ?`
+
+	cert := &storage.Certificate{}
+	err := r.db.QueryRowContext(ctx, query, domain).Scan(certificateFields(cert)...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// Save creates or replaces the certificate of a domain host
+func (r *CertificateRepository) Save(ctx context.Context, cert *storage.Certificate) error {
+	query := `
+		INSERT INTO certificates (domain, source, cert_pem, key_pem, issuer, names, not_before, not_after,
+		                          checked_at, last_error, expiry_notice, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(domain) DO UPDATE SET
+			source = excluded.source,
+			cert_pem = excluded.cert_pem,
+			key_pem = excluded.key_pem,
+			issuer = excluded.issuer,
+			names = excluded.names,
+			not_before = excluded.not_before,
+			not_after = excluded.not_after,
+			checked_at = excluded.checked_at,
+			last_error = excluded.last_error,
+			expiry_notice = excluded.expiry_notice,
+			updated_at = excluded.updated_at
+	`
+	cert.UpdatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		cert.Domain,
+		cert.Source,
+		nullString(cert.CertPEM),
+		nullString(cert.KeyPEM),
+		nullString(cert.Issuer),
+		nullString(cert.Names),
+		cert.NotBefore,
+		cert.NotAfter,
+		cert.CheckedAt,
+		nullString(cert.LastError),
+		cert.ExpiryNotice,
+		cert.UpdatedAt,
+	)
+	return err
+}
+
+// Delete deletes the certificate of a domain host
+func (r *CertificateRepository) Delete(ctx context.Context, domain string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM certificates WHERE domain = ?`, domain)
+	return err
+}
+
+// List returns every stored certificate
+func (r *CertificateRepository) List(ctx context.Context) ([]*storage.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates ORDER BY domain ASC`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var certs []*storage.Certificate
+	for rows.Next() {
+		cert := &storage.Certificate{}
+		if err := rows.Scan(certificateFields(cert)...); err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, rows.Err()
+}
+
+// certificateFields returns scan destinations matching certificateColumns
+func certificateFields(c *storage.Certificate) []any {
+	return []any{
+		&c.Domain,
+		&c.Source,
+		&c.CertPEM,
+		&c.KeyPEM,
+		&c.Issuer,
+		&c.Names,
+		&c.NotBefore,
+		&c.NotAfter,
+		&c.CheckedAt,
+		&c.LastError,
+		&c.ExpiryNotice,
+		&c.UpdatedAt,
+	}
+}
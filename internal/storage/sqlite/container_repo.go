@@ -21,8 +21,8 @@ func NewContainerRepository(db *sql.DB) *ContainerRepository {
 // Create creates a new container record
 func (r *ContainerRepository) Create(ctx context.Context, container *storage.Container) error {
 	query := `
-		INSERT INTO containers (id, deployment_id, container_id, name, status, port, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO containers (id, deployment_id, container_id, name, status, port, host, app_port, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	container.CreatedAt = time.Now()
 
@@ -33,6 +33,8 @@ func (r *ContainerRepository) Create(ctx context.Context, container *storage.Con
 		container.Name,
 		container.Status,
 		container.Port,
+		container.Host,
+		container.AppPort,
 		container.CreatedAt,
 	)
 	return err
@@ -41,7 +43,7 @@ func (r *ContainerRepository) Create(ctx context.Context, container *storage.Con
 // GetByID retrieves a container by ID
 func (r *ContainerRepository) GetByID(ctx context.Context, id string) (*storage.Container, error) {
 	query := `
-		SELECT id, deployment_id, container_id, name, status, port, created_at
+		SELECT id, deployment_id, container_id, name, status, port, COALESCE(host, ''), COALESCE(app_port, 0), created_at
 		FROM containers
 		WHERE id = ?
 	`
@@ -53,6 +55,8 @@ func (r *ContainerRepository) GetByID(ctx context.Context, id string) (*storage.
 		&c.Name,
 		&c.Status,
 		&c.Port,
+		&c.Host,
+		&c.AppPort,
 		&c.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -89,7 +93,7 @@ func (r *ContainerRepository) Delete(ctx context.Context, id string) error {
 // ListByDeploymentID returns all containers for a deployment
 func (r *ContainerRepository) ListByDeploymentID(ctx context.Context, deploymentID string) ([]*storage.Container, error) {
 	query := `
-		SELECT id, deployment_id, container_id, name, status, port, created_at
+		SELECT id, deployment_id, container_id, name, status, port, COALESCE(host, ''), COALESCE(app_port, 0), created_at
 		FROM containers
 		WHERE deployment_id = ?
 		ORDER BY created_at DESC
@@ -110,6 +114,8 @@ func (r *ContainerRepository) ListByDeploymentID(ctx context.Context, deployment
 			&c.Name,
 			&c.Status,
 			&c.Port,
+			&c.Host,
+			&c.AppPort,
 			&c.CreatedAt,
 		); err != nil {
 			return nil, err
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "modernc.org/sqlite"
 
@@ -16,9 +17,17 @@ type Store struct {
 	db *sql.DB
 
 	// New repositories
-	projects *ProjectRepository
-	services *ServiceRepository
-	domains  *DomainRepository
+	projects       *ProjectRepository
+	services       *ServiceRepository
+	domains        *DomainRepository
+	gitCredentials *GitCredentialRepository
+	releases       *ReleaseRepository
+	freezeWindows  *FreezeWindowRepository
+	audit          *AuditRepository
+	previewConfigs *PreviewConfigRepository
+	previews       *PreviewRepository
+	certificates   *CertificateRepository
+	tlsAllowlists  *TLSAllowlistRepository
 
 	// Legacy repositories
 	apps          *AppRepository
@@ -56,6 +65,14 @@ func NewStore(dbPath string) (*Store, error) {
 	store.projects = NewProjectRepository(db)
 	store.services = NewServiceRepository(db)
 	store.domains = NewDomainRepository(db)
+	store.gitCredentials = NewGitCredentialRepository(db)
+	store.releases = NewReleaseRepository(db)
+	store.freezeWindows = NewFreezeWindowRepository(db)
+	store.audit = NewAuditRepository(db)
+	store.previewConfigs = NewPreviewConfigRepository(db)
+	store.previews = NewPreviewRepository(db)
+	store.certificates = NewCertificateRepository(db)
+	store.tlsAllowlists = NewTLSAllowlistRepository(db)
 
 	// Initialize legacy repositories
 	store.apps = NewAppRepository(db)
@@ -85,6 +102,46 @@ func (s *Store) Domains() storage.DomainRepository {
 	return s.domains
 }
 
+// GitCredentials returns the git credential repository
+func (s *Store) GitCredentials() storage.GitCredentialRepository {
+	return s.gitCredentials
+}
+
+// Releases returns the project release repository
+func (s *Store) Releases() storage.ReleaseRepository {
+	return s.releases
+}
+
+// FreezeWindows returns the freeze window repository
+func (s *Store) FreezeWindows() storage.FreezeWindowRepository {
+	return s.freezeWindows
+}
+
+// Audit returns the audit log repository
+func (s *Store) Audit() storage.AuditRepository {
+	return s.audit
+}
+
+// PreviewConfigs returns the preview settings repository
+func (s *Store) PreviewConfigs() storage.PreviewConfigRepository {
+	return s.previewConfigs
+}
+
+// Previews returns the pull request preview repository
+func (s *Store) Previews() storage.PreviewRepository {
+	return s.previews
+}
+
+// Certificates returns the domain certificate repository
+func (s *Store) Certificates() storage.CertificateRepository {
+	return s.certificates
+}
+
+// TLSAllowlists returns the on-demand TLS allowlist repository
+func (s *Store) TLSAllowlists() storage.TLSAllowlistRepository {
+	return s.tlsAllowlists
+}
+
 // Apps returns the application repository (legacy, wraps ProjectRepository)
 func (s *Store) Apps() storage.AppRepository {
 	return s.apps
@@ -177,6 +234,155 @@ func (s *Store) Migrate() error {
 	// Create index on service_id after column is added
 	_, _ = s.db.Exec("CREATE INDEX IF NOT EXISTS idx_deployments_service_id ON deployments(service_id)")
 
+	// Run V4 migration (git credentials for private repositories)
+	if _, err := s.db.Exec(migrationV4); err != nil {
+		return fmt.Errorf("failed to run migration V4: %w", err)
+	}
+
+	// V4 schema changes - ignore errors if already applied
+	v4Alterations := []string{
+		"ALTER TABLE services ADD COLUMN git_credential_id TEXT REFERENCES git_credentials(id) ON DELETE SET NULL",
+	}
+	for _, alt := range v4Alterations {
+		_, _ = s.db.Exec(alt)
+	}
+
+	// V5 schema changes - ignore errors if already applied
+	v5Alterations := []string{
+		// Git clone options per service
+		"ALTER TABLE services ADD COLUMN git_submodules INTEGER DEFAULT 0",
+		"ALTER TABLE services ADD COLUMN git_lfs INTEGER DEFAULT 0",
+		"ALTER TABLE services ADD COLUMN git_depth INTEGER DEFAULT 1",
+		// Build cache key per service
+		"ALTER TABLE services ADD COLUMN build_cache_key TEXT",
+		// Build args and encrypted build secrets per service
+		"ALTER TABLE services ADD COLUMN build_args TEXT",
+		"ALTER TABLE services ADD COLUMN build_secrets TEXT",
+		// Health check configuration per service
+		"ALTER TABLE services ADD COLUMN health_check TEXT",
+		// Slot retirement: drain period, SIGTERM grace and warm standby per service
+		"ALTER TABLE services ADD COLUMN drain_seconds INTEGER DEFAULT 10",
+		"ALTER TABLE services ADD COLUMN stop_grace_seconds INTEGER DEFAULT 30",
+		"ALTER TABLE services ADD COLUMN keep_warm_minutes INTEGER DEFAULT 0",
+		"ALTER TABLE services ADD COLUMN watch_seconds INTEGER DEFAULT 120",
+		"ALTER TABLE deployments ADD COLUMN retire_at DATETIME",
+		// Canary traffic share per deployment
+		"ALTER TABLE deployments ADD COLUMN traffic_weight INTEGER DEFAULT 0",
+	}
+	for _, alt := range v5Alterations {
+		_, _ = s.db.Exec(alt)
+	}
+
+	// Run V6 migration (project releases)
+	if _, err := s.db.Exec(migrationV6); err != nil {
+		return fmt.Errorf("failed to run migration V6: %w", err)
+	}
+
+	// V6 schema changes - ignore errors if already applied
+	v6Alterations := []string{
+		// Services deployed before this one in a project release
+		"ALTER TABLE services ADD COLUMN depends_on TEXT",
+		"ALTER TABLE deployments ADD COLUMN release_id TEXT REFERENCES releases(id) ON DELETE SET NULL",
+	}
+	for _, alt := range v6Alterations {
+		_, _ = s.db.Exec(alt)
+	}
+	_, _ = s.db.Exec("CREATE INDEX IF NOT EXISTS idx_deployments_release_id ON deployments(release_id)")
+
+	// Run V7 migration (deploy approvals and freeze windows)
+	if _, err := s.db.Exec(migrationV7); err != nil {
+		return fmt.Errorf("failed to run migration V7: %w", err)
+	}
+
+	// V7 schema changes - ignore errors if already applied
+	v7Alterations := []string{
+		"ALTER TABLE services ADD COLUMN requires_approval INTEGER DEFAULT 0",
+		"ALTER TABLE deployments ADD COLUMN request TEXT",
+		"ALTER TABLE deployments ADD COLUMN requested_by TEXT",
+		"ALTER TABLE deployments ADD COLUMN approved_by TEXT",
+		"ALTER TABLE deployments ADD COLUMN freeze_override TEXT",
+	}
+	for _, alt := range v7Alterations {
+		_, _ = s.db.Exec(alt)
+	}
+
+	// V8 schema changes (project environments) - ignore errors if already applied
+	v8Alterations := []string{
+		"ALTER TABLE applications ADD COLUMN parent_id TEXT REFERENCES applications(id) ON DELETE CASCADE",
+		"ALTER TABLE applications ADD COLUMN environment_name TEXT",
+	}
+	for _, alt := range v8Alterations {
+		_, _ = s.db.Exec(alt)
+	}
+	_, _ = s.db.Exec("CREATE INDEX IF NOT EXISTS idx_applications_parent_id ON applications(parent_id)")
+
+	// Run V9 migration (pull request previews)
+	if _, err := s.db.Exec(migrationV9); err != nil {
+		return fmt.Errorf("failed to run migration V9: %w", err)
+	}
+
+	// Run V10 migration (path-based routing). Domains become unique per host
+	// and path prefix; SQLite cannot drop the old constraint, so the table is
+	// rebuilt once.
+	var domainsSchema string
+	_ = s.db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'domains'").Scan(&domainsSchema)
+	if !strings.Contains(domainsSchema, "UNIQUE(domain, path_prefix)") {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to run migration V10: %w", err)
+		}
+		if _, err := tx.Exec(migrationV10); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to run migration V10: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to run migration V10: %w", err)
+		}
+	}
+
+	// V11 schema changes: containers are routed to over the Docker network
+	v11Alterations := []string{
+		"ALTER TABLE containers ADD COLUMN host TEXT",
+		"ALTER TABLE containers ADD COLUMN app_port INTEGER",
+	}
+	for _, alt := range v11Alterations {
+		_, _ = s.db.Exec(alt)
+	}
+
+	// V12 schema changes: redirect and rewrite rules per domain
+	v12Alterations := []string{
+		"ALTER TABLE domains ADD COLUMN rules TEXT",
+	}
+	for _, alt := range v12Alterations {
+		_, _ = s.db.Exec(alt)
+	}
+
+	// V13 schema changes: access control per domain
+	v13Alterations := []string{
+		"ALTER TABLE domains ADD COLUMN access TEXT",
+	}
+	for _, alt := range v13Alterations {
+		_, _ = s.db.Exec(alt)
+	}
+
+	// V14 schema changes: response headers per domain
+	v14Alterations := []string{
+		"ALTER TABLE domains ADD COLUMN headers TEXT",
+	}
+	for _, alt := range v14Alterations {
+		_, _ = s.db.Exec(alt)
+	}
+
+	// Run V15 migration (domain certificates)
+	if _, err := s.db.Exec(migrationV15); err != nil {
+		return fmt.Errorf("failed to run migration V15: %w", err)
+	}
+
+	// Run V16 migration (on-demand TLS allowlists)
+	if _, err := s.db.Exec(migrationV16); err != nil {
+		return fmt.Errorf("failed to run migration V16: %w", err)
+	}
+
 	return nil
 }
 
@@ -326,3 +532,156 @@ CREATE INDEX IF NOT EXISTS idx_services_type ON services(type);
 CREATE INDEX IF NOT EXISTS idx_domains_project_id ON domains(project_id);
 CREATE INDEX IF NOT EXISTS idx_domains_service_id ON domains(service_id);
 `
+
+const migrationV4 = `
+-- Git credentials: SSH deploy keys and HTTPS tokens for private repositories
+CREATE TABLE IF NOT EXISTS git_credentials (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    host TEXT NOT NULL,
+    username TEXT,
+    secret TEXT NOT NULL,
+    public_key TEXT,
+    known_hosts TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(project_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_git_credentials_project_id ON git_credentials(project_id);
+`
+
+const migrationV6 = `
+-- Project releases: service deployments rolled out together in dependency order
+CREATE TABLE IF NOT EXISTS releases (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    plan TEXT NOT NULL,
+    error_message TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_releases_project_id ON releases(project_id);
+`
+
+const migrationV7 = `
+-- Periods during which deployments are refused unless overridden
+CREATE TABLE IF NOT EXISTS freeze_windows (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    starts_at DATETIME,
+    ends_at DATETIME,
+    weekdays TEXT,
+    start_time TEXT,
+    end_time TEXT,
+    timezone TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_freeze_windows_project_id ON freeze_windows(project_id);
+
+-- Approvals, rejections and freeze overrides
+CREATE TABLE IF NOT EXISTS audit_log (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target_id TEXT,
+    reason TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_project_id ON audit_log(project_id);
+`
+
+const migrationV9 = `
+-- Pull request preview settings per project
+CREATE TABLE IF NOT EXISTS preview_configs (
+    project_id TEXT PRIMARY KEY REFERENCES applications(id) ON DELETE CASCADE,
+    enabled INTEGER DEFAULT 0,
+    webhook_secret TEXT,
+    base_domain TEXT,
+    fork_databases INTEGER DEFAULT 0,
+    ttl_hours INTEGER DEFAULT 0,
+    max_previews INTEGER DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Environments deployed from pull request branches
+CREATE TABLE IF NOT EXISTS previews (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    environment_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    pr_number INTEGER NOT NULL,
+    title TEXT,
+    branch TEXT NOT NULL,
+    commit_sha TEXT,
+    release_id TEXT REFERENCES releases(id) ON DELETE SET NULL,
+    expires_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(project_id, pr_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_previews_expires_at ON previews(expires_at);
+`
+
+const migrationV10 = `
+-- Domains are unique per host and path prefix
+CREATE TABLE domains_v10 (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    service_id TEXT NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    domain TEXT NOT NULL,
+    path_prefix TEXT DEFAULT '/',
+    strip_prefix INTEGER DEFAULT 0,
+    active_slot TEXT DEFAULT 'blue',
+    ssl_enabled BOOLEAN DEFAULT TRUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(domain, path_prefix)
+);
+
+INSERT INTO domains_v10 (id, project_id, service_id, domain, path_prefix, active_slot, ssl_enabled, created_at)
+SELECT id, project_id, service_id, domain, COALESCE(path_prefix, '/'), active_slot, ssl_enabled, created_at FROM domains;
+
+DROP TABLE domains;
+ALTER TABLE domains_v10 RENAME TO domains;
+
+CREATE INDEX IF NOT EXISTS idx_domains_project_id ON domains(project_id);
+CREATE INDEX IF NOT EXISTS idx_domains_service_id ON domains(service_id);
+`
+
+const migrationV15 = `
+-- TLS certificate of each domain host, uploaded or managed by the proxy
+CREATE TABLE IF NOT EXISTS certificates (
+    domain TEXT PRIMARY KEY,
+    source TEXT NOT NULL,
+    cert_pem TEXT,
+    key_pem TEXT,
+    issuer TEXT,
+    names TEXT,
+    not_before DATETIME,
+    not_after DATETIME,
+    checked_at DATETIME,
+    last_error TEXT,
+    expiry_notice INTEGER DEFAULT -1,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+const migrationV16 = `
+-- Hosts each project may obtain on-demand certificates for
+CREATE TABLE IF NOT EXISTS tls_allowlists (
+    project_id TEXT PRIMARY KEY REFERENCES applications(id) ON DELETE CASCADE,
+    patterns TEXT,
+    service_id TEXT REFERENCES services(id) ON DELETE SET NULL,
+    path_prefix TEXT,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`
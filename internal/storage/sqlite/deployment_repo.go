@@ -8,6 +8,12 @@ import (
 	"github.com/victalejo/nebula/internal/core/storage"
 )
 
+// deploymentColumns lists the columns read by every deployment query, in scan order
+const deploymentColumns = `id, app_id, COALESCE(service_id, ''), version, slot, status, source_config, environment,
+		       error_message, COALESCE(logs, ''), created_at, started_at, finished_at, retire_at, COALESCE(traffic_weight, 0),
+		       COALESCE(release_id, ''), COALESCE(request, ''), COALESCE(requested_by, ''), COALESCE(approved_by, ''),
+		       COALESCE(freeze_override, '')`
+
 // DeploymentRepository is the SQLite implementation of DeploymentRepository
 type DeploymentRepository struct {
 	db *sql.DB
@@ -21,8 +27,9 @@ func NewDeploymentRepository(db *sql.DB) *DeploymentRepository {
 // Create creates a new deployment
 func (r *DeploymentRepository) Create(ctx context.Context, deployment *storage.Deployment) error {
 	query := `
-		INSERT INTO deployments (id, app_id, service_id, version, slot, status, source_config, environment, error_message, logs, created_at, started_at, finished_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO deployments (id, app_id, service_id, version, slot, status, source_config, environment, error_message, logs, created_at, started_at, finished_at, retire_at, traffic_weight, release_id,
+			request, requested_by, approved_by, freeze_override)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	deployment.CreatedAt = time.Now()
 
@@ -40,6 +47,13 @@ func (r *DeploymentRepository) Create(ctx context.Context, deployment *storage.D
 		deployment.CreatedAt,
 		deployment.StartedAt,
 		deployment.FinishedAt,
+		deployment.RetireAt,
+		deployment.TrafficWeight,
+		nullString(deployment.ReleaseID),
+		nullString(deployment.Request),
+		nullString(deployment.RequestedBy),
+		nullString(deployment.ApprovedBy),
+		nullString(deployment.FreezeOverride),
 	)
 	return err
 }
@@ -47,48 +61,34 @@ func (r *DeploymentRepository) Create(ctx context.Context, deployment *storage.D
 // GetByID retrieves a deployment by ID
 func (r *DeploymentRepository) GetByID(ctx context.Context, id string) (*storage.Deployment, error) {
 	query := `
-		SELECT id, app_id, COALESCE(service_id, ''), version, slot, status, source_config, environment, error_message, COALESCE(logs, ''), created_at, started_at, finished_at
+		SELECT ` + deploymentColumns + `
 		FROM deployments
 		WHERE id = ?
 	`
-	d := &storage.Deployment{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&d.ID,
-		&d.AppID,
-		&d.ServiceID,
-		&d.Version,
-		&d.Slot,
-		&d.Status,
-		&d.SourceConfig,
-		&d.Environment,
-		&d.ErrorMessage,
-		&d.Logs,
-		&d.CreatedAt,
-		&d.StartedAt,
-		&d.FinishedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return d, nil
+	return r.scanDeployment(r.db.QueryRowContext(ctx, query, id))
 }
 
 // Update updates a deployment
 func (r *DeploymentRepository) Update(ctx context.Context, deployment *storage.Deployment) error {
 	query := `
 		UPDATE deployments
-		SET status = ?, error_message = ?, logs = ?, started_at = ?, finished_at = ?
+		SET slot = ?, status = ?, source_config = ?, environment = ?, error_message = ?, logs = ?, started_at = ?, finished_at = ?,
+		    retire_at = ?, traffic_weight = ?, approved_by = ?, freeze_override = ?
 		WHERE id = ?
 	`
 	_, err := r.db.ExecContext(ctx, query,
+		deployment.Slot,
 		deployment.Status,
+		deployment.SourceConfig,
+		deployment.Environment,
 		deployment.ErrorMessage,
 		deployment.Logs,
 		deployment.StartedAt,
 		deployment.FinishedAt,
+		deployment.RetireAt,
+		deployment.TrafficWeight,
+		nullString(deployment.ApprovedBy),
+		nullString(deployment.FreezeOverride),
 		deployment.ID,
 	)
 	return err
@@ -104,7 +104,7 @@ func (r *DeploymentRepository) Delete(ctx context.Context, id string) error {
 // ListByAppID returns all deployments for an application
 func (r *DeploymentRepository) ListByAppID(ctx context.Context, appID string) ([]*storage.Deployment, error) {
 	query := `
-		SELECT id, app_id, COALESCE(service_id, ''), version, slot, status, source_config, environment, error_message, COALESCE(logs, ''), created_at, started_at, finished_at
+		SELECT ` + deploymentColumns + `
 		FROM deployments
 		WHERE app_id = ?
 		ORDER BY created_at DESC
@@ -115,7 +115,7 @@ func (r *DeploymentRepository) ListByAppID(ctx context.Context, appID string) ([
 // ListByServiceID returns all deployments for a service
 func (r *DeploymentRepository) ListByServiceID(ctx context.Context, serviceID string) ([]*storage.Deployment, error) {
 	query := `
-		SELECT id, app_id, COALESCE(service_id, ''), version, slot, status, source_config, environment, error_message, COALESCE(logs, ''), created_at, started_at, finished_at
+		SELECT ` + deploymentColumns + `
 		FROM deployments
 		WHERE service_id = ?
 		ORDER BY created_at DESC
@@ -123,150 +123,79 @@ func (r *DeploymentRepository) ListByServiceID(ctx context.Context, serviceID st
 	return r.scanDeployments(r.db.QueryContext(ctx, query, serviceID))
 }
 
-func (r *DeploymentRepository) scanDeployments(rows *sql.Rows, err error) ([]*storage.Deployment, error) {
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// ListByStatus returns all deployments with the given status
+func (r *DeploymentRepository) ListByStatus(ctx context.Context, status string) ([]*storage.Deployment, error) {
+	query := `
+		SELECT ` + deploymentColumns + `
+		FROM deployments
+		WHERE status = ?
+		ORDER BY created_at DESC
+	`
+	return r.scanDeployments(r.db.QueryContext(ctx, query, status))
+}
 
-	var deployments []*storage.Deployment
-	for rows.Next() {
-		d := &storage.Deployment{}
-		if err := rows.Scan(
-			&d.ID,
-			&d.AppID,
-			&d.ServiceID,
-			&d.Version,
-			&d.Slot,
-			&d.Status,
-			&d.SourceConfig,
-			&d.Environment,
-			&d.ErrorMessage,
-			&d.Logs,
-			&d.CreatedAt,
-			&d.StartedAt,
-			&d.FinishedAt,
-		); err != nil {
-			return nil, err
-		}
-		deployments = append(deployments, d)
-	}
-	return deployments, rows.Err()
+// ListByReleaseID returns all deployments of a project release
+func (r *DeploymentRepository) ListByReleaseID(ctx context.Context, releaseID string) ([]*storage.Deployment, error) {
+	query := `
+		SELECT ` + deploymentColumns + `
+		FROM deployments
+		WHERE release_id = ?
+		ORDER BY created_at ASC
+	`
+	return r.scanDeployments(r.db.QueryContext(ctx, query, releaseID))
 }
 
 // GetLatestByAppID returns the latest deployment for an application
 func (r *DeploymentRepository) GetLatestByAppID(ctx context.Context, appID string) (*storage.Deployment, error) {
 	query := `
-		SELECT id, app_id, COALESCE(service_id, ''), version, slot, status, source_config, environment, error_message, COALESCE(logs, ''), created_at, started_at, finished_at
+		SELECT ` + deploymentColumns + `
 		FROM deployments
 		WHERE app_id = ?
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
-	d := &storage.Deployment{}
-	err := r.db.QueryRowContext(ctx, query, appID).Scan(
-		&d.ID,
-		&d.AppID,
-		&d.ServiceID,
-		&d.Version,
-		&d.Slot,
-		&d.Status,
-		&d.SourceConfig,
-		&d.Environment,
-		&d.ErrorMessage,
-		&d.Logs,
-		&d.CreatedAt,
-		&d.StartedAt,
-		&d.FinishedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return d, nil
+	return r.scanDeployment(r.db.QueryRowContext(ctx, query, appID))
 }
 
 // GetLatestByServiceID returns the latest deployment for a service
 func (r *DeploymentRepository) GetLatestByServiceID(ctx context.Context, serviceID string) (*storage.Deployment, error) {
 	query := `
-		SELECT id, app_id, COALESCE(service_id, ''), version, slot, status, source_config, environment, error_message, COALESCE(logs, ''), created_at, started_at, finished_at
+		SELECT ` + deploymentColumns + `
 		FROM deployments
 		WHERE service_id = ?
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
-	d := &storage.Deployment{}
-	err := r.db.QueryRowContext(ctx, query, serviceID).Scan(
-		&d.ID,
-		&d.AppID,
-		&d.ServiceID,
-		&d.Version,
-		&d.Slot,
-		&d.Status,
-		&d.SourceConfig,
-		&d.Environment,
-		&d.ErrorMessage,
-		&d.Logs,
-		&d.CreatedAt,
-		&d.StartedAt,
-		&d.FinishedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return d, nil
+	return r.scanDeployment(r.db.QueryRowContext(ctx, query, serviceID))
 }
 
 // GetByAppIDAndSlot returns the deployment for an application and slot
 func (r *DeploymentRepository) GetByAppIDAndSlot(ctx context.Context, appID string, slot string) (*storage.Deployment, error) {
 	query := `
-		SELECT id, app_id, COALESCE(service_id, ''), version, slot, status, source_config, environment, error_message, COALESCE(logs, ''), created_at, started_at, finished_at
+		SELECT ` + deploymentColumns + `
 		FROM deployments
 		WHERE app_id = ? AND slot = ? AND status = 'running'
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
-	d := &storage.Deployment{}
-	err := r.db.QueryRowContext(ctx, query, appID, slot).Scan(
-		&d.ID,
-		&d.AppID,
-		&d.ServiceID,
-		&d.Version,
-		&d.Slot,
-		&d.Status,
-		&d.SourceConfig,
-		&d.Environment,
-		&d.ErrorMessage,
-		&d.Logs,
-		&d.CreatedAt,
-		&d.StartedAt,
-		&d.FinishedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	return d, nil
+	return r.scanDeployment(r.db.QueryRowContext(ctx, query, appID, slot))
 }
 
 // GetByServiceIDAndSlot returns the deployment for a service and slot
 func (r *DeploymentRepository) GetByServiceIDAndSlot(ctx context.Context, serviceID string, slot string) (*storage.Deployment, error) {
 	query := `
-		SELECT id, app_id, COALESCE(service_id, ''), version, slot, status, source_config, environment, error_message, COALESCE(logs, ''), created_at, started_at, finished_at
+		SELECT ` + deploymentColumns + `
 		FROM deployments
 		WHERE service_id = ? AND slot = ? AND status = 'running'
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
-	d := &storage.Deployment{}
-	err := r.db.QueryRowContext(ctx, query, serviceID, slot).Scan(
+	return r.scanDeployment(r.db.QueryRowContext(ctx, query, serviceID, slot))
+}
+
+// deploymentFields returns the scan destinations matching deploymentColumns
+func deploymentFields(d *storage.Deployment) []any {
+	return []any{
 		&d.ID,
 		&d.AppID,
 		&d.ServiceID,
@@ -280,7 +209,19 @@ func (r *DeploymentRepository) GetByServiceIDAndSlot(ctx context.Context, servic
 		&d.CreatedAt,
 		&d.StartedAt,
 		&d.FinishedAt,
-	)
+		&d.RetireAt,
+		&d.TrafficWeight,
+		&d.ReleaseID,
+		&d.Request,
+		&d.RequestedBy,
+		&d.ApprovedBy,
+		&d.FreezeOverride,
+	}
+}
+
+func (r *DeploymentRepository) scanDeployment(row *sql.Row) (*storage.Deployment, error) {
+	d := &storage.Deployment{}
+	err := row.Scan(deploymentFields(d)...)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -289,3 +230,20 @@ func (r *DeploymentRepository) GetByServiceIDAndSlot(ctx context.Context, servic
 	}
 	return d, nil
 }
+
+func (r *DeploymentRepository) scanDeployments(rows *sql.Rows, err error) ([]*storage.Deployment, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deployments []*storage.Deployment
+	for rows.Next() {
+		d := &storage.Deployment{}
+		if err := rows.Scan(deploymentFields(d)...); err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, d)
+	}
+	return deployments, rows.Err()
+}
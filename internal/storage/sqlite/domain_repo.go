@@ -8,6 +8,10 @@ import (
 	"github.com/victalejo/nebula/internal/core/storage"
 )
 
+// domainColumns lists the columns read by every domain query, in scan order
+const domainColumns = `id, project_id, service_id, domain, COALESCE(path_prefix, '/'), COALESCE(strip_prefix, 0),
+		       COALESCE(active_slot, 'blue'), COALESCE(ssl_enabled, 1), COALESCE(rules, ''), COALESCE(access, ''), COALESCE(headers, ''), created_at`
+
 // DomainRepository is the SQLite implementation of DomainRepository
 type DomainRepository struct {
 	db *sql.DB
@@ -21,8 +25,8 @@ func NewDomainRepository(db *sql.DB) *DomainRepository {
 // Create creates a new domain
 func (r *DomainRepository) Create(ctx context.Context, domain *storage.Domain) error {
 	query := `
-		INSERT INTO domains (id, project_id, service_id, domain, path_prefix, active_slot, ssl_enabled, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO domains (id, project_id, service_id, domain, path_prefix, strip_prefix, active_slot, ssl_enabled, rules, access, headers, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	now := time.Now()
 	domain.CreatedAt = now
@@ -40,8 +44,12 @@ func (r *DomainRepository) Create(ctx context.Context, domain *storage.Domain) e
 		domain.ServiceID,
 		domain.Domain,
 		domain.PathPrefix,
+		domain.StripPrefix,
 		domain.ActiveSlot,
 		domain.SSLEnabled,
+		nullString(domain.Rules),
+		nullString(domain.Access),
+		nullString(domain.Headers),
 		domain.CreatedAt,
 	)
 	return err
@@ -50,25 +58,36 @@ func (r *DomainRepository) Create(ctx context.Context, domain *storage.Domain) e
 // GetByID retrieves a domain by ID
 func (r *DomainRepository) GetByID(ctx context.Context, id string) (*storage.Domain, error) {
 	query := `
-		SELECT id, project_id, service_id, domain, COALESCE(path_prefix, '/'),
-		       COALESCE(active_slot, 'blue'), COALESCE(ssl_enabled, 1), created_at
+		SELECT ` + domainColumns + `
 		FROM domains
 		WHERE id = ?
 	`
 	return r.scanDomain(r.db.QueryRowContext(ctx, query, id))
 }
 
-// GetByDomain retrieves a domain by domain name
+// GetByDomain retrieves a domain by domain name. When the host routes
+// several path prefixes, the shortest prefix is returned.
 func (r *DomainRepository) GetByDomain(ctx context.Context, domainName string) (*storage.Domain, error) {
 	query := `
-		SELECT id, project_id, service_id, domain, COALESCE(path_prefix, '/'),
-		       COALESCE(active_slot, 'blue'), COALESCE(ssl_enabled, 1), created_at
+		SELECT ` + domainColumns + `
 		FROM domains
 		WHERE domain = ?
+		ORDER BY LENGTH(path_prefix) ASC
+		LIMIT 1
 	`
 	return r.scanDomain(r.db.QueryRowContext(ctx, query, domainName))
 }
 
+// GetByDomainAndPath retrieves the domain routing a host and path prefix
+func (r *DomainRepository) GetByDomainAndPath(ctx context.Context, domainName, pathPrefix string) (*storage.Domain, error) {
+	query := `
+		SELECT ` + domainColumns + `
+		FROM domains
+		WHERE domain = ? AND COALESCE(path_prefix, '/') = ?
+	`
+	return r.scanDomain(r.db.QueryRowContext(ctx, query, domainName, pathPrefix))
+}
+
 func (r *DomainRepository) scanDomain(row *sql.Row) (*storage.Domain, error) {
 	domain := &storage.Domain{}
 	err := row.Scan(
@@ -77,8 +96,12 @@ func (r *DomainRepository) scanDomain(row *sql.Row) (*storage.Domain, error) {
 		&domain.ServiceID,
 		&domain.Domain,
 		&domain.PathPrefix,
+		&domain.StripPrefix,
 		&domain.ActiveSlot,
 		&domain.SSLEnabled,
+		&domain.Rules,
+		&domain.Access,
+		&domain.Headers,
 		&domain.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -94,15 +117,19 @@ func (r *DomainRepository) scanDomain(row *sql.Row) (*storage.Domain, error) {
 func (r *DomainRepository) Update(ctx context.Context, domain *storage.Domain) error {
 	query := `
 		UPDATE domains
-		SET service_id = ?, domain = ?, path_prefix = ?, active_slot = ?, ssl_enabled = ?
+		SET service_id = ?, domain = ?, path_prefix = ?, strip_prefix = ?, active_slot = ?, ssl_enabled = ?, rules = ?, access = ?, headers = ?
 		WHERE id = ?
 	`
 	_, err := r.db.ExecContext(ctx, query,
 		domain.ServiceID,
 		domain.Domain,
 		domain.PathPrefix,
+		domain.StripPrefix,
 		domain.ActiveSlot,
 		domain.SSLEnabled,
+		nullString(domain.Rules),
+		nullString(domain.Access),
+		nullString(domain.Headers),
 		domain.ID,
 	)
 	return err
@@ -118,11 +145,10 @@ func (r *DomainRepository) Delete(ctx context.Context, id string) error {
 // ListByProjectID returns all domains for a project
 func (r *DomainRepository) ListByProjectID(ctx context.Context, projectID string) ([]*storage.Domain, error) {
 	query := `
-		SELECT id, project_id, service_id, domain, COALESCE(path_prefix, '/'),
-		       COALESCE(active_slot, 'blue'), COALESCE(ssl_enabled, 1), created_at
+		SELECT ` + domainColumns + `
 		FROM domains
 		WHERE project_id = ?
-		ORDER BY domain ASC
+		ORDER BY domain ASC, path_prefix ASC
 	`
 	return r.scanDomains(r.db.QueryContext(ctx, query, projectID))
 }
@@ -130,22 +156,31 @@ func (r *DomainRepository) ListByProjectID(ctx context.Context, projectID string
 // ListByServiceID returns all domains for a service
 func (r *DomainRepository) ListByServiceID(ctx context.Context, serviceID string) ([]*storage.Domain, error) {
 	query := `
-		SELECT id, project_id, service_id, domain, COALESCE(path_prefix, '/'),
-		       COALESCE(active_slot, 'blue'), COALESCE(ssl_enabled, 1), created_at
+		SELECT ` + domainColumns + `
 		FROM domains
 		WHERE service_id = ?
-		ORDER BY domain ASC
+		ORDER BY domain ASC, path_prefix ASC
 	`
 	return r.scanDomains(r.db.QueryContext(ctx, query, serviceID))
 }
 
+// ListByDomain returns the path prefixes routed on a host
+func (r *DomainRepository) ListByDomain(ctx context.Context, domainName string) ([]*storage.Domain, error) {
+	query := `
+		SELECT ` + domainColumns + `
+		FROM domains
+		WHERE domain = ?
+		ORDER BY path_prefix ASC
+	`
+	return r.scanDomains(r.db.QueryContext(ctx, query, domainName))
+}
+
 // List returns all domains
 func (r *DomainRepository) List(ctx context.Context) ([]*storage.Domain, error) {
 	query := `
-		SELECT id, project_id, service_id, domain, COALESCE(path_prefix, '/'),
-		       COALESCE(active_slot, 'blue'), COALESCE(ssl_enabled, 1), created_at
+		SELECT ` + domainColumns + `
 		FROM domains
-		ORDER BY domain ASC
+		ORDER BY domain ASC, path_prefix ASC
 	`
 	return r.scanDomains(r.db.QueryContext(ctx, query))
 }
@@ -165,8 +200,12 @@ func (r *DomainRepository) scanDomains(rows *sql.Rows, err error) ([]*storage.Do
 			&domain.ServiceID,
 			&domain.Domain,
 			&domain.PathPrefix,
+			&domain.StripPrefix,
 			&domain.ActiveSlot,
 			&domain.SSLEnabled,
+			&domain.Rules,
+			&domain.Access,
+			&domain.Headers,
 			&domain.CreatedAt,
 		); err != nil {
 			return nil, err
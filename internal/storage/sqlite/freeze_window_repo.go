@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+const freezeWindowColumns = `id, project_id, name, starts_at, ends_at, COALESCE(weekdays, ''),
+		       COALESCE(start_time, ''), COALESCE(end_time, ''), COALESCE(timezone, ''), created_at`
+
+// FreezeWindowRepository is the SQLite implementation of FreezeWindowRepository
+type FreezeWindowRepository struct {
+	db *sql.DB
+}
+
+// NewFreezeWindowRepository creates a new freeze window repository
+func NewFreezeWindowRepository(db *sql.DB) *FreezeWindowRepository {
+	return &FreezeWindowRepository{db: db}
+}
+
+// Create creates a new freeze window
+func (r *FreezeWindowRepository) Create(ctx context.Context, window *storage.FreezeWindow) error {
+	query := `
+		INSERT INTO freeze_windows (id, project_id, name, starts_at, ends_at, weekdays, start_time, end_time, timezone, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	window.CreatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		window.ID,
+		window.ProjectID,
+		window.Name,
+		window.StartsAt,
+		window.EndsAt,
+		nullString(window.Weekdays),
+		nullString(window.StartTime),
+		nullString(window.EndTime),
+		nullString(window.Timezone),
+		window.CreatedAt,
+	)
+	return err
+}
+
+// GetByID retrieves a freeze window by ID
+func (r *FreezeWindowRepository) GetByID(ctx context.Context, id string) (*storage.FreezeWindow, error) {
+	query := `SELECT ` + freezeWindowColumns + ` FROM freeze_windows WHERE id = ?`
+
+	window := &storage.FreezeWindow{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(freezeWindowFields(window)...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// Delete deletes a freeze window
+func (r *FreezeWindowRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM freeze_windows WHERE id = ?`, id)
+	return err
+}
+
+// ListByProjectID returns all freeze windows of a project
+func (r *FreezeWindowRepository) ListByProjectID(ctx context.Context, projectID string) ([]*storage.FreezeWindow, error) {
+	query := `
+		SELECT ` + freezeWindowColumns + `
+		FROM freeze_windows
+		WHERE project_id = ?
+		ORDER BY created_at ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []*storage.FreezeWindow
+	for rows.Next() {
+		window := &storage.FreezeWindow{}
+		if err := rows.Scan(freezeWindowFields(window)...); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, rows.Err()
+}
+
+// freezeWindowFields returns scan destinations matching freezeWindowColumns
+func freezeWindowFields(w *storage.FreezeWindow) []any {
+	return []any{
+		&w.ID,
+		&w.ProjectID,
+		&w.Name,
+		&w.StartsAt,
+		&w.EndsAt,
+		&w.Weekdays,
+		&w.StartTime,
+		&w.EndTime,
+		&w.Timezone,
+		&w.CreatedAt,
+	}
+}
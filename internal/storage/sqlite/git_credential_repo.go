@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// GitCredentialRepository is the SQLite implementation of GitCredentialRepository
+type GitCredentialRepository struct {
+	db *sql.DB
+}
+
+// NewGitCredentialRepository creates a new git credential repository
+func NewGitCredentialRepository(db *sql.DB) *GitCredentialRepository {
+	return &GitCredentialRepository{db: db}
+}
+
+// Create creates a new git credential
+func (r *GitCredentialRepository) Create(ctx context.Context, credential *storage.GitCredential) error {
+	query := `
+		INSERT INTO git_credentials (id, project_id, name, type, host, username, secret, public_key, known_hosts, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now()
+	credential.CreatedAt = now
+	credential.UpdatedAt = now
+
+	_, err := r.db.ExecContext(ctx, query,
+		credential.ID,
+		credential.ProjectID,
+		credential.Name,
+		string(credential.Type),
+		credential.Host,
+		nullString(credential.Username),
+		credential.Secret,
+		nullString(credential.PublicKey),
+		nullString(credential.KnownHosts),
+		credential.CreatedAt,
+		credential.UpdatedAt,
+	)
+	return err
+}
+
+// GetByID retrieves a git credential by ID
+func (r *GitCredentialRepository) GetByID(ctx context.Context, id string) (*storage.GitCredential, error) {
+	query := `
+		SELECT id, project_id, name, type, host, COALESCE(username, ''), secret,
+		       COALESCE(public_key, ''), COALESCE(known_hosts, ''), created_at, updated_at
+		FROM git_credentials
+		WHERE id = ?
+	`
+	credential := &storage.GitCredential{}
+	var credType string
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&credential.ID,
+		&credential.ProjectID,
+		&credential.Name,
+		&credType,
+		&credential.Host,
+		&credential.Username,
+		&credential.Secret,
+		&credential.PublicKey,
+		&credential.KnownHosts,
+		&credential.CreatedAt,
+		&credential.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	credential.Type = storage.GitCredentialType(credType)
+	return credential, nil
+}
+
+// Update updates a git credential
+func (r *GitCredentialRepository) Update(ctx context.Context, credential *storage.GitCredential) error {
+	query := `
+		UPDATE git_credentials
+		SET name = ?, host = ?, username = ?, secret = ?, public_key = ?, known_hosts = ?, updated_at = ?
+		WHERE id = ?
+	`
+	credential.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query,
+		credential.Name,
+		credential.Host,
+		nullString(credential.Username),
+		credential.Secret,
+		nullString(credential.PublicKey),
+		nullString(credential.KnownHosts),
+		credential.UpdatedAt,
+		credential.ID,
+	)
+	return err
+}
+
+// Delete deletes a git credential
+func (r *GitCredentialRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM git_credentials WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// ListByProjectID returns all git credentials for a project
+func (r *GitCredentialRepository) ListByProjectID(ctx context.Context, projectID string) ([]*storage.GitCredential, error) {
+	query := `
+		SELECT id, project_id, name, type, host, COALESCE(username, ''), secret,
+		       COALESCE(public_key, ''), COALESCE(known_hosts, ''), created_at, updated_at
+		FROM git_credentials
+		WHERE project_id = ?
+		ORDER BY created_at ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credentials []*storage.GitCredential
+	for rows.Next() {
+		credential := &storage.GitCredential{}
+		var credType string
+		if err := rows.Scan(
+			&credential.ID,
+			&credential.ProjectID,
+			&credential.Name,
+			&credType,
+			&credential.Host,
+			&credential.Username,
+			&credential.Secret,
+			&credential.PublicKey,
+			&credential.KnownHosts,
+			&credential.CreatedAt,
+			&credential.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		credential.Type = storage.GitCredentialType(credType)
+		credentials = append(credentials, credential)
+	}
+	return credentials, rows.Err()
+}
@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// PreviewConfigRepository is the SQLite implementation of PreviewConfigRepository
+type PreviewConfigRepository struct {
+	db *sql.DB
+}
+
+// NewPreviewConfigRepository creates a new preview settings repository
+func NewPreviewConfigRepository(db *sql.DB) *PreviewConfigRepository {
+	return &PreviewConfigRepository{db: db}
+}
+
+// Get retrieves the preview settings of a project
+func (r *PreviewConfigRepository) Get(ctx context.Context, projectID string) (*storage.PreviewConfig, error) {
+	query := `
+		SELECT project_id, COALESCE(enabled, 0), COALESCE(webhook_secret, ''), COALESCE(base_domain, ''),
+		       COALESCE(fork_databases, 0), COALESCE(ttl_hours, 0), COALESCE(max_previews, 0), updated_at
+		FROM preview_configs
+		WHERE project_id = ?
+	`
+	config := &storage.PreviewConfig{}
+	err := r.db.QueryRowContext(ctx, query, projectID).Scan(
+		&config.ProjectID,
+		&config.Enabled,
+		&config.WebhookSecret,
+		&config.BaseDomain,
+		&config.ForkDatabases,
+		&config.TTLHours,
+		&config.MaxPreviews,
+		&config.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Save creates or replaces the preview settings of a project
+func (r *PreviewConfigRepository) Save(ctx context.Context, config *storage.PreviewConfig) error {
+	query := `
+		INSERT INTO preview_configs (project_id, enabled, webhook_secret, base_domain, fork_databases, ttl_hours, max_previews, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(project_id) DO UPDATE SET
+			enabled = excluded.enabled,
+			webhook_secret = excluded.webhook_secret,
+			base_domain = excluded.base_domain,
+			fork_databases = excluded.fork_databases,
+			ttl_hours = excluded.ttl_hours,
+			max_previews = excluded.max_previews,
+			updated_at = excluded.updated_at
+	`
+	config.UpdatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		config.ProjectID,
+		config.Enabled,
+		nullString(config.WebhookSecret),
+		nullString(config.BaseDomain),
+		config.ForkDatabases,
+		config.TTLHours,
+		config.MaxPreviews,
+		config.UpdatedAt,
+	)
+	return err
+}
+
+const previewColumns = `id, project_id, environment_id, pr_number, COALESCE(title, ''), branch,
+		       COALESCE(commit_sha, ''), COALESCE(release_id, ''), expires_at, created_at, updated_at`
+
+// PreviewRepository is the SQLite implementation of PreviewRepository
+type PreviewRepository struct {
+	db *sql.DB
+}
+
+// NewPreviewRepository creates a new pull request preview repository
+func NewPreviewRepository(db *sql.DB) *PreviewRepository {
+	return &PreviewRepository{db: db}
+}
+
+// Create creates a new preview
+func (r *PreviewRepository) Create(ctx context.Context, preview *storage.Preview) error {
+	query := `
+		INSERT INTO previews (id, project_id, environment_id, pr_number, title, branch, commit_sha, release_id, expires_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now()
+	preview.CreatedAt = now
+	preview.UpdatedAt = now
+
+	_, err := r.db.ExecContext(ctx, query,
+		preview.ID,
+		preview.ProjectID,
+		preview.EnvironmentID,
+		preview.PRNumber,
+		nullString(preview.Title),
+		preview.Branch,
+		nullString(preview.CommitSHA),
+		nullString(preview.ReleaseID),
+		preview.ExpiresAt,
+		preview.CreatedAt,
+		preview.UpdatedAt,
+	)
+	return err
+}
+
+// GetByPullRequest retrieves the preview of a pull request
+func (r *PreviewRepository) GetByPullRequest(ctx context.Context, projectID string, number int) (*storage.Preview, error) {
+	query := `SELECT ` + previewColumns + ` FROM previews WHERE project_id = ? AND pr_number = ?`
+
+	preview := &storage.Preview{}
+	err := r.db.QueryRowContext(ctx, query, projectID, number).Scan(previewFields(preview)...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// Update updates a preview
+func (r *PreviewRepository) Update(ctx context.Context, preview *storage.Preview) error {
+	query := `
+		UPDATE previews
+		SET title = ?, branch = ?, commit_sha = ?, release_id = ?, expires_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+	preview.UpdatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		nullString(preview.Title),
+		preview.Branch,
+		nullString(preview.CommitSHA),
+		nullString(preview.ReleaseID),
+		preview.ExpiresAt,
+		preview.UpdatedAt,
+		preview.ID,
+	)
+	return err
+}
+
+// Delete deletes a preview
+func (r *PreviewRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM previews WHERE id = ?`, id)
+	return err
+}
+
+// ListByProjectID returns the previews of a project
+func (r *PreviewRepository) ListByProjectID(ctx context.Context, projectID string) ([]*storage.Preview, error) {
+	query := `
+		SELECT ` + previewColumns + `
+		FROM previews
+		WHERE project_id = ?
+		ORDER BY pr_number ASC
+	`
+	return r.scanPreviews(r.db.QueryContext(ctx, query, projectID))
+}
+
+// ListExpired returns the previews whose TTL ran out before the given time
+func (r *PreviewRepository) ListExpired(ctx context.Context, before time.Time) ([]*storage.Preview, error) {
+	query := `
+		SELECT ` + previewColumns + `
+		FROM previews
+		WHERE expires_at IS NOT NULL AND expires_at < ?
+		ORDER BY expires_at ASC
+	`
+	return r.scanPreviews(r.db.QueryContext(ctx, query, before))
+}
+
+func (r *PreviewRepository) scanPreviews(rows *sql.Rows, err error) ([]*storage.Preview, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var previews []*storage.Preview
+	for rows.Next() {
+		preview := &storage.Preview{}
+		if err := rows.Scan(previewFields(preview)...); err != nil {
+			return nil, err
+		}
+		previews = append(previews, preview)
+	}
+	return previews, rows.Err()
+}
+
+// previewFields returns scan destinations matching previewColumns
+func previewFields(p *storage.Preview) []any {
+	return []any{
+		&p.ID,
+		&p.ProjectID,
+		&p.EnvironmentID,
+		&p.PRNumber,
+		&p.Title,
+		&p.Branch,
+		&p.CommitSHA,
+		&p.ReleaseID,
+		&p.ExpiresAt,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	}
+}
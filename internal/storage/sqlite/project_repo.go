@@ -8,6 +8,11 @@ import (
 	"github.com/victalejo/nebula/internal/core/storage"
 )
 
+// projectColumns lists the columns read by every project query, in scan order
+const projectColumns = `id, name, COALESCE(display_name, ''), COALESCE(description, ''),
+		       COALESCE(git_repo, ''), COALESCE(git_branch, ''), COALESCE(environment, '{}'),
+		       created_at, updated_at, COALESCE(parent_id, ''), COALESCE(environment_name, 'production')`
+
 // ProjectRepository is the SQLite implementation of ProjectRepository
 type ProjectRepository struct {
 	db *sql.DB
@@ -21,8 +26,8 @@ func NewProjectRepository(db *sql.DB) *ProjectRepository {
 // Create creates a new project
 func (r *ProjectRepository) Create(ctx context.Context, project *storage.Project) error {
 	query := `
-		INSERT INTO applications (id, name, display_name, description, deployment_mode, domain, git_repo, git_branch, environment, created_at, updated_at)
-		VALUES (?, ?, ?, ?, 'git', '', ?, ?, ?, ?, ?)
+		INSERT INTO applications (id, name, display_name, description, deployment_mode, domain, git_repo, git_branch, environment, created_at, updated_at, parent_id, environment_name)
+		VALUES (?, ?, ?, ?, 'git', '', ?, ?, ?, ?, ?, ?, ?)
 	`
 	now := time.Now()
 	project.CreatedAt = now
@@ -38,6 +43,8 @@ func (r *ProjectRepository) Create(ctx context.Context, project *storage.Project
 		project.Environment,
 		project.CreatedAt,
 		project.UpdatedAt,
+		nullString(project.ParentID),
+		nullString(project.EnvironmentName),
 	)
 	return err
 }
@@ -45,24 +52,12 @@ func (r *ProjectRepository) Create(ctx context.Context, project *storage.Project
 // GetByID retrieves a project by ID
 func (r *ProjectRepository) GetByID(ctx context.Context, id string) (*storage.Project, error) {
 	query := `
-		SELECT id, name, COALESCE(display_name, ''), COALESCE(description, ''),
-		       COALESCE(git_repo, ''), COALESCE(git_branch, ''), COALESCE(environment, '{}'),
-		       created_at, updated_at
+		SELECT ` + projectColumns + `
 		FROM applications
 		WHERE id = ?
 	`
 	project := &storage.Project{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&project.ID,
-		&project.Name,
-		&project.DisplayName,
-		&project.Description,
-		&project.GitRepo,
-		&project.GitBranch,
-		&project.Environment,
-		&project.CreatedAt,
-		&project.UpdatedAt,
-	)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(projectFields(project)...)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -75,24 +70,12 @@ func (r *ProjectRepository) GetByID(ctx context.Context, id string) (*storage.Pr
 // GetByName retrieves a project by name
 func (r *ProjectRepository) GetByName(ctx context.Context, name string) (*storage.Project, error) {
 	query := `
-		SELECT id, name, COALESCE(display_name, ''), COALESCE(description, ''),
-		       COALESCE(git_repo, ''), COALESCE(git_branch, ''), COALESCE(environment, '{}'),
-		       created_at, updated_at
+		SELECT ` + projectColumns + `
 		FROM applications
 		WHERE name = ?
 	`
 	project := &storage.Project{}
-	err := r.db.QueryRowContext(ctx, query, name).Scan(
-		&project.ID,
-		&project.Name,
-		&project.DisplayName,
-		&project.Description,
-		&project.GitRepo,
-		&project.GitBranch,
-		&project.Environment,
-		&project.CreatedAt,
-		&project.UpdatedAt,
-	)
+	err := r.db.QueryRowContext(ctx, query, name).Scan(projectFields(project)...)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -133,13 +116,25 @@ func (r *ProjectRepository) Delete(ctx context.Context, id string) error {
 // List returns all projects
 func (r *ProjectRepository) List(ctx context.Context) ([]*storage.Project, error) {
 	query := `
-		SELECT id, name, COALESCE(display_name, ''), COALESCE(description, ''),
-		       COALESCE(git_repo, ''), COALESCE(git_branch, ''), COALESCE(environment, '{}'),
-		       created_at, updated_at
+		SELECT ` + projectColumns + `
 		FROM applications
 		ORDER BY created_at DESC
 	`
-	rows, err := r.db.QueryContext(ctx, query)
+	return r.scanProjects(r.db.QueryContext(ctx, query))
+}
+
+// ListByParentID returns the environments of a project
+func (r *ProjectRepository) ListByParentID(ctx context.Context, parentID string) ([]*storage.Project, error) {
+	query := `
+		SELECT ` + projectColumns + `
+		FROM applications
+		WHERE parent_id = ?
+		ORDER BY created_at ASC
+	`
+	return r.scanProjects(r.db.QueryContext(ctx, query, parentID))
+}
+
+func (r *ProjectRepository) scanProjects(rows *sql.Rows, err error) ([]*storage.Project, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -148,20 +143,27 @@ func (r *ProjectRepository) List(ctx context.Context) ([]*storage.Project, error
 	var projects []*storage.Project
 	for rows.Next() {
 		project := &storage.Project{}
-		if err := rows.Scan(
-			&project.ID,
-			&project.Name,
-			&project.DisplayName,
-			&project.Description,
-			&project.GitRepo,
-			&project.GitBranch,
-			&project.Environment,
-			&project.CreatedAt,
-			&project.UpdatedAt,
-		); err != nil {
+		if err := rows.Scan(projectFields(project)...); err != nil {
 			return nil, err
 		}
 		projects = append(projects, project)
 	}
 	return projects, rows.Err()
 }
+
+// projectFields returns scan destinations matching projectColumns
+func projectFields(p *storage.Project) []any {
+	return []any{
+		&p.ID,
+		&p.Name,
+		&p.DisplayName,
+		&p.Description,
+		&p.GitRepo,
+		&p.GitBranch,
+		&p.Environment,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		&p.ParentID,
+		&p.EnvironmentName,
+	}
+}
@@ -0,0 +1,114 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// ReleaseRepository is the SQLite implementation of ReleaseRepository
+type ReleaseRepository struct {
+	db *sql.DB
+}
+
+// NewReleaseRepository creates a new release repository
+func NewReleaseRepository(db *sql.DB) *ReleaseRepository {
+	return &ReleaseRepository{db: db}
+}
+
+// Create creates a new release
+func (r *ReleaseRepository) Create(ctx context.Context, release *storage.Release) error {
+	query := `
+		INSERT INTO releases (id, project_id, status, plan, error_message, created_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	release.CreatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		release.ID,
+		release.ProjectID,
+		release.Status,
+		release.Plan,
+		nullString(release.ErrorMessage),
+		release.CreatedAt,
+		release.FinishedAt,
+	)
+	return err
+}
+
+// GetByID retrieves a release by ID
+func (r *ReleaseRepository) GetByID(ctx context.Context, id string) (*storage.Release, error) {
+	query := `
+		SELECT id, project_id, status, plan, COALESCE(error_message, ''), created_at, finished_at
+		FROM releases
+		WHERE id = ?
+	`
+	release := &storage.Release{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&release.ID,
+		&release.ProjectID,
+		&release.Status,
+		&release.Plan,
+		&release.ErrorMessage,
+		&release.CreatedAt,
+		&release.FinishedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// Update updates a release
+func (r *ReleaseRepository) Update(ctx context.Context, release *storage.Release) error {
+	query := `
+		UPDATE releases
+		SET status = ?, error_message = ?, finished_at = ?
+		WHERE id = ?
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		release.Status,
+		nullString(release.ErrorMessage),
+		release.FinishedAt,
+		release.ID,
+	)
+	return err
+}
+
+// ListByProjectID returns all releases of a project, newest first
+func (r *ReleaseRepository) ListByProjectID(ctx context.Context, projectID string) ([]*storage.Release, error) {
+	query := `
+		SELECT id, project_id, status, plan, COALESCE(error_message, ''), created_at, finished_at
+		FROM releases
+		WHERE project_id = ?
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var releases []*storage.Release
+	for rows.Next() {
+		release := &storage.Release{}
+		if err := rows.Scan(
+			&release.ID,
+			&release.ProjectID,
+			&release.Status,
+			&release.Plan,
+			&release.ErrorMessage,
+			&release.CreatedAt,
+			&release.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releases, rows.Err()
+}
@@ -8,6 +8,24 @@ import (
 	"github.com/victalejo/nebula/internal/core/storage"
 )
 
+// serviceColumns lists the columns read by every service query, in scan order
+const serviceColumns = `id, project_id, name, type,
+		       COALESCE(builder, 'nixpacks'), COALESCE(git_repo, ''), COALESCE(git_branch, ''),
+		       COALESCE(subdirectory, '.'), COALESCE(docker_image, ''),
+		       COALESCE(git_credential_id, ''),
+		       COALESCE(git_submodules, 0), COALESCE(git_lfs, 0), COALESCE(git_depth, 1),
+		       COALESCE(build_cache_key, ''), COALESCE(build_args, '{}'), COALESCE(build_secrets, ''),
+		       COALESCE(health_check, ''),
+		       COALESCE(drain_seconds, 10), COALESCE(stop_grace_seconds, 30), COALESCE(keep_warm_minutes, 0), COALESCE(watch_seconds, 120),
+		       COALESCE(depends_on, '[]'), COALESCE(requires_approval, 0),
+		       COALESCE(database_type, ''), COALESCE(database_version, ''),
+		       COALESCE(database_host, ''), COALESCE(database_port, 0),
+		       COALESCE(database_user, ''), COALESCE(database_password, ''),
+		       COALESCE(database_name, ''), COALESCE(database_exposed, 0),
+		       COALESCE(port, 8080), COALESCE(command, ''), COALESCE(environment, '{}'),
+		       COALESCE(replicas, 1), COALESCE(status, 'stopped'),
+		       created_at, updated_at`
+
 // ServiceRepository is the SQLite implementation of ServiceRepository
 type ServiceRepository struct {
 	db *sql.DB
@@ -23,12 +41,15 @@ func (r *ServiceRepository) Create(ctx context.Context, service *storage.Service
 	query := `
 		INSERT INTO services (
 			id, project_id, name, type,
-			builder, git_repo, git_branch, subdirectory, docker_image,
+			builder, git_repo, git_branch, subdirectory, docker_image, git_credential_id,
+			git_submodules, git_lfs, git_depth, build_cache_key, build_args, build_secrets, health_check,
+			drain_seconds, stop_grace_seconds, keep_warm_minutes, watch_seconds,
+			depends_on, requires_approval,
 			database_type, database_version, database_host, database_port,
 			database_user, database_password, database_name, database_exposed,
 			port, command, environment, replicas, status,
 			created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	now := time.Now()
 	service.CreatedAt = now
@@ -40,9 +61,6 @@ func (r *ServiceRepository) Create(ctx context.Context, service *storage.Service
 	if service.Replicas == 0 {
 		service.Replicas = 1
 	}
-	if service.Port == 0 {
-		service.Port = 8080
-	}
 	if service.Subdirectory == "" {
 		service.Subdirectory = "."
 	}
@@ -60,6 +78,20 @@ func (r *ServiceRepository) Create(ctx context.Context, service *storage.Service
 		nullString(service.GitBranch),
 		service.Subdirectory,
 		nullString(service.DockerImage),
+		nullString(service.GitCredentialID),
+		service.GitSubmodules,
+		service.GitLFS,
+		service.GitDepth,
+		nullString(service.BuildCacheKey),
+		nullString(service.BuildArgs),
+		nullString(service.BuildSecrets),
+		nullString(service.HealthCheck),
+		service.DrainSeconds,
+		service.StopGraceSeconds,
+		service.KeepWarmMinutes,
+		service.WatchSeconds,
+		nullString(service.DependsOn),
+		service.RequiresApproval,
 		nullString(service.DatabaseType),
 		nullString(service.DatabaseVersion),
 		nullString(service.DatabaseHost),
@@ -82,16 +114,7 @@ func (r *ServiceRepository) Create(ctx context.Context, service *storage.Service
 // GetByID retrieves a service by ID
 func (r *ServiceRepository) GetByID(ctx context.Context, id string) (*storage.Service, error) {
 	query := `
-		SELECT id, project_id, name, type,
-		       COALESCE(builder, 'nixpacks'), COALESCE(git_repo, ''), COALESCE(git_branch, ''),
-		       COALESCE(subdirectory, '.'), COALESCE(docker_image, ''),
-		       COALESCE(database_type, ''), COALESCE(database_version, ''),
-		       COALESCE(database_host, ''), COALESCE(database_port, 0),
-		       COALESCE(database_user, ''), COALESCE(database_password, ''),
-		       COALESCE(database_name, ''), COALESCE(database_exposed, 0),
-		       COALESCE(port, 8080), COALESCE(command, ''), COALESCE(environment, '{}'),
-		       COALESCE(replicas, 1), COALESCE(status, 'stopped'),
-		       created_at, updated_at
+		SELECT ` + serviceColumns + `
 		FROM services
 		WHERE id = ?
 	`
@@ -101,16 +124,7 @@ func (r *ServiceRepository) GetByID(ctx context.Context, id string) (*storage.Se
 // GetByProjectIDAndName retrieves a service by project ID and name
 func (r *ServiceRepository) GetByProjectIDAndName(ctx context.Context, projectID, name string) (*storage.Service, error) {
 	query := `
-		SELECT id, project_id, name, type,
-		       COALESCE(builder, 'nixpacks'), COALESCE(git_repo, ''), COALESCE(git_branch, ''),
-		       COALESCE(subdirectory, '.'), COALESCE(docker_image, ''),
-		       COALESCE(database_type, ''), COALESCE(database_version, ''),
-		       COALESCE(database_host, ''), COALESCE(database_port, 0),
-		       COALESCE(database_user, ''), COALESCE(database_password, ''),
-		       COALESCE(database_name, ''), COALESCE(database_exposed, 0),
-		       COALESCE(port, 8080), COALESCE(command, ''), COALESCE(environment, '{}'),
-		       COALESCE(replicas, 1), COALESCE(status, 'stopped'),
-		       created_at, updated_at
+		SELECT ` + serviceColumns + `
 		FROM services
 		WHERE project_id = ? AND name = ?
 	`
@@ -130,6 +144,20 @@ func (r *ServiceRepository) scanService(row *sql.Row) (*storage.Service, error)
 		&service.GitBranch,
 		&service.Subdirectory,
 		&service.DockerImage,
+		&service.GitCredentialID,
+		&service.GitSubmodules,
+		&service.GitLFS,
+		&service.GitDepth,
+		&service.BuildCacheKey,
+		&service.BuildArgs,
+		&service.BuildSecrets,
+		&service.HealthCheck,
+		&service.DrainSeconds,
+		&service.StopGraceSeconds,
+		&service.KeepWarmMinutes,
+		&service.WatchSeconds,
+		&service.DependsOn,
+		&service.RequiresApproval,
 		&service.DatabaseType,
 		&service.DatabaseVersion,
 		&service.DatabaseHost,
@@ -163,6 +191,10 @@ func (r *ServiceRepository) Update(ctx context.Context, service *storage.Service
 		UPDATE services
 		SET name = ?, type = ?,
 		    builder = ?, git_repo = ?, git_branch = ?, subdirectory = ?, docker_image = ?,
+		    git_credential_id = ?, git_submodules = ?, git_lfs = ?, git_depth = ?,
+		    build_cache_key = ?, build_args = ?, build_secrets = ?, health_check = ?,
+		    drain_seconds = ?, stop_grace_seconds = ?, keep_warm_minutes = ?, watch_seconds = ?,
+		    depends_on = ?, requires_approval = ?,
 		    database_type = ?, database_version = ?,
 		    database_host = ?, database_port = ?,
 		    database_user = ?, database_password = ?,
@@ -180,6 +212,20 @@ func (r *ServiceRepository) Update(ctx context.Context, service *storage.Service
 		nullString(service.GitBranch),
 		service.Subdirectory,
 		nullString(service.DockerImage),
+		nullString(service.GitCredentialID),
+		service.GitSubmodules,
+		service.GitLFS,
+		service.GitDepth,
+		nullString(service.BuildCacheKey),
+		nullString(service.BuildArgs),
+		nullString(service.BuildSecrets),
+		nullString(service.HealthCheck),
+		service.DrainSeconds,
+		service.StopGraceSeconds,
+		service.KeepWarmMinutes,
+		service.WatchSeconds,
+		nullString(service.DependsOn),
+		service.RequiresApproval,
 		nullString(service.DatabaseType),
 		nullString(service.DatabaseVersion),
 		nullString(service.DatabaseHost),
@@ -209,16 +255,7 @@ func (r *ServiceRepository) Delete(ctx context.Context, id string) error {
 // ListByProjectID returns all services for a project
 func (r *ServiceRepository) ListByProjectID(ctx context.Context, projectID string) ([]*storage.Service, error) {
 	query := `
-		SELECT id, project_id, name, type,
-		       COALESCE(builder, 'nixpacks'), COALESCE(git_repo, ''), COALESCE(git_branch, ''),
-		       COALESCE(subdirectory, '.'), COALESCE(docker_image, ''),
-		       COALESCE(database_type, ''), COALESCE(database_version, ''),
-		       COALESCE(database_host, ''), COALESCE(database_port, 0),
-		       COALESCE(database_user, ''), COALESCE(database_password, ''),
-		       COALESCE(database_name, ''), COALESCE(database_exposed, 0),
-		       COALESCE(port, 8080), COALESCE(command, ''), COALESCE(environment, '{}'),
-		       COALESCE(replicas, 1), COALESCE(status, 'stopped'),
-		       created_at, updated_at
+		SELECT ` + serviceColumns + `
 		FROM services
 		WHERE project_id = ?
 		ORDER BY created_at ASC
@@ -229,16 +266,7 @@ func (r *ServiceRepository) ListByProjectID(ctx context.Context, projectID strin
 // List returns all services
 func (r *ServiceRepository) List(ctx context.Context) ([]*storage.Service, error) {
 	query := `
-		SELECT id, project_id, name, type,
-		       COALESCE(builder, 'nixpacks'), COALESCE(git_repo, ''), COALESCE(git_branch, ''),
-		       COALESCE(subdirectory, '.'), COALESCE(docker_image, ''),
-		       COALESCE(database_type, ''), COALESCE(database_version, ''),
-		       COALESCE(database_host, ''), COALESCE(database_port, 0),
-		       COALESCE(database_user, ''), COALESCE(database_password, ''),
-		       COALESCE(database_name, ''), COALESCE(database_exposed, 0),
-		       COALESCE(port, 8080), COALESCE(command, ''), COALESCE(environment, '{}'),
-		       COALESCE(replicas, 1), COALESCE(status, 'stopped'),
-		       created_at, updated_at
+		SELECT ` + serviceColumns + `
 		FROM services
 		ORDER BY created_at DESC
 	`
@@ -265,6 +293,20 @@ func (r *ServiceRepository) scanServices(rows *sql.Rows, err error) ([]*storage.
 			&service.GitBranch,
 			&service.Subdirectory,
 			&service.DockerImage,
+			&service.GitCredentialID,
+			&service.GitSubmodules,
+			&service.GitLFS,
+			&service.GitDepth,
+			&service.BuildCacheKey,
+			&service.BuildArgs,
+			&service.BuildSecrets,
+			&service.HealthCheck,
+			&service.DrainSeconds,
+			&service.StopGraceSeconds,
+			&service.KeepWarmMinutes,
+			&service.WatchSeconds,
+			&service.DependsOn,
+			&service.RequiresApproval,
 			&service.DatabaseType,
 			&service.DatabaseVersion,
 			&service.DatabaseHost,
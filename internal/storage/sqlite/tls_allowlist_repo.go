@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/victalejo/nebula/internal/core/storage"
+)
+
+// TLSAllowlistRepository is the SQLite implementation of TLSAllowlistRepository
+type TLSAllowlistRepository struct {
+	db *sql.DB
+}
+
+// NewTLSAllowlistRepository creates a new on-demand TLS allowlist repository
+func NewTLSAllowlistRepository(db *sql.DB) *TLSAllowlistRepository {
+	return &TLSAllowlistRepository{db: db}
+}
+
+// Get retrieves the allowlist of a project
+func (r *TLSAllowlistRepository) Get(ctx context.Context, projectID string) (*storage.TLSAllowlist, error) {
+	query := `SELECT project_id, COALESCE(patterns, ''), COALESCE(service_id, ''), COALESCE(path_prefix, ''), updated_at FROM tls_allowlists WHERE project_id = ?`
+
+	allowlist := &storage.TLSAllowlist{}
+	err := r.db.QueryRowContext(ctx, query, projectID).Scan(
+		&allowlist.ProjectID,
+		&allowlist.Patterns,
+		&allowlist.ServiceID,
+		&allowlist.PathPrefix,
+		&allowlist.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return allowlist, nil
+}
+
+// Save creates or replaces the allowlist of a project
+func (r *TLSAllowlistRepository) Save(ctx context.Context, allowlist *storage.TLSAllowlist) error {
+	query := `
+		INSERT INTO tls_allowlists (project_id, patterns, service_id, path_prefix, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(project_id) DO UPDATE SET
+			patterns = excluded.patterns,
+			service_id = excluded.service_id,
+			path_prefix = excluded.path_prefix,
+			updated_at = excluded.updated_at
+	`
+	allowlist.UpdatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query,
+		allowlist.ProjectID,
+		nullString(allowlist.Patterns),
+		nullString(allowlist.ServiceID),
+		nullString(allowlist.PathPrefix),
+		allowlist.UpdatedAt,
+	)
+	return err
+}
+
+// List returns the allowlists of every project
+func (r *TLSAllowlistRepository) List(ctx context.Context) ([]*storage.TLSAllowlist, error) {
+	query := `SELECT project_id, COALESCE(patterns, ''), COALESCE(service_id, ''), COALESCE(path_prefix, ''), updated_at FROM tls_allowlists ORDER BY project_id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allowlists []*storage.TLSAllowlist
+	for rows.Next() {
+		allowlist := &storage.TLSAllowlist{}
+		if err := rows.Scan(&allowlist.ProjectID, &allowlist.Patterns, &allowlist.ServiceID, &allowlist.PathPrefix, &allowlist.UpdatedAt); err != nil {
+			return nil, err
+		}
+		allowlists = append(allowlists, allowlist)
+	}
+	return allowlists, rows.Err()
+}
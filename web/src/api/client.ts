@@ -248,8 +248,8 @@ class ApiClient {
     return result.data;
   }
 
-  async getDomain(domainName: string): Promise<Domain> {
-    const result = await this.get<ApiResponse<Domain>>(`/domains/${domainName}`);
+  async getDomain(domainName: string, pathPrefix?: string): Promise<Domain> {
+    const result = await this.get<ApiResponse<Domain>>(`/domains/${domainName}${domainPathQuery(pathPrefix)}`);
     return result.data;
   }
 
@@ -258,14 +258,87 @@ class ApiClient {
     return result.data;
   }
 
-  async updateDomain(domainName: string, data: UpdateDomainRequest): Promise<Domain> {
-    const result = await this.put<ApiResponse<Domain>>(`/domains/${domainName}`, data);
+  async updateDomain(domainName: string, data: UpdateDomainRequest, pathPrefix?: string): Promise<Domain> {
+    const result = await this.put<ApiResponse<Domain>>(`/domains/${domainName}${domainPathQuery(pathPrefix)}`, data);
     return result.data;
   }
 
-  async deleteDomain(domainName: string): Promise<void> {
-    await this.delete(`/domains/${domainName}`);
+  async deleteDomain(domainName: string, pathPrefix?: string): Promise<void> {
+    await this.delete(`/domains/${domainName}${domainPathQuery(pathPrefix)}`);
   }
+
+  async getDomainRules(domainName: string, pathPrefix?: string): Promise<DomainRules> {
+    const result = await this.get<ApiResponse<DomainRules>>(`/domains/${domainName}/rules${domainPathQuery(pathPrefix)}`);
+    return result.data;
+  }
+
+  async updateDomainRules(domainName: string, rules: DomainRules, pathPrefix?: string): Promise<DomainRules> {
+    const result = await this.put<ApiResponse<DomainRules>>(`/domains/${domainName}/rules${domainPathQuery(pathPrefix)}`, rules);
+    return result.data;
+  }
+
+  async getDomainAccess(domainName: string, pathPrefix?: string): Promise<DomainAccess> {
+    const result = await this.get<ApiResponse<DomainAccess>>(`/domains/${domainName}/access${domainPathQuery(pathPrefix)}`);
+    return result.data;
+  }
+
+  async updateDomainAccess(domainName: string, access: DomainAccess, pathPrefix?: string): Promise<DomainAccess> {
+    const result = await this.put<ApiResponse<DomainAccess>>(`/domains/${domainName}/access${domainPathQuery(pathPrefix)}`, access);
+    return result.data;
+  }
+
+  async getDomainHeaders(domainName: string, pathPrefix?: string): Promise<DomainHeaders> {
+    const result = await this.get<ApiResponse<DomainHeaders>>(`/domains/${domainName}/headers${domainPathQuery(pathPrefix)}`);
+    return result.data;
+  }
+
+  async updateDomainHeaders(domainName: string, headers: DomainHeaders, pathPrefix?: string): Promise<DomainHeaders> {
+    const result = await this.put<ApiResponse<DomainHeaders>>(`/domains/${domainName}/headers${domainPathQuery(pathPrefix)}`, headers);
+    return result.data;
+  }
+
+  async getDomainCertificate(domainName: string): Promise<DomainCertificate> {
+    const result = await this.get<ApiResponse<DomainCertificate>>(`/domains/${domainName}/certificate`);
+    return result.data;
+  }
+
+  async uploadDomainCertificate(domainName: string, certificate: string, privateKey: string): Promise<DomainCertificate> {
+    const result = await this.put<ApiResponse<DomainCertificate>>(`/domains/${domainName}/certificate`, {
+      certificate,
+      private_key: privateKey,
+    });
+    return result.data;
+  }
+
+  async deleteDomainCertificate(domainName: string): Promise<void> {
+    await this.delete(`/domains/${domainName}/certificate`);
+  }
+
+  async getTLSAllowlist(projectId: string): Promise<TLSAllowlist> {
+    const result = await this.get<ApiResponse<TLSAllowlist>>(`/projects/${projectId}/tls-allowlist`);
+    return result.data;
+  }
+
+  async updateTLSAllowlist(projectId: string, data: UpdateTLSAllowlistRequest): Promise<TLSAllowlist> {
+    const result = await this.put<ApiResponse<TLSAllowlist>>(`/projects/${projectId}/tls-allowlist`, data);
+    return result.data;
+  }
+
+  // Proxy routes
+  async diffProxyRoutes(): Promise<RouteChange[]> {
+    const result = await this.get<ApiResponse<RouteChange[]>>('/proxy/routes/diff');
+    return result.data;
+  }
+
+  async syncProxyRoutes(): Promise<RouteChange[]> {
+    const result = await this.post<ApiResponse<RouteChange[]>>('/proxy/routes/sync', {});
+    return result.data;
+  }
+}
+
+// domainPathQuery selects one of the path prefixes routed on a host
+function domainPathQuery(pathPrefix?: string): string {
+  return pathPrefix ? `?path=${encodeURIComponent(pathPrefix)}` : '';
 }
 
 // Types
@@ -365,22 +438,94 @@ export interface Domain {
   service_id: string;
   domain: string;
   path_prefix: string;
+  strip_prefix: boolean;
   active_slot: 'blue' | 'green';
   ssl_enabled: boolean;
+  rules?: DomainRules;
   created_at: string;
 }
 
+export type RedirectStatus = 301 | 302 | 307 | 308;
+
+export interface DomainRules {
+  force_https: boolean;
+  redirect_www: boolean;
+  aliases?: string[];
+  host_redirects?: { host: string; status?: RedirectStatus }[];
+  redirects?: { from: string; to: string; status?: RedirectStatus }[];
+  rewrites?: { from: string; to: string }[];
+}
+
+export interface DomainAccess {
+  // Existing users sent without a password keep theirs
+  basic_auth?: { username: string; password?: string }[];
+  allow_cidrs?: string[];
+  deny_cidrs?: string[];
+  rate_limit?: { requests: number; window?: string };
+}
+
+export interface DomainHeaders {
+  preset?: 'basic' | 'strict';
+  hsts?: { max_age?: number; include_subdomains: boolean; preload: boolean };
+  set?: Record<string, string>;
+  remove?: string[];
+  cors?: {
+    allow_origins: string[];
+    allow_methods?: string[];
+    allow_headers?: string[];
+    expose_headers?: string[];
+    allow_credentials: boolean;
+    max_age?: number;
+  };
+}
+
+export interface DomainCertificate {
+  domain: string;
+  source: 'custom' | 'managed';
+  issuer?: string;
+  names?: string[];
+  not_before?: string;
+  not_after?: string;
+  days_remaining?: number;
+  last_error?: string;
+  checked_at?: string;
+}
+
+export interface TLSAllowlist {
+  project_id: string;
+  patterns: string[];
+  service?: string;
+  path_prefix?: string;
+  updated_at?: string;
+}
+
+export interface UpdateTLSAllowlistRequest {
+  patterns: string[];
+  service?: string;
+  path_prefix?: string;
+}
+
 export interface CreateDomainRequest {
   domain: string;
   path_prefix?: string;
+  strip_prefix?: boolean;
   ssl_enabled?: boolean;
 }
 
 export interface UpdateDomainRequest {
   path_prefix?: string;
+  strip_prefix?: boolean;
   ssl_enabled?: boolean;
 }
 
+export interface RouteChange {
+  action: 'add' | 'update' | 'remove';
+  domain: string;
+  path_prefix: string;
+  current?: unknown;
+  desired?: unknown;
+}
+
 export interface Deployment {
   id: string;
   app_id: string;